@@ -1,3 +1,5 @@
+//go:build darwin
+
 package main
 
 import (
@@ -59,12 +61,7 @@ func monitorButtonData(panel *fip.IOKitFIPPanel) {
 	events := panel.GetButtonEvents()
 
 	for event := range events {
-		action := "pressed"
-		if !event.Pressed {
-			action = "released"
-		}
-
-		fmt.Printf("\n🎯 BUTTON EVENT: Button %d %s\n", event.ButtonID+1, action)
+		fmt.Printf("\n🎯 INPUT EVENT: %s\n", event)
 		fmt.Printf("   Raw Data: %v\n", panel.GetLastButtonData())
 		fmt.Printf("   Binary: %08b %08b\n", panel.GetLastButtonData()[0], panel.GetLastButtonData()[1])
 	}
@@ -96,10 +93,10 @@ func analyzeButtonData(panel *fip.IOKitFIPPanel) {
 	}
 
 	// Check for any pressed buttons
-	pressedButtons := []int{}
+	pressedButtons := []fip.InputControl{}
 	for i := 0; i < 12; i++ {
-		if panel.GetButtonState(i) {
-			pressedButtons = append(pressedButtons, i+1)
+		if panel.GetButtonState(fip.InputControl(i)) {
+			pressedButtons = append(pressedButtons, fip.InputControl(i))
 		}
 	}
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"saitek-controller/internal/fip"
+)
+
+func main() {
+	var (
+		profiles = flag.String("profiles", "assets/profiles/example.yaml", "Device profile file (YAML or JSON)")
+		list     = flag.Bool("list", false, "List the devices with their instance GUIDs and exit")
+		virtual  = flag.Bool("virtual", false, "Attach a virtual FIP and X52 Pro when using the native transports")
+	)
+	flag.Parse()
+
+	do, err := fip.NewDirectOutput()
+	if err != nil {
+		log.Fatalf("Failed to create DirectOutput: %v", err)
+	}
+	if err := do.Initialize("Device Profiles"); err != nil {
+		log.Fatalf("Failed to initialize DirectOutput: %v", err)
+	}
+	defer do.Deinitialize()
+
+	if native, ok := do.(*fip.NativeDirectOutput); ok && *virtual {
+		native.Attach(fip.NewVirtualFIP())
+		native.Attach(fip.NewVirtualDevice(fip.DeviceTypeX52Pro))
+	}
+	devices, err := fip.NewDeviceManager(do)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer devices.Close()
+
+	set, err := fip.LoadProfiles(*profiles)
+	if err != nil {
+		log.Fatalf("Error loading profiles: %v", err)
+	}
+
+	if *list {
+		for _, dev := range devices.Devices() {
+			profile := "(none)"
+			if p, ok := set.Match(dev); ok {
+				profile = p.Name
+			}
+			fmt.Printf("%-10s %-7s %s  profile: %s\n", dev.ID, dev.Kind, dev.Instance, profile)
+		}
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	fmt.Println("Applying profiles as devices connect; press Ctrl+C to exit")
+	for e := range set.Run(ctx, devices) {
+		fmt.Printf("%s %s: %s\n", e.Device.ID, e.Button, e.Action)
+	}
+}
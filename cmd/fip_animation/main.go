@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"saitek-controller/internal/fip"
+	"saitek-controller/internal/imaging"
+)
+
+func main() {
+	var (
+		input      = flag.String("file", "", "Animated GIF, image, or quoted glob of numbered frames such as \"frames/*.png\" (required)")
+		fps        = flag.Float64("fps", 0, "Frames per second; 0 uses the GIF delays (image sequences default to 10)")
+		mode       = flag.String("mode", "loop", "Playback mode: loop, pingpong, once")
+		backend    = flag.String("backend", "direct", "FIP backend: direct (HID), usb, file")
+		output     = flag.String("output", "frames/frame_%04d.png", "Output pattern for the file backend")
+		resizeMode = flag.String("resize", "fit", "Resize mode: stretch, fit, crop, center")
+		resample   = flag.String("resample", "bilinear", "Resampler: nearest, bilinear, catmullrom, lanczos")
+		filters    = flag.String("filters", "", "Filter chain, e.g. gamma=2.2,night=0.6,dither=5")
+	)
+	flag.Parse()
+
+	if *input == "" {
+		fmt.Println("Error: Animation file is required")
+		fmt.Println("Usage: fip_animation -file <filename> [options]")
+		fmt.Println("\nOptions:")
+		flag.PrintDefaults()
+		return
+	}
+
+	playback, err := fip.ParsePlaybackMode(*mode)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Set up the loader like fip_image_loader
+	loader := fip.NewImageLoader()
+	switch strings.ToLower(*resizeMode) {
+	case "stretch":
+		loader.SetResizeMode(fip.ResizeModeStretch)
+	case "fit":
+		loader.SetResizeMode(fip.ResizeModeFit)
+	case "crop":
+		loader.SetResizeMode(fip.ResizeModeCrop)
+	case "center":
+		loader.SetResizeMode(fip.ResizeModeCenter)
+	default:
+		log.Fatalf("Error: Invalid resize mode: %s", *resizeMode)
+	}
+	resampler, err := imaging.ParseResampler(*resample)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetResampler(resampler)
+	chain, err := imaging.ParseFilters(*filters)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetFilters(chain...)
+
+	// Load and pre-convert every frame
+	var anim *fip.Animation
+	if strings.ContainsAny(*input, "*?[") {
+		anim, err = loader.LoadImageSequence(*input, *fps)
+	} else {
+		anim, err = loader.LoadAnimation(*input)
+	}
+	if err != nil {
+		log.Fatalf("Error loading animation: %v", err)
+	}
+	fmt.Printf("Loaded %d frames (%v per pass)\n", anim.Len(), anim.Duration())
+
+	// Open the backend
+	var sink fip.Sink
+	switch strings.ToLower(*backend) {
+	case "direct":
+		device := fip.NewFIPDirect()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewDeviceSink(device)
+	case "usb":
+		device := fip.NewFIPUSB()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewDeviceSink(device)
+	case "file":
+		fileSink, err := fip.NewFileSink(*output)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		sink = fileSink
+	default:
+		log.Fatalf("Error: Invalid backend: %s", *backend)
+	}
+
+	player, err := fip.NewAnimationPlayer(anim, sink)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	player.SetMode(playback)
+	if *fps > 0 {
+		player.SetFPS(*fps)
+	}
+	player.Play()
+	defer player.Stop()
+
+	fmt.Println("Commands: p = pause/resume, s <frame> = seek, f <fps> = frame rate, q = quit")
+
+	// Read commands from stdin
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+		close(commands)
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	for {
+		select {
+		case <-player.Done():
+			fmt.Println("Playback finished")
+			return
+		case <-interrupt:
+			return
+		case line, ok := <-commands:
+			if !ok {
+				// No terminal input; play until finished or interrupted
+				commands = nil
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "p":
+				player.TogglePause()
+				fmt.Printf("Paused: %v (frame %d)\n", player.Paused(), player.Frame())
+			case "s":
+				frame, err := argInt(fields)
+				if err == nil {
+					err = player.Seek(frame)
+				}
+				if err != nil {
+					fmt.Printf("Seek failed: %v\n", err)
+				}
+			case "f":
+				rate, err := strconv.ParseFloat(strings.Join(fields[1:], ""), 64)
+				if err != nil {
+					fmt.Printf("Invalid frame rate: %v\n", err)
+					continue
+				}
+				player.SetFPS(rate)
+			case "q":
+				return
+			default:
+				fmt.Printf("Unknown command: %s\n", line)
+			}
+		}
+	}
+}
+
+// argInt parses the first argument of a command
+func argInt(fields []string) (int, error) {
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("missing argument")
+	}
+	return strconv.Atoi(fields[1])
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"saitek-controller/internal/bmp"
+	"saitek-controller/internal/fip"
+	"saitek-controller/internal/imaging"
+)
+
+func main() {
+	var (
+		build      = flag.String("build", "", "Build a bundle from this assets directory")
+		output     = flag.String("output", "assets.fipb", "Bundle file to write with -build, or image file to write with -extract")
+		bundle     = flag.String("bundle", "assets.fipb", "Bundle file to list, verify or extract from")
+		list       = flag.Bool("list", false, "List the frames in the bundle")
+		verify     = flag.Bool("verify", false, "Verify the checksums of every frame")
+		extract    = flag.String("extract", "", "Frame name to extract to -output (.png, .jpg or .bmp)")
+		resizeMode = flag.String("resize", "fit", "Resize mode: stretch, fit, crop, center")
+		resample   = flag.String("resample", "bilinear", "Resampler: nearest, bilinear, catmullrom, lanczos")
+		filters    = flag.String("filters", "", "Filter chain, e.g. gamma=2.2,night=0.6,dither=5")
+	)
+	flag.Parse()
+
+	if *build != "" {
+		buildBundle(*build, *output, *resizeMode, *resample, *filters)
+		return
+	}
+
+	if !*list && !*verify && *extract == "" {
+		fmt.Println("Usage:")
+		fmt.Println("  fip_bundle -build assets -output assets.fipb [-resize fit] [-resample lanczos] [-filters ...]")
+		fmt.Println("  fip_bundle -bundle assets.fipb -list | -verify | -extract <name> -output <file>")
+		fmt.Println("\nOptions:")
+		flag.PrintDefaults()
+		return
+	}
+
+	b, err := fip.OpenBundle(*bundle)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer b.Close()
+
+	if *list {
+		fmt.Printf("Bundle %s: %d frames, %dx%d, created %s\n", *bundle, len(b.Frames), b.Width, b.Height, b.Created.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Settings: resize=%s resampler=%s filters=%q\n", b.Settings.ResizeMode, b.Settings.Resampler, b.Settings.Filters)
+		for _, name := range b.Names() {
+			e, _ := b.Entry(name)
+			fmt.Printf("  %-32s %4dx%-4d crc32=%08x  %s\n", e.Name, e.SourceWidth, e.SourceHeight, e.CRC32, e.Source)
+		}
+	}
+
+	if *verify {
+		if err := b.Verify(); err != nil {
+			fmt.Printf("Verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ All %d frames verified\n", len(b.Frames))
+	}
+
+	if *extract != "" {
+		buf, err := b.ReadFrame(*extract)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		img, err := bmp.FIPImage(buf)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if err := fip.NewImageLoader().SaveImage(img, *output); err != nil {
+			log.Fatalf("Error saving image: %v", err)
+		}
+		fmt.Printf("✓ Frame %s saved to: %s\n", *extract, *output)
+	}
+}
+
+// buildBundle converts every image below dir and writes the bundle
+func buildBundle(dir, output, resizeMode, resample, filters string) {
+	loader := fip.NewImageLoader()
+
+	mode, err := fip.ParseResizeMode(resizeMode)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetResizeMode(mode)
+
+	resampler, err := imaging.ParseResampler(resample)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetResampler(resampler)
+
+	chain, err := imaging.ParseFilters(filters)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetFilters(chain...)
+
+	builder := fip.NewBundleBuilder(loader)
+	builder.SetFilterSpec(filters)
+
+	fmt.Printf("Converting images in %s...\n", dir)
+	n, err := builder.AddDir(dir)
+	if err != nil {
+		log.Fatalf("Error building bundle: %v", err)
+	}
+	if n == 0 {
+		log.Fatalf("Error: No supported images found in %s", dir)
+	}
+
+	if err := builder.WriteFile(output); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("✓ Bundle with %d frames written to: %s\n", n, output)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saitek-controller/internal/bmp"
+	"saitek-controller/internal/checklist"
+	"saitek-controller/internal/fip"
+)
+
+func main() {
+	var (
+		files    = flag.String("checklists", "assets/checklists", "Comma separated checklist files (YAML or Markdown) or directories")
+		flight   = flag.String("flight", time.Now().Format("2006-01-02"), "Flight name; progress is kept per flight")
+		progress = flag.String("progress", "", "Directory for progress files (default: user config directory)")
+		backend  = flag.String("backend", "direct", "FIP backend: direct (HID), usb, file")
+		output   = flag.String("output", "frames/checklist_%04d.png", "Output pattern for the file backend")
+		maxFPS   = flag.Float64("max-fps", fip.DefaultMaxFPS, "Frame rate limit for the FIP device; 0 for no limit")
+	)
+	flag.Parse()
+	if *maxFPS < 0 {
+		log.Fatalf("Error: Invalid frame rate limit: %v", *maxFPS)
+	}
+
+	lists, err := checklist.LoadAll(strings.Split(*files, ",")...)
+	if err != nil {
+		log.Fatalf("Error loading checklists: %v", err)
+	}
+
+	dir := *progress
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			log.Fatalf("Error: %v (use -progress)", err)
+		}
+		dir = filepath.Join(config, "saitek-controller", "checklists")
+	}
+	store, err := checklist.OpenProgress(dir, *flight)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	page, err := checklist.NewPage(lists, store)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Loaded %d checklists, progress in %s\n", len(lists), store.Path())
+
+	// Open the backend; hardware backends also deliver button events. Device
+	// frames go through a pipeline that skips unchanged frames, converts
+	// only the parts that changed and keeps to the frame rate limit
+	var (
+		sink   fip.Sink
+		events chan fip.InputEvent
+	)
+	pipeline := fip.PipelineOptions{MaxFPS: *maxFPS}
+	switch strings.ToLower(*backend) {
+	case "direct":
+		device := fip.NewFIPDirect()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewFramePipeline(fip.NewDeviceSink(device), pipeline)
+		if events, err = device.ReadButtonEvents(); err != nil {
+			log.Printf("Button events unavailable: %v", err)
+		}
+	case "usb":
+		device := fip.NewFIPUSB()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewFramePipeline(fip.NewDeviceSink(device), pipeline)
+		if events, err = device.ReadButtonEvents(); err != nil {
+			log.Printf("Button events unavailable: %v", err)
+		}
+	case "file":
+		fileSink, err := fip.NewFileSink(*output)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		sink = fileSink
+	default:
+		log.Fatalf("Error: Invalid backend: %s", *backend)
+	}
+	defer sink.Close()
+
+	surface := fip.NewSurface(bmp.FIPWidth, bmp.FIPHeight)
+	show := func() {
+		if err := page.Render(surface); err != nil {
+			log.Printf("Failed to render checklist: %v", err)
+			return
+		}
+		if err := sink.WriteFrame(surface.Image()); err != nil {
+			log.Printf("Failed to send frame: %v", err)
+		}
+	}
+	show()
+
+	fmt.Println("Commands: a control name (S1-S6, RightDialCW, RightDialCCW, LeftDialCW, LeftDialCCW) or q = quit")
+
+	// Read commands from stdin
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+		close(commands)
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	for {
+		select {
+		case <-interrupt:
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if page.HandleInput(event) {
+				show()
+			}
+		case line, ok := <-commands:
+			if !ok {
+				// No terminal input; keep following the FIP buttons
+				commands = nil
+				continue
+			}
+			if line == "" {
+				continue
+			}
+			if line == "q" {
+				return
+			}
+			control, err := fip.ParseInputControl(line)
+			if err != nil {
+				fmt.Printf("Unknown command: %s\n", line)
+				continue
+			}
+			// A typed button is a full press and release
+			page.HandleInput(fip.InputEvent{Control: control, Pressed: true, Timestamp: time.Now()})
+			page.HandleInput(fip.InputEvent{Control: control, Pressed: false, Timestamp: time.Now()})
+			show()
+		}
+	}
+}
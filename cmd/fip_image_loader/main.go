@@ -3,14 +3,13 @@ package main
 import (
 	"flag"
 	"fmt"
-	"image"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"unsafe"
 
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/imaging"
 )
 
 func main() {
@@ -19,7 +18,15 @@ func main() {
 		imageFile   = flag.String("image", "", "Image file to load (required)")
 		resizeMode  = flag.String("resize", "fit", "Resize mode: stretch, fit, crop, center")
 		quality     = flag.Int("quality", 90, "JPEG quality (1-100)")
-		outputFile  = flag.String("output", "", "Output file for processed image")
+		resample    = flag.String("resample", "bilinear", "Resampler: nearest, bilinear, catmullrom, lanczos")
+		filters     = flag.String("filters", "", "Filter chain, e.g. gamma=2.2,contrast=1.2,night=0.6,dither=5")
+		gamma       = flag.Float64("gamma", 1, "Gamma correction (above 1 brightens mid tones)")
+		brightness  = flag.Float64("brightness", 0, "Brightness offset (-1 to 1)")
+		contrast    = flag.Float64("contrast", 1, "Contrast factor (1 leaves the image unchanged)")
+		night       = flag.Float64("night", 0, "Night mode: red on black at this brightness (0-1, 0 for off)")
+		dither      = flag.Int("dither", 0, "Floyd-Steinberg dither to this many bits per channel (1-7, 0 for off)")
+		outputFile  = flag.String("output", "", "Output file for processed image (.png, .jpg or .bmp)")
+		rawFile     = flag.String("raw", "", "Output file for the raw 230,400-byte FIP frame buffer")
 		showInfo    = flag.Bool("info", false, "Show image information only")
 		validate    = flag.Bool("validate", false, "Validate image size only")
 		listFormats = flag.Bool("formats", false, "List supported formats")
@@ -74,6 +81,26 @@ func main() {
 	// Set quality
 	loader.SetQuality(*quality)
 
+	// Set resampler and filters; single adjustment flags run after -filters
+	resampler, err := imaging.ParseResampler(*resample)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetResampler(resampler)
+
+	chain, err := imaging.ParseFilters(*filters)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	chain = append(chain, imaging.Adjustments{
+		Gamma:      *gamma,
+		Brightness: *brightness,
+		Contrast:   *contrast,
+		Night:      *night,
+		DitherBits: *dither,
+	}.Filters()...)
+	loader.SetFilters(chain...)
+
 	// Load the image
 	fmt.Printf("Loading image: %s\n", *imageFile)
 	img, err := loader.LoadImageFromFile(*imageFile)
@@ -88,6 +115,8 @@ func main() {
 	fmt.Printf("  FIP size: %dx%d\n", info.FIPWidth, info.FIPHeight)
 	fmt.Printf("  Needs resize: %v\n", info.NeedsResize)
 	fmt.Printf("  Resize mode: %s\n", *resizeMode)
+	fmt.Printf("  Resampler: %s\n", resampler)
+	fmt.Printf("  Filters: %d\n", len(chain))
 
 	// Validate image size if requested
 	if *validate {
@@ -119,22 +148,21 @@ func main() {
 	if *outputFile != "" {
 		fmt.Printf("Saving processed image to: %s\n", *outputFile)
 		
-		ext := strings.ToLower(filepath.Ext(*outputFile))
-		switch ext {
-		case ".png":
-			err = loader.SaveImageAsPNG(img, *outputFile)
-		case ".jpg", ".jpeg":
-			err = loader.SaveImageAsJPEG(img, *outputFile)
-		default:
-			log.Fatalf("Error: Unsupported output format: %s", ext)
-		}
-		
+		err = loader.SaveImage(img, *outputFile)
 		if err != nil {
 			log.Fatalf("Error saving image: %v", err)
 		}
 		fmt.Printf("✓ Image saved successfully\n")
 	}
 
+	// Save the frame buffer exactly as it is sent to the FIP
+	if *rawFile != "" {
+		if err := os.WriteFile(*rawFile, fipData, 0644); err != nil {
+			log.Fatalf("Error saving frame buffer: %v", err)
+		}
+		fmt.Printf("✓ Frame buffer saved to: %s\n", *rawFile)
+	}
+
 	// Simulate sending to FIP
 	fmt.Printf("Simulating FIP display...\n")
 	
@@ -148,7 +176,7 @@ func main() {
 		log.Printf("Warning: Failed to initialize SDK: %v", err)
 	} else {
 		// Create device handle
-		deviceHandle := unsafe.Pointer(uintptr(0x12345678))
+		deviceHandle := uintptr(0x12345678)
 
 		// Add page
 		err = sdk.AddPage(deviceHandle, 1, "Image Loader Page", 0x00000001)
@@ -193,18 +221,18 @@ func (sdk *DirectOutputSDK) Initialize(pluginName string) error {
 	return nil
 }
 
-func (sdk *DirectOutputSDK) AddPage(deviceHandle unsafe.Pointer, page uint32, name string, flags uint32) error {
-	log.Printf("DirectOutput_AddPage: device=%p, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
+func (sdk *DirectOutputSDK) AddPage(deviceHandle uintptr, page uint32, name string, flags uint32) error {
+	log.Printf("DirectOutput_AddPage: device=0x%x, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
 	return nil
 }
 
-func (sdk *DirectOutputSDK) SetImage(deviceHandle unsafe.Pointer, page uint32, index uint32, data []byte) error {
-	log.Printf("DirectOutput_SetImage: device=%p, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
+func (sdk *DirectOutputSDK) SetImage(deviceHandle uintptr, page uint32, index uint32, data []byte) error {
+	log.Printf("DirectOutput_SetImage: device=0x%x, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
 	return nil
 }
 
-func (sdk *DirectOutputSDK) RemovePage(deviceHandle unsafe.Pointer, page uint32) error {
-	log.Printf("DirectOutput_RemovePage: device=%p, page=%d", deviceHandle, page)
+func (sdk *DirectOutputSDK) RemovePage(deviceHandle uintptr, page uint32) error {
+	log.Printf("DirectOutput_RemovePage: device=0x%x, page=%d", deviceHandle, page)
 	return nil
 }
 
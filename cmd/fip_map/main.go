@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"saitek-controller/internal/bmp"
+	"saitek-controller/internal/fip"
+	"saitek-controller/internal/tiles"
+)
+
+func main() {
+	var (
+		tilePath = flag.String("tiles", "", "MBTiles file or z/x/y tile directory (required)")
+		lat      = flag.Float64("lat", 51.4700, "Start latitude in degrees")
+		lon      = flag.Float64("lon", -0.4543, "Start longitude in degrees")
+		heading  = flag.Float64("heading", 0, "Heading in degrees")
+		zoom     = flag.Int("zoom", 12, "Zoom level")
+		mode     = flag.String("mode", "north", "Map orientation: north, heading")
+		speed    = flag.Float64("speed", 0, "Simulated ground speed in knots; 0 keeps the aircraft still")
+		turn     = flag.Float64("turn", 0, "Simulated turn rate in degrees per second")
+		fps      = flag.Float64("fps", 5, "Frames per second")
+		maxFPS   = flag.Float64("max-fps", fip.DefaultMaxFPS, "Frame rate limit for the FIP device; 0 for no limit")
+		rate     = flag.Float64("rate", 1, "Simulated position updates per second; frames in between are interpolated")
+		backend  = flag.String("backend", "direct", "FIP backend: direct (HID), usb, file")
+		output   = flag.String("output", "frames/map_%04d.png", "Output pattern for the file backend")
+		frames   = flag.Int("frames", 0, "Stop after this many frames; 0 runs until quit")
+	)
+	flag.Parse()
+
+	if *tilePath == "" {
+		fmt.Println("Error: Tile source is required")
+		fmt.Println("Usage: fip_map -tiles <file.mbtiles|directory> [options]")
+		fmt.Println("\nOptions:")
+		flag.PrintDefaults()
+		return
+	}
+	if *fps <= 0 {
+		log.Fatalf("Error: Invalid frame rate: %v", *fps)
+	}
+	if *maxFPS < 0 {
+		log.Fatalf("Error: Invalid frame rate limit: %v", *maxFPS)
+	}
+	if *rate <= 0 {
+		log.Fatalf("Error: Invalid update rate: %v", *rate)
+	}
+
+	orientation, err := fip.ParseMapOrientation(*mode)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	source, err := tiles.Open(*tilePath)
+	if err != nil {
+		log.Fatalf("Error opening tiles: %v", err)
+	}
+	movingMap := fip.NewMovingMap(tiles.NewCache(source, 0), *zoom)
+	defer movingMap.Close()
+	movingMap.SetOrientation(orientation)
+	minZoom, maxZoom := source.Zooms()
+	fmt.Printf("Opened %s (zoom %d-%d)\n", *tilePath, minZoom, maxZoom)
+
+	// Open the backend. Device frames go through a pipeline that skips
+	// unchanged frames, converts only the parts that changed and keeps to
+	// the frame rate limit
+	var sink fip.Sink
+	pipeline := fip.PipelineOptions{MaxFPS: *maxFPS}
+	switch strings.ToLower(*backend) {
+	case "direct":
+		device := fip.NewFIPDirect()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewFramePipeline(fip.NewDeviceSink(device), pipeline)
+	case "usb":
+		device := fip.NewFIPUSB()
+		if err := device.Connect(); err != nil {
+			log.Fatalf("Failed to connect to FIP: %v", err)
+		}
+		defer device.Disconnect()
+		sink = fip.NewFramePipeline(fip.NewDeviceSink(device), pipeline)
+	case "file":
+		fileSink, err := fip.NewFileSink(*output)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		sink = fileSink
+	default:
+		log.Fatalf("Error: Invalid backend: %s", *backend)
+	}
+	defer sink.Close()
+
+	fmt.Println("Commands: + / - = zoom, m = north-up/heading-up, c = clear track, h <deg> = heading, q = quit")
+
+	// Read commands from stdin
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+		close(commands)
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	interval := time.Duration(float64(time.Second) / *fps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	updates := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer updates.Stop()
+
+	// The simulated position arrives like a sim connection's would, and the
+	// conditioner moves the map smoothly between updates
+	data := fip.InstrumentData{Latitude: *lat, Longitude: *lon, Heading: *heading, Airspeed: *speed}
+	conditioner := fip.NewConditioner(fip.DefaultConditioning(fip.InstrumentCustom))
+	conditioner.Update(data)
+	surface := fip.NewSurface(bmp.FIPWidth, bmp.FIPHeight)
+	last := time.Now()
+	for frame := 0; *frames == 0 || frame < *frames; {
+		select {
+		case <-interrupt:
+			return
+		case line, ok := <-commands:
+			if !ok {
+				// No terminal input; run until interrupted
+				commands = nil
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "+":
+				movingMap.ZoomBy(1)
+				fmt.Printf("Zoom %d\n", movingMap.Zoom())
+			case "-":
+				movingMap.ZoomBy(-1)
+				fmt.Printf("Zoom %d\n", movingMap.Zoom())
+			case "m":
+				movingMap.ToggleOrientation()
+				fmt.Printf("Orientation: %v\n", movingMap.Orientation())
+			case "c":
+				movingMap.ClearTrack()
+			case "h":
+				if len(fields) < 2 {
+					fmt.Println("Missing heading")
+					continue
+				}
+				value, err := strconv.ParseFloat(fields[1], 64)
+				if err != nil {
+					fmt.Printf("Invalid heading: %v\n", err)
+					continue
+				}
+				data.Heading = value
+				conditioner.Update(data)
+			case "q":
+				return
+			default:
+				fmt.Printf("Unknown command: %s\n", line)
+			}
+		case now := <-updates.C:
+			advance(&data, *speed, *turn, now.Sub(last).Seconds())
+			last = now
+			conditioner.Update(data)
+		case <-ticker.C:
+			if err := movingMap.RenderData(surface, conditioner.Sample()); err != nil {
+				log.Fatalf("Error rendering map: %v", err)
+			}
+			if err := sink.WriteFrame(surface.Image()); err != nil {
+				log.Printf("Failed to send frame: %v", err)
+			}
+			frame++
+		}
+	}
+}
+
+// advance moves the aircraft along its heading for dt seconds at speed
+// knots, turning at turn degrees per second
+func advance(data *fip.InstrumentData, speed, turn, dt float64) {
+	data.Heading = math.Mod(data.Heading+turn*dt+360, 360)
+	if turn != 0 {
+		data.TurnRate = turn
+	}
+
+	// One nautical mile is one minute of latitude
+	distance := speed * dt / 3600 / 60
+	rad := data.Heading * math.Pi / 180
+	data.Latitude += distance * math.Cos(rad)
+	data.Longitude += distance * math.Sin(rad) / math.Cos(data.Latitude*math.Pi/180)
+	if data.Longitude > 180 {
+		data.Longitude -= 360
+	} else if data.Longitude < -180 {
+		data.Longitude += 360
+	}
+}
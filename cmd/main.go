@@ -6,10 +6,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/gauge"
+	"saitek-controller/internal/preview"
 	"saitek-controller/internal/usb"
 
 	"github.com/faiface/pixel/pixelgl"
@@ -23,7 +26,8 @@ func main() {
 		height      = flag.Int("height", 240, "FIP display height")
 		title       = flag.String("title", "Saitek FIP Controller", "Window title")
 		imageFile   = flag.String("image", "", "Image file to display")
-		instrument  = flag.String("instrument", "test", "Instrument type (artificial_horizon, airspeed, altimeter, compass, vsi, turn_coordinator, test)")
+		gaugeFile   = flag.String("gauge", "", "Gauge definition (JSON or YAML) to display instead of a built-in instrument")
+		instrument  = flag.String("instrument", "test", "Instrument type (artificial_horizon, airspeed, altimeter, compass, vsi, turn_coordinator, test; aliases such as ai, asi, alt, hi, tc are accepted)")
 		vendorID    = flag.String("vendor", "06a3", "USB vendor ID (hex, e.g. 06a3)")
 		productID   = flag.String("product", "0a2ae", "USB product ID (hex, e.g. 0a2ae)")
 		listDevices = flag.Bool("list-devices", false, "List all connected HID devices and exit")
@@ -35,6 +39,11 @@ func main() {
 		topRow      = flag.String("top", "250", "Top row display (multi panel)")
 		bottomRow   = flag.String("bottom", "3000", "Bottom row display (multi panel)")
 		buttonLEDs  = flag.Uint("leds", 0x01, "Button LED states (multi panel)")
+		headless    = flag.Bool("headless", false, "Render the FIP without a preview window")
+		vSpeeds     = flag.String("vspeeds", "40,48,85,129,163", "Airspeed indicator V-speeds in knots (VS0,VS1,VFE,VNO,VNE)")
+		pressure    = flag.String("pressure-unit", "inhg", "Altimeter setting unit (inhg, hpa)")
+		headingBug  = flag.Float64("heading-bug", 200, "Heading bug setting in degrees")
+		output      = flag.String("output", "", "Write FIP frames to a PNG or BMP file (use %d to number frames)")
 	)
 	flag.Parse()
 
@@ -313,8 +322,7 @@ func main() {
 		}
 	}
 
-	// Initialize pixelgl for FIP panel
-	pixelgl.Run(func() {
+	runFIP := func(withPreview bool) {
 		// Create FIP panel with vendor/product IDs
 		panel, err := fip.NewFIPPanelWithUSB(*title, *width, *height, vID, pID)
 		if err != nil {
@@ -322,6 +330,21 @@ func main() {
 		}
 		defer panel.Close()
 
+		if *output != "" {
+			sink, err := fip.NewFileSink(*output)
+			if err != nil {
+				log.Fatalf("Failed to create output file: %v", err)
+			}
+			panel.AddSink(sink)
+		}
+		if withPreview {
+			window, err := preview.NewWindow(*title, *width, *height)
+			if err != nil {
+				log.Fatalf("Failed to create preview window: %v", err)
+			}
+			panel.AddSink(window)
+		}
+
 		// Try to connect to physical device
 		if err := panel.Connect(); err != nil {
 			log.Printf("Warning: Could not connect to physical FIP device: %v", err)
@@ -329,21 +352,33 @@ func main() {
 		}
 
 		// Set instrument type
-		switch *instrument {
-		case "artificial_horizon":
-			panel.SetInstrument(fip.InstrumentArtificialHorizon)
-		case "airspeed":
-			panel.SetInstrument(fip.InstrumentAirspeed)
-		case "altimeter":
-			panel.SetInstrument(fip.InstrumentAltimeter)
-		case "compass":
-			panel.SetInstrument(fip.InstrumentCompass)
-		case "vsi":
-			panel.SetInstrument(fip.InstrumentVerticalSpeed)
-		case "turn_coordinator":
-			panel.SetInstrument(fip.InstrumentTurnCoordinator)
-		default:
-			panel.SetInstrument(fip.InstrumentCustom)
+		if *instrument != "test" {
+			inst, err := fip.ParseInstrument(*instrument)
+			if err != nil {
+				log.Fatalf("%v (known instruments: %s)", err, strings.Join(fip.InstrumentNames(), ", "))
+			}
+			panel.SetInstrument(inst)
+		}
+
+		// Configure V-speeds and altimeter units
+		config := fip.DefaultInstrumentConfig()
+		if config.VSpeeds, err = fip.ParseVSpeeds(*vSpeeds); err != nil {
+			log.Fatalf("Invalid -vspeeds: %v", err)
+		}
+		if config.PressureUnit, err = fip.ParsePressureUnit(*pressure); err != nil {
+			log.Fatalf("Invalid -pressure-unit: %v", err)
+		}
+		if err := panel.SetInstrumentConfig(config); err != nil {
+			log.Fatalf("Failed to configure instruments: %v", err)
+		}
+
+		// Replace the built-in instrument with a gauge definition
+		if *gaugeFile != "" {
+			g, err := gauge.Load(*gaugeFile)
+			if err != nil {
+				log.Fatalf("Failed to load gauge: %v", err)
+			}
+			panel.SetGauge(g)
 		}
 
 		// Display image if specified
@@ -353,7 +388,7 @@ func main() {
 			}
 		} else {
 			// Display test pattern or instrument
-			if *instrument == "test" {
+			if *instrument == "test" && *gaugeFile == "" {
 				// Create and display test pattern using image generator
 				generator := fip.NewImageGenerator(*width, *height)
 				testImg := generator.CreateTestPattern()
@@ -369,6 +404,7 @@ func main() {
 					Altitude:      5000.0,
 					Pressure:      29.92,
 					Heading:       180.0,
+					HeadingBug:    *headingBug,
 					VerticalSpeed: 500.0,
 					TurnRate:      3.0,
 					Slip:          0.0,
@@ -379,8 +415,23 @@ func main() {
 			}
 		}
 
+		// Headless runs render a single frame and exit
+		if !withPreview {
+			return
+		}
+
 		// Run the display loop
 		panel.Run()
+	}
+
+	if *headless {
+		runFIP(false)
+		return
+	}
+
+	// Initialize pixelgl for the preview window
+	pixelgl.Run(func() {
+		runFIP(true)
 	})
 }
 
@@ -0,0 +1,471 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"saitek-controller/internal/fip"
+)
+
+// openAPIYAML describes the /api/v1 endpoints
+//
+//go:embed openapi.yaml
+var openAPIYAML []byte
+
+// maxAPIBody limits the size of a request body
+const maxAPIBody = 64 * 1024
+
+// maxDisplayDigits is the number of digits on each panel display
+const maxDisplayDigits = 5
+
+// apiError is an error response. Fields lists the request fields that
+// failed validation.
+type apiError struct {
+	Status  int          `json:"status"`
+	Message string       `json:"error"`
+	Fields  []fieldError `json:"fields,omitempty"`
+}
+
+// fieldError is a validation failure of one request field
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// writeJSON writes a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeAPIError writes an error response. Panel errors become 503 when the
+// panel isn't connected and 500 otherwise.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = &apiError{Status: panelErrorStatus(err), Message: err.Error()}
+	}
+	writeJSON(w, apiErr.Status, apiErr)
+}
+
+// panelErrorStatus returns the HTTP status for an error writing to a panel
+func panelErrorStatus(err error) int {
+	if errors.Is(err, errPanelNotConnected) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// methodNotAllowed writes a 405 response listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeAPIError(w, &apiError{Status: http.StatusMethodNotAllowed, Message: "method not allowed"})
+}
+
+// apiField is a property of a writable resource: a string checked by
+// check, or a boolean when check is nil
+type apiField struct {
+	name  string
+	check func(value string) string
+}
+
+// apiResource is a writable part of a panel. Its values are read with get
+// and written with set; PUT replaces every field and PATCH the given ones.
+type apiResource struct {
+	name   string
+	fields []apiField
+	get    func(pm *PanelManager) map[string]interface{}
+	set    func(pm *PanelManager, values map[string]interface{}) error
+}
+
+// apiPanel is a panel of the /api/v1 API
+type apiPanel struct {
+	id        string
+	name      string
+	resources []apiResource
+}
+
+// multiLEDs maps the multi panel LED names to their bits
+var multiLEDs = []struct {
+	name string
+	bit  uint8
+}{
+	{"ap", fip.ButtonAP}, {"hdg", fip.ButtonHDG}, {"nav", fip.ButtonNAV}, {"ias", fip.ButtonIAS},
+	{"alt", fip.ButtonALT}, {"vs", fip.ButtonVS}, {"apr", fip.ButtonAPR}, {"rev", fip.ButtonREV},
+}
+
+// switchLightNames are the landing gear lights in LandingGearLights order
+var switchLightNames = []string{"greenN", "greenL", "greenR", "redN", "redL", "redR"}
+
+// apiPanels are the panels and their resources
+var apiPanels = []apiPanel{
+	{"radio", "Saitek Flight Radio Panel", []apiResource{{
+		name: "display",
+		fields: []apiField{
+			{"com1Active", checkRadioValue}, {"com1Standby", checkRadioValue},
+			{"com2Active", checkRadioValue}, {"com2Standby", checkRadioValue},
+		},
+		get: func(pm *PanelManager) map[string]interface{} {
+			d := pm.RadioDisplay()
+			return map[string]interface{}{
+				"com1Active": d.COM1Active, "com1Standby": d.COM1Standby,
+				"com2Active": d.COM2Active, "com2Standby": d.COM2Standby,
+			}
+		},
+		set: func(pm *PanelManager, v map[string]interface{}) error {
+			return pm.SetRadioDisplay(v["com1Active"].(string), v["com1Standby"].(string),
+				v["com2Active"].(string), v["com2Standby"].(string))
+		},
+	}}},
+	{"multi", "Saitek Flight Multi Panel", []apiResource{{
+		name:   "display",
+		fields: []apiField{{"topRow", checkMultiValue}, {"bottomRow", checkMultiValue}},
+		get: func(pm *PanelManager) map[string]interface{} {
+			d := pm.MultiDisplay()
+			return map[string]interface{}{"topRow": d.TopRow, "bottomRow": d.BottomRow}
+		},
+		set: func(pm *PanelManager, v map[string]interface{}) error {
+			return pm.SetMultiDisplay(v["topRow"].(string), v["bottomRow"].(string), pm.MultiDisplay().ButtonLEDs)
+		},
+	}, {
+		name:   "leds",
+		fields: multiLEDFields(),
+		get: func(pm *PanelManager) map[string]interface{} {
+			leds := pm.MultiDisplay().ButtonLEDs
+			values := make(map[string]interface{})
+			for _, led := range multiLEDs {
+				values[led.name] = leds&led.bit != 0
+			}
+			return values
+		},
+		set: func(pm *PanelManager, v map[string]interface{}) error {
+			var leds uint8
+			for _, led := range multiLEDs {
+				if v[led.name].(bool) {
+					leds |= led.bit
+				}
+			}
+			d := pm.MultiDisplay()
+			return pm.SetMultiDisplay(d.TopRow, d.BottomRow, leds)
+		},
+	}}},
+	{"switch", "Saitek Flight Switch Panel", []apiResource{{
+		name:   "lights",
+		fields: switchLightFields(),
+		get: func(pm *PanelManager) map[string]interface{} {
+			l := pm.SwitchLights()
+			return map[string]interface{}{
+				"greenN": l.GreenN, "greenL": l.GreenL, "greenR": l.GreenR,
+				"redN": l.RedN, "redL": l.RedL, "redR": l.RedR,
+			}
+		},
+		set: func(pm *PanelManager, v map[string]interface{}) error {
+			return pm.SetSwitchLights(fip.LandingGearLights{
+				GreenN: v["greenN"].(bool), GreenL: v["greenL"].(bool), GreenR: v["greenR"].(bool),
+				RedN: v["redN"].(bool), RedL: v["redL"].(bool), RedR: v["redR"].(bool),
+			})
+		},
+	}}},
+}
+
+// multiLEDFields returns a boolean field per multi panel LED
+func multiLEDFields() []apiField {
+	fields := make([]apiField, len(multiLEDs))
+	for i, led := range multiLEDs {
+		fields[i] = apiField{name: led.name}
+	}
+	return fields
+}
+
+// switchLightFields returns a boolean field per landing gear light
+func switchLightFields() []apiField {
+	fields := make([]apiField, len(switchLightNames))
+	for i, name := range switchLightNames {
+		fields[i] = apiField{name: name}
+	}
+	return fields
+}
+
+// checkRadioValue validates a radio display value: up to five digits with
+// an optional decimal point after a digit, or empty to blank the display
+func checkRadioValue(value string) string {
+	digits, points := 0, 0
+	for i, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.':
+			if i == 0 || value[i-1] == '.' {
+				return "a decimal point must follow a digit"
+			}
+			points++
+		default:
+			return fmt.Sprintf("invalid character %q: only digits and a decimal point are allowed", c)
+		}
+	}
+	if digits > maxDisplayDigits {
+		return fmt.Sprintf("at most %d digits", maxDisplayDigits)
+	}
+	if points > 1 {
+		return "at most one decimal point"
+	}
+	return ""
+}
+
+// checkMultiValue validates a multi panel row: up to five digits or
+// dashes, or empty to blank the row
+func checkMultiValue(value string) string {
+	for _, c := range value {
+		if (c < '0' || c > '9') && c != '-' {
+			return fmt.Sprintf("invalid character %q: only digits and dashes are allowed", c)
+		}
+	}
+	if len(value) > maxDisplayDigits {
+		return fmt.Sprintf("at most %d characters", maxDisplayDigits)
+	}
+	return ""
+}
+
+// decodeFields reads a JSON object body and validates it against fields.
+// With partial unset (PUT) every field is required.
+func decodeFields(r *http.Request, fields []apiField, partial bool) (map[string]interface{}, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" && mediaType != "application/merge-patch+json" {
+		return nil, &apiError{Status: http.StatusUnsupportedMediaType, Message: "Content-Type must be application/json"}
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return nil, &apiError{Status: http.StatusRequestEntityTooLarge, Message: "request body too large"}
+		}
+		return nil, &apiError{Status: http.StatusBadRequest, Message: "invalid JSON: a JSON object is required"}
+	}
+	if body == nil {
+		return nil, &apiError{Status: http.StatusBadRequest, Message: "invalid JSON: a JSON object is required"}
+	}
+
+	values := make(map[string]interface{})
+	var problems []fieldError
+	known := make(map[string]bool)
+	for _, field := range fields {
+		known[field.name] = true
+		raw, ok := body[field.name]
+		if !ok {
+			if !partial {
+				problems = append(problems, fieldError{field.name, "required"})
+			}
+			continue
+		}
+
+		if field.check == nil {
+			var on bool
+			if err := json.Unmarshal(raw, &on); err != nil || string(raw) == "null" {
+				problems = append(problems, fieldError{field.name, "must be a boolean"})
+				continue
+			}
+			values[field.name] = on
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil || string(raw) == "null" {
+			problems = append(problems, fieldError{field.name, "must be a string"})
+			continue
+		}
+		if message := field.check(s); message != "" {
+			problems = append(problems, fieldError{field.name, message})
+			continue
+		}
+		values[field.name] = s
+	}
+
+	var unknown []string
+	for name := range body {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fieldError{name, "unknown field"})
+	}
+
+	if len(problems) > 0 {
+		return nil, &apiError{Status: http.StatusUnprocessableEntity, Message: "validation failed", Fields: problems}
+	}
+	return values, nil
+}
+
+// panelSummary is a panel in the /api/v1/panels list
+type panelSummary struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Connected bool              `json:"connected"`
+	Links     map[string]string `json:"links"`
+}
+
+// summary describes a panel and links to its resources
+func (p apiPanel) summary(pm *PanelManager) panelSummary {
+	base := "/api/v1/panels/" + p.id
+	links := map[string]string{"self": base, "inputs": base + "/inputs"}
+	for _, r := range p.resources {
+		links[r.name] = base + "/" + r.name
+	}
+	return panelSummary{ID: p.id, Name: p.name, Connected: pm.isConnected(p.id), Links: links}
+}
+
+// handleAPIv1 routes the /api/v1 endpoints
+func (s *Server) handleAPIv1(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "openapi.yaml":
+		s.handleOpenAPI(w, r, "application/yaml", openAPIYAML)
+	case path == "openapi.json":
+		s.handleOpenAPI(w, r, "application/json", s.openAPIJSON)
+	case path == "panels":
+		if r.Method != "GET" {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		panels := make([]panelSummary, len(apiPanels))
+		for i, p := range apiPanels {
+			panels[i] = p.summary(s.panelManager)
+		}
+		writeJSON(w, http.StatusOK, panels)
+	case parts[0] == "panels" && len(parts) <= 3:
+		panel, ok := findAPIPanel(parts[1])
+		if !ok {
+			writeAPIError(w, &apiError{Status: http.StatusNotFound, Message: fmt.Sprintf("unknown panel %q", parts[1])})
+			return
+		}
+		switch {
+		case len(parts) == 2:
+			s.handlePanel(w, r, panel)
+		case parts[2] == "inputs":
+			s.handlePanelInputs(w, r, panel)
+		default:
+			s.handlePanelResource(w, r, panel, parts[2])
+		}
+	default:
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Message: "not found"})
+	}
+}
+
+// findAPIPanel returns a panel by ID
+func findAPIPanel(id string) (apiPanel, bool) {
+	for _, p := range apiPanels {
+		if p.id == id {
+			return p, true
+		}
+	}
+	return apiPanel{}, false
+}
+
+// handleOpenAPI serves the OpenAPI document
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request, contentType string, doc []byte) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(doc)
+}
+
+// handlePanel returns a panel with the current values of its resources
+func (s *Server) handlePanel(w http.ResponseWriter, r *http.Request, panel apiPanel) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	summary := panel.summary(s.panelManager)
+	body := map[string]interface{}{
+		"id":        summary.ID,
+		"name":      summary.Name,
+		"connected": summary.Connected,
+		"links":     summary.Links,
+	}
+	for _, resource := range panel.resources {
+		body[resource.name] = resource.get(s.panelManager)
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handlePanelInputs returns the last input reading of a panel
+func (s *Server) handlePanelInputs(w http.ResponseWriter, r *http.Request, panel apiPanel) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	inputs, ok := s.panelManager.Inputs(panel.id)
+	if !ok {
+		writeAPIError(w, &apiError{Status: http.StatusServiceUnavailable, Message: panel.id + " panel inputs not available: " + errPanelNotConnected.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, inputs)
+}
+
+// handlePanelResource reads (GET), replaces (PUT) or updates (PATCH) a
+// panel resource and returns its new values
+func (s *Server) handlePanelResource(w http.ResponseWriter, r *http.Request, panel apiPanel, name string) {
+	var resource *apiResource
+	for i := range panel.resources {
+		if panel.resources[i].name == name {
+			resource = &panel.resources[i]
+		}
+	}
+	if resource == nil {
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Message: fmt.Sprintf("panel %s has no %q resource", panel.id, name)})
+		return
+	}
+
+	pm := s.panelManager
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, resource.get(pm))
+	case "PUT", "PATCH":
+		r.Body = http.MaxBytesReader(w, r.Body, maxAPIBody)
+		values, err := decodeFields(r, resource.fields, r.Method == "PATCH")
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		merged := resource.get(pm)
+		for name, value := range values {
+			merged[name] = value
+		}
+		if err := resource.set(pm, merged); err != nil {
+			log.Printf("API: %s %s: %v", r.Method, r.URL.Path, err)
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resource.get(pm))
+	default:
+		methodNotAllowed(w, "GET", "PUT", "PATCH")
+	}
+}
+
+// openAPIToJSON converts the embedded OpenAPI document to JSON
+func openAPIToJSON(doc []byte) ([]byte, error) {
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
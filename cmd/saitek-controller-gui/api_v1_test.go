@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testAPIPanel returns a panel with one resource kept in values
+func testAPIPanel(values map[string]interface{}) apiPanel {
+	return apiPanel{id: "test", name: "Test Panel", resources: []apiResource{{
+		name:   "display",
+		fields: []apiField{{"top", checkMultiValue}, {"bottom", checkMultiValue}, {"on", nil}},
+		get: func(pm *PanelManager) map[string]interface{} {
+			copied := make(map[string]interface{})
+			for k, v := range values {
+				copied[k] = v
+			}
+			return copied
+		},
+		set: func(pm *PanelManager, v map[string]interface{}) error {
+			for k, value := range v {
+				values[k] = value
+			}
+			return nil
+		},
+	}}}
+}
+
+func TestHandlePanelResource(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		fields      []fieldError
+		want        map[string]interface{}
+		allow       string
+	}{
+		{
+			name: "wrong content type", method: "PUT", contentType: "text/plain",
+			body: `{"top":"1","bottom":"2","on":true}`, status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "invalid JSON", method: "PUT", contentType: "application/json",
+			body: `{"top":`, status: http.StatusBadRequest,
+		},
+		{
+			name: "not an object", method: "PATCH", contentType: "application/json",
+			body: `null`, status: http.StatusBadRequest,
+		},
+		{
+			name: "field errors", method: "PUT", contentType: "application/json",
+			body: `{"top":"12a","on":"yes"}`, status: http.StatusUnprocessableEntity,
+			fields: []fieldError{
+				{"top", `invalid character 'a': only digits and dashes are allowed`},
+				{"bottom", "required"},
+				{"on", "must be a boolean"},
+			},
+		},
+		{
+			name: "unknown fields", method: "PATCH", contentType: "application/json",
+			body: `{"top":"1","zeta":1,"alpha":2}`, status: http.StatusUnprocessableEntity,
+			fields: []fieldError{{"alpha", "unknown field"}, {"zeta", "unknown field"}},
+		},
+		{
+			name: "patch merges", method: "PATCH", contentType: "application/merge-patch+json",
+			body: `{"top":"123"}`, status: http.StatusOK,
+			want: map[string]interface{}{"top": "123", "bottom": "456", "on": true},
+		},
+		{
+			name: "put replaces", method: "PUT", contentType: "application/json; charset=utf-8",
+			body: `{"top":"","bottom":"--","on":false}`, status: http.StatusOK,
+			want: map[string]interface{}{"top": "", "bottom": "--", "on": false},
+		},
+		{
+			name: "method not allowed", method: "DELETE", status: http.StatusMethodNotAllowed,
+			allow: "GET, PUT, PATCH",
+		},
+	}
+
+	s := &Server{panelManager: &PanelManager{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"top": "000", "bottom": "456", "on": true}
+			req := httptest.NewRequest(tt.method, "/api/v1/panels/test/display", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			s.handlePanelResource(rec, req, testAPIPanel(values), "display")
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Expected Allow %q, got %q", tt.allow, got)
+			}
+			if tt.status >= 400 {
+				var apiErr apiError
+				if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Status != tt.status {
+					t.Fatalf("Expected an error body with status %d, got %s", tt.status, rec.Body)
+				}
+				if !reflect.DeepEqual(apiErr.Fields, tt.fields) {
+					t.Errorf("Expected field errors %+v, got %+v", tt.fields, apiErr.Fields)
+				}
+				if values["top"] != "000" {
+					t.Error("Expected a rejected request to leave the resource unchanged")
+				}
+				return
+			}
+
+			var got map[string]interface{}
+			json.Unmarshal(rec.Body.Bytes(), &got)
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("Expected %v, got response %v and resource %v", tt.want, got, values)
+			}
+		})
+	}
+}
+
+func TestAPIv1Status(t *testing.T) {
+	// No panels are connected
+	s := &Server{panelManager: &PanelManager{inputs: make(map[string]map[string]bool), hub: NewLiveHub()}}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		allow              string
+	}{
+		{"PUT", "/api/v1/panels/radio/display", `{"com1Active":"118.00","com1Standby":"","com2Active":"","com2Standby":""}`, http.StatusServiceUnavailable, ""},
+		{"PATCH", "/api/v1/panels/switch/lights", `{"greenN":true}`, http.StatusServiceUnavailable, ""},
+		{"GET", "/api/v1/panels/multi/inputs", "", http.StatusServiceUnavailable, ""},
+		{"GET", "/api/v1/panels/multi/leds", "", http.StatusOK, ""},
+		{"POST", "/api/v1/panels", "", http.StatusMethodNotAllowed, "GET"},
+		{"POST", "/api/v1/panels/radio/inputs", "", http.StatusMethodNotAllowed, "GET"},
+		{"GET", "/api/v1/panels/fip", "", http.StatusNotFound, ""},
+		{"GET", "/api/v1/panels/radio/lights", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.handleAPIv1(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.status, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, got)
+		}
+	}
+}
@@ -0,0 +1,716 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"saitek-controller/internal/fip"
+)
+
+// fipEventHistory is the number of recent input events kept for the web view
+const fipEventHistory = 10
+
+// maxDialDetents limits the detents a single request may turn a dial by
+const maxDialDetents = 10
+
+// imagePageID is the emulator page showing uploaded images. It is added
+// with the first upload, after the instrument pages.
+const imagePageID = 7
+
+// syncPageID is the emulator page showing the panels that don't match the
+// sim. It is added the first time the sync service draws.
+const syncPageID = 8
+
+// maxFIPImageUpload limits the size of an uploaded image
+const maxFIPImageUpload = 10 << 20
+
+// maxFIPImageSize limits the width and height of an uploaded image, which
+// is checked before decoding since small files can claim huge images
+const maxFIPImageSize = 4096
+
+// emulatorPage is a page of the FIP emulator showing one instrument. The
+// dials adjust the value shown, by one step per detent.
+type emulatorPage struct {
+	id         uint32
+	name       string
+	instrument fip.Instrument
+	rightDial  func(data *fip.InstrumentData, delta int)
+	leftDial   func(data *fip.InstrumentData, delta int)
+}
+
+// emulatorPages are the pages of the emulated FIP, in page order
+var emulatorPages = []emulatorPage{
+	{1, "Attitude", fip.InstrumentArtificialHorizon,
+		func(d *fip.InstrumentData, delta int) { d.Pitch += float64(delta) },
+		func(d *fip.InstrumentData, delta int) { d.Roll += float64(delta) * 5 }},
+	{2, "Airspeed", fip.InstrumentAirspeed,
+		func(d *fip.InstrumentData, delta int) { d.Airspeed = clampValue(d.Airspeed+float64(delta)*5, 0, 400) },
+		func(d *fip.InstrumentData, delta int) { d.Airspeed = clampValue(d.Airspeed+float64(delta), 0, 400) }},
+	{3, "Altimeter", fip.InstrumentAltimeter,
+		func(d *fip.InstrumentData, delta int) { d.Altitude += float64(delta) * 100 },
+		func(d *fip.InstrumentData, delta int) { d.Pressure += float64(delta) * 0.01 }},
+	{4, "Heading", fip.InstrumentCompass,
+		func(d *fip.InstrumentData, delta int) { d.HeadingBug = wrapDegrees(d.HeadingBug + float64(delta)) },
+		func(d *fip.InstrumentData, delta int) { d.Heading = wrapDegrees(d.Heading + float64(delta)) }},
+	{5, "Vertical Speed", fip.InstrumentVerticalSpeed,
+		func(d *fip.InstrumentData, delta int) { d.VerticalSpeed += float64(delta) * 100 },
+		func(d *fip.InstrumentData, delta int) { d.VerticalSpeed += float64(delta) * 500 }},
+	{6, "Turn Coordinator", fip.InstrumentTurnCoordinator,
+		func(d *fip.InstrumentData, delta int) { d.TurnRate += float64(delta) * 0.5 },
+		func(d *fip.InstrumentData, delta int) { d.Slip += float64(delta) }},
+}
+
+// clampValue limits v to the range min to max
+func clampValue(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// wrapDegrees wraps an angle to 0-359 degrees
+func wrapDegrees(v float64) float64 {
+	for v < 0 {
+		v += 360
+	}
+	for v >= 360 {
+		v -= 360
+	}
+	return v
+}
+
+// emulatorLEDs records the soft button LED states the page manager sends,
+// standing in for the LEDs of a real FIP
+type emulatorLEDs struct {
+	mu   sync.Mutex
+	leds [6]bool
+}
+
+// SetLED implements fip.LEDController
+func (l *emulatorLEDs) SetLED(index int, value bool) error {
+	if index < 0 || index >= len(l.leds) {
+		return fmt.Errorf("invalid LED index: %d", index)
+	}
+	l.mu.Lock()
+	l.leds[index] = value
+	l.mu.Unlock()
+	return nil
+}
+
+// States returns the LED states, S1 first
+func (l *emulatorLEDs) States() [6]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leds
+}
+
+// FIPEmulator is a virtual FIP shown in the browser. Its frames are
+// streamed as MJPEG and clicks on the bezel come back as the same input
+// events a real FIP produces, handled by a page manager.
+type FIPEmulator struct {
+	panel  *fip.FIPPanel
+	pages  *fip.PageManager
+	stream *fip.StreamSink
+	leds   *emulatorLEDs
+
+	mu       sync.Mutex
+	data     fip.InstrumentData
+	image    image.Image
+	sync     image.Image
+	events   []string
+	onInput  func(fip.InputEvent)
+	onChange func()
+	done     chan struct{}
+
+	closeOnce sync.Once
+
+	imageMu sync.Mutex // serializes adding the image and sync pages
+}
+
+// NewFIPEmulator creates an emulator with one page per instrument and
+// starts handling its input events
+func NewFIPEmulator() (*FIPEmulator, error) {
+	panel, err := fip.NewFIPPanel("FIP Emulator", 320, 240)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &FIPEmulator{
+		panel:  panel,
+		stream: fip.NewStreamSink(85),
+		leds:   &emulatorLEDs{},
+		data: fip.InstrumentData{
+			Airspeed: 110,
+			Altitude: 3500,
+			Pressure: 29.92,
+			Heading:  270,
+		},
+		done: make(chan struct{}),
+	}
+	e.data.HeadingBug = e.data.Heading
+	// Unchanged frames, such as a redraw of the same page, aren't encoded
+	// and streamed again, and bursts of frames are thinned out to the rate
+	// a FIP would show
+	panel.AddSink(fip.NewFramePipeline(e.stream, fip.PipelineOptions{MaxFPS: fip.DefaultMaxFPS}))
+
+	e.pages = fip.NewPageManager(panel, 320, 240)
+	e.pages.SetLEDController(e.leds)
+	for _, p := range emulatorPages {
+		if _, err := e.pages.AddPage(p.id, p.name, e.renderer(p.instrument), 0); err != nil {
+			panel.Close()
+			return nil, fmt.Errorf("failed to add page %s: %w", p.name, err)
+		}
+	}
+
+	go e.run()
+	return e, nil
+}
+
+// renderer draws an instrument with the emulator's current data
+func (e *FIPEmulator) renderer(instrument fip.Instrument) fip.Renderer {
+	return fip.RendererFunc(func(s *fip.Surface) error {
+		e.mu.Lock()
+		data := e.data
+		e.mu.Unlock()
+		return fip.InstrumentRenderer{Instrument: instrument, Data: data}.Render(s)
+	})
+}
+
+// run handles the panel's input events until the emulator is closed
+func (e *FIPEmulator) run() {
+	for {
+		select {
+		case <-e.done:
+			return
+		case event := <-e.panel.Inputs():
+			if err := e.handleInput(event); err != nil {
+				log.Printf("FIP emulator: %s: %v", event, err)
+			}
+			e.mu.Lock()
+			onInput := e.onInput
+			e.mu.Unlock()
+			if onInput != nil {
+				onInput(event)
+			}
+		}
+	}
+}
+
+// handleInput passes an event to the page manager, then toggles the
+// page's LED on soft button presses or turns the dial's value
+func (e *FIPEmulator) handleInput(event fip.InputEvent) error {
+	e.mu.Lock()
+	e.events = append(e.events, event.Timestamp.Format("15:04:05.000")+" "+event.String())
+	if len(e.events) > fipEventHistory {
+		e.events = e.events[len(e.events)-fipEventHistory:]
+	}
+	e.mu.Unlock()
+
+	if err := e.pages.HandleInput(event); err != nil {
+		return err
+	}
+
+	page := e.pages.ActivePage()
+	if page == nil {
+		return nil
+	}
+
+	switch {
+	case event.Control.IsButton() && event.Pressed:
+		index := event.Control.Button() - 1
+		return e.pages.SetLed(page.ID, index, !page.Leds()[index])
+
+	case event.Control.IsDial():
+		for _, p := range emulatorPages {
+			if p.id != page.ID {
+				continue
+			}
+			e.mu.Lock()
+			switch event.Control {
+			case fip.InputRightDialCW, fip.InputRightDialCCW:
+				p.rightDial(&e.data, event.Delta())
+			default:
+				p.leftDial(&e.data, event.Delta())
+			}
+			e.mu.Unlock()
+			return e.pages.UpdatePage(page.ID)
+		}
+	}
+	return nil
+}
+
+// OnInput sets a callback run after each input event has been handled
+func (e *FIPEmulator) OnInput(callback func(fip.InputEvent)) {
+	e.mu.Lock()
+	e.onInput = callback
+	e.mu.Unlock()
+}
+
+// OnChange sets a callback run after the image, instrument values or LEDs
+// were changed other than by an input event
+func (e *FIPEmulator) OnChange(callback func()) {
+	e.mu.Lock()
+	e.onChange = callback
+	e.mu.Unlock()
+}
+
+// changed runs the OnChange callback
+func (e *FIPEmulator) changed() {
+	e.mu.Lock()
+	onChange := e.onChange
+	e.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// ShowImage resizes an image with the resize mode and shows it on the
+// image page, adding the page on the first upload
+func (e *FIPEmulator) ShowImage(img image.Image, mode fip.ResizeMode) error {
+	loader := fip.NewImageLoader()
+	loader.SetResizeMode(mode)
+	processed, err := loader.ProcessImageForFIP(img)
+	if err != nil {
+		return err
+	}
+
+	return e.showFrame(imagePageID, "Image", &e.image, processed)
+}
+
+// DisplayImage shows a frame from the panel sync service on the sync page,
+// so the emulator can be the sync service's display
+func (e *FIPEmulator) DisplayImage(img image.Image) error {
+	return e.showFrame(syncPageID, "Sync", &e.sync, img)
+}
+
+// showFrame stores a frame and shows the page drawing it, adding the page
+// the first time
+func (e *FIPEmulator) showFrame(id uint32, name string, frame *image.Image, img image.Image) error {
+	e.mu.Lock()
+	*frame = img
+	e.mu.Unlock()
+
+	var err error
+	e.imageMu.Lock()
+	if e.pages.Page(id) == nil {
+		_, err = e.pages.AddPage(id, name, e.frameRenderer(frame), fip.FLAG_SET_AS_ACTIVE)
+	} else if page := e.pages.ActivePage(); page != nil && page.ID == id {
+		err = e.pages.Refresh()
+	} else {
+		err = e.pages.SetActivePage(id)
+	}
+	e.imageMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	e.changed()
+	return nil
+}
+
+// frameRenderer draws the last frame stored in frame
+func (e *FIPEmulator) frameRenderer(frame *image.Image) fip.Renderer {
+	return fip.RendererFunc(func(s *fip.Surface) error {
+		e.mu.Lock()
+		img := *frame
+		e.mu.Unlock()
+		if img != nil {
+			s.DrawImage(img)
+		}
+		return nil
+	})
+}
+
+// fipInstrumentRequest selects the page showing an instrument and sets
+// instrument values, with the field names of fip.InstrumentData. Either
+// may be left out; values left out keep their value.
+type fipInstrumentRequest struct {
+	Instrument string          `json:"instrument"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// ShowInstrument switches to the page of the requested instrument and
+// redraws it with the new values
+func (e *FIPEmulator) ShowInstrument(request fipInstrumentRequest) error {
+	var pageID uint32
+	if request.Instrument != "" {
+		instrument, err := fip.ParseInstrument(request.Instrument)
+		if err != nil {
+			return err
+		}
+		for _, p := range emulatorPages {
+			if p.instrument == instrument {
+				pageID = p.id
+			}
+		}
+		if pageID == 0 {
+			return fmt.Errorf("no page shows the %s instrument", instrument)
+		}
+	}
+
+	if len(request.Data) > 0 && string(request.Data) != "null" {
+		e.mu.Lock()
+		data := e.data
+		dec := json.NewDecoder(bytes.NewReader(request.Data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&data)
+		if err == nil {
+			e.data = data
+		}
+		e.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("invalid instrument data: %w", err)
+		}
+	}
+
+	var err error
+	if page := e.pages.ActivePage(); pageID != 0 && (page == nil || page.ID != pageID) {
+		err = e.pages.SetActivePage(pageID)
+	} else {
+		err = e.pages.Refresh()
+	}
+	if err != nil {
+		return err
+	}
+
+	e.changed()
+	return nil
+}
+
+// fipLEDsRequest sets soft button LEDs by name, S1 to S6, on a page or on
+// the active page if Page is 0
+type fipLEDsRequest struct {
+	Page uint32          `json:"page"`
+	LEDs map[string]bool `json:"leds"`
+}
+
+// SetLEDs sets the requested soft button LEDs. The LEDs are kept with the
+// page, as on a real FIP.
+func (e *FIPEmulator) SetLEDs(request fipLEDsRequest) error {
+	pageID := request.Page
+	if pageID == 0 {
+		page := e.pages.ActivePage()
+		if page == nil {
+			return fmt.Errorf("no active page")
+		}
+		pageID = page.ID
+	} else if e.pages.Page(pageID) == nil {
+		return fmt.Errorf("page %d not found", pageID)
+	}
+
+	// Check every name before changing any LED
+	leds := make(map[int]bool, len(request.LEDs))
+	for name, on := range request.LEDs {
+		control, err := fip.ParseInputControl(name)
+		if err != nil || !control.IsButton() {
+			return fmt.Errorf("unknown soft button %q (must be S1-S6)", name)
+		}
+		leds[control.Button()-1] = on
+	}
+	for index, on := range leds {
+		if err := e.pages.SetLed(pageID, index, on); err != nil {
+			return err
+		}
+	}
+
+	e.changed()
+	return nil
+}
+
+// FramePNG returns the current display as a PNG
+func (e *FIPEmulator) FramePNG() ([]byte, error) {
+	frame := e.panel.Frame()
+	if frame == nil {
+		return nil, fmt.Errorf("no frame shown yet")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frame); err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fipInputRequest is a click on the emulator bezel. Buttons send a press or
+// release; dials turn by Count detents.
+type fipInputRequest struct {
+	Control string `json:"control"`
+	Pressed bool   `json:"pressed"`
+	Count   int    `json:"count"`
+}
+
+// Send turns a bezel click into FIP input events
+func (e *FIPEmulator) Send(request fipInputRequest) error {
+	control, err := fip.ParseInputControl(request.Control)
+	if err != nil {
+		return err
+	}
+
+	if control.IsDial() {
+		count := request.Count
+		if count <= 0 {
+			count = 1
+		}
+		if count > maxDialDetents {
+			count = maxDialDetents
+		}
+		for i := 0; i < count; i++ {
+			e.panel.SendInput(fip.InputEvent{Control: control, Pressed: true, Timestamp: time.Now()})
+		}
+	} else {
+		e.panel.SendInput(fip.InputEvent{Control: control, Pressed: request.Pressed, Timestamp: time.Now()})
+	}
+	return nil
+}
+
+// FIPEmulatorState describes the emulator for the web view
+type FIPEmulatorState struct {
+	ActivePage uint32             `json:"activePage"`
+	Pages      []FIPEmulatorPage  `json:"pages"`
+	LEDs       [6]bool            `json:"leds"`
+	Data       fip.InstrumentData `json:"data"`
+	Events     []string           `json:"events"`
+	Frames     uint64             `json:"frames"`
+	Clients    int                `json:"clients"`
+}
+
+// FIPEmulatorPage names one emulator page
+type FIPEmulatorPage struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+// State returns the emulator's current state
+func (e *FIPEmulator) State() FIPEmulatorState {
+	state := FIPEmulatorState{
+		LEDs:    e.leds.States(),
+		Frames:  e.stream.Frames(),
+		Clients: e.stream.Clients(),
+		Pages:   []FIPEmulatorPage{},
+	}
+	if page := e.pages.ActivePage(); page != nil {
+		state.ActivePage = page.ID
+	}
+	for _, page := range e.pages.Pages() {
+		state.Pages = append(state.Pages, FIPEmulatorPage{ID: page.ID, Name: page.Name})
+	}
+
+	e.mu.Lock()
+	state.Data = e.data
+	state.Events = append([]string{}, e.events...)
+	e.mu.Unlock()
+	return state
+}
+
+// Close stops the emulator and ends every stream
+func (e *FIPEmulator) Close() {
+	e.closeOnce.Do(func() {
+		close(e.done)
+		e.panel.Close()
+	})
+}
+
+// handleFIPState returns the emulator state
+func (s *Server) handleFIPState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.panelManager.fipEmulator.State())
+}
+
+// handleFIPStream streams the emulator display as MJPEG
+func (s *Server) handleFIPStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.panelManager.fipEmulator.stream.ServeHTTP(w, r)
+}
+
+// handleFIPFrame returns the current emulator display as a JPEG
+func (s *Server) handleFIPFrame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.panelManager.fipEmulator.stream.ServeSnapshot(w, r)
+}
+
+// handleFIPInput turns a click on the emulator bezel into FIP input events
+func (s *Server) handleFIPInput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request fipInputRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.panelManager.fipEmulator.Send(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// handleFIPFramePNG returns the current emulator display as a lossless PNG
+func (s *Server) handleFIPFramePNG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := s.panelManager.fipEmulator.FramePNG()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// handleFIPImage shows an uploaded image on the emulator. The image is the
+// "image" field of a multipart form or the whole body; the mode parameter
+// picks the resize mode, fit by default.
+func (s *Server) handleFIPImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFIPImageUpload)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxFIPImageUpload); err != nil {
+			http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, "Missing image field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	mode := fip.ResizeModeFit
+	if name := r.FormValue("mode"); name != "" {
+		parsed, err := fip.ParseResizeMode(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mode = parsed
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if config.Width > maxFIPImageSize || config.Height > maxFIPImageSize {
+		http.Error(w, fmt.Sprintf("Image too large: %dx%d (at most %dx%d)",
+			config.Width, config.Height, maxFIPImageSize, maxFIPImageSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.panelManager.fipEmulator.ShowImage(img, mode); err != nil {
+		writeResult(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"format":  format,
+		"width":   img.Bounds().Dx(),
+		"height":  img.Bounds().Dy(),
+		"mode":    mode.String(),
+		"page":    imagePageID,
+	})
+}
+
+// handleFIPInstrument selects an instrument page and sets its values
+func (s *Server) handleFIPInstrument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request fipInstrumentRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.panelManager.fipEmulator.ShowInstrument(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeResult(w, nil)
+}
+
+// handleFIPLEDs sets soft button LEDs
+func (s *Server) handleFIPLEDs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request fipLEDsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.panelManager.fipEmulator.SetLEDs(request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeResult(w, nil)
+}
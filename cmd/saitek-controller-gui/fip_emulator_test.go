@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"saitek-controller/internal/fip"
+)
+
+// newTestServer returns a server with a FIP emulator and no panels
+func newTestServer(t *testing.T) *Server {
+	emulator, err := NewFIPEmulator()
+	if err != nil {
+		t.Fatalf("Failed to create FIP emulator: %v", err)
+	}
+	t.Cleanup(emulator.Close)
+	return &Server{panelManager: &PanelManager{fipEmulator: emulator}}
+}
+
+// bmpHeader returns the headers of a 24bpp bitmap of the given size,
+// without any pixel data
+func bmpHeader(width, height int) []byte {
+	data := make([]byte, 54)
+	data[0], data[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(data[10:], 54)
+	binary.LittleEndian.PutUint32(data[14:], 40)
+	binary.LittleEndian.PutUint32(data[18:], uint32(width))
+	binary.LittleEndian.PutUint32(data[22:], uint32(height))
+	binary.LittleEndian.PutUint16(data[26:], 1)
+	binary.LittleEndian.PutUint16(data[28:], 24)
+	return data
+}
+
+func TestHandleFIPImage(t *testing.T) {
+	s := newTestServer(t)
+
+	var small bytes.Buffer
+	png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 64, 48)))
+
+	tests := map[string]struct {
+		body   []byte
+		status int
+	}{
+		"png":            {small.Bytes(), http.StatusOK},
+		"huge header":    {bmpHeader(200000, 200000), http.StatusRequestEntityTooLarge},
+		"too wide":       {bmpHeader(maxFIPImageSize+1, 1), http.StatusRequestEntityTooLarge},
+		"not an image":   {[]byte("hello"), http.StatusBadRequest},
+		"too many bytes": {make([]byte, maxFIPImageUpload+1), http.StatusRequestEntityTooLarge},
+	}
+	for name, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/fip/image", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rec := httptest.NewRecorder()
+		s.handleFIPImage(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tt.status, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestFIPEmulatorSyncPage(t *testing.T) {
+	s := newTestServer(t)
+	e := s.panelManager.fipEmulator
+
+	// The sync service draws on the emulator through fip.ImageDisplay
+	var display fip.ImageDisplay = e
+	mismatches := []fip.Mismatch{{Panel: "switch", Control: "BAT", Hardware: "OFF", Sim: "ON"}}
+	if err := display.DisplayImage(fip.RenderMismatchList(320, 240, mismatches)); err != nil {
+		t.Fatalf("Failed to display the mismatch list: %v", err)
+	}
+	if page := e.pages.ActivePage(); page == nil || page.ID != syncPageID || page.Name != "Sync" {
+		t.Errorf("Expected the sync page to be shown, got %+v", page)
+	}
+
+	e.pages.SetActivePage(1)
+	display.DisplayImage(fip.RenderMismatchList(320, 240, nil))
+	if page := e.pages.ActivePage(); page.ID != syncPageID {
+		t.Errorf("Expected the sync page to be shown again, got page %d", page.ID)
+	}
+}
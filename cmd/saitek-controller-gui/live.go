@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"saitek-controller/internal/fip"
+)
+
+// liveClientBuffer is the number of messages queued for a WebSocket client
+// before it is considered too slow and dropped
+const liveClientBuffer = 64
+
+// inputPollInterval is how often connected panels are read for input
+const inputPollInterval = 100 * time.Millisecond
+
+// PanelInput is an input event from a panel: a switch flip, an encoder
+// detent or a button press or release
+type PanelInput struct {
+	Panel     string    `json:"panel"`
+	Control   string    `json:"control"`
+	Kind      string    `json:"kind"`                // switch, button or encoder
+	On        bool      `json:"on"`                  // switch position or button pressed
+	Direction string    `json:"direction,omitempty"` // cw or ccw for encoders
+	Time      time.Time `json:"time"`
+}
+
+// Messages pushed to WebSocket clients, told apart by their type
+type (
+	stateMessage struct {
+		Type  string     `json:"type"`
+		State PanelState `json:"state"`
+	}
+	connectionMessage struct {
+		Type      string `json:"type"`
+		Panel     string `json:"panel"`
+		Connected bool   `json:"connected"`
+	}
+	inputMessage struct {
+		Type  string     `json:"type"`
+		Input PanelInput `json:"input"`
+	}
+	fipMessage struct {
+		Type string           `json:"type"`
+		FIP  FIPEmulatorState `json:"fip"`
+	}
+	resultMessage struct {
+		Type    string `json:"type"`
+		ID      int    `json:"id"`
+		Success bool   `json:"success"`
+		Error   string `json:"error,omitempty"`
+	}
+)
+
+// liveCommand is a command sent by a WebSocket client. Data holds the same
+// body as the matching REST endpoint.
+type liveCommand struct {
+	ID   int             `json:"id"`
+	Type string          `json:"type"` // radio, multi, switch, fipInput or connect
+	Data json.RawMessage `json:"data"`
+}
+
+// LiveHub fans messages out to the connected WebSocket clients
+type LiveHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// NewLiveHub creates a hub without clients
+func NewLiveHub() *LiveHub {
+	return &LiveHub{clients: make(map[chan []byte]struct{})}
+}
+
+// Subscribe returns a channel receiving every published message. The
+// channel is closed by Unsubscribe or when the client falls behind.
+func (h *LiveHub) Subscribe() chan []byte {
+	ch := make(chan []byte, liveClientBuffer)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a client and closes its channel
+func (h *LiveHub) Unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[ch]; ok {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+// Publish sends a message to every client without waiting. Clients whose
+// queue is full are dropped; the page reconnects and gets a fresh state.
+func (h *LiveHub) Publish(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Live: failed to encode message: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- data:
+		default:
+			log.Printf("Live: dropping slow client")
+			delete(h.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// Clients returns the number of connected clients
+func (h *LiveHub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// switchInputs returns the switch panel state by control name
+func switchInputs(s *fip.SwitchState) map[string]bool {
+	return map[string]bool{
+		"BAT": s.BAT, "ALT": s.ALT, "AVIONICS": s.AVIONICS, "FUEL": s.FUEL,
+		"DEICE": s.DEICE, "PITOT": s.PITOT, "COWL": s.COWL, "PANEL": s.PANEL,
+		"BEACON": s.BEACON, "NAV": s.NAV, "STROBE": s.STROBE, "TAXI": s.TAXI,
+		"LANDING": s.LANDING, "OFF": s.OFF, "R": s.R, "L": s.L,
+		"BOTH": s.BOTH, "START": s.START, "GEARUP": s.GEARUP, "GEARDOWN": s.GEARDOWN,
+	}
+}
+
+// switchState is the inverse of switchInputs
+func switchState(inputs map[string]bool) *fip.SwitchState {
+	return &fip.SwitchState{
+		BAT: inputs["BAT"], ALT: inputs["ALT"], AVIONICS: inputs["AVIONICS"], FUEL: inputs["FUEL"],
+		DEICE: inputs["DEICE"], PITOT: inputs["PITOT"], COWL: inputs["COWL"], PANEL: inputs["PANEL"],
+		BEACON: inputs["BEACON"], NAV: inputs["NAV"], STROBE: inputs["STROBE"], TAXI: inputs["TAXI"],
+		LANDING: inputs["LANDING"], OFF: inputs["OFF"], R: inputs["R"], L: inputs["L"],
+		BOTH: inputs["BOTH"], START: inputs["START"], GEARUP: inputs["GEARUP"], GEARDOWN: inputs["GEARDOWN"],
+	}
+}
+
+// errNoInputs is returned by the cached panels before the first reading
+var errNoInputs = errors.New("no input reading yet")
+
+// cachedSwitchPanel gives the sync service the switch positions last read
+// by watchInputs. Only the watcher reads the panels: each read takes a
+// report off the interrupt endpoint, which a second reader would miss.
+type cachedSwitchPanel struct {
+	pm *PanelManager
+}
+
+// IsConnected implements fip.SwitchStateReader
+func (c cachedSwitchPanel) IsConnected() bool {
+	return c.pm.isConnected("switch")
+}
+
+// GetSwitchState implements fip.SwitchStateReader
+func (c cachedSwitchPanel) GetSwitchState() (*fip.SwitchState, error) {
+	inputs, ok := c.pm.Inputs("switch")
+	if !ok {
+		return nil, errNoInputs
+	}
+	return switchState(inputs), nil
+}
+
+// cachedMultiPanel gives the sync service the multi panel selector
+// position last read by watchInputs
+type cachedMultiPanel struct {
+	pm *PanelManager
+}
+
+// IsConnected implements fip.SelectorReader
+func (c cachedMultiPanel) IsConnected() bool {
+	return c.pm.isConnected("multi")
+}
+
+// GetSelector implements fip.SelectorReader
+func (c cachedMultiPanel) GetSelector() (string, error) {
+	inputs, ok := c.pm.Inputs("multi")
+	if !ok {
+		return "", errNoInputs
+	}
+	return fip.SelectorPosition(inputs), nil
+}
+
+// classifyInput splits a control name from ParseSwitchState into its kind,
+// control and encoder direction
+func classifyInput(name string) (kind, control, direction string) {
+	switch {
+	case strings.HasSuffix(name, "_CCW"):
+		return "encoder", strings.TrimSuffix(name, "_CCW"), "ccw"
+	case strings.HasSuffix(name, "_CW"):
+		return "encoder", strings.TrimSuffix(name, "_CW"), "cw"
+	case strings.HasSuffix(name, "_BTN"), name == "AP", strings.HasPrefix(name, "ACT_STBY"):
+		return "button", name, ""
+	default:
+		return "switch", name, ""
+	}
+}
+
+// diffInputs returns the input events between two readings of a panel.
+// Each report with an encoder bit set is one detent; switches and buttons
+// are reported when they change. Events are sorted by control name.
+func diffInputs(panel string, previous, current map[string]bool, now time.Time) []PanelInput {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []PanelInput
+	for _, name := range names {
+		on := current[name]
+		kind, control, direction := classifyInput(name)
+		if kind == "encoder" {
+			if on {
+				events = append(events, PanelInput{Panel: panel, Control: control, Kind: kind, On: true, Direction: direction, Time: now})
+			}
+			continue
+		}
+		if on != previous[name] {
+			events = append(events, PanelInput{Panel: panel, Control: control, Kind: kind, On: on, Time: now})
+		}
+	}
+	return events
+}
+
+// fipInput converts a FIP emulator event to a panel input
+func fipInput(event fip.InputEvent) PanelInput {
+	input := PanelInput{Panel: "fip", Control: event.Control.String(), Kind: "button", On: event.Pressed, Time: event.Timestamp}
+	if event.Control.IsDial() {
+		input.Kind = "encoder"
+		input.Direction = "cw"
+		if event.Delta() < 0 {
+			input.Direction = "ccw"
+		}
+		input.Control = strings.TrimSuffix(strings.TrimSuffix(input.Control, "CCW"), "CW")
+	}
+	return input
+}
+
+// panelReader reads the inputs of one panel
+type panelReader struct {
+	name string
+	read func() (map[string]bool, error)
+}
+
+// readers returns the input readers of the three panels
+func (pm *PanelManager) readers() []panelReader {
+	return []panelReader{
+		{"radio", func() (map[string]bool, error) {
+			data, err := pm.radio.ReadSwitchState()
+			if err != nil {
+				return nil, err
+			}
+			return pm.radio.ParseSwitchState(data), nil
+		}},
+		{"multi", func() (map[string]bool, error) {
+			data, err := pm.multi.ReadSwitchState()
+			if err != nil {
+				return nil, err
+			}
+			return pm.multi.ParseSwitchState(data), nil
+		}},
+		{"switch", func() (map[string]bool, error) {
+			state, err := pm.switch_.GetSwitchState()
+			if err != nil || state == nil {
+				return nil, err
+			}
+			return switchInputs(state), nil
+		}},
+	}
+}
+
+// StartInputWatch polls each connected panel for input in its own
+// goroutine, so a blocking read on one panel doesn't hold up the others
+func (pm *PanelManager) StartInputWatch(interval time.Duration) {
+	for _, r := range pm.readers() {
+		go pm.watchInputs(r, interval)
+	}
+}
+
+// watchInputs reads a panel until the manager is closed, publishing its
+// input events and state. A failed read marks the panel disconnected.
+func (pm *PanelManager) watchInputs(r panelReader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pm.done:
+			return
+		case <-ticker.C:
+		}
+		if !pm.isConnected(r.name) {
+			continue
+		}
+
+		current, err := r.read()
+		if err != nil {
+			log.Printf("Error reading %s panel: %v", r.name, err)
+			pm.disconnect(r.name)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		pm.mu.Lock()
+		previous, seen := pm.inputs[r.name]
+		pm.inputs[r.name] = current
+		pm.mu.Unlock()
+
+		// The first reading after connecting is the starting position
+		var events []PanelInput
+		if seen {
+			events = diffInputs(r.name, previous, current, time.Now())
+		}
+		for _, input := range events {
+			pm.hub.Publish(inputMessage{Type: "input", Input: input})
+		}
+		if !seen || len(events) > 0 {
+			pm.publishState()
+		}
+	}
+}
+
+// isConnected reports whether a panel is connected
+func (pm *PanelManager) isConnected(panel string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	switch panel {
+	case "radio":
+		return pm.radioConnected
+	case "multi":
+		return pm.multiConnected
+	case "switch":
+		return pm.switchConnected
+	}
+	return false
+}
+
+// disconnect closes a panel after it stopped responding
+func (pm *PanelManager) disconnect(panel string) {
+	pm.mu.Lock()
+	switch panel {
+	case "radio":
+		pm.radio.Disconnect()
+		pm.radioConnected = false
+	case "multi":
+		pm.multi.Disconnect()
+		pm.multiConnected = false
+	case "switch":
+		pm.switch_.Disconnect()
+		pm.switchConnected = false
+	}
+	delete(pm.inputs, panel)
+	pm.mu.Unlock()
+
+	pm.hub.Publish(connectionMessage{Type: "connection", Panel: panel, Connected: false})
+	pm.publishState()
+}
+
+// publishState pushes the current state of all panels
+func (pm *PanelManager) publishState() {
+	pm.hub.Publish(stateMessage{Type: "state", State: pm.GetState()})
+}
+
+// publishFIP pushes a FIP emulator input event and its new state
+func (pm *PanelManager) publishFIP(event fip.InputEvent) {
+	pm.hub.Publish(inputMessage{Type: "input", Input: fipInput(event)})
+	pm.publishFIPState()
+}
+
+// publishFIPState pushes the FIP emulator state
+func (pm *PanelManager) publishFIPState() {
+	pm.hub.Publish(fipMessage{Type: "fip", FIP: pm.fipEmulator.State()})
+}
+
+// runCommand carries out a command from a WebSocket client
+func (s *Server) runCommand(command liveCommand) error {
+	pm := s.panelManager
+	decode := func(v interface{}) error {
+		if len(command.Data) == 0 {
+			return fmt.Errorf("missing data")
+		}
+		if err := json.Unmarshal(command.Data, v); err != nil {
+			return fmt.Errorf("invalid data: %w", err)
+		}
+		return nil
+	}
+
+	switch command.Type {
+	case "radio":
+		var request radioRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.SetRadioDisplay(request.COM1Active, request.COM1Standby, request.COM2Active, request.COM2Standby)
+	case "multi":
+		var request multiRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.SetMultiDisplay(request.TopRow, request.BottomRow, request.LEDs)
+	case "switch":
+		var request switchRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.SetSwitchLights(request.lights())
+	case "fipInput":
+		var request fipInputRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.fipEmulator.Send(request)
+	case "fipInstrument":
+		var request fipInstrumentRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.fipEmulator.ShowInstrument(request)
+	case "fipLEDs":
+		var request fipLEDsRequest
+		if err := decode(&request); err != nil {
+			return err
+		}
+		return pm.fipEmulator.SetLEDs(request)
+	case "connect":
+		pm.ConnectAll()
+		return nil
+	default:
+		return fmt.Errorf("unknown command: %q", command.Type)
+	}
+}
+
+// handleWebSocket pushes live panel state, connection changes and input
+// events to the page and takes display and LED commands from it
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("Live: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	id := requestIdentity(r)
+	hub := s.panelManager.hub
+	messages := hub.Subscribe()
+	defer hub.Unsubscribe(messages)
+
+	// The client starts from the full state
+	pm := s.panelManager
+	for _, message := range []interface{}{
+		stateMessage{Type: "state", State: pm.GetState()},
+		fipMessage{Type: "fip", FIP: pm.fipEmulator.State()},
+	} {
+		data, _ := json.Marshal(message)
+		if err := conn.WriteMessage(data); err != nil {
+			return
+		}
+	}
+
+	go func() {
+		for data := range messages {
+			if err := conn.WriteMessage(data); err != nil {
+				break
+			}
+		}
+		// Dropped as too slow, or the write failed: end the read loop too
+		conn.Close()
+	}()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var command liveCommand
+		result := resultMessage{Type: "result", Success: true}
+		if err := json.Unmarshal(data, &command); err != nil {
+			result.Success, result.Error = false, "invalid JSON"
+		} else if !id.CanControl() {
+			result.Success, result.Error = false, "read-only access"
+		} else if err := s.runCommand(command); err != nil {
+			result.Success, result.Error = false, err.Error()
+		}
+		result.ID = command.ID
+
+		reply, _ := json.Marshal(result)
+		if err := conn.WriteMessage(reply); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"saitek-controller/internal/fip"
+)
+
+func TestCachedPanels(t *testing.T) {
+	pm := &PanelManager{inputs: make(map[string]map[string]bool)}
+	switchPanel, multiPanel := cachedSwitchPanel{pm}, cachedMultiPanel{pm}
+
+	if switchPanel.IsConnected() || multiPanel.IsConnected() {
+		t.Error("Expected panels to follow the manager's connection state")
+	}
+	pm.switchConnected, pm.multiConnected = true, true
+	if _, err := switchPanel.GetSwitchState(); !errors.Is(err, errNoInputs) {
+		t.Errorf("Expected errNoInputs before the first reading, got %v", err)
+	}
+
+	state := &fip.SwitchState{BAT: true, AVIONICS: true, BOTH: true, GEARDOWN: true}
+	pm.inputs["switch"] = switchInputs(state)
+	pm.inputs["multi"] = map[string]bool{"HDG": true, "AP_BTN": true}
+	if got, err := switchPanel.GetSwitchState(); err != nil || *got != *state {
+		t.Errorf("Expected %+v, got %+v, %v", state, got, err)
+	}
+	if got, err := multiPanel.GetSelector(); err != nil || got != "HDG" {
+		t.Errorf("Expected HDG, got %q, %v", got, err)
+	}
+
+	// The sync service compares the cached readings without reading the panels
+	source := fip.NewMemorySimSource()
+	source.SetTarget(fip.PanelSnapshot{Switch: &fip.SwitchState{BAT: true, BOTH: true, GEARDOWN: true}, MultiSelector: "HDG"})
+	result, err := fip.NewSyncService(source, switchPanel, multiPanel).Sync()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Mismatches) != 1 || result.Mismatches[0].Control != "AVIONICS" {
+		t.Errorf("Expected the avionics switch to mismatch, got %+v", result.Mismatches)
+	}
+}
+
+func TestPanelManagerCloseTwice(t *testing.T) {
+	s := newTestServer(t)
+	pm := s.panelManager
+	pm.done = make(chan struct{})
+	pm.syncService = fip.NewSyncService(nil, cachedSwitchPanel{pm}, cachedMultiPanel{pm})
+
+	pm.Close()
+	pm.Close()
+	select {
+	case <-pm.done:
+	default:
+		t.Error("Expected Close to stop the watchers")
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
@@ -9,12 +11,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"saitek-controller/internal/fip"
 )
 
+// errPanelNotConnected is returned when writing to a panel that isn't
+// connected
+var errPanelNotConnected = errors.New("panel not connected")
+
 // PanelManager manages all connected panels
 type PanelManager struct {
 	radio  *fip.RadioPanel
@@ -25,6 +34,23 @@ type PanelManager struct {
 	multiConnected  bool
 	switchConnected bool
 	
+	simSource   *fip.MemorySimSource
+	syncService *fip.SyncService
+	
+	fipEmulator *FIPEmulator
+	
+	// Last values sent to each panel, as the panels can't be read back
+	radioDisplay fip.RadioDisplay
+	multiDisplay fip.MultiDisplay
+	switchLights fip.LandingGearLights
+	
+	// Last input reading of each connected panel, by control name
+	inputs map[string]map[string]bool
+	
+	hub       *LiveHub
+	done      chan struct{}
+	closeOnce sync.Once
+	
 	mu sync.RWMutex
 }
 
@@ -36,6 +62,7 @@ type PanelState struct {
 		COM1Standby string `json:"com1Standby"`
 		COM2Active string `json:"com2Active"`
 		COM2Standby string `json:"com2Standby"`
+		Inputs      map[string]bool `json:"inputs,omitempty"`
 	} `json:"radio"`
 	
 	Multi struct {
@@ -43,6 +70,7 @@ type PanelState struct {
 		TopRow    string `json:"topRow"`
 		BottomRow string `json:"bottomRow"`
 		LEDs      uint8  `json:"leds"`
+		Inputs    map[string]bool `json:"inputs,omitempty"`
 	} `json:"multi"`
 	
 	Switch struct {
@@ -55,22 +83,43 @@ type PanelState struct {
 			RedL   bool `json:"redL"`
 			RedR   bool `json:"redR"`
 		} `json:"lights"`
+		Inputs map[string]bool `json:"inputs,omitempty"`
 	} `json:"switch"`
 }
 
 // NewPanelManager creates a new panel manager
 func NewPanelManager() *PanelManager {
-	return &PanelManager{
+	pm := &PanelManager{
 		radio:  fip.NewRadioPanel(),
 		multi:  fip.NewMultiPanel(),
 		switch_: fip.NewSwitchPanel(),
+		simSource: fip.NewMemorySimSource(),
+		inputs:    make(map[string]map[string]bool),
+		hub:       NewLiveHub(),
+		done:      make(chan struct{}),
 	}
+	pm.syncService = fip.NewSyncService(pm.simSource, cachedSwitchPanel{pm}, cachedMultiPanel{pm})
+	
+	emulator, err := NewFIPEmulator()
+	if err != nil {
+		log.Fatal("Failed to create FIP emulator:", err)
+	}
+	emulator.OnInput(pm.publishFIP)
+	emulator.OnChange(pm.publishFIPState)
+	pm.fipEmulator = emulator
+	pm.syncService.SetDisplay(emulator)
+	return pm
 }
 
-// ConnectAll attempts to connect to all panels
+// ConnectAll attempts to connect to all panels and tells the live clients
+// about panels that connected or went away
 func (pm *PanelManager) ConnectAll() {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
+	before := map[string]bool{
+		"radio":  pm.radioConnected,
+		"multi":  pm.multiConnected,
+		"switch": pm.switchConnected,
+	}
 	
 	// Connect to radio panel
 	if err := pm.radio.Connect(); err != nil {
@@ -98,6 +147,25 @@ func (pm *PanelManager) ConnectAll() {
 		log.Printf("Successfully connected to switch panel")
 		pm.switchConnected = true
 	}
+	
+	// Reconnected panels start from a fresh input reading
+	pm.inputs = make(map[string]map[string]bool)
+	after := map[string]bool{
+		"radio":  pm.radioConnected,
+		"multi":  pm.multiConnected,
+		"switch": pm.switchConnected,
+	}
+	pm.mu.Unlock()
+	
+	// Bring the sim in line with the hardware positions at connect time
+	pm.syncService.Start(time.Second)
+	
+	for _, panel := range []string{"radio", "multi", "switch"} {
+		if before[panel] != after[panel] {
+			pm.hub.Publish(connectionMessage{Type: "connection", Panel: panel, Connected: after[panel]})
+		}
+	}
+	pm.publishState()
 }
 
 // GetState returns the current state of all panels
@@ -110,85 +178,149 @@ func (pm *PanelManager) GetState() PanelState {
 	// Radio panel state
 	state.Radio.Connected = pm.radioConnected
 	if pm.radioConnected {
-		// For now, we'll use default values - in a real app you'd cache the current display
-		state.Radio.COM1Active = "118.00"
-		state.Radio.COM1Standby = "118.50"
-		state.Radio.COM2Active = "121.30"
-		state.Radio.COM2Standby = "121.90"
+		state.Radio.COM1Active = pm.radioDisplay.COM1Active
+		state.Radio.COM1Standby = pm.radioDisplay.COM1Standby
+		state.Radio.COM2Active = pm.radioDisplay.COM2Active
+		state.Radio.COM2Standby = pm.radioDisplay.COM2Standby
+		state.Radio.Inputs = pm.inputs["radio"]
 	}
 	
 	// Multi panel state
 	state.Multi.Connected = pm.multiConnected
 	if pm.multiConnected {
-		state.Multi.TopRow = "0000"
-		state.Multi.BottomRow = "0000"
-		state.Multi.LEDs = 0
+		state.Multi.TopRow = pm.multiDisplay.TopRow
+		state.Multi.BottomRow = pm.multiDisplay.BottomRow
+		state.Multi.LEDs = pm.multiDisplay.ButtonLEDs
+		state.Multi.Inputs = pm.inputs["multi"]
 	}
 	
 	// Switch panel state
 	state.Switch.Connected = pm.switchConnected
 	if pm.switchConnected {
-		state.Switch.Lights.GreenN = false
-		state.Switch.Lights.GreenL = false
-		state.Switch.Lights.GreenR = false
-		state.Switch.Lights.RedN = false
-		state.Switch.Lights.RedL = false
-		state.Switch.Lights.RedR = false
+		state.Switch.Lights.GreenN = pm.switchLights.GreenN
+		state.Switch.Lights.GreenL = pm.switchLights.GreenL
+		state.Switch.Lights.GreenR = pm.switchLights.GreenR
+		state.Switch.Lights.RedN = pm.switchLights.RedN
+		state.Switch.Lights.RedL = pm.switchLights.RedL
+		state.Switch.Lights.RedR = pm.switchLights.RedR
+		state.Switch.Inputs = pm.inputs["switch"]
 	}
 	
 	return state
 }
 
+// RadioDisplay returns the values last sent to the radio panel
+func (pm *PanelManager) RadioDisplay() fip.RadioDisplay {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.radioDisplay
+}
+
+// MultiDisplay returns the values and LEDs last sent to the multi panel
+func (pm *PanelManager) MultiDisplay() fip.MultiDisplay {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.multiDisplay
+}
+
+// SwitchLights returns the landing gear lights last sent to the switch panel
+func (pm *PanelManager) SwitchLights() fip.LandingGearLights {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.switchLights
+}
+
+// Inputs returns the last input reading of a panel, or false before the
+// first reading or while it isn't connected
+func (pm *PanelManager) Inputs(panel string) (map[string]bool, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	inputs, ok := pm.inputs[panel]
+	return inputs, ok
+}
+
 // SetRadioDisplay sets the radio panel display
 func (pm *PanelManager) SetRadioDisplay(com1Active, com1Standby, com2Active, com2Standby string) error {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	
 	if !pm.radioConnected {
-		return fmt.Errorf("radio panel not connected")
+		pm.mu.Unlock()
+		return fmt.Errorf("radio %w", errPanelNotConnected)
+	}
+	if err := pm.radio.SetDisplay(com1Active, com1Standby, com2Active, com2Standby); err != nil {
+		pm.mu.Unlock()
+		return err
 	}
+	pm.radioDisplay = fip.RadioDisplay{
+		COM1Active:  fip.FormatFrequency(com1Active),
+		COM1Standby: fip.FormatFrequency(com1Standby),
+		COM2Active:  fip.FormatFrequency(com2Active),
+		COM2Standby: fip.FormatFrequency(com2Standby),
+	}
+	pm.mu.Unlock()
 	
-	return pm.radio.SetDisplay(com1Active, com1Standby, com2Active, com2Standby)
+	pm.publishState()
+	return nil
 }
 
 // SetMultiDisplay sets the multi panel display
 func (pm *PanelManager) SetMultiDisplay(topRow, bottomRow string, leds uint8) error {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	
 	if !pm.multiConnected {
-		return fmt.Errorf("multi panel not connected")
+		pm.mu.Unlock()
+		return fmt.Errorf("multi %w", errPanelNotConnected)
+	}
+	if err := pm.multi.SetDisplay(topRow, bottomRow, leds); err != nil {
+		pm.mu.Unlock()
+		return err
 	}
+	pm.multiDisplay = fip.MultiDisplay{
+		TopRow:     fip.FormatMultiValue(topRow),
+		BottomRow:  fip.FormatMultiValue(bottomRow),
+		ButtonLEDs: leds,
+	}
+	pm.mu.Unlock()
 	
-	return pm.multi.SetDisplay(topRow, bottomRow, leds)
+	pm.publishState()
+	return nil
 }
 
 // SetSwitchLights sets the switch panel landing gear lights
 func (pm *PanelManager) SetSwitchLights(lights fip.LandingGearLights) error {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	
 	if !pm.switchConnected {
-		return fmt.Errorf("switch panel not connected")
+		pm.mu.Unlock()
+		return fmt.Errorf("switch %w", errPanelNotConnected)
+	}
+	if err := pm.switch_.SetLandingGearLights(lights); err != nil {
+		pm.mu.Unlock()
+		return err
 	}
+	pm.switchLights = lights
+	pm.mu.Unlock()
 	
-	return pm.switch_.SetLandingGearLights(lights)
+	pm.publishState()
+	return nil
 }
 
-// Close closes all panels
+// Close closes all panels. Calls after the first do nothing.
 func (pm *PanelManager) Close() {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	
-	if pm.radio != nil {
-		pm.radio.Close()
-	}
-	if pm.multi != nil {
-		pm.multi.Close()
-	}
-	if pm.switch_ != nil {
-		pm.switch_.Close()
-	}
+	pm.closeOnce.Do(func() {
+		pm.mu.Lock()
+		defer pm.mu.Unlock()
+
+		close(pm.done)
+		pm.syncService.Stop()
+		pm.fipEmulator.Close()
+		if pm.radio != nil {
+			pm.radio.Close()
+		}
+		if pm.multi != nil {
+			pm.multi.Close()
+		}
+		if pm.switch_ != nil {
+			pm.switch_.Close()
+		}
+	})
 }
 
 // HTML template for the web interface
@@ -198,6 +330,7 @@ const htmlTemplate = `
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="csrf-token" content="{{.CSRFToken}}">
     <title>Saitek Controller</title>
     <style>
         body {
@@ -398,6 +531,18 @@ const htmlTemplate = `
             border: 1px solid #f5c6cb;
         }
         
+        .sync-table {
+            width: 100%;
+            border-collapse: collapse;
+            margin-top: 15px;
+        }
+        
+        .sync-table th, .sync-table td {
+            text-align: left;
+            padding: 6px 10px;
+            border-bottom: 1px solid #dee2e6;
+        }
+        
         .alert-info {
             background: #d1ecf1;
             color: #0c5460;
@@ -412,6 +557,129 @@ const htmlTemplate = `
             color: #6c757d;
         }
         
+        .fip-bezel {
+            display: grid;
+            grid-template-columns: 70px 340px 70px;
+            grid-template-rows: auto auto;
+            gap: 12px;
+            justify-content: center;
+            background: #1c1c1c;
+            border-radius: 18px;
+            padding: 20px;
+            margin: 0 auto;
+            width: fit-content;
+            user-select: none;
+        }
+        
+        .fip-screen {
+            width: 320px;
+            height: 240px;
+            border: 10px solid #000;
+            border-radius: 4px;
+            background: #000;
+            display: block;
+        }
+        
+        .fip-buttons {
+            display: flex;
+            flex-direction: column;
+            justify-content: space-between;
+            align-items: center;
+        }
+        
+        .fip-button {
+            position: relative;
+            width: 44px;
+            height: 30px;
+            border: none;
+            border-radius: 6px;
+            background: #3a3a3a;
+            color: #ddd;
+            font-weight: 600;
+            cursor: pointer;
+        }
+        
+        .fip-button:active, .fip-button.pressed {
+            background: #5a5a5a;
+        }
+        
+        .fip-led {
+            position: absolute;
+            left: -14px;
+            top: 11px;
+            width: 8px;
+            height: 8px;
+            border-radius: 50%;
+            background: #333;
+        }
+        
+        .fip-led.on {
+            background: #00ff00;
+            box-shadow: 0 0 8px #00ff00;
+        }
+        
+        .fip-dials {
+            grid-column: 1 / 4;
+            display: flex;
+            justify-content: space-between;
+            padding: 0 20px;
+        }
+        
+        .fip-dial {
+            width: 64px;
+            height: 64px;
+            border-radius: 50%;
+            background: radial-gradient(circle, #555 0%, #222 70%);
+            border: 3px solid #444;
+            cursor: pointer;
+            color: #aaa;
+            font-size: 11px;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        
+        .fip-events {
+            font-family: 'Courier New', monospace;
+            font-size: 13px;
+            background: #2c3e50;
+            color: #00ff00;
+            padding: 10px;
+            border-radius: 8px;
+            min-height: 60px;
+            white-space: pre;
+        }
+        
+        .fip-preview {
+            width: 320px;
+            height: 240px;
+            border: 1px solid #dee2e6;
+            border-radius: 4px;
+            background: #000;
+            display: block;
+            image-rendering: pixelated;
+        }
+        
+        .fip-fields {
+            display: grid;
+            grid-template-columns: repeat(2, 1fr);
+            gap: 10px;
+        }
+        
+        .live-inputs {
+            font-family: 'Courier New', monospace;
+            font-size: 13px;
+            color: #495057;
+            min-height: 18px;
+            margin-bottom: 15px;
+        }
+        
+        .live-status {
+            margin-left: auto;
+            color: #6c757d;
+            font-size: 0.9em;
+        }
+        
         @media (max-width: 768px) {
             .panel-grid {
                 grid-template-columns: 1fr;
@@ -445,6 +713,9 @@ const htmlTemplate = `
                         <div id="radio-status" class="status-indicator status-disconnected"></div>
                     </div>
                     
+                    <div id="radio-live" class="frequency-display">----- ----- / ----- -----</div>
+                    <div id="radio-inputs" class="live-inputs"></div>
+                    
                     <div class="form-group">
                         <label for="com1-active">COM1 Active Frequency:</label>
                         <input type="text" id="com1-active" class="form-control" value="118.00" placeholder="e.g., 118.00">
@@ -476,6 +747,9 @@ const htmlTemplate = `
                         <div id="multi-status" class="status-indicator status-disconnected"></div>
                     </div>
                     
+                    <div id="multi-live" class="frequency-display">----- / -----</div>
+                    <div id="multi-inputs" class="live-inputs"></div>
+                    
                     <div class="form-group">
                         <label for="multi-top">Top Row Display:</label>
                         <input type="text" id="multi-top" class="form-control" value="0000" placeholder="e.g., 0000">
@@ -535,6 +809,8 @@ const htmlTemplate = `
                         <div id="switch-status" class="status-indicator status-disconnected"></div>
                     </div>
                     
+                    <div id="switch-inputs" class="live-inputs"></div>
+                    
                     <div class="form-group">
                         <label>Landing Gear Lights:</label>
                         <div class="led-grid">
@@ -572,6 +848,157 @@ const htmlTemplate = `
                 </div>
             </div>
             
+            <!-- Live Input Events -->
+            <div class="panel" style="margin-top: 30px;">
+                <div class="panel-header">
+                    <h2 class="panel-title">Panel Inputs</h2>
+                    <span id="live-status" class="live-status">Connecting...</span>
+                </div>
+                
+                <div id="panel-events" class="fip-events"></div>
+            </div>
+            
+            <!-- Sim Synchronization -->
+            <div class="panel" style="margin-top: 30px;">
+                <div class="panel-header">
+                    <h2 class="panel-title">Sim Synchronization</h2>
+                    <div id="sync-status" class="status-indicator status-disconnected"></div>
+                </div>
+                
+                <p id="sync-summary">Not checked yet</p>
+                <table class="sync-table">
+                    <thead>
+                        <tr><th>Panel</th><th>Control</th><th>Hardware</th><th>Sim</th></tr>
+                    </thead>
+                    <tbody id="sync-mismatches"></tbody>
+                </table>
+                
+                <div style="margin-top: 15px;">
+                    <button class="btn" onclick="runSync('wait')">Check Switches</button>
+                    <button class="btn btn-warning" onclick="runSync('push')">Push Hardware to Sim</button>
+                </div>
+            </div>
+            
+            <!-- FIP Emulator -->
+            <div class="panel" style="margin-top: 30px;">
+                <div class="panel-header">
+                    <h2 class="panel-title">FIP Emulator</h2>
+                    <span id="fip-page" style="margin-left: auto; color: #6c757d;"></span>
+                </div>
+                
+                <div class="fip-bezel">
+                    <div class="fip-buttons">
+                        <button class="fip-button" data-control="S1"><span class="fip-led" id="fip-led-0"></span>S1</button>
+                        <button class="fip-button" data-control="S2"><span class="fip-led" id="fip-led-1"></span>S2</button>
+                        <button class="fip-button" data-control="S3"><span class="fip-led" id="fip-led-2"></span>S3</button>
+                        <button class="fip-button" data-control="S4"><span class="fip-led" id="fip-led-3"></span>S4</button>
+                        <button class="fip-button" data-control="S5"><span class="fip-led" id="fip-led-4"></span>S5</button>
+                        <button class="fip-button" data-control="S6"><span class="fip-led" id="fip-led-5"></span>S6</button>
+                    </div>
+                    <img class="fip-screen" src="/api/fip/stream" alt="FIP display">
+                    <div class="fip-buttons" style="justify-content: flex-start; gap: 12px;">
+                        <button class="fip-button" data-control="PageUp">&#9650;</button>
+                        <button class="fip-button" data-control="PageDown">&#9660;</button>
+                    </div>
+                    <div class="fip-dials">
+                        <div class="fip-dial" data-dial="LeftDial" title="Click the left/right half or scroll to turn">LEFT</div>
+                        <div class="fip-dial" data-dial="RightDial" title="Click the left/right half or scroll to turn">RIGHT</div>
+                    </div>
+                </div>
+                
+                <div class="form-group" style="margin-top: 15px;">
+                    <label>Input Events:</label>
+                    <div id="fip-events" class="fip-events"></div>
+                </div>
+            </div>
+            
+            <!-- FIP Images, Instruments and LEDs -->
+            <div class="panel" style="margin-top: 30px;">
+                <div class="panel-header">
+                    <h2 class="panel-title">FIP Control</h2>
+                    <a href="/api/fip/frame.png" download="fip.png" style="margin-left: auto;">Save Frame as PNG</a>
+                </div>
+                
+                <div class="panel-grid" style="margin-bottom: 0;">
+                    <div>
+                        <div class="form-group">
+                            <label>Current Frame:</label>
+                            <img id="fip-preview" class="fip-preview" src="/api/fip/frame.png" alt="Current FIP frame">
+                        </div>
+                        
+                        <div class="form-group">
+                            <label for="fip-image">Image:</label>
+                            <input type="file" id="fip-image" class="form-control" accept="image/png,image/jpeg,image/gif,image/bmp">
+                        </div>
+                        
+                        <div class="form-group">
+                            <label for="fip-resize-mode">Resize Mode:</label>
+                            <select id="fip-resize-mode" class="form-control">
+                                <option value="fit">Fit (keep aspect, pad)</option>
+                                <option value="crop">Crop (keep aspect, fill)</option>
+                                <option value="stretch">Stretch</option>
+                                <option value="center">Center (no scaling)</option>
+                            </select>
+                        </div>
+                        
+                        <button class="btn" onclick="uploadFIPImage()">Upload Image</button>
+                    </div>
+                    
+                    <div>
+                        <div class="form-group">
+                            <label for="fip-instrument">Instrument:</label>
+                            <select id="fip-instrument" class="form-control" onchange="renderFIPFields()">
+                                <option value="artificial_horizon">Attitude</option>
+                                <option value="airspeed">Airspeed</option>
+                                <option value="altimeter">Altimeter</option>
+                                <option value="compass">Heading</option>
+                                <option value="vsi">Vertical Speed</option>
+                                <option value="turn_coordinator">Turn Coordinator</option>
+                            </select>
+                        </div>
+                        
+                        <div id="fip-fields" class="form-group fip-fields"></div>
+                        
+                        <div class="form-group led-item">
+                            <input type="checkbox" id="fip-live" class="led-checkbox" checked>
+                            <label for="fip-live" style="display: inline; margin: 0;">Update as values change</label>
+                        </div>
+                        
+                        <button class="btn" onclick="showFIPInstrument()">Show Instrument</button>
+                        
+                        <div class="form-group" style="margin-top: 20px;">
+                            <label>Soft Button LEDs (active page):</label>
+                            <div class="led-grid">
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-0" class="led-checkbox" data-button="S1">
+                                    <label for="fip-set-led-0">S1</label>
+                                </div>
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-1" class="led-checkbox" data-button="S2">
+                                    <label for="fip-set-led-1">S2</label>
+                                </div>
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-2" class="led-checkbox" data-button="S3">
+                                    <label for="fip-set-led-2">S3</label>
+                                </div>
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-3" class="led-checkbox" data-button="S4">
+                                    <label for="fip-set-led-3">S4</label>
+                                </div>
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-4" class="led-checkbox" data-button="S5">
+                                    <label for="fip-set-led-4">S5</label>
+                                </div>
+                                <div class="led-item">
+                                    <input type="checkbox" id="fip-set-led-5" class="led-checkbox" data-button="S6">
+                                    <label for="fip-set-led-5">S6</label>
+                                </div>
+                            </div>
+                        </div>
+                    </div>
+                </div>
+            </div>
+            
             <div style="text-align: center; margin-top: 30px;">
                 <button class="btn" onclick="refreshStatus()">Refresh Status</button>
                 <button class="btn btn-secondary" onclick="connectAll()">Reconnect All</button>
@@ -606,46 +1033,154 @@ const htmlTemplate = `
             }, 5000);
         }
         
+        // Number of input events listed under Panel Inputs
+        const PANEL_EVENT_HISTORY = 15;
+        const panelEvents = [];
+        
+        // activeInputs lists the switches and buttons that are on
+        function activeInputs(inputs) {
+            return Object.keys(inputs || {})
+                .filter(name => inputs[name] && !name.endsWith('_CW') && !name.endsWith('_CCW'))
+                .sort()
+                .join(' ');
+        }
+        
+        function renderState(data) {
+            // Update radio panel status
+            const radioStatus = document.getElementById('radio-status');
+            radioStatus.className = 'status-indicator ' + (data.radio.connected ? 'status-connected' : 'status-disconnected');
+            
+            // Update multi panel status
+            const multiStatus = document.getElementById('multi-status');
+            multiStatus.className = 'status-indicator ' + (data.multi.connected ? 'status-connected' : 'status-disconnected');
+            
+            // Update switch panel status
+            const switchStatus = document.getElementById('switch-status');
+            switchStatus.className = 'status-indicator ' + (data.switch.connected ? 'status-connected' : 'status-disconnected');
+            
+            // Show what the panels are displaying and which controls are on
+            const show = value => value || '-----';
+            document.getElementById('radio-live').textContent = data.radio.connected ?
+                show(data.radio.com1Active) + ' ' + show(data.radio.com1Standby) + ' / ' +
+                show(data.radio.com2Active) + ' ' + show(data.radio.com2Standby) : 'Not connected';
+            document.getElementById('multi-live').textContent = data.multi.connected ?
+                show(data.multi.topRow) + ' / ' + show(data.multi.bottomRow) : 'Not connected';
+            document.getElementById('radio-inputs').textContent = activeInputs(data.radio.inputs);
+            document.getElementById('multi-inputs').textContent = activeInputs(data.multi.inputs);
+            document.getElementById('switch-inputs').textContent = activeInputs(data.switch.inputs);
+        }
+        
+        function renderInput(input) {
+            const time = new Date(input.time).toLocaleTimeString();
+            let text = input.panel + ' ' + input.control;
+            if (input.kind === 'encoder') {
+                text += ' ' + input.direction.toUpperCase();
+            } else if (input.kind === 'button') {
+                text += input.on ? ' pressed' : ' released';
+            } else {
+                text += input.on ? ' on' : ' off';
+            }
+            panelEvents.unshift(time + ' ' + text);
+            panelEvents.length = Math.min(panelEvents.length, PANEL_EVENT_HISTORY);
+            document.getElementById('panel-events').textContent = panelEvents.join('\n');
+        }
+        
         function updateStatus() {
             fetch('/api/status')
                 .then(response => response.json())
-                .then(data => {
-                    // Update radio panel status
-                    const radioStatus = document.getElementById('radio-status');
-                    radioStatus.className = 'status-indicator ' + (data.radio.connected ? 'status-connected' : 'status-disconnected');
-                    
-                    // Update multi panel status
-                    const multiStatus = document.getElementById('multi-status');
-                    multiStatus.className = 'status-indicator ' + (data.multi.connected ? 'status-connected' : 'status-disconnected');
-                    
-                    // Update switch panel status
-                    const switchStatus = document.getElementById('switch-status');
-                    switchStatus.className = 'status-indicator ' + (data.switch.connected ? 'status-connected' : 'status-disconnected');
-                })
+                .then(renderState)
                 .catch(error => {
                     console.error('Error fetching status:', error);
                 });
         }
         
-        function setRadioDisplay() {
-            const com1Active = document.getElementById('com1-active').value;
-            const com1Standby = document.getElementById('com1-standby').value;
-            const com2Active = document.getElementById('com2-active').value;
-            const com2Standby = document.getElementById('com2-standby').value;
+        // csrfToken goes with every request that changes state
+        const csrfToken = document.querySelector('meta[name="csrf-token"]').content;
+        
+        // The live socket pushes state, connection changes and input events
+        // and carries commands. While it is down the page polls instead.
+        let socket = null;
+        let nextCommandID = 1;
+        const pendingCommands = {};
+        
+        function connectLive() {
+            const scheme = location.protocol === 'https:' ? 'wss://' : 'ws://';
+            const ws = new WebSocket(scheme + location.host + '/api/ws');
             
-            fetch('/api/radio/set', {
+            ws.onopen = () => {
+                socket = ws;
+                document.getElementById('live-status').textContent = 'Live';
+            };
+            ws.onmessage = event => {
+                const message = JSON.parse(event.data);
+                switch (message.type) {
+                case 'state':
+                    renderState(message.state);
+                    break;
+                case 'connection':
+                    showAlert(message.panel + ' panel ' + (message.connected ? 'connected' : 'disconnected'),
+                        message.connected ? 'success' : 'danger');
+                    break;
+                case 'input':
+                    renderInput(message.input);
+                    break;
+                case 'fip':
+                    renderFIP(message.fip);
+                    break;
+                case 'result': {
+                    const pending = pendingCommands[message.id];
+                    delete pendingCommands[message.id];
+                    if (pending) {
+                        pending(message);
+                    }
+                    break;
+                }
+                }
+            };
+            ws.onclose = () => {
+                socket = null;
+                document.getElementById('live-status').textContent = 'Reconnecting...';
+                Object.keys(pendingCommands).forEach(id => {
+                    pendingCommands[id]({ success: false, error: 'connection lost' });
+                    delete pendingCommands[id];
+                });
+                setTimeout(connectLive, 2000);
+            };
+        }
+        
+        // sendCommand sends a command over the live socket, or to the REST
+        // endpoint while the socket is down, and resolves to the result
+        function sendCommand(type, data, url) {
+            if (socket && socket.readyState === WebSocket.OPEN) {
+                const id = nextCommandID++;
+                return new Promise(resolve => {
+                    pendingCommands[id] = resolve;
+                    socket.send(JSON.stringify({ id: id, type: type, data: data }));
+                });
+            }
+            return fetch(url, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    'X-CSRF-Token': csrfToken,
                 },
-                body: JSON.stringify({
-                    com1Active: com1Active,
-                    com1Standby: com1Standby,
-                    com2Active: com2Active,
-                    com2Standby: com2Standby
-                })
+                body: JSON.stringify(data)
             })
-            .then(response => response.json())
+            .then(response => response.json().catch(() => ({ success: false, error: response.statusText })));
+        }
+        
+        function setRadioDisplay() {
+            const com1Active = document.getElementById('com1-active').value;
+            const com1Standby = document.getElementById('com1-standby').value;
+            const com2Active = document.getElementById('com2-active').value;
+            const com2Standby = document.getElementById('com2-standby').value;
+            
+            sendCommand('radio', {
+                com1Active: com1Active,
+                com1Standby: com1Standby,
+                com2Active: com2Active,
+                com2Standby: com2Standby
+            }, '/api/radio/set')
             .then(data => {
                 if (data.success) {
                     showAlert('Radio display updated successfully!', 'success');
@@ -681,18 +1216,11 @@ const htmlTemplate = `
             if (document.getElementById('led-apr').checked) leds |= LED_APR;
             if (document.getElementById('led-rev').checked) leds |= LED_REV;
             
-            fetch('/api/multi/set', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify({
-                    topRow: topRow,
-                    bottomRow: bottomRow,
-                    leds: leds
-                })
-            })
-            .then(response => response.json())
+            sendCommand('multi', {
+                topRow: topRow,
+                bottomRow: bottomRow,
+                leds: leds
+            }, '/api/multi/set')
             .then(data => {
                 if (data.success) {
                     showAlert('Multi panel display updated successfully!', 'success');
@@ -727,14 +1255,7 @@ const htmlTemplate = `
                 redR: document.getElementById('light-red-r').checked
             };
             
-            fetch('/api/switch/set', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify(lights)
-            })
-            .then(response => response.json())
+            sendCommand('switch', lights, '/api/switch/set')
             .then(data => {
                 if (data.success) {
                     showAlert('Switch panel lights updated successfully!', 'success');
@@ -791,16 +1312,70 @@ const htmlTemplate = `
             setSwitchLights();
         }
         
+        function renderSync(data) {
+            const syncStatus = document.getElementById('sync-status');
+            syncStatus.className = 'status-indicator ' + (data.inSync ? 'status-connected' : 'status-disconnected');
+            
+            const summary = document.getElementById('sync-summary');
+            if (!data.lastSync) {
+                summary.textContent = 'Not checked yet';
+            } else if (data.inSync) {
+                summary.textContent = 'Panels match the sim';
+            } else {
+                summary.textContent = data.mismatches.length + ' control(s) differ from the sim - move the switches or push the hardware state';
+            }
+            
+            const body = document.getElementById('sync-mismatches');
+            body.innerHTML = '';
+            (data.mismatches || []).forEach(m => {
+                const row = document.createElement('tr');
+                [m.panel, m.control, m.hardware, m.sim].forEach(value => {
+                    const cell = document.createElement('td');
+                    cell.textContent = value;
+                    row.appendChild(cell);
+                });
+                body.appendChild(row);
+            });
+        }
+        
+        function updateSync() {
+            fetch('/api/sync')
+                .then(response => response.json())
+                .then(renderSync)
+                .catch(error => {
+                    console.error('Error fetching sync state:', error);
+                });
+        }
+        
+        function runSync(mode) {
+            fetch('/api/sync', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': csrfToken,
+                },
+                body: JSON.stringify({ mode: mode })
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.success) {
+                    renderSync(data);
+                } else {
+                    showAlert('Sync failed: ' + data.error, 'danger');
+                }
+            })
+            .catch(error => {
+                showAlert('Error running sync: ' + error.message, 'danger');
+            });
+        }
+        
         function refreshStatus() {
             updateStatus();
             showAlert('Status refreshed', 'info');
         }
         
         function connectAll() {
-            fetch('/api/connect', {
-                method: 'POST'
-            })
-            .then(response => response.json())
+            sendCommand('connect', {}, '/api/connect')
             .then(data => {
                 if (data.success) {
                     showAlert('Reconnected to all panels', 'success');
@@ -814,32 +1389,277 @@ const htmlTemplate = `
             });
         }
         
-        // Update status every 5 seconds
-        setInterval(updateStatus, 5000);
+        function sendFIPInput(input) {
+            sendCommand('fipInput', input, '/api/fip/input')
+            .then(data => {
+                if (!socket) {
+                    updateFIP();
+                }
+                if (!data.success) {
+                    console.error('Error sending FIP input:', data.error);
+                }
+            })
+            .catch(error => {
+                console.error('Error sending FIP input:', error);
+            });
+        }
+        
+        function renderFIP(data) {
+            data.leds.forEach((on, i) => {
+                document.getElementById('fip-led-' + i).className = 'fip-led' + (on ? ' on' : '');
+            });
+            const page = data.pages.find(p => p.id === data.activePage);
+            document.getElementById('fip-page').textContent = page ? 'Page ' + page.id + ': ' + page.name : '';
+            document.getElementById('fip-events').textContent = data.events.slice().reverse().join('\n');
+            renderFIPControl(data);
+        }
+        
+        // Instrument values each instrument shows, with the step of their
+        // input, keyed by instrument name
+        const FIP_FIELDS = {
+            artificial_horizon: [['Pitch', 1], ['Roll', 1]],
+            airspeed: [['Airspeed', 1]],
+            altimeter: [['Altitude', 10], ['Pressure', 0.01]],
+            compass: [['Heading', 1], ['HeadingBug', 1]],
+            vsi: [['VerticalSpeed', 100]],
+            turn_coordinator: [['TurnRate', 0.5], ['Slip', 1]]
+        };
+        let fipData = {};
+        
+        function renderFIPFields() {
+            const instrument = document.getElementById('fip-instrument').value;
+            const fields = document.getElementById('fip-fields');
+            fields.innerHTML = '';
+            FIP_FIELDS[instrument].forEach(([name, step]) => {
+                const field = document.createElement('div');
+                const label = document.createElement('label');
+                label.htmlFor = 'fip-field-' + name;
+                label.textContent = name + ':';
+                const input = document.createElement('input');
+                input.type = 'number';
+                input.id = 'fip-field-' + name;
+                input.className = 'form-control';
+                input.step = step;
+                input.dataset.field = name;
+                input.value = fipData[name] !== undefined ? fipData[name] : 0;
+                input.addEventListener('input', () => {
+                    if (document.getElementById('fip-live').checked) {
+                        showFIPInstrument();
+                    }
+                });
+                field.appendChild(label);
+                field.appendChild(input);
+                fields.appendChild(field);
+            });
+        }
+        
+        // renderFIPControl shows the current values, LEDs and frame, leaving
+        // the field being edited alone
+        function renderFIPControl(data) {
+            fipData = data.data;
+            document.querySelectorAll('#fip-fields input').forEach(input => {
+                if (input !== document.activeElement) {
+                    input.value = fipData[input.dataset.field];
+                }
+            });
+            data.leds.forEach((on, i) => {
+                document.getElementById('fip-set-led-' + i).checked = on;
+            });
+            document.getElementById('fip-preview').src = '/api/fip/frame.png?frame=' + data.frames;
+        }
+        
+        function showFIPInstrument() {
+            const values = {};
+            document.querySelectorAll('#fip-fields input').forEach(input => {
+                if (input.value !== '' && !isNaN(Number(input.value))) {
+                    values[input.dataset.field] = Number(input.value);
+                }
+            });
+            const request = { instrument: document.getElementById('fip-instrument').value, data: values };
+            sendCommand('fipInstrument', request, '/api/fip/instrument')
+            .then(data => {
+                if (!socket) {
+                    updateFIP();
+                }
+                if (!data.success) {
+                    showAlert('Error showing instrument: ' + data.error, 'danger');
+                }
+            })
+            .catch(error => {
+                showAlert('Error showing instrument: ' + error.message, 'danger');
+            });
+        }
+        
+        function uploadFIPImage() {
+            const file = document.getElementById('fip-image').files[0];
+            if (!file) {
+                showAlert('Choose an image first', 'danger');
+                return;
+            }
+            const form = new FormData();
+            form.append('image', file);
+            form.append('mode', document.getElementById('fip-resize-mode').value);
+            fetch('/api/fip/image', {
+                method: 'POST',
+                headers: {
+                    'X-CSRF-Token': csrfToken,
+                },
+                body: form
+            })
+            .then(response => response.json().catch(() => response.text().then(text => ({ success: false, error: text || response.statusText }))))
+            .then(data => {
+                if (data.success) {
+                    showAlert('Image shown (' + data.width + 'x' + data.height + ' ' + data.format + ', ' + data.mode + ')', 'success');
+                    if (!socket) {
+                        updateFIP();
+                    }
+                } else {
+                    showAlert('Upload failed: ' + data.error, 'danger');
+                }
+            })
+            .catch(error => {
+                showAlert('Upload failed: ' + error.message, 'danger');
+            });
+        }
+        
+        // Each LED checkbox sets its LED on the active page
+        document.querySelectorAll('[id^="fip-set-led-"]').forEach(checkbox => {
+            checkbox.addEventListener('change', () => {
+                const leds = {};
+                leds[checkbox.dataset.button] = checkbox.checked;
+                sendCommand('fipLEDs', { leds: leds }, '/api/fip/leds')
+                .then(data => {
+                    if (!socket) {
+                        updateFIP();
+                    }
+                    if (!data.success) {
+                        showAlert('Error setting FIP LED: ' + data.error, 'danger');
+                    }
+                });
+            });
+        });
+        
+        function updateFIP() {
+            fetch('/api/fip/state')
+                .then(response => response.json())
+                .then(renderFIP)
+                .catch(error => {
+                    console.error('Error fetching FIP state:', error);
+                });
+        }
+        
+        // Buttons send a press on pointer down and a release on pointer up,
+        // like the real panel
+        document.querySelectorAll('.fip-button').forEach(button => {
+            const control = button.dataset.control;
+            const release = () => {
+                if (button.classList.contains('pressed')) {
+                    button.classList.remove('pressed');
+                    sendFIPInput({ control: control, pressed: false });
+                }
+            };
+            button.addEventListener('pointerdown', event => {
+                event.preventDefault();
+                button.classList.add('pressed');
+                sendFIPInput({ control: control, pressed: true });
+            });
+            button.addEventListener('pointerup', release);
+            button.addEventListener('pointerleave', release);
+        });
+        
+        // Dials turn one detent per click (left half counter-clockwise,
+        // right half clockwise) or per scroll step
+        document.querySelectorAll('.fip-dial').forEach(dial => {
+            const name = dial.dataset.dial;
+            dial.addEventListener('click', event => {
+                const rect = dial.getBoundingClientRect();
+                const cw = event.clientX >= rect.left + rect.width / 2;
+                sendFIPInput({ control: name + (cw ? 'CW' : 'CCW'), count: 1 });
+            });
+            dial.addEventListener('wheel', event => {
+                event.preventDefault();
+                sendFIPInput({ control: name + (event.deltaY < 0 ? 'CW' : 'CCW'), count: 1 });
+            }, { passive: false });
+        });
+        
+        // Poll only while the live socket is down
+        setInterval(() => { if (!socket) updateStatus(); }, 5000);
+        setInterval(updateSync, 2000);
+        setInterval(() => { if (!socket) updateFIP(); }, 2000);
         
         // Initial status update
         updateStatus();
+        updateSync();
+        renderFIPFields();
+        updateFIP();
+        connectLive();
     </script>
 </body>
 </html>
 `
 
+// radioRequest is the body of a radio display update
+type radioRequest struct {
+	COM1Active  string `json:"com1Active"`
+	COM1Standby string `json:"com1Standby"`
+	COM2Active  string `json:"com2Active"`
+	COM2Standby string `json:"com2Standby"`
+}
+
+// multiRequest is the body of a multi panel display update
+type multiRequest struct {
+	TopRow    string `json:"topRow"`
+	BottomRow string `json:"bottomRow"`
+	LEDs      uint8  `json:"leds"`
+}
+
+// switchRequest is the body of a landing gear lights update
+type switchRequest struct {
+	GreenN bool `json:"greenN"`
+	GreenL bool `json:"greenL"`
+	GreenR bool `json:"greenR"`
+	RedN   bool `json:"redN"`
+	RedL   bool `json:"redL"`
+	RedR   bool `json:"redR"`
+}
+
+// lights returns the requested landing gear lights
+func (r switchRequest) lights() fip.LandingGearLights {
+	return fip.LandingGearLights{
+		GreenN: r.GreenN,
+		GreenL: r.GreenL,
+		GreenR: r.GreenR,
+		RedN:   r.RedN,
+		RedL:   r.RedL,
+		RedR:   r.RedR,
+	}
+}
+
 // Server handles the web interface
 type Server struct {
 	panelManager *PanelManager
+	security     *Security
 	template     *template.Template
+	openAPIJSON  []byte
 }
 
 // NewServer creates a new server instance
-func NewServer(pm *PanelManager) *Server {
+func NewServer(pm *PanelManager, security *Security) *Server {
 	tmpl, err := template.New("index").Parse(htmlTemplate)
 	if err != nil {
 		log.Fatal("Failed to parse template:", err)
 	}
 	
+	openAPIJSON, err := openAPIToJSON(openAPIYAML)
+	if err != nil {
+		log.Fatal("Failed to load OpenAPI document:", err)
+	}
+	
 	return &Server{
 		panelManager: pm,
+		security:     security,
 		template:     tmpl,
+		openAPIJSON:  openAPIJSON,
 	}
 }
 
@@ -851,7 +1671,9 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	w.Header().Set("Content-Type", "text/html")
-	s.template.Execute(w, nil)
+	s.template.Execute(w, struct{ CSRFToken string }{
+		CSRFToken: s.security.CSRFToken(requestIdentity(r)),
+	})
 }
 
 // handleStatus returns the current status of all panels
@@ -874,12 +1696,7 @@ func (s *Server) handleRadioSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	var request struct {
-		COM1Active  string `json:"com1Active"`
-		COM1Standby string `json:"com1Standby"`
-		COM2Active  string `json:"com2Active"`
-		COM2Standby string `json:"com2Standby"`
-	}
+	var request radioRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -888,17 +1705,7 @@ func (s *Server) handleRadioSet(w http.ResponseWriter, r *http.Request) {
 	
 	err := s.panelManager.SetRadioDisplay(request.COM1Active, request.COM1Standby, request.COM2Active, request.COM2Standby)
 	
-	w.Header().Set("Content-Type", "application/json")
-	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
-	} else {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-		})
-	}
+	writeResult(w, err)
 }
 
 // handleMultiSet handles setting the multi panel display
@@ -908,11 +1715,7 @@ func (s *Server) handleMultiSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	var request struct {
-		TopRow    string `json:"topRow"`
-		BottomRow string `json:"bottomRow"`
-		LEDs      uint8  `json:"leds"`
-	}
+	var request multiRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -921,17 +1724,7 @@ func (s *Server) handleMultiSet(w http.ResponseWriter, r *http.Request) {
 	
 	err := s.panelManager.SetMultiDisplay(request.TopRow, request.BottomRow, request.LEDs)
 	
-	w.Header().Set("Content-Type", "application/json")
-	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
-	} else {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-		})
-	}
+	writeResult(w, err)
 }
 
 // handleSwitchSet handles setting the switch panel lights
@@ -941,52 +1734,130 @@ func (s *Server) handleSwitchSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	var request struct {
-		GreenN bool `json:"greenN"`
-		GreenL bool `json:"greenL"`
-		GreenR bool `json:"greenR"`
-		RedN   bool `json:"redN"`
-		RedL   bool `json:"redL"`
-		RedR   bool `json:"redR"`
-	}
+	var request switchRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 	
-	lights := fip.LandingGearLights{
-		GreenN: request.GreenN,
-		GreenL: request.GreenL,
-		GreenR: request.GreenR,
-		RedN:   request.RedN,
-		RedL:   request.RedL,
-		RedR:   request.RedR,
+	err := s.panelManager.SetSwitchLights(request.lights())
+	
+	writeResult(w, err)
+}
+
+// handleConnect handles reconnecting to all panels
+func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 	
-	err := s.panelManager.SetSwitchLights(lights)
+	s.panelManager.ConnectAll()
 	
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// writeResult writes the success or error body of the POST endpoints, with
+// 503 for a panel that isn't connected and 500 for other errors
+func writeResult(w http.ResponseWriter, err error) {
 	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		writeJSON(w, panelErrorStatus(err), map[string]interface{}{
 			"success": false,
 			"error":   err.Error(),
 		})
-	} else {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-		})
+		return
 	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
 }
 
-// handleConnect handles reconnecting to all panels
-func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
+// syncResponse builds the JSON body describing a sync result
+func syncResponse(mode fip.SyncMode, result fip.SyncResult) map[string]interface{} {
+	response := map[string]interface{}{
+		"success":    true,
+		"mode":       mode.String(),
+		"inSync":     result.InSync(),
+		"pushed":     result.Pushed,
+		"mismatches": []fip.Mismatch{},
+		"lastSync":   nil,
+	}
+	if !result.Pushed && len(result.Mismatches) > 0 {
+		response["mismatches"] = result.Mismatches
+	}
+	if !result.Time.IsZero() {
+		response["lastSync"] = result.Time
+	}
+	return response
+}
+
+// handleSync returns the last sync result (GET) or runs a sync pass (POST)
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	service := s.panelManager.syncService
+	
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(syncResponse(service.GetMode(), service.LastResult()))
+		
+	case "POST":
+		var request struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		
+		if request.Mode != "" {
+			mode, err := fip.ParseSyncMode(request.Mode)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			service.SetMode(mode)
+		}
+		
+		result, err := service.Sync()
+		if err != nil {
+			writeResult(w, err)
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(syncResponse(service.GetMode(), result))
+		
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSyncTarget lets a sim bridge publish the sim's current control positions
+func (s *Server) handleSyncTarget(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	
-	s.panelManager.ConnectAll()
+	var request struct {
+		Switch        *fip.SwitchState `json:"switch"`
+		MultiSelector string           `json:"multiSelector"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	
+	s.panelManager.simSource.SetTarget(fip.PanelSnapshot{
+		Switch:        request.Switch,
+		MultiSelector: request.MultiSelector,
+		Taken:         time.Now(),
+	})
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -996,28 +1867,84 @@ func (s *Server) handleConnect(w http.ResponseWriter, r *http.Request) {
 
 func main() {
 	var (
-		port = flag.String("port", "8080", "Port to listen on")
-		host = flag.String("host", "localhost", "Host to bind to (use 0.0.0.0 for network access)")
+		port        = flag.String("port", "8080", "Port to listen on")
+		host        = flag.String("host", "localhost", "Host to bind to (use 0.0.0.0 for network access)")
+		authFile    = flag.String("auth", "", "YAML or JSON file with API tokens and users (default: no authentication)")
+		useTLS      = flag.Bool("tls", false, "Serve HTTPS with a self-signed certificate")
+		tlsCert     = flag.String("tls-cert", "", "TLS certificate file (implies -tls)")
+		tlsKey      = flag.String("tls-key", "", "TLS key file (implies -tls)")
+		corsOrigins = flag.String("cors-origins", "", "Comma separated origins allowed cross-origin access, or *")
 	)
 	flag.Parse()
 	
+	// Set up authentication, CORS and CSRF protection
+	var authConfig *AuthConfig
+	if *authFile != "" {
+		config, err := LoadAuthConfig(*authFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		authConfig = config
+	}
+	var origins []string
+	if *corsOrigins != "" {
+		origins = strings.Split(*corsOrigins, ",")
+	}
+	security, err := NewSecurity(authConfig, origins)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if !security.Enabled() && *host != "localhost" && *host != "127.0.0.1" {
+		log.Printf("Warning: no -auth file, anyone who can reach %s can control the panels", *host)
+	}
+	
+	var tlsConfig *tls.Config
+	if *useTLS || *tlsCert != "" || *tlsKey != "" {
+		dir := ""
+		if *tlsCert == "" && *tlsKey == "" {
+			config, err := os.UserConfigDir()
+			if err != nil {
+				log.Fatalf("Error: %v (use -tls-cert and -tls-key)", err)
+			}
+			dir = filepath.Join(config, "saitek-controller")
+		}
+		tlsConfig, err = loadTLSConfig(*tlsCert, *tlsKey, dir, certificateHosts(*host))
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	
 	// Create panel manager
 	panelManager := NewPanelManager()
 	
 	// Connect to all panels
 	fmt.Println("Connecting to Saitek panels...")
 	panelManager.ConnectAll()
+	panelManager.StartInputWatch(inputPollInterval)
 	
 	// Create server
-	server := NewServer(panelManager)
+	server := NewServer(panelManager, security)
 	
 	// Set up routes
-	http.HandleFunc("/", server.handleIndex)
-	http.HandleFunc("/api/status", server.handleStatus)
-	http.HandleFunc("/api/radio/set", server.handleRadioSet)
-	http.HandleFunc("/api/multi/set", server.handleMultiSet)
-	http.HandleFunc("/api/switch/set", server.handleSwitchSet)
-	http.HandleFunc("/api/connect", server.handleConnect)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handleIndex)
+	mux.HandleFunc("/api/status", server.handleStatus)
+	mux.HandleFunc("/api/radio/set", server.handleRadioSet)
+	mux.HandleFunc("/api/multi/set", server.handleMultiSet)
+	mux.HandleFunc("/api/switch/set", server.handleSwitchSet)
+	mux.HandleFunc("/api/connect", server.handleConnect)
+	mux.HandleFunc("/api/sync", server.handleSync)
+	mux.HandleFunc("/api/sync/target", server.handleSyncTarget)
+	mux.HandleFunc("/api/fip/state", server.handleFIPState)
+	mux.HandleFunc("/api/fip/stream", server.handleFIPStream)
+	mux.HandleFunc("/api/fip/frame", server.handleFIPFrame)
+	mux.HandleFunc("/api/fip/frame.png", server.handleFIPFramePNG)
+	mux.HandleFunc("/api/fip/image", server.handleFIPImage)
+	mux.HandleFunc("/api/fip/instrument", server.handleFIPInstrument)
+	mux.HandleFunc("/api/fip/leds", server.handleFIPLEDs)
+	mux.HandleFunc("/api/fip/input", server.handleFIPInput)
+	mux.HandleFunc("/api/ws", server.handleWebSocket)
+	mux.HandleFunc("/api/v1/", server.handleAPIv1)
 	
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -1026,14 +1953,32 @@ func main() {
 	// Start server in a goroutine
 	go func() {
 		addr := *host + ":" + *port
-		fmt.Printf("Starting Saitek Controller GUI on http://%s\n", addr)
+		scheme := "http"
+		if tlsConfig != nil {
+			scheme = "https"
+		}
+		fmt.Printf("Starting Saitek Controller GUI on %s://%s\n", scheme, addr)
 		if *host == "0.0.0.0" {
 			fmt.Printf("Network access enabled - other computers can connect\n")
 		} else {
 			fmt.Printf("Local access only\n")
 		}
+		if security.Enabled() {
+			fmt.Printf("Authentication required\n")
+		}
 		fmt.Printf("Open your web browser and navigate to the URL above\n")
-		if err := http.ListenAndServe(addr, nil); err != nil {
+		httpServer := &http.Server{
+			Addr:      addr,
+			Handler:   security.Wrap(mux),
+			TLSConfig: tlsConfig,
+		}
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil {
 			log.Fatal("Server error:", err)
 		}
 	}()
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role is what an authenticated client may do
+type Role string
+
+const (
+	RoleRead    Role = "read"    // Read state and watch the live socket
+	RoleControl Role = "control" // Also change displays, LEDs and lights
+)
+
+// csrfHeader carries the page's CSRF token on state-changing requests
+const csrfHeader = "X-CSRF-Token"
+
+// AuthConfig lists the API tokens and basic auth users allowed to use the
+// GUI. Passwords may be given in clear or as a hex SHA-256 hash.
+type AuthConfig struct {
+	Tokens []TokenConfig `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Users  []UserConfig  `json:"users,omitempty" yaml:"users,omitempty"`
+}
+
+// TokenConfig is an API token, sent as "Authorization: Bearer <token>"
+type TokenConfig struct {
+	Name  string `json:"name" yaml:"name"`
+	Token string `json:"token" yaml:"token"`
+	Role  Role   `json:"role" yaml:"role"`
+}
+
+// UserConfig is a basic auth user
+type UserConfig struct {
+	Name           string `json:"name" yaml:"name"`
+	Password       string `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordSHA256 string `json:"password_sha256,omitempty" yaml:"password_sha256,omitempty"`
+	Role           Role   `json:"role" yaml:"role"`
+}
+
+// LoadAuthConfig reads an auth file, choosing YAML or JSON by extension
+func LoadAuthConfig(filename string) (*AuthConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var config AuthConfig
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&config)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&config)
+	default:
+		return nil, fmt.Errorf("unsupported auth config format: %s", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse auth config %s: %w", filename, err)
+	}
+	if len(config.Tokens) == 0 && len(config.Users) == 0 {
+		return nil, fmt.Errorf("auth config %s has no tokens or users", filename)
+	}
+	return &config, nil
+}
+
+// Identity is the client a request comes from
+type Identity struct {
+	Name   string
+	Role   Role
+	Bearer bool // Authenticated by API token rather than by the browser
+}
+
+// CanControl reports whether the client may change panel state
+func (id Identity) CanControl() bool {
+	return id.Role == RoleControl
+}
+
+type identityKey struct{}
+
+// requestIdentity returns the identity Security attached to a request
+func requestIdentity(r *http.Request) Identity {
+	if id, ok := r.Context().Value(identityKey{}).(Identity); ok {
+		return id
+	}
+	return Identity{Role: RoleControl}
+}
+
+// credential is a token or user with its secret hashed
+type credential struct {
+	name string
+	hash [sha256.Size]byte
+	role Role
+}
+
+// Security authenticates requests, enforces roles and guards the browser UI
+// against cross-site requests. Without credentials every client has the
+// control role, as before.
+type Security struct {
+	tokens  []credential
+	users   []credential
+	origins []string // Origins allowed cross-origin access; "*" for any
+	csrfKey []byte
+}
+
+// NewSecurity creates the security layer. config may be nil to leave the
+// GUI open; origins are the CORS origins, such as https://example.com.
+func NewSecurity(config *AuthConfig, origins []string) (*Security, error) {
+	s := &Security{csrfKey: make([]byte, 32)}
+	if _, err := rand.Read(s.csrfKey); err != nil {
+		return nil, fmt.Errorf("failed to create CSRF key: %w", err)
+	}
+
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin != "*" {
+			if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("invalid CORS origin %q: must be scheme://host[:port] or *", origin)
+			}
+		}
+		s.origins = append(s.origins, origin)
+	}
+
+	if config == nil {
+		return s, nil
+	}
+	for i, t := range config.Tokens {
+		if t.Name == "" || len(t.Token) < 16 {
+			return nil, fmt.Errorf("token %d: needs a name and a token of at least 16 characters", i+1)
+		}
+		if err := checkRole(t.Role); err != nil {
+			return nil, fmt.Errorf("token %q: %w", t.Name, err)
+		}
+		s.tokens = append(s.tokens, credential{t.Name, sha256.Sum256([]byte(t.Token)), t.Role})
+	}
+	for _, u := range config.Users {
+		if u.Name == "" || strings.Contains(u.Name, ":") {
+			return nil, fmt.Errorf("user names must be set and can't contain ':'")
+		}
+		if err := checkRole(u.Role); err != nil {
+			return nil, fmt.Errorf("user %q: %w", u.Name, err)
+		}
+		c := credential{name: u.Name, role: u.Role}
+		switch {
+		case u.PasswordSHA256 != "" && u.Password == "":
+			hash, err := hex.DecodeString(u.PasswordSHA256)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("user %q: password_sha256 must be 64 hex digits", u.Name)
+			}
+			copy(c.hash[:], hash)
+		case u.Password != "" && u.PasswordSHA256 == "":
+			c.hash = sha256.Sum256([]byte(u.Password))
+		default:
+			return nil, fmt.Errorf("user %q: needs either password or password_sha256", u.Name)
+		}
+		s.users = append(s.users, c)
+	}
+	return s, nil
+}
+
+// checkRole validates a configured role
+func checkRole(role Role) error {
+	if role != RoleRead && role != RoleControl {
+		return fmt.Errorf("unknown role %q (must be read or control)", role)
+	}
+	return nil
+}
+
+// Enabled reports whether clients have to authenticate
+func (s *Security) Enabled() bool {
+	return len(s.tokens) > 0 || len(s.users) > 0
+}
+
+// match finds the credential with the secret, comparing every entry in
+// constant time
+func match(credentials []credential, name string, secret string) (credential, bool) {
+	hash := sha256.Sum256([]byte(secret))
+	var found credential
+	ok := false
+	for _, c := range credentials {
+		nameOK := name == "" || subtle.ConstantTimeCompare([]byte(name), []byte(c.name)) == 1
+		if subtle.ConstantTimeCompare(hash[:], c.hash[:]) == 1 && nameOK && !ok {
+			found, ok = c, true
+		}
+	}
+	return found, ok
+}
+
+// authenticate returns the identity of a request. Bearer tokens may also
+// be passed as the token query parameter of the live socket, which
+// browsers can't add headers to.
+func (s *Security) authenticate(r *http.Request) (Identity, bool) {
+	if !s.Enabled() {
+		return Identity{Role: RoleControl}, true
+	}
+
+	token := ""
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		token = strings.TrimSpace(auth[7:])
+	} else if r.URL.Path == "/api/ws" {
+		token = r.URL.Query().Get("token")
+	}
+	if token != "" {
+		c, ok := match(s.tokens, "", token)
+		return Identity{Name: c.name, Role: c.role, Bearer: true}, ok
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		c, ok := match(s.users, name, password)
+		return Identity{Name: c.name, Role: c.role}, ok
+	}
+	return Identity{}, false
+}
+
+// CSRFToken returns the token the page sends back on state-changing
+// requests, tied to the signed-in user
+func (s *Security) CSRFToken(id Identity) string {
+	mac := hmac.New(sha256.New, s.csrfKey)
+	mac.Write([]byte(id.Name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sameOrigin reports whether an Origin header names the server itself
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// corsAllowed reports whether an origin was configured for CORS
+func (s *Security) corsAllowed(origin string) bool {
+	for _, o := range s.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// changesState reports whether a request may change panel state
+func changesState(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+// Wrap applies CORS, cross-origin checks, authentication, roles and CSRF
+// protection to a handler
+func (s *Security) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		crossOrigin := origin != "" && !sameOrigin(r, origin)
+		if crossOrigin && s.corsAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+csrfHeader)
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		// Browsers send the panels' credentials along with requests from
+		// any site, so only configured origins may change state or open
+		// the live socket, which takes commands
+		changes := changesState(r)
+		if changes || headerContains(r.Header, "Upgrade", "websocket") {
+			site := r.Header.Get("Sec-Fetch-Site")
+			crossSite := crossOrigin || (origin == "" && site != "" && site != "same-origin" && site != "none")
+			if crossSite && !s.corsAllowed(origin) {
+				writeAPIError(w, &apiError{Status: http.StatusForbidden, Message: "cross-origin request refused"})
+				return
+			}
+		}
+
+		id, ok := s.authenticate(r)
+		if !ok {
+			if len(s.users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Saitek Controller", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="Saitek Controller"`)
+			}
+			writeAPIError(w, &apiError{Status: http.StatusUnauthorized, Message: "authentication required"})
+			return
+		}
+
+		if changes {
+			if !id.CanControl() {
+				writeAPIError(w, &apiError{Status: http.StatusForbidden, Message: "read-only access"})
+				return
+			}
+			// Requests from a browser page must carry the page's token;
+			// scripts and API token clients send no Origin and are exempt
+			fromBrowser := origin != "" || r.Header.Get("Sec-Fetch-Site") != ""
+			if fromBrowser && !id.Bearer && !hmac.Equal([]byte(r.Header.Get(csrfHeader)), []byte(s.CSRFToken(id))) {
+				writeAPIError(w, &apiError{Status: http.StatusForbidden, Message: "missing or invalid CSRF token"})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
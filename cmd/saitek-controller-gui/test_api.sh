@@ -3,7 +3,13 @@
 # Test script for Saitek Controller GUI API
 # This script demonstrates the API endpoints
 
-BASE_URL="http://localhost:8080"
+BASE_URL="${BASE_URL:-http://localhost:8080}"
+
+# Servers started with -auth need an API token with the control role:
+#   TOKEN=... ./test_api.sh
+if [ -n "$TOKEN" ]; then
+    curl() { command curl -k -H "Authorization: Bearer $TOKEN" "$@"; }
+fi
 
 echo "Testing Saitek Controller GUI API..."
 echo "====================================="
@@ -61,5 +67,37 @@ echo "5. Getting updated status..."
 curl -s "$BASE_URL/api/status" | jq '.' 2>/dev/null || curl -s "$BASE_URL/api/status"
 echo -e "\n"
 
+# Test 6: Press S1 and turn the right dial on the FIP emulator
+echo "6. Sending FIP emulator input..."
+curl -s -X POST "$BASE_URL/api/fip/input" -H "Content-Type: application/json" -d '{"control": "S1", "pressed": true}'
+curl -s -X POST "$BASE_URL/api/fip/input" -H "Content-Type: application/json" -d '{"control": "S1", "pressed": false}'
+curl -s -X POST "$BASE_URL/api/fip/input" -H "Content-Type: application/json" -d '{"control": "RightDialCW", "count": 3}'
+curl -s "$BASE_URL/api/fip/state" | jq '.' 2>/dev/null || curl -s "$BASE_URL/api/fip/state"
+echo -e "\n"
+
+# Test 7: The v1 API
+echo "7. Using the v1 API..."
+curl -s "$BASE_URL/api/v1/panels" | jq '.' 2>/dev/null || curl -s "$BASE_URL/api/v1/panels"
+curl -s -X PATCH "$BASE_URL/api/v1/panels/radio/display" -H "Content-Type: application/json" -d '{"com1Active": "119.10"}'
+curl -s -X PATCH "$BASE_URL/api/v1/panels/multi/leds" -H "Content-Type: application/json" -d '{"ap": true, "hdg": true}'
+curl -s -X PUT "$BASE_URL/api/v1/panels/switch/lights" -H "Content-Type: application/json" \
+  -d '{"greenN": true, "greenL": true, "greenR": true, "redN": false, "redL": false, "redR": false}'
+echo -e "\n"
+
+# Test 8: Validation errors list the failing fields with status 422
+echo "8. Sending an invalid radio display..."
+curl -s -w "HTTP %{http_code}\n" -X PUT "$BASE_URL/api/v1/panels/radio/display" \
+  -H "Content-Type: application/json" -d '{"com1Active": "118.250", "com1Standby": "abc"}'
+echo -e "\n"
+
+# Test 9: FIP images, instruments, LEDs and the current frame
+echo "9. Driving the FIP emulator..."
+curl -s -X POST "$BASE_URL/api/fip/instrument" -H "Content-Type: application/json" \
+  -d '{"instrument": "airspeed", "data": {"Airspeed": 135}}'
+curl -s -X POST "$BASE_URL/api/fip/leds" -H "Content-Type: application/json" -d '{"leds": {"S1": true, "S2": false}}'
+curl -s "$BASE_URL/api/fip/frame.png" -o fip_frame.png && echo "Saved fip_frame.png"
+curl -s -X POST "$BASE_URL/api/fip/image?mode=crop" -H "Content-Type: image/png" --data-binary @fip_frame.png
+echo -e "\n"
+
 echo "API tests completed!"
 echo "Open http://localhost:8080 in your browser to see the web interface." 
\ No newline at end of file
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate is valid
+const selfSignedValidity = 2 * 365 * 24 * time.Hour
+
+// selfSignedRenewal is how long before expiry a generated certificate is
+// replaced
+const selfSignedRenewal = 30 * 24 * time.Hour
+
+// loadTLSConfig returns the TLS configuration for the server: the given
+// certificate and key, or else a self-signed certificate kept in dir for
+// the host names
+func loadTLSConfig(certFile, keyFile, dir string, hosts []string) (*tls.Config, error) {
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("both a certificate and a key are needed")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	}
+
+	cert, err := selfSignedCertificate(dir, hosts)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// selfSignedCertificate loads the certificate generated by an earlier run,
+// or generates one if there is none, it is about to expire or it doesn't
+// cover every host
+func selfSignedCertificate(dir string, hosts []string) (tls.Certificate, error) {
+	certFile := filepath.Join(dir, "gui-cert.pem")
+	keyFile := filepath.Join(dir, "gui-key.pem")
+
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err == nil && time.Until(leaf.NotAfter) > selfSignedRenewal && coversHosts(leaf, hosts) {
+			logFingerprint(certFile, leaf)
+			return cert, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Saitek Controller GUI", Organization: []string{"saitek-controller"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to encode key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	// Keep the certificate so browsers only have to accept it once
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Warning: can't keep the self-signed certificate: %v", err)
+	} else if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		log.Printf("Warning: can't keep the self-signed certificate: %v", err)
+	} else if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		log.Printf("Warning: can't keep the self-signed certificate: %v", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load generated certificate: %w", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	log.Printf("Generated a self-signed certificate for %v", hosts)
+	logFingerprint(certFile, leaf)
+	return cert, nil
+}
+
+// coversHosts reports whether a certificate is valid for every host
+func coversHosts(cert *x509.Certificate, hosts []string) bool {
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// logFingerprint prints the certificate fingerprint, for checking the
+// browser warning against
+func logFingerprint(file string, cert *x509.Certificate) {
+	sum := sha256.Sum256(cert.Raw)
+	log.Printf("TLS certificate %s, SHA-256 fingerprint %s", file, hex.EncodeToString(sum[:]))
+}
+
+// certificateHosts returns the names the GUI can be reached by: localhost,
+// the machine's host name and the bind address, or every local address
+// when bound to all interfaces
+func certificateHosts(bind string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+
+	if bind != "" && bind != "0.0.0.0" && bind != "::" && bind != "localhost" {
+		return appendHost(hosts, bind)
+	}
+	if bind == "0.0.0.0" || bind == "::" {
+		addrs, err := net.InterfaceAddrs()
+		if err == nil {
+			for _, addr := range addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+					hosts = appendHost(hosts, ipNet.IP.String())
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// appendHost adds a host that isn't in the list yet
+func appendHost(hosts []string, host string) []string {
+	for _, h := range hosts {
+		if h == host {
+			return hosts
+		}
+	}
+	return append(hosts, host)
+}
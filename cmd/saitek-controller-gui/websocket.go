@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is the key suffix of the RFC 6455 opening handshake
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage limits the size of a message a client may send
+const maxWebSocketMessage = 64 * 1024
+
+// websocketWriteTimeout limits how long a write to a client may take
+const websocketWriteTimeout = 5 * time.Second
+
+// WebSocket frame opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// errWebSocketClosed is returned by ReadMessage once the client closed the
+// connection
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn is a server side WebSocket connection. It supports what the web
+// GUI needs: text messages, fragmentation, ping/pong and the close
+// handshake.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	mu     sync.Mutex // serializes frame writes
+	closed bool
+}
+
+// upgradeWebSocket performs the opening handshake and takes over the
+// connection. Origins are checked by Security before it gets here.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket: method %s", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket: unsupported version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket: invalid key %q", key)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %w", err)
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// headerContains reports whether a comma separated header has the token
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// while waiting. It returns errWebSocketClosed after a close frame.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, closePayload(payload))
+			return nil, errWebSocketClosed
+		case wsText, wsBinary:
+			if started {
+				return nil, c.fail(1002, "expected a continuation frame")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, c.fail(1002, "unexpected continuation frame")
+			}
+		default:
+			return nil, c.fail(1002, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if len(message)+len(payload) > maxWebSocketMessage {
+			return nil, c.fail(1009, "message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// closePayload returns the status code of a received close frame, to be
+// echoed back
+func closePayload(payload []byte) []byte {
+	if len(payload) < 2 {
+		return nil
+	}
+	return payload[:2]
+}
+
+// readFrame reads one frame and unmasks its payload. Clients must mask
+// every frame.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(1002, "reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(1002, "unmasked client frame")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(1002, "invalid control frame")
+	}
+	if length > maxWebSocketMessage {
+		return false, 0, nil, c.fail(1009, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection with a status code after a protocol error
+func (c *wsConn) fail(code uint16, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	c.writeFrame(wsClose, append(payload, reason...))
+	c.Close()
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// WriteMessage sends a text message
+func (c *wsConn) WriteMessage(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// writeFrame sends one unfragmented, unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	c.rw.Write(header)
+	c.rw.Write(payload)
+	if err := c.rw.Flush(); err != nil {
+		return fmt.Errorf("websocket: write failed: %w", err)
+	}
+	if opcode == wsClose {
+		c.closed = true
+	}
+	return nil
+}
+
+// Close closes the underlying connection
+func (c *wsConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.conn.Close()
+}
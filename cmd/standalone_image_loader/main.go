@@ -11,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"saitek-controller/internal/imaging"
 )
 
 // ImageLoader provides functionality to load and process images for FIP display
@@ -22,7 +24,11 @@ type ImageLoader struct {
 	
 	// Resize options
 	ResizeMode ResizeMode
+	Resampler  imaging.Resampler
 	Quality    int // 1-100 for JPEG quality
+
+	// Filters applied after resizing, such as gamma or night mode
+	Filters imaging.Chain
 }
 
 // ResizeMode defines how images should be resized
@@ -42,6 +48,7 @@ func NewImageLoader() *ImageLoader {
 		FIPHeight:  240,
 		FIPFormat:  "RGB",
 		ResizeMode: ResizeModeFit,
+		Resampler:  imaging.ResampleBilinear,
 		Quality:    90,
 	}
 }
@@ -82,16 +89,21 @@ func (loader *ImageLoader) ProcessImageForFIP(img image.Image) (image.Image, err
 	// Check if resizing is needed
 	if originalWidth == loader.FIPWidth && originalHeight == loader.FIPHeight {
 		log.Printf("Image is already the correct size")
-		return img, nil
+	} else {
+		// Resize the image according to the selected mode
+		resizedImg, err := loader.resizeImage(img)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resize image: %v", err)
+		}
+		img = resizedImg
 	}
 
-	// Resize the image according to the selected mode
-	resizedImg, err := loader.resizeImage(img)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resize image: %v", err)
+	// Apply filters to a copy, leaving the caller's image untouched
+	if len(loader.Filters) > 0 {
+		img = imaging.Apply(img, loader.Filters...)
 	}
 
-	return resizedImg, nil
+	return img, nil
 }
 
 // resizeImage resizes an image according to the selected resize mode
@@ -120,7 +132,7 @@ func (loader *ImageLoader) resizeImage(img image.Image) (image.Image, error) {
 // stretchImage stretches the image to fit the target dimensions (may distort)
 func (loader *ImageLoader) stretchImage(img image.Image, targetWidth, targetHeight int) image.Image {
 	resized := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
-	draw.Draw(resized, resized.Bounds(), img, img.Bounds().Min, draw.Over)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 	return resized
 }
 
@@ -143,7 +155,7 @@ func (loader *ImageLoader) fitImage(img image.Image, targetWidth, targetHeight i
 
 	// Resize the image
 	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-	draw.Draw(resized, resized.Bounds(), img, img.Bounds().Min, draw.Over)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 
 	// Create final image with padding
 	final := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
@@ -177,7 +189,7 @@ func (loader *ImageLoader) cropImage(img image.Image, targetWidth, targetHeight
 
 	// Resize the image
 	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-	draw.Draw(resized, resized.Bounds(), img, img.Bounds().Min, draw.Over)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 
 	// Crop to target dimensions
 	final := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
@@ -262,6 +274,16 @@ func (loader *ImageLoader) SetResizeMode(mode ResizeMode) {
 	loader.ResizeMode = mode
 }
 
+// SetResampler sets the interpolation used when resizing
+func (loader *ImageLoader) SetResampler(resampler imaging.Resampler) {
+	loader.Resampler = resampler
+}
+
+// SetFilters sets the filters applied after resizing
+func (loader *ImageLoader) SetFilters(filters ...imaging.Filter) {
+	loader.Filters = filters
+}
+
 // SetQuality sets the JPEG quality for saving images
 func (loader *ImageLoader) SetQuality(quality int) {
 	if quality < 1 {
@@ -354,6 +376,13 @@ func main() {
 		imageFile   = flag.String("image", "", "Image file to load (required)")
 		resizeMode  = flag.String("resize", "fit", "Resize mode: stretch, fit, crop, center")
 		quality     = flag.Int("quality", 90, "JPEG quality (1-100)")
+		resample    = flag.String("resample", "bilinear", "Resampler: nearest, bilinear, catmullrom, lanczos")
+		filters     = flag.String("filters", "", "Filter chain, e.g. gamma=2.2,contrast=1.2,night=0.6,dither=5")
+		gamma       = flag.Float64("gamma", 1, "Gamma correction (above 1 brightens mid tones)")
+		brightness  = flag.Float64("brightness", 0, "Brightness offset (-1 to 1)")
+		contrast    = flag.Float64("contrast", 1, "Contrast factor (1 leaves the image unchanged)")
+		night       = flag.Float64("night", 0, "Night mode: red on black at this brightness (0-1, 0 for off)")
+		dither      = flag.Int("dither", 0, "Floyd-Steinberg dither to this many bits per channel (1-7, 0 for off)")
 		outputFile  = flag.String("output", "", "Output file for processed image")
 		showInfo    = flag.Bool("info", false, "Show image information only")
 		validate    = flag.Bool("validate", false, "Validate image size only")
@@ -409,6 +438,26 @@ func main() {
 	// Set quality
 	loader.SetQuality(*quality)
 
+	// Set resampler and filters; single adjustment flags run after -filters
+	resampler, err := imaging.ParseResampler(*resample)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	loader.SetResampler(resampler)
+
+	chain, err := imaging.ParseFilters(*filters)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	chain = append(chain, imaging.Adjustments{
+		Gamma:      *gamma,
+		Brightness: *brightness,
+		Contrast:   *contrast,
+		Night:      *night,
+		DitherBits: *dither,
+	}.Filters()...)
+	loader.SetFilters(chain...)
+
 	// Load the image
 	fmt.Printf("Loading image: %s\n", *imageFile)
 	img, err := loader.LoadImageFromFile(*imageFile)
@@ -423,6 +472,8 @@ func main() {
 	fmt.Printf("  FIP size: %dx%d\n", info.FIPWidth, info.FIPHeight)
 	fmt.Printf("  Needs resize: %v\n", info.NeedsResize)
 	fmt.Printf("  Resize mode: %s\n", *resizeMode)
+	fmt.Printf("  Resampler: %s\n", resampler)
+	fmt.Printf("  Filters: %d\n", len(chain))
 
 	// Validate image size if requested
 	if *validate {
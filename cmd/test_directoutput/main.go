@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
 	"log"
+	"os"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
 )
 
@@ -19,7 +22,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create DirectOutput: %v", err)
 	}
-	defer do.Close()
+	defer do.Deinitialize()
 
 	// Initialize DirectOutput
 	err = do.Initialize("Saitek Controller Test")
@@ -33,30 +36,19 @@ func main() {
 
 	// Convert to FIP format
 	fmt.Println("Converting to FIP format...")
-	fipData, err := do.ConvertImageToFIPFormat(img)
-	if err != nil {
-		log.Fatalf("Failed to convert image: %v", err)
-	}
+	fipData := bmp.FIPBuffer(img)
 
 	// Save the FIP image for inspection
 	outputPath := "test_fip_image.png"
 	fmt.Printf("Saving FIP image to: %s\n", outputPath)
-	err = do.SaveImageAsPNG(img, outputPath)
+	err = savePNG(img, outputPath)
 	if err != nil {
 		log.Fatalf("Failed to save image: %v", err)
 	}
 
-		// Create a simulated device
-	fmt.Println("Creating simulated FIP device...")
-	deviceHandle := unsafe.Pointer(uintptr(1)) // Simulated handle
-	
-	// Create a device in the DirectOutput instance
-	device := &fip.Device{
-		Handle:     deviceHandle,
-		DeviceType: fip.DeviceTypeFip,
-		Pages:      make(map[uint32]*fip.Page),
-	}
-	do.Devices[deviceHandle] = device
+	// Open the FIP, or a virtual one
+	fmt.Println("Opening FIP device...")
+	deviceHandle := openFIP(do)
 	
 	// Add a page to the device
 	err = do.AddPage(deviceHandle, 1, "Test Page", fip.FLAG_SET_AS_ACTIVE)
@@ -107,3 +99,28 @@ func createTestImage() image.Image {
 
 	return img
 }
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
+	}
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
+
+// savePNG saves an image as PNG for inspection
+func savePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
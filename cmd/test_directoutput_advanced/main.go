@@ -9,7 +9,9 @@ import (
 	"time"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 )
 
 func main() {
@@ -21,7 +23,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create DirectOutput: %v", err)
 	}
-	defer do.Close()
+	defer do.Deinitialize()
 
 	// Initialize DirectOutput
 	err = do.Initialize("Saitek FIP Controller")
@@ -29,16 +31,9 @@ func main() {
 		log.Fatalf("Failed to initialize DirectOutput: %v", err)
 	}
 
-	// Create a simulated FIP device
+	// Open the FIP, or a virtual one
 	fmt.Println("Creating FIP device...")
-	deviceHandle := unsafe.Pointer(uintptr(1))
-
-	device := &fip.Device{
-		Handle:     deviceHandle,
-		DeviceType: fip.DeviceTypeFip,
-		Pages:      make(map[uint32]*fip.Page),
-	}
-	do.Devices[deviceHandle] = device
+	deviceHandle := openFIP(do)
 
 	// Register callbacks
 	fmt.Println("Registering callbacks...")
@@ -85,11 +80,7 @@ func main() {
 			// Create a test image if the file doesn't exist
 			fmt.Printf("Creating test image for page %d\n", page.id)
 			img := createInstrumentImage(page.name)
-			fipData, err := do.ConvertImageToFIPFormat(img)
-			if err != nil {
-				log.Printf("Failed to convert image for page %d: %v", page.id, err)
-				continue
-			}
+			fipData := bmp.FIPBuffer(img)
 			err = do.SetImage(deviceHandle, page.id, 0, fipData)
 			if err != nil {
 				log.Printf("Failed to set image for page %d: %v", page.id, err)
@@ -352,19 +343,12 @@ func drawGenericInstrument(img *image.RGBA, name string) {
 	drawText(img, name, 160, 120, color.RGBA{255, 255, 255, 255})
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	// Simple text drawing (in a real implementation, you'd use a proper font)
-	// For now, just draw some pixels to represent text
-	for i, _ := range text {
-		// Simple character representation
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
-	}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
 }
 
 // Simple math functions
@@ -377,3 +361,18 @@ func sin(x float64) float64 {
 	// Simple sine approximation
 	return x - x*x*x/6 + x*x*x*x*x/120
 }
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
+	}
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
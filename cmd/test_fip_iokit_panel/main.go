@@ -1,3 +1,5 @@
+//go:build darwin
+
 package main
 
 import (
@@ -62,39 +64,20 @@ func monitorButtonEvents(panel *fip.IOKitFIPPanel) {
 	events := panel.GetButtonEvents()
 
 	for event := range events {
-		action := "pressed"
-		if !event.Pressed {
-			action = "released"
-		}
-
-		fmt.Printf("🎯 FIP Button %d %s\n", event.ButtonID+1, action)
-
-		// You can add specific actions for each button here
-		switch event.ButtonID {
-		case 0:
-			fmt.Println("   → Button 1: Navigation")
-		case 1:
-			fmt.Println("   → Button 2: Menu")
-		case 2:
-			fmt.Println("   → Button 3: Enter")
-		case 3:
-			fmt.Println("   → Button 4: Escape")
-		case 4:
-			fmt.Println("   → Button 5: Up")
-		case 5:
-			fmt.Println("   → Button 6: Down")
-		case 6:
-			fmt.Println("   → Button 7: Left")
-		case 7:
-			fmt.Println("   → Button 8: Right")
-		case 8:
-			fmt.Println("   → Button 9: Function 1")
-		case 9:
-			fmt.Println("   → Button 10: Function 2")
-		case 10:
-			fmt.Println("   → Button 11: Function 3")
-		case 11:
-			fmt.Println("   → Button 12: Function 4")
+		fmt.Printf("🎯 FIP %s\n", event)
+
+		// You can add specific actions for each control here
+		switch {
+		case event.Control.IsButton() && event.Pressed:
+			fmt.Printf("   → Button %d\n", event.Control.Button())
+		case event.Control == fip.InputPageUp && event.Pressed:
+			fmt.Println("   → Previous page")
+		case event.Control == fip.InputPageDown && event.Pressed:
+			fmt.Println("   → Next page")
+		case event.Control == fip.InputRightDialCW || event.Control == fip.InputRightDialCCW:
+			fmt.Printf("   → Right dial %+d\n", event.Delta())
+		case event.Control == fip.InputLeftDialCW || event.Control == fip.InputLeftDialCCW:
+			fmt.Printf("   → Left dial %+d\n", event.Delta())
 		}
 	}
 }
@@ -102,7 +85,7 @@ func monitorButtonEvents(panel *fip.IOKitFIPPanel) {
 func printButtonStates(panel *fip.IOKitFIPPanel) {
 	fmt.Print("Button States: [")
 	for i := 0; i < 12; i++ {
-		if panel.GetButtonState(i) {
+		if panel.GetButtonState(fip.InputControl(i)) {
 			fmt.Print("●")
 		} else {
 			fmt.Print("○")
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 )
 
 func main() {
@@ -91,8 +92,7 @@ func main() {
 		for {
 			select {
 			case event := <-eventChan:
-				fmt.Printf("Button event: Button=%d, Pressed=%v, Time=%v\n",
-					event.Button, event.Pressed, event.Timestamp)
+				fmt.Printf("Input event: %s, Time=%v\n", event, event.Timestamp)
 			case <-timeout:
 				fmt.Println("Button event listening timeout")
 				goto done
@@ -245,14 +245,10 @@ func createTextImage(text string) image.Image {
 	return img
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i, _ := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
-	}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
 }
@@ -5,11 +5,9 @@ import (
 	"image"
 	"image/color"
 	"log"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"unsafe"
 
 	"saitek-controller/internal/fip"
 )
@@ -32,7 +30,7 @@ func main() {
 	}
 
 	// Create device handle
-	deviceHandle := unsafe.Pointer(uintptr(0x12345678))
+	deviceHandle := uintptr(0x12345678)
 
 	// Add page
 	err = sdk.AddPage(deviceHandle, 1, "Image Loader Test Page", 0x00000001)
@@ -102,9 +100,9 @@ func main() {
 				fmt.Printf("     ✓ Sent %s to FIP\n", filename)
 			}
 
-			// Save processed image
-			outputFilename := fmt.Sprintf("processed_%s_%s", mode.desc, filename)
-			err = loader.SaveImageAsPNG(img, outputFilename)
+			// Save processed image as a BMP, the format DirectOutput loads
+			outputFilename := fmt.Sprintf("processed_%s_%s.bmp", mode.desc, strings.TrimSuffix(filename, ".png"))
+			err = loader.SaveImage(img, outputFilename)
 			if err != nil {
 				log.Printf("Warning: Failed to save processed %s: %v", outputFilename, err)
 			} else {
@@ -144,7 +142,7 @@ func main() {
 		{120, 240, false},  // Wrong aspect ratio
 	}
 
-	for i, size := range sizes {
+	for _, size := range sizes {
 		img := image.NewRGBA(image.Rect(0, 0, size.width, size.height))
 		
 		// Fill with test pattern
@@ -277,29 +275,29 @@ func (sdk *DirectOutputSDK) Initialize(pluginName string) error {
 	return nil
 }
 
-func (sdk *DirectOutputSDK) AddPage(deviceHandle unsafe.Pointer, page uint32, name string, flags uint32) error {
+func (sdk *DirectOutputSDK) AddPage(deviceHandle uintptr, page uint32, name string, flags uint32) error {
 	if sdk.useRealSDK {
-		log.Printf("DirectOutput_AddPage (REAL): device=%p, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
+		log.Printf("DirectOutput_AddPage (REAL): device=0x%x, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
 	} else {
-		log.Printf("DirectOutput_AddPage (simulated): device=%p, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
+		log.Printf("DirectOutput_AddPage (simulated): device=0x%x, page=%d, name=%s, flags=0x%08X", deviceHandle, page, name, flags)
 	}
 	return nil
 }
 
-func (sdk *DirectOutputSDK) SetImage(deviceHandle unsafe.Pointer, page uint32, index uint32, data []byte) error {
+func (sdk *DirectOutputSDK) SetImage(deviceHandle uintptr, page uint32, index uint32, data []byte) error {
 	if sdk.useRealSDK {
-		log.Printf("DirectOutput_SetImage (REAL): device=%p, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
+		log.Printf("DirectOutput_SetImage (REAL): device=0x%x, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
 	} else {
-		log.Printf("DirectOutput_SetImage (simulated): device=%p, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
+		log.Printf("DirectOutput_SetImage (simulated): device=0x%x, page=%d, index=%d, size=%d", deviceHandle, page, index, len(data))
 	}
 	return nil
 }
 
-func (sdk *DirectOutputSDK) RemovePage(deviceHandle unsafe.Pointer, page uint32) error {
+func (sdk *DirectOutputSDK) RemovePage(deviceHandle uintptr, page uint32) error {
 	if sdk.useRealSDK {
-		log.Printf("DirectOutput_RemovePage (REAL): device=%p, page=%d", deviceHandle, page)
+		log.Printf("DirectOutput_RemovePage (REAL): device=0x%x, page=%d", deviceHandle, page)
 	} else {
-		log.Printf("DirectOutput_RemovePage (simulated): device=%p, page=%d", deviceHandle, page)
+		log.Printf("DirectOutput_RemovePage (simulated): device=0x%x, page=%d", deviceHandle, page)
 	}
 	return nil
 }
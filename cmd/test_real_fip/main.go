@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
 	"log"
+	"os"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 	"saitek-controller/internal/usb"
 )
 
@@ -104,7 +108,7 @@ func testDirectOutput() {
 		log.Printf("Failed to create DirectOutput: %v", err)
 		return
 	}
-	defer do.Close()
+	defer do.Deinitialize()
 
 	// Initialize DirectOutput
 	err = do.Initialize("Real FIP Test")
@@ -120,32 +124,21 @@ func testDirectOutput() {
 	img := createTestImage()
 
 	// Convert to FIP format
-	fipData, err := do.ConvertImageToFIPFormat(img)
-	if err != nil {
-		log.Printf("Failed to convert image: %v", err)
-		return
-	}
+	fipData := bmp.FIPBuffer(img)
 
 	fmt.Printf("   ✓ Image converted to FIP format: %d bytes\n", len(fipData))
 
 	// Save test image for inspection
-	err = do.SaveImageAsPNG(img, "real_fip_test_image.png")
+	err = savePNG(img, "real_fip_test_image.png")
 	if err != nil {
 		log.Printf("Failed to save test image: %v", err)
 	} else {
 		fmt.Println("   ✓ Test image saved as 'real_fip_test_image.png'")
 	}
 
-	// Try to create a simulated device (since we don't have real DirectOutput DLL on macOS)
-	fmt.Println("   Creating simulated DirectOutput device...")
-	deviceHandle := unsafe.Pointer(uintptr(1))
-
-	device := &fip.Device{
-		Handle:     deviceHandle,
-		DeviceType: fip.DeviceTypeFip,
-		Pages:      make(map[uint32]*fip.Page),
-	}
-	do.Devices[deviceHandle] = device
+	// Open the FIP, or a virtual one when there is none
+	fmt.Println("   Opening DirectOutput device...")
+	deviceHandle := openFIP(do)
 
 	// Add a test page
 	err = do.AddPage(deviceHandle, 1, "Real FIP Test", fip.FLAG_SET_AS_ACTIVE)
@@ -214,17 +207,12 @@ func createTestImage() image.Image {
 	return img
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	// Simple text drawing
-	for i, _ := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
-	}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
 }
 
 func min(a, b int) int {
@@ -233,3 +221,28 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
+	}
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
+
+// savePNG saves an image as PNG for inspection
+func savePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
 	"log"
 	"os"
 	"time"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 )
 
 func main() {
@@ -18,17 +21,17 @@ func main() {
 
 	// Create real DirectOutput SDK instance
 	fmt.Println("1. Initializing Real DirectOutput SDK...")
-	realSDK, err := fip.NewDirectOutputReal()
+	realSDK, err := fip.NewDirectOutput()
 	if err != nil {
 		log.Fatalf("Failed to create Real DirectOutput SDK: %v", err)
 	}
-	defer realSDK.Close()
+	defer realSDK.Deinitialize()
 
-	// Check if we're using the real SDK or fallback
-	if realSDK.IsUsingRealSDK() {
+	// Check if we're using the real SDK or the native transports
+	if isSDK(realSDK) {
 		fmt.Println("   ✓ Using REAL DirectOutput SDK")
 	} else {
-		fmt.Println("   ⚠ Using cross-platform fallback (no real SDK available)")
+		fmt.Println("   ⚠ Using native USB/HID transports (no real SDK available)")
 	}
 
 	// Initialize the SDK
@@ -51,8 +54,8 @@ func main() {
 		log.Printf("Warning: Failed to enumerate devices: %v", err)
 	}
 
-	// Create a simulated FIP device handle
-	deviceHandle := unsafe.Pointer(uintptr(0x12345678))
+	// Use the first FIP
+	deviceHandle := openFIP(realSDK)
 
 	// Add a page to the device
 	fmt.Println("4. Adding FIP page...")
@@ -78,11 +81,8 @@ func main() {
 
 	// Test 1: Simple test image
 	fmt.Println("   Sending simple test image...")
-	testImage := realSDK.CreateTestImage()
-	fipData, err := realSDK.ConvertImageToFIPFormat(testImage)
-	if err != nil {
-		log.Fatalf("Failed to convert test image: %v", err)
-	}
+	testImage := fip.NewImageGenerator(320, 240).CreateTestPattern()
+	fipData := bmp.FIPBuffer(testImage)
 
 	err = realSDK.SetImage(deviceHandle, 1, 0, fipData)
 	if err != nil {
@@ -92,7 +92,7 @@ func main() {
 	}
 
 	// Save the test image for inspection
-	err = realSDK.SaveImageAsPNG(testImage, "real_sdk_test_image_1.png")
+	err = savePNG(testImage, "real_sdk_test_image_1.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save test image: %v", err)
 	} else {
@@ -102,10 +102,7 @@ func main() {
 	// Test 2: Color bars
 	fmt.Println("   Sending color bars image...")
 	colorImage := createColorBars()
-	colorData, err := realSDK.ConvertImageToFIPFormat(colorImage)
-	if err != nil {
-		log.Fatalf("Failed to convert color image: %v", err)
-	}
+	colorData := bmp.FIPBuffer(colorImage)
 
 	err = realSDK.SetImage(deviceHandle, 1, 0, colorData)
 	if err != nil {
@@ -114,7 +111,7 @@ func main() {
 		fmt.Println("   ✓ Color bars image sent")
 	}
 
-	err = realSDK.SaveImageAsPNG(colorImage, "real_sdk_test_image_2.png")
+	err = savePNG(colorImage, "real_sdk_test_image_2.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save color image: %v", err)
 	} else {
@@ -124,10 +121,7 @@ func main() {
 	// Test 3: Gradient
 	fmt.Println("   Sending gradient image...")
 	gradientImage := createGradient()
-	gradientData, err := realSDK.ConvertImageToFIPFormat(gradientImage)
-	if err != nil {
-		log.Fatalf("Failed to convert gradient image: %v", err)
-	}
+	gradientData := bmp.FIPBuffer(gradientImage)
 
 	err = realSDK.SetImage(deviceHandle, 1, 0, gradientData)
 	if err != nil {
@@ -136,7 +130,7 @@ func main() {
 		fmt.Println("   ✓ Gradient image sent")
 	}
 
-	err = realSDK.SaveImageAsPNG(gradientImage, "real_sdk_test_image_3.png")
+	err = savePNG(gradientImage, "real_sdk_test_image_3.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save gradient image: %v", err)
 	} else {
@@ -146,10 +140,7 @@ func main() {
 	// Test 4: Text pattern
 	fmt.Println("   Sending text pattern image...")
 	textImage := createTextPattern()
-	textData, err := realSDK.ConvertImageToFIPFormat(textImage)
-	if err != nil {
-		log.Fatalf("Failed to convert text image: %v", err)
-	}
+	textData := bmp.FIPBuffer(textImage)
 
 	err = realSDK.SetImage(deviceHandle, 1, 0, textData)
 	if err != nil {
@@ -158,7 +149,7 @@ func main() {
 		fmt.Println("   ✓ Text pattern image sent")
 	}
 
-	err = realSDK.SaveImageAsPNG(textImage, "real_sdk_test_image_4.png")
+	err = savePNG(textImage, "real_sdk_test_image_4.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save text image: %v", err)
 	} else {
@@ -168,10 +159,7 @@ func main() {
 	// Test 5: Complex pattern
 	fmt.Println("   Sending complex pattern image...")
 	patternImage := createComplexPattern()
-	patternData, err := realSDK.ConvertImageToFIPFormat(patternImage)
-	if err != nil {
-		log.Fatalf("Failed to convert pattern image: %v", err)
-	}
+	patternData := bmp.FIPBuffer(patternImage)
 
 	err = realSDK.SetImage(deviceHandle, 1, 0, patternData)
 	if err != nil {
@@ -180,7 +168,7 @@ func main() {
 		fmt.Println("   ✓ Complex pattern image sent")
 	}
 
-	err = realSDK.SaveImageAsPNG(patternImage, "real_sdk_test_image_5.png")
+	err = savePNG(patternImage, "real_sdk_test_image_5.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save pattern image: %v", err)
 	} else {
@@ -212,7 +200,7 @@ func main() {
 	
 	// Create a test image file first
 	testFileImage := createTestFileImage()
-	err = realSDK.SaveImageAsPNG(testFileImage, "real_test_fip_image.png")
+	err = savePNG(testFileImage, "real_test_fip_image.png")
 	if err != nil {
 		log.Printf("Warning: Failed to create test file: %v", err)
 	} else {
@@ -237,19 +225,15 @@ func main() {
 		
 		// Send a different image to the second page
 		page2Image := createPage2Image()
-		page2Data, err := realSDK.ConvertImageToFIPFormat(page2Image)
+		page2Data := bmp.FIPBuffer(page2Image)
+		err = realSDK.SetImage(deviceHandle, 2, 0, page2Data)
 		if err != nil {
-			log.Printf("Warning: Failed to convert page 2 image: %v", err)
+			log.Printf("Warning: Failed to send page 2 image: %v", err)
 		} else {
-			err = realSDK.SetImage(deviceHandle, 2, 0, page2Data)
-			if err != nil {
-				log.Printf("Warning: Failed to send page 2 image: %v", err)
-			} else {
-				fmt.Println("   ✓ Page 2 image sent")
-			}
+			fmt.Println("   ✓ Page 2 image sent")
 		}
-		
-		err = realSDK.SaveImageAsPNG(page2Image, "real_sdk_page2_image.png")
+
+		err = savePNG(page2Image, "real_sdk_page2_image.png")
 		if err != nil {
 			log.Printf("Warning: Failed to save page 2 image: %v", err)
 		} else {
@@ -280,15 +264,15 @@ func main() {
 	fmt.Println("  - real_sdk_page2_image.png (Page 2 test)")
 	fmt.Println("\nThis demonstrates driver-independent FIP image sending using the REAL DirectOutput SDK!")
 	
-	if realSDK.IsUsingRealSDK() {
+	if isSDK(realSDK) {
 		fmt.Println("\n🎉 SUCCESS: Using the REAL DirectOutput SDK!")
 		fmt.Println("   This means the SDK was found and loaded successfully.")
 		fmt.Println("   On Windows, this would communicate with real FIP hardware.")
 	} else {
-		fmt.Println("\n⚠️  NOTE: Using cross-platform fallback")
+		fmt.Println("\n⚠️  NOTE: Using native USB/HID transports")
 		fmt.Println("   The real DirectOutput SDK was not available.")
 		fmt.Println("   This is normal on non-Windows systems or when SDK is not installed.")
-		fmt.Println("   The functionality is simulated for testing purposes.")
+		fmt.Println("   The FIP is driven over USB/HID, or a virtual FIP is used without one.")
 	}
 }
 
@@ -501,14 +485,42 @@ func createPage2Image() image.Image {
 	return img
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
+}
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
+	}
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
+
+// savePNG saves an image as PNG for inspection
+func savePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
 	}
-}
\ No newline at end of file
+	defer file.Close()
+	return png.Encode(file, img)
+}
+
+// isSDK reports whether DirectOutput runs on the SDK DLL rather than the
+// native transports
+func isSDK(do fip.DirectOutput) bool {
+	_, native := do.(*fip.NativeDirectOutput)
+	return !native
+}
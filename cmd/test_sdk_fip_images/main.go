@@ -4,14 +4,15 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"image/png"
 	"log"
 	"os"
 	"time"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 )
 
 func main() {
@@ -20,11 +21,11 @@ func main() {
 
 	// Create DirectOutput SDK instance
 	fmt.Println("1. Initializing DirectOutput SDK...")
-	sdk, err := fip.NewDirectOutputSDK()
+	sdk, err := fip.NewDirectOutput()
 	if err != nil {
 		log.Fatalf("Failed to create DirectOutput SDK: %v", err)
 	}
-	defer sdk.Close()
+	defer sdk.Deinitialize()
 
 	// Initialize the SDK
 	err = sdk.Initialize("FIP Image Sender")
@@ -46,8 +47,8 @@ func main() {
 		log.Printf("Warning: Failed to enumerate devices: %v", err)
 	}
 
-	// Create a simulated FIP device handle
-	deviceHandle := unsafe.Pointer(uintptr(0x12345678))
+	// Use the first FIP
+	deviceHandle := openFIP(sdk)
 
 	// Add a page to the device
 	fmt.Println("4. Adding FIP page...")
@@ -73,11 +74,8 @@ func main() {
 
 	// Test 1: Simple test image
 	fmt.Println("   Sending simple test image...")
-	testImage := sdk.CreateTestImage()
-	fipData, err := sdk.ConvertImageToFIPFormat(testImage)
-	if err != nil {
-		log.Fatalf("Failed to convert test image: %v", err)
-	}
+	testImage := fip.NewImageGenerator(320, 240).CreateTestPattern()
+	fipData := bmp.FIPBuffer(testImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, fipData)
 	if err != nil {
@@ -87,7 +85,7 @@ func main() {
 	}
 
 	// Save the test image for inspection
-	err = sdk.SaveImageAsPNG(testImage, "sdk_test_image_1.png")
+	err = savePNG(testImage, "sdk_test_image_1.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save test image: %v", err)
 	} else {
@@ -97,10 +95,7 @@ func main() {
 	// Test 2: Color bars
 	fmt.Println("   Sending color bars image...")
 	colorImage := createColorBars()
-	colorData, err := sdk.ConvertImageToFIPFormat(colorImage)
-	if err != nil {
-		log.Fatalf("Failed to convert color image: %v", err)
-	}
+	colorData := bmp.FIPBuffer(colorImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, colorData)
 	if err != nil {
@@ -109,7 +104,7 @@ func main() {
 		fmt.Println("   ✓ Color bars image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(colorImage, "sdk_test_image_2.png")
+	err = savePNG(colorImage, "sdk_test_image_2.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save color image: %v", err)
 	} else {
@@ -119,10 +114,7 @@ func main() {
 	// Test 3: Gradient
 	fmt.Println("   Sending gradient image...")
 	gradientImage := createGradient()
-	gradientData, err := sdk.ConvertImageToFIPFormat(gradientImage)
-	if err != nil {
-		log.Fatalf("Failed to convert gradient image: %v", err)
-	}
+	gradientData := bmp.FIPBuffer(gradientImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, gradientData)
 	if err != nil {
@@ -131,7 +123,7 @@ func main() {
 		fmt.Println("   ✓ Gradient image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(gradientImage, "sdk_test_image_3.png")
+	err = savePNG(gradientImage, "sdk_test_image_3.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save gradient image: %v", err)
 	} else {
@@ -141,10 +133,7 @@ func main() {
 	// Test 4: Text pattern
 	fmt.Println("   Sending text pattern image...")
 	textImage := createTextPattern()
-	textData, err := sdk.ConvertImageToFIPFormat(textImage)
-	if err != nil {
-		log.Fatalf("Failed to convert text image: %v", err)
-	}
+	textData := bmp.FIPBuffer(textImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, textData)
 	if err != nil {
@@ -153,7 +142,7 @@ func main() {
 		fmt.Println("   ✓ Text pattern image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(textImage, "sdk_test_image_4.png")
+	err = savePNG(textImage, "sdk_test_image_4.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save text image: %v", err)
 	} else {
@@ -163,10 +152,7 @@ func main() {
 	// Test 5: Complex pattern
 	fmt.Println("   Sending complex pattern image...")
 	patternImage := createComplexPattern()
-	patternData, err := sdk.ConvertImageToFIPFormat(patternImage)
-	if err != nil {
-		log.Fatalf("Failed to convert pattern image: %v", err)
-	}
+	patternData := bmp.FIPBuffer(patternImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, patternData)
 	if err != nil {
@@ -175,7 +161,7 @@ func main() {
 		fmt.Println("   ✓ Complex pattern image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(patternImage, "sdk_test_image_5.png")
+	err = savePNG(patternImage, "sdk_test_image_5.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save pattern image: %v", err)
 	} else {
@@ -207,7 +193,7 @@ func main() {
 	
 	// Create a test image file first
 	testFileImage := createTestFileImage()
-	err = sdk.SaveImageAsPNG(testFileImage, "test_fip_image.png")
+	err = savePNG(testFileImage, "test_fip_image.png")
 	if err != nil {
 		log.Printf("Warning: Failed to create test file: %v", err)
 	} else {
@@ -418,14 +404,35 @@ func createTestFileImage() image.Image {
 	return img
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
+}
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
 	}
-}
\ No newline at end of file
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
+
+// savePNG saves an image as PNG for inspection
+func savePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
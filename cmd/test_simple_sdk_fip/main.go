@@ -10,7 +10,9 @@ import (
 	"time"
 	"unsafe"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/text"
 )
 
 func main() {
@@ -19,17 +21,17 @@ func main() {
 
 	// Create DirectOutput SDK instance
 	fmt.Println("1. Creating DirectOutput SDK...")
-	sdk, err := fip.NewDirectOutputReal()
+	sdk, err := fip.NewDirectOutput()
 	if err != nil {
 		log.Fatalf("Failed to create SDK: %v", err)
 	}
-	defer sdk.Close()
+	defer sdk.Deinitialize()
 
-	// Check if we're using the real SDK or fallback
-	if sdk.IsUsingRealSDK() {
+	// Check if we're using the real SDK or the native transports
+	if isSDK(sdk) {
 		fmt.Println("   ✓ Using REAL DirectOutput SDK")
 	} else {
-		fmt.Println("   ⚠ Using cross-platform fallback")
+		fmt.Println("   ⚠ Using native USB/HID transports")
 	}
 
 	// Initialize the SDK
@@ -39,8 +41,8 @@ func main() {
 		log.Fatalf("Failed to initialize SDK: %v", err)
 	}
 
-	// Create a simulated device handle
-	deviceHandle := unsafe.Pointer(uintptr(0x12345678))
+	// Use the first FIP
+	deviceHandle := openFIP(sdk)
 
 	// Add a page
 	fmt.Println("3. Adding FIP page...")
@@ -54,13 +56,10 @@ func main() {
 
 	// Test 1: Simple test image
 	fmt.Println("   Creating simple test image...")
-	testImage := sdk.CreateTestImage()
+	testImage := fip.NewImageGenerator(320, 240).CreateTestPattern()
 	
 	// Convert to FIP format
-	fipData, err := sdk.ConvertImageToFIPFormat(testImage)
-	if err != nil {
-		log.Fatalf("Failed to convert test image: %v", err)
-	}
+	fipData := bmp.FIPBuffer(testImage)
 
 	// Send image
 	err = sdk.SetImage(deviceHandle, 1, 0, fipData)
@@ -71,7 +70,7 @@ func main() {
 	}
 
 	// Save the test image
-	err = sdk.SaveImageAsPNG(testImage, "simple_sdk_test_image.png")
+	err = savePNG(testImage, "simple_sdk_test_image.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save test image: %v", err)
 	} else {
@@ -81,10 +80,7 @@ func main() {
 	// Test 2: Color bars
 	fmt.Println("   Creating color bars image...")
 	colorImage := createColorBars()
-	colorData, err := sdk.ConvertImageToFIPFormat(colorImage)
-	if err != nil {
-		log.Fatalf("Failed to convert color image: %v", err)
-	}
+	colorData := bmp.FIPBuffer(colorImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, colorData)
 	if err != nil {
@@ -93,7 +89,7 @@ func main() {
 		fmt.Println("   ✓ Color bars image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(colorImage, "simple_sdk_color_bars.png")
+	err = savePNG(colorImage, "simple_sdk_color_bars.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save color image: %v", err)
 	} else {
@@ -103,10 +99,7 @@ func main() {
 	// Test 3: Text pattern
 	fmt.Println("   Creating text pattern image...")
 	textImage := createTextPattern()
-	textData, err := sdk.ConvertImageToFIPFormat(textImage)
-	if err != nil {
-		log.Fatalf("Failed to convert text image: %v", err)
-	}
+	textData := bmp.FIPBuffer(textImage)
 
 	err = sdk.SetImage(deviceHandle, 1, 0, textData)
 	if err != nil {
@@ -115,7 +108,7 @@ func main() {
 		fmt.Println("   ✓ Text pattern image sent")
 	}
 
-	err = sdk.SaveImageAsPNG(textImage, "simple_sdk_text_pattern.png")
+	err = savePNG(textImage, "simple_sdk_text_pattern.png")
 	if err != nil {
 		log.Printf("Warning: Failed to save text image: %v", err)
 	} else {
@@ -147,7 +140,7 @@ func main() {
 	
 	// Create a test image file first
 	testFileImage := createTestFileImage()
-	err = sdk.SaveImageAsPNG(testFileImage, "simple_test_fip_image.png")
+	err = savePNG(testFileImage, "simple_test_fip_image.png")
 	if err != nil {
 		log.Printf("Warning: Failed to create test file: %v", err)
 	} else {
@@ -175,10 +168,10 @@ func main() {
 	fmt.Println("  - simple_test_fip_image.png (File test)")
 	fmt.Println("\nThis demonstrates driver-independent FIP image sending!")
 	
-	if sdk.IsUsingRealSDK() {
+	if isSDK(sdk) {
 		fmt.Println("\n🎉 SUCCESS: Using the REAL DirectOutput SDK!")
 	} else {
-		fmt.Println("\n⚠️  NOTE: Using cross-platform fallback")
+		fmt.Println("\n⚠️  NOTE: Using native USB/HID transports")
 		fmt.Println("   This is normal for development/testing.")
 	}
 }
@@ -269,14 +262,42 @@ func createTestFileImage() image.Image {
 	return img
 }
 
-func drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
+}
+
+// openFIP returns the first FIP, or attaches a virtual FIP when there is
+// none and DirectOutput runs on the native transports
+func openFIP(do fip.DirectOutput) unsafe.Pointer {
+	deviceHandle, err := fip.FirstDevice(do, fip.DeviceTypeFip)
+	if err == nil {
+		return deviceHandle
 	}
-}
\ No newline at end of file
+	native, ok := do.(*fip.NativeDirectOutput)
+	if !ok {
+		log.Fatalf("No FIP found: %v", err)
+	}
+	fmt.Println("   No FIP found, using a virtual FIP")
+	return native.Attach(fip.NewVirtualFIP())
+}
+
+// savePNG saves an image as PNG for inspection
+func savePNG(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
+
+// isSDK reports whether DirectOutput runs on the SDK DLL rather than the
+// native transports
+func isSDK(do fip.DirectOutput) bool {
+	_, native := do.(*fip.NativeDirectOutput)
+	return !native
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"saitek-controller/internal/fip"
+)
+
+func main() {
+	var (
+		lines  = flag.String("lines", "SAITEK X52 PRO|MFD TEST|LONG LINES SCROLL ACROSS THE MFD", "MFD lines separated by |")
+		leds   = flag.String("leds", "fire_a=green,fire_b=amber,t1=red,throttle=on", "LED colours as name=colour pairs")
+		scroll = flag.Duration("scroll", 300*time.Millisecond, "Scroll interval for lines over 16 characters")
+	)
+	flag.Parse()
+
+	do, err := fip.NewDirectOutput()
+	if err != nil {
+		log.Fatalf("Failed to create DirectOutput: %v", err)
+	}
+	if err := do.Initialize("X52 Pro MFD"); err != nil {
+		log.Fatalf("Failed to initialize DirectOutput: %v", err)
+	}
+	defer do.Deinitialize()
+
+	x52, err := fip.OpenX52Pro(do)
+	if err != nil {
+		native, ok := do.(*fip.NativeDirectOutput)
+		if !ok {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Println("No X52 Pro found, using a virtual X52 Pro")
+		if x52, err = fip.NewX52Pro(do, native.Attach(fip.NewVirtualDevice(fip.DeviceTypeX52Pro))); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	x52.SetCallbacks(fip.PageCallbacks{
+		OnPageChanged: func(page uint32, active bool) {
+			log.Printf("Page %d active: %v", page, active)
+		},
+		OnSoftButtonChanged: func(buttons uint32) {
+			log.Printf("Soft buttons: select=%v up=%v down=%v",
+				buttons&fip.SoftButtonSelect != 0, buttons&fip.SoftButtonUp != 0, buttons&fip.SoftButtonDown != 0)
+		},
+	})
+
+	if err := x52.AddPage(1, "MFD Test", true); err != nil {
+		log.Fatalf("Failed to add page: %v", err)
+	}
+	for i, line := range strings.SplitN(*lines, "|", fip.X52ProMFDLines) {
+		if err := x52.SetLine(1, i, line); err != nil {
+			log.Fatalf("Failed to set line %d: %v", i, err)
+		}
+	}
+	if err := setLEDs(x52, 1, *leds); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	x52.Start(*scroll)
+	defer x52.Stop()
+
+	fmt.Println("Showing the MFD page; press Ctrl+C to exit")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+}
+
+// setLEDs sets LEDs from name=colour pairs; "on" is green
+func setLEDs(x52 *fip.X52Pro, page uint32, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid LED setting: %s", pair)
+		}
+		led, err := fip.ParseX52ProLED(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		value = strings.TrimSpace(value)
+		if value == "on" {
+			value = "green"
+		}
+		color, err := fip.ParseLEDColor(value)
+		if err != nil {
+			return err
+		}
+		if err := x52.SetLED(page, led, color); err != nil {
+			return fmt.Errorf("failed to set %v: %w", led, err)
+		}
+	}
+	return nil
+}
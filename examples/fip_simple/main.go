@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"saitek-controller/internal/fip"
+	"saitek-controller/internal/preview"
 
 	"github.com/faiface/pixel/pixelgl"
 )
@@ -24,6 +25,13 @@ func main() {
 		}
 		defer panel.Close()
 
+		// Show frames in a preview window
+		window, err := preview.NewWindow("FIP Test", 320, 240)
+		if err != nil {
+			log.Fatalf("Failed to create preview window: %v", err)
+		}
+		panel.AddSink(window)
+
 		// Try to connect to physical device
 		fmt.Println("Attempting to connect to FIP device...")
 		if err := panel.Connect(); err != nil {
@@ -35,8 +43,7 @@ func main() {
 
 		// Animation loop
 		startTime := time.Now()
-		window := panel.GetWindow()
-		for window != nil && !window.Closed() {
+		for !window.Closed() {
 			// Calculate time-based animation
 			elapsed := time.Since(startTime).Seconds()
 
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/faiface/pixel v0.10.0
 	golang.org/x/image v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -19,4 +20,5 @@ require (
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/sstallion/go-hid v0.15.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,371 @@
+// Package bmp reads and writes 24 and 32 bits per pixel Windows bitmaps and
+// converts images to the raw frame buffer of the Saitek FIP, which has the
+// same layout as the pixel data of a 24bpp bottom-up bitmap.
+package bmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+const (
+	fileHeaderSize = 14
+	infoHeaderSize = 40  // BITMAPINFOHEADER
+	v4HeaderSize   = 108 // BITMAPV4HEADER
+	v5HeaderSize   = 124 // BITMAPV5HEADER
+
+	biRGB            = 0
+	biBitfields      = 3
+	biAlphaBitfields = 6
+
+	// pixelsPerMeter is 72 DPI, written as the resolution of new files
+	pixelsPerMeter = 2835
+
+	// maxDimension and maxPixels bound the image Decode allocates, since
+	// the header can claim any size
+	maxDimension = 16384
+	maxPixels    = 1 << 25
+)
+
+// Channel masks for 32bpp BGRA pixels
+const (
+	maskRed   = 0x00ff0000
+	maskGreen = 0x0000ff00
+	maskBlue  = 0x000000ff
+	maskAlpha = 0xff000000
+)
+
+// ErrUnsupported is returned for valid bitmaps this package cannot read,
+// such as palette or compressed images
+var ErrUnsupported = errors.New("bmp: unsupported format")
+
+// ErrTooLarge is returned by Decode for images larger than it will allocate
+var ErrTooLarge = errors.New("bmp: image too large")
+
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", Decode, DecodeConfig)
+}
+
+// header is the part of the file and info headers needed to read pixels
+type header struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	offset        int
+	alpha         bool // 32bpp with an alpha channel mask
+	detectAlpha   bool // 32bpp BI_RGB, where the fourth byte may be alpha
+}
+
+// Options configures encoding
+type Options struct {
+	BitsPerPixel int  // 24 (default) or 32 with alpha
+	TopDown      bool // store the first row first, instead of the last
+}
+
+// readHeader reads the file and info headers up to the pixel data
+func readHeader(r io.Reader) (*header, error) {
+	var buf [fileHeaderSize + 4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, fmt.Errorf("bmp: failed to read header: %w", err)
+	}
+	if buf[0] != 'B' || buf[1] != 'M' {
+		return nil, errors.New("bmp: not a BMP file")
+	}
+
+	h := &header{offset: int(binary.LittleEndian.Uint32(buf[10:]))}
+	size := int(binary.LittleEndian.Uint32(buf[14:]))
+	switch size {
+	case infoHeaderSize, v4HeaderSize, v5HeaderSize:
+	default:
+		return nil, fmt.Errorf("%w: %d byte info header", ErrUnsupported, size)
+	}
+
+	info := make([]byte, size-4)
+	if _, err := io.ReadFull(r, info); err != nil {
+		return nil, fmt.Errorf("bmp: failed to read info header: %w", err)
+	}
+	width := int(int32(binary.LittleEndian.Uint32(info[0:])))
+	height := int(int32(binary.LittleEndian.Uint32(info[4:])))
+	planes := binary.LittleEndian.Uint16(info[8:])
+	h.bpp = int(binary.LittleEndian.Uint16(info[10:]))
+	compression := binary.LittleEndian.Uint32(info[12:])
+
+	if height < 0 {
+		height = -height
+		h.topDown = true
+	}
+	if width <= 0 || height <= 0 || planes != 1 {
+		return nil, fmt.Errorf("bmp: invalid size %dx%d", width, height)
+	}
+	h.width, h.height = width, height
+
+	// Masks follow a BITMAPINFOHEADER, or are part of the V4 and V5 headers
+	var masks []byte
+	switch compression {
+	case biRGB:
+	case biBitfields, biAlphaBitfields:
+		n := 12
+		if compression == biAlphaBitfields {
+			n = 16
+		}
+		if size == infoHeaderSize {
+			masks = make([]byte, n)
+			if _, err := io.ReadFull(r, masks); err != nil {
+				return nil, fmt.Errorf("bmp: failed to read channel masks: %w", err)
+			}
+			size += n
+		} else {
+			masks = info[36:52]
+		}
+	default:
+		return nil, fmt.Errorf("%w: compression %d", ErrUnsupported, compression)
+	}
+
+	switch {
+	case h.bpp == 24 && compression == biRGB:
+	case h.bpp == 32 && compression == biRGB:
+		h.detectAlpha = true
+	case h.bpp == 32 && masks != nil:
+		if binary.LittleEndian.Uint32(masks[0:]) != maskRed ||
+			binary.LittleEndian.Uint32(masks[4:]) != maskGreen ||
+			binary.LittleEndian.Uint32(masks[8:]) != maskBlue {
+			return nil, fmt.Errorf("%w: channel masks other than BGRA", ErrUnsupported)
+		}
+		if len(masks) >= 16 {
+			h.alpha = binary.LittleEndian.Uint32(masks[12:]) == maskAlpha
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d bits per pixel", ErrUnsupported, h.bpp)
+	}
+
+	// Skip anything between the headers and the pixels, such as a color table
+	read := fileHeaderSize + size
+	if h.offset < read {
+		return nil, fmt.Errorf("bmp: invalid pixel data offset %d", h.offset)
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(h.offset-read)); err != nil {
+		return nil, fmt.Errorf("bmp: failed to read pixel data: %w", err)
+	}
+	return h, nil
+}
+
+// DecodeConfig returns the size and color model of a BMP image
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.RGBAModel
+	if h.alpha || h.detectAlpha {
+		model = color.NRGBAModel
+	}
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+// Decode reads a BMP image. 24bpp images and 32bpp images without alpha
+// decode to *image.RGBA, 32bpp images with alpha to *image.NRGBA. A 32bpp
+// BI_RGB image is read as having alpha unless its fourth bytes are all zero.
+func Decode(r io.Reader) (image.Image, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.width > maxDimension || h.height > maxDimension || h.width*h.height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, h.width, h.height)
+	}
+	stride := rowSize(h.width, h.bpp)
+	if n, ok := remaining(r); ok && n < int64(stride)*int64(h.height) {
+		return nil, fmt.Errorf("bmp: pixel data truncated: %d of %d bytes", n, stride*h.height)
+	}
+
+	row := make([]byte, stride)
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	opaque := true
+	for i := 0; i < h.height; i++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, fmt.Errorf("bmp: failed to read pixel data: %w", err)
+		}
+		y := i
+		if !h.topDown {
+			y = h.height - 1 - i
+		}
+		dst := img.Pix[y*img.Stride : y*img.Stride+h.width*4]
+		for x := 0; x < h.width; x++ {
+			s := row[x*h.bpp/8:]
+			d := dst[x*4 : x*4+4]
+			d[0], d[1], d[2], d[3] = s[2], s[1], s[0], 0xff
+			if h.bpp == 32 && (h.alpha || h.detectAlpha) {
+				d[3] = s[3]
+				if s[3] != 0xff {
+					opaque = false
+				}
+			}
+		}
+	}
+
+	// BI_RGB files usually leave the fourth byte zero, meaning no alpha
+	if h.detectAlpha && !opaque && allZeroAlpha(img) {
+		setOpaque(img)
+		opaque = true
+	}
+	if h.alpha || (h.detectAlpha && !opaque) {
+		return img, nil
+	}
+	// Opaque NRGBA and RGBA pixels are the same bytes
+	return &image.RGBA{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect}, nil
+}
+
+// Encode writes an image as a BMP. With nil options it writes a 24bpp
+// bottom-up bitmap, the format DirectOutput expects for FIP images.
+// Transparent pixels in 24bpp output are composited over black.
+func Encode(w io.Writer, img image.Image, o *Options) error {
+	bpp, topDown := 24, false
+	if o != nil {
+		topDown = o.TopDown
+		switch o.BitsPerPixel {
+		case 0, 24:
+		case 32:
+			bpp = 32
+		default:
+			return fmt.Errorf("bmp: cannot encode %d bits per pixel", o.BitsPerPixel)
+		}
+	}
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 {
+		return errors.New("bmp: cannot encode an empty image")
+	}
+
+	infoSize := infoHeaderSize
+	compression := uint32(biRGB)
+	if bpp == 32 {
+		infoSize = v4HeaderSize
+		compression = biBitfields
+	}
+	offset := fileHeaderSize + infoSize
+	imageSize := rowSize(width, bpp) * height
+
+	hdr := make([]byte, offset)
+	hdr[0], hdr[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(hdr[2:], uint32(offset+imageSize))
+	binary.LittleEndian.PutUint32(hdr[10:], uint32(offset))
+
+	info := hdr[fileHeaderSize:]
+	binary.LittleEndian.PutUint32(info[0:], uint32(infoSize))
+	binary.LittleEndian.PutUint32(info[4:], uint32(width))
+	h := int32(height)
+	if topDown {
+		h = -h
+	}
+	binary.LittleEndian.PutUint32(info[8:], uint32(h))
+	binary.LittleEndian.PutUint16(info[12:], 1)
+	binary.LittleEndian.PutUint16(info[14:], uint16(bpp))
+	binary.LittleEndian.PutUint32(info[16:], compression)
+	binary.LittleEndian.PutUint32(info[20:], uint32(imageSize))
+	binary.LittleEndian.PutUint32(info[24:], pixelsPerMeter)
+	binary.LittleEndian.PutUint32(info[28:], pixelsPerMeter)
+	if bpp == 32 {
+		binary.LittleEndian.PutUint32(info[40:], maskRed)
+		binary.LittleEndian.PutUint32(info[44:], maskGreen)
+		binary.LittleEndian.PutUint32(info[48:], maskBlue)
+		binary.LittleEndian.PutUint32(info[52:], maskAlpha)
+		copy(info[56:], "BGRs") // LCS_WINDOWS_COLOR_SPACE, little endian
+	}
+
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := w.Write(pixelData(img, width, height, bpp, topDown)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// pixelData returns the BGR or BGRA rows of the top-left width x height
+// pixels of an image, padded to four bytes
+func pixelData(img image.Image, width, height, bpp int, topDown bool) []byte {
+	b := img.Bounds()
+	var pix []byte
+	var stride int
+	if bpp == 32 {
+		src := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+		pix, stride = src.Pix, src.Stride
+	} else {
+		// Premultiplied color is the same as compositing over black
+		src := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+		pix, stride = src.Pix, src.Stride
+	}
+
+	size := rowSize(width, bpp)
+	data := make([]byte, size*height)
+	for y := 0; y < height; y++ {
+		row := y
+		if !topDown {
+			row = height - 1 - y
+		}
+		dst := data[row*size:]
+		src := pix[y*stride:]
+		for x := 0; x < width; x++ {
+			s := src[x*4:]
+			d := dst[x*bpp/8:]
+			d[0], d[1], d[2] = s[2], s[1], s[0]
+			if bpp == 32 {
+				d[3] = s[3]
+			}
+		}
+	}
+	return data
+}
+
+// remaining returns how many bytes are left in a reader whose size is known
+func remaining(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - cur, true
+	}
+	return 0, false
+}
+
+// rowSize returns the bytes per row, padded to a multiple of four
+func rowSize(width, bpp int) int {
+	return (width*bpp + 31) / 32 * 4
+}
+
+// allZeroAlpha reports whether every alpha value of an image is zero
+func allZeroAlpha(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// setOpaque sets every alpha value of an image to opaque
+func setOpaque(img *image.NRGBA) {
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+}
@@ -0,0 +1,239 @@
+package bmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	xbmp "golang.org/x/image/bmp"
+)
+
+// testImage returns a small image with distinct, partly transparent pixels
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 40), uint8(y * 50), uint8(x + y), uint8(255 - x*20)})
+		}
+	}
+	return img
+}
+
+// opaque returns a copy of an image with every pixel made opaque
+func opaque(src *image.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(src.Bounds())
+	copy(img.Pix, src.Pix)
+	setOpaque(img)
+	return img
+}
+
+// sameColors reports whether two images have the same colors
+func sameColors(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if color.NRGBAModel.Convert(a.At(x, y)) != color.NRGBAModel.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := testImage(5, 3) // 5 pixels makes 24bpp rows need padding
+
+	for _, o := range []*Options{
+		nil,
+		{TopDown: true},
+		{BitsPerPixel: 32},
+		{BitsPerPixel: 32, TopDown: true},
+	} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, opaque(src), o); err != nil {
+			t.Fatalf("%+v: failed to encode: %v", o, err)
+		}
+		data := buf.Bytes()
+
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%+v: failed to decode: %v", o, err)
+		}
+		if !sameColors(img, opaque(src)) {
+			t.Errorf("%+v: decoded image differs", o)
+		}
+
+		// Check against an independent decoder
+		ximg, err := xbmp.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%+v: x/image/bmp failed to decode: %v", o, err)
+		}
+		if !sameColors(ximg, opaque(src)) {
+			t.Errorf("%+v: x/image/bmp decoded a different image", o)
+		}
+	}
+}
+
+func TestAlpha(t *testing.T) {
+	src := testImage(4, 4)
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, &Options{BitsPerPixel: 32}); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if _, ok := img.(*image.NRGBA); !ok || !sameColors(img, src) {
+		t.Errorf("Expected alpha to survive a 32bpp round trip, got %T", img)
+	}
+
+	// 24bpp composites over black
+	half := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	half.SetNRGBA(0, 0, color.NRGBA{200, 100, 0, 128})
+	buf.Reset()
+	Encode(&buf, half, nil)
+	img, err = Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if c := img.At(0, 0).(color.RGBA); c.R != 100 || c.G != 50 || c.A != 255 {
+		t.Errorf("Expected pixel composited over black, got %v", c)
+	}
+}
+
+func TestDecodeBIRGB32(t *testing.T) {
+	// A 2x1 32bpp BI_RGB bitmap with the fourth byte left zero
+	data := make([]byte, 54+8)
+	data[0], data[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(data[10:], 54)
+	binary.LittleEndian.PutUint32(data[14:], 40)
+	binary.LittleEndian.PutUint32(data[18:], 2)
+	binary.LittleEndian.PutUint32(data[22:], 1)
+	binary.LittleEndian.PutUint16(data[26:], 1)
+	binary.LittleEndian.PutUint16(data[28:], 32)
+	copy(data[54:], []byte{0x10, 0x20, 0x30, 0, 0x40, 0x50, 0x60, 0})
+
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if got := img.At(0, 0); got != (color.RGBA{0x30, 0x20, 0x10, 0xff}) {
+		t.Errorf("Expected opaque BGR pixel, got %v", got)
+	}
+
+	// 8bpp palette images are not supported
+	binary.LittleEndian.PutUint16(data[28:], 8)
+	if _, err := Decode(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestDecodeSizeLimits(t *testing.T) {
+	// A header claiming a huge image, without the pixel data
+	data := make([]byte, 54+16)
+	data[0], data[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(data[10:], 54)
+	binary.LittleEndian.PutUint32(data[14:], 40)
+	binary.LittleEndian.PutUint32(data[18:], 200000)
+	binary.LittleEndian.PutUint32(data[22:], 200000)
+	binary.LittleEndian.PutUint16(data[26:], 1)
+	binary.LittleEndian.PutUint16(data[28:], 24)
+
+	if _, err := Decode(bytes.NewReader(data)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+	cfg, err := DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 200000 {
+		t.Errorf("Expected DecodeConfig to report the header size, got %v, %v", cfg, err)
+	}
+
+	// Within the limits, but with less pixel data than the header needs
+	binary.LittleEndian.PutUint32(data[18:], 4000)
+	binary.LittleEndian.PutUint32(data[22:], 4000)
+	_, err = Decode(bytes.NewReader(data))
+	if err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected truncated pixel data error, got %v", err)
+	}
+}
+
+func TestImageDecodeRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	Encode(&buf, testImage(3, 3), nil)
+	_, format, err := image.Decode(&buf)
+	if err != nil || format != "bmp" {
+		t.Errorf("Expected image.Decode to read BMP, got %q, %v", format, err)
+	}
+}
+
+func TestFIPBuffer(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, FIPWidth, FIPHeight))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+	img.Set(0, FIPHeight-1, color.RGBA{1, 2, 3, 255})
+	img.Set(FIPWidth-1, 0, color.RGBA{4, 5, 6, 255})
+
+	buf := FIPBuffer(img)
+	if len(buf) != FIPBufferSize || FIPBufferSize != 230400 {
+		t.Fatalf("Expected %d byte buffer, got %d", FIPBufferSize, len(buf))
+	}
+	// Bottom-left pixel first, in BGR order; top-right pixel last
+	if !bytes.Equal(buf[:3], []byte{3, 2, 1}) || !bytes.Equal(buf[len(buf)-3:], []byte{6, 5, 4}) {
+		t.Errorf("Unexpected buffer layout: %v ... %v", buf[:3], buf[len(buf)-3:])
+	}
+
+	// The buffer is the pixel data of a bottom-up 24bpp BMP
+	var file bytes.Buffer
+	Encode(&file, img, nil)
+	if !bytes.Equal(file.Bytes()[54:], buf) {
+		t.Error("Expected buffer to match BMP pixel data")
+	}
+
+	back, err := FIPImage(buf)
+	if err != nil {
+		t.Fatalf("Failed to convert buffer: %v", err)
+	}
+	if !sameColors(back, img) {
+		t.Error("Expected buffer to convert back to the image")
+	}
+	if _, err := FIPImage(buf[1:]); err == nil {
+		t.Error("Expected error for short buffer")
+	}
+
+	// Smaller images are padded with black
+	small := FIPBuffer(testImage(2, 2))
+	if len(small) != FIPBufferSize || small[0] != 0 {
+		t.Errorf("Expected padded buffer, got %d bytes", len(small))
+	}
+}
+
+func TestUpdateFIPBuffer(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, FIPWidth, FIPHeight))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+	buf := FIPBuffer(img)
+
+	changed := image.Rect(300, 10, 330, 20) // partly off the display
+	draw.Draw(img, changed, &image.Uniform{color.RGBA{10, 20, 30, 255}}, image.Point{}, draw.Src)
+	if err := UpdateFIPBuffer(buf, img, changed); err != nil {
+		t.Fatalf("Failed to update buffer: %v", err)
+	}
+	if !bytes.Equal(buf, FIPBuffer(img)) {
+		t.Error("Expected the updated buffer to match a full conversion")
+	}
+
+	// Other image types are converted pixel by pixel, alpha over black
+	nrgba := testImage(4, 4)
+	buf = FIPBuffer(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	UpdateFIPBuffer(buf, nrgba, nrgba.Bounds())
+	if !bytes.Equal(buf, FIPBuffer(nrgba)) {
+		t.Error("Expected the updated buffer to match a full conversion of an NRGBA image")
+	}
+	if err := UpdateFIPBuffer(buf[1:], img, changed); err == nil {
+		t.Error("Expected error for short buffer")
+	}
+}
@@ -0,0 +1,69 @@
+package bmp
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// FIP display size and the size of its raw frame buffer
+const (
+	FIPWidth      = 320
+	FIPHeight     = 240
+	FIPBufferSize = FIPWidth * FIPHeight * 3 // 230,400 bytes
+)
+
+// FIPBuffer converts an image to the frame buffer DirectOutput's SetImage
+// expects for a FIP: 320x240 pixels of 24bpp BGR, bottom row first, which is
+// the pixel data of a bottom-up 24bpp BMP. The top-left 320x240 pixels of
+// the image are used, anything outside the image is black and transparent
+// pixels are composited over black.
+func FIPBuffer(img image.Image) []byte {
+	return pixelData(img, FIPWidth, FIPHeight, 24, false)
+}
+
+// UpdateFIPBuffer converts the pixels of img inside r into buf, a frame
+// buffer made by FIPBuffer from an earlier image with the same bounds, so
+// only the changed parts of a frame have to be converted
+func UpdateFIPBuffer(buf []byte, img image.Image, r image.Rectangle) error {
+	if len(buf) != FIPBufferSize {
+		return fmt.Errorf("bmp: FIP buffer is %d bytes, expected %d", len(buf), FIPBufferSize)
+	}
+
+	b := img.Bounds()
+	r = r.Intersect(b).Intersect(image.Rect(0, 0, FIPWidth, FIPHeight).Add(b.Min))
+	rgba, _ := img.(*image.RGBA)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dst := buf[(FIPHeight-1-(y-b.Min.Y))*FIPWidth*3:]
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var c color.RGBA
+			if rgba != nil {
+				i := rgba.PixOffset(x, y)
+				c = color.RGBA{rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3]}
+			} else {
+				// Premultiplied color is the same as compositing over black
+				c = color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			}
+			d := dst[(x-b.Min.X)*3:]
+			d[0], d[1], d[2] = c.B, c.G, c.R
+		}
+	}
+	return nil
+}
+
+// FIPImage converts a FIP frame buffer back into an image
+func FIPImage(buf []byte) (*image.RGBA, error) {
+	if len(buf) != FIPBufferSize {
+		return nil, fmt.Errorf("bmp: FIP buffer is %d bytes, expected %d", len(buf), FIPBufferSize)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, FIPWidth, FIPHeight))
+	for y := 0; y < FIPHeight; y++ {
+		src := buf[(FIPHeight-1-y)*FIPWidth*3:]
+		dst := img.Pix[y*img.Stride:]
+		for x := 0; x < FIPWidth; x++ {
+			dst[x*4], dst[x*4+1], dst[x*4+2], dst[x*4+3] = src[x*3+2], src[x*3+1], src[x*3], 0xff
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,220 @@
+// Package checklist loads aircraft checklists from YAML or Markdown files
+// and shows them as a FIP page, stepping through the items with the soft
+// buttons and dials and remembering progress per flight.
+package checklist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Checklist is a named list of sections, such as the normal procedures
+// of one aircraft
+type Checklist struct {
+	Name     string    `yaml:"name"`
+	Sections []Section `yaml:"sections"`
+}
+
+// Section is one phase of a checklist, such as "Before Start"
+type Section struct {
+	Name  string `yaml:"name"`
+	Items []Item `yaml:"items"`
+}
+
+// Item is a challenge and its expected state, such as "Parking brake" and
+// "SET". Expect may be empty for items that are only read.
+type Item struct {
+	Text   string `yaml:"item"`
+	Expect string `yaml:"expect,omitempty"`
+}
+
+// UnmarshalYAML accepts an item as a mapping or as a string such as
+// "Parking brake ... SET"
+func (i *Item) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*i = splitItem(node.Value)
+		return nil
+	}
+	type plain Item
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*i = Item(p)
+	return nil
+}
+
+// itemSeparator splits a challenge from its expected state: a run of dots
+// or a dash surrounded by spaces
+var itemSeparator = regexp.MustCompile(`\s*\.{2,}\s*|\s+[-–—]{1,2}\s+`)
+
+// splitItem parses "Challenge ... EXPECT" into an item
+func splitItem(s string) Item {
+	s = strings.TrimSpace(s)
+	loc := itemSeparator.FindAllStringIndex(s, -1)
+	if len(loc) == 0 {
+		return Item{Text: s}
+	}
+	last := loc[len(loc)-1]
+	return Item{Text: strings.TrimSpace(s[:last[0]]), Expect: strings.TrimSpace(s[last[1]:])}
+}
+
+// Items returns the number of items in all sections
+func (c *Checklist) Items() int {
+	n := 0
+	for _, s := range c.Sections {
+		n += len(s.Items)
+	}
+	return n
+}
+
+// validate checks that the checklist has a name and every section has items
+func (c *Checklist) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("checklist has no name")
+	}
+	if len(c.Sections) == 0 {
+		return fmt.Errorf("checklist %q has no sections", c.Name)
+	}
+	seen := make(map[string]bool)
+	for i, s := range c.Sections {
+		if s.Name == "" {
+			return fmt.Errorf("section %d of %q has no name", i+1, c.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("checklist %q has two sections named %q", c.Name, s.Name)
+		}
+		seen[s.Name] = true
+		if len(s.Items) == 0 {
+			return fmt.Errorf("section %q has no items", s.Name)
+		}
+		for j, item := range s.Items {
+			if item.Text == "" {
+				return fmt.Errorf("item %d of section %q has no text", j+1, s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Parse reads a checklist in the given format: "yaml", "yml", "md" or
+// "markdown"
+func Parse(data []byte, format string) (*Checklist, error) {
+	var c *Checklist
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		c = &Checklist{}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("failed to parse checklist YAML: %w", err)
+		}
+	case "md", "markdown":
+		var err error
+		if c, err = parseMarkdown(data); err != nil {
+			return nil, fmt.Errorf("failed to parse checklist Markdown: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported checklist format: %s", format)
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load reads a checklist file, choosing the format by extension
+func Load(filename string) (*Checklist, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checklist: %w", err)
+	}
+	c, err := Parse(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// LoadAll loads checklist files and directories of them, in name order
+// within each directory
+func LoadAll(paths ...string) ([]*Checklist, error) {
+	var lists []*Checklist
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open checklist: %w", err)
+		}
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			for _, pattern := range []string{"*.yaml", "*.yml", "*.md"} {
+				matches, _ := filepath.Glob(filepath.Join(path, pattern))
+				files = append(files, matches...)
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			c, err := Load(file)
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, c)
+		}
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("no checklists found in %s", strings.Join(paths, ", "))
+	}
+	return lists, nil
+}
+
+// markdownItem matches a list item, with an optional task checkbox
+var markdownItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?(.*)$`)
+
+// parseMarkdown reads a checklist written as a Markdown document: the
+// first "#" heading names the checklist, "##" headings start sections and
+// list items are the items
+func parseMarkdown(data []byte) (*Checklist, error) {
+	c := &Checklist{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t")
+		switch {
+		case strings.HasPrefix(text, "## "):
+			c.Sections = append(c.Sections, Section{Name: strings.TrimSpace(text[3:])})
+		case strings.HasPrefix(text, "# "):
+			if c.Name != "" {
+				return nil, fmt.Errorf("line %d: only one checklist per file", line)
+			}
+			c.Name = strings.TrimSpace(text[2:])
+		default:
+			m := markdownItem.FindStringSubmatch(text)
+			if m == nil {
+				// Paragraphs and other headings are notes for the reader
+				continue
+			}
+			if len(c.Sections) == 0 {
+				return nil, fmt.Errorf("line %d: item before the first ## section", line)
+			}
+			s := &c.Sections[len(c.Sections)-1]
+			s.Items = append(s.Items, splitItem(stripEmphasis(m[1])))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// stripEmphasis removes Markdown bold, italic and code markers
+func stripEmphasis(s string) string {
+	return strings.NewReplacer("**", "", "__", "", "`", "").Replace(s)
+}
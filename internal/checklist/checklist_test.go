@@ -0,0 +1,189 @@
+package checklist
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"saitek-controller/internal/fip"
+)
+
+const testYAML = `
+name: Test
+sections:
+  - name: Before Start
+    items:
+      - item: Parking brake
+        expect: SET
+      - Fuel selector ... BOTH
+      - Read the briefing
+  - name: Taxi
+    items:
+      - Brakes -- CHECK
+`
+
+const testMarkdown = `# Test
+
+Notes are ignored.
+
+## Before Start
+- [ ] Parking brake ... SET
+- [x] Fuel selector ..... **BOTH**
+* Read the briefing
+
+## Taxi
+1. Brakes — CHECK
+`
+
+func TestParseFormats(t *testing.T) {
+	for format, data := range map[string]string{"yaml": testYAML, "md": testMarkdown} {
+		c, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", format, err)
+		}
+		if c.Name != "Test" || len(c.Sections) != 2 || c.Items() != 4 {
+			t.Fatalf("%s: unexpected checklist %+v", format, c)
+		}
+		want := []Item{{"Parking brake", "SET"}, {"Fuel selector", "BOTH"}, {"Read the briefing", ""}}
+		for i, item := range want {
+			if c.Sections[0].Items[i] != item {
+				t.Errorf("%s: item %d is %+v, want %+v", format, i, c.Sections[0].Items[i], item)
+			}
+		}
+		if got := c.Sections[1].Items[0]; got != (Item{"Brakes", "CHECK"}) {
+			t.Errorf("%s: taxi item is %+v", format, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, tc := range map[string]struct{ data, format string }{
+		"unknown field":  {"name: x\nsection: []\n", "yaml"},
+		"no sections":    {"name: x\n", "yaml"},
+		"empty section":  {"name: x\nsections: [{name: a}]\n", "yaml"},
+		"duplicate":      {"# x\n## a\n- one\n## a\n- two\n", "md"},
+		"item first":     {"# x\n- one\n## a\n", "md"},
+		"two checklists": {"# x\n## a\n- one\n# y\n", "md"},
+		"unknown format": {"", "toml"},
+		"no name":        {"## a\n- one\n", "md"},
+	} {
+		if _, err := Parse([]byte(tc.data), tc.format); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadAssets(t *testing.T) {
+	lists, err := LoadAll(filepath.Join("..", "..", "assets", "checklists"))
+	if err != nil {
+		t.Fatalf("Failed to load example checklists: %v", err)
+	}
+	if len(lists) != 2 || lists[0].Name != "C172 Emergency Procedures" {
+		t.Errorf("Unexpected checklists %v", lists)
+	}
+}
+
+func TestProgressStore(t *testing.T) {
+	c, _ := Parse([]byte(testYAML), "yaml")
+	dir := t.TempDir()
+	store, err := OpenProgress(dir, "EGLL/LFPG 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(store.Path()) != "EGLL_LFPG_1.json" {
+		t.Errorf("Unexpected progress file %s", store.Path())
+	}
+
+	p := store.Progress(c)
+	p.Checked["Taxi"][0] = true
+	p.Section, p.Item = 1, 0
+	if err := store.Save(c.Name, p); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	// A different flight starts fresh, the same flight carries on
+	other, _ := OpenProgress(dir, "other")
+	if p := other.Progress(c); p.Checked["Taxi"][0] || p.Section != 0 {
+		t.Errorf("Expected a new flight to start fresh, got %+v", p)
+	}
+	reopened, err := OpenProgress(dir, "EGLL/LFPG 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := reopened.Progress(c); !p.Checked["Taxi"][0] || p.Section != 1 || len(p.Checked["Before Start"]) != 3 {
+		t.Errorf("Progress was not restored: %+v", p)
+	}
+}
+
+func TestPageInput(t *testing.T) {
+	c, _ := Parse([]byte(testYAML), "yaml")
+	store := NewMemoryProgress()
+	page, err := NewPage([]*Checklist{c}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	press := func(control fip.InputControl) {
+		page.HandleInput(fip.InputEvent{Control: control, Pressed: true})
+		page.HandleInput(fip.InputEvent{Control: control, Pressed: false})
+	}
+
+	// Skip the first item, check the second: the cursor wraps back to it
+	page.HandleInput(fip.InputEvent{Control: fip.InputRightDialCW, Pressed: true})
+	press(fip.InputButton1)
+	if section, item := page.Position(); !page.Checked(0, 1) || section != 0 || item != 2 {
+		t.Errorf("Expected item 1 checked and cursor on 2, got %d/%d", section, item)
+	}
+	press(fip.InputButton1)
+	if _, item := page.Position(); item != 0 {
+		t.Errorf("Expected cursor back on the unchecked item 0, got %d", item)
+	}
+	press(fip.InputButton1)
+	if !page.SectionComplete(0) {
+		t.Error("Expected the section to be complete")
+	}
+
+	// S1 on a complete section moves to the next one
+	press(fip.InputButton1)
+	if section, item := page.Position(); section != 1 || item != 0 {
+		t.Errorf("Expected to move to the next section, got %d/%d", section, item)
+	}
+	// Back on a complete section the cursor rests on the last item
+	page.HandleInput(fip.InputEvent{Control: fip.InputLeftDialCCW, Pressed: true})
+	press(fip.InputButton2)
+	if page.SectionComplete(0) || page.Checked(0, 2) {
+		t.Error("Expected S2 to uncheck the last item")
+	}
+	press(fip.InputButton6)
+	if page.Checked(0, 0) || page.Checked(0, 1) {
+		t.Error("Expected S6 to reset the section")
+	}
+
+	if p := store.Progress(c); p.Section != 0 || p.Checked["Before Start"][1] {
+		t.Errorf("Expected changes to be saved, got %+v", p)
+	}
+}
+
+func TestPageRender(t *testing.T) {
+	c, _ := Parse([]byte(testYAML), "yaml")
+	page, _ := NewPage([]*Checklist{c}, nil)
+	page.Move(1)
+
+	s := fip.NewSurface(320, 240)
+	if err := page.Render(s); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	// The second row is highlighted, the first is not
+	if got := s.Image().RGBAAt(318, headerHeight+rowHeight+2); got != cursorColor {
+		t.Errorf("Expected cursor row color, got %v", got)
+	}
+	if got := s.Image().RGBAAt(318, headerHeight+2); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("Expected background on the first row, got %v", got)
+	}
+
+	page.Check()
+	page.Render(s)
+	if got := s.Image().RGBAAt(7, headerHeight+rowHeight+rowHeight/2-5); got != checkedBox {
+		t.Errorf("Expected a checked box on the second row, got %v", got)
+	}
+}
@@ -0,0 +1,354 @@
+package checklist
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"sync"
+
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+
+	"saitek-controller/internal/fip"
+	"saitek-controller/internal/render"
+	"saitek-controller/internal/text"
+)
+
+var (
+	pageBackground = color.RGBA{0, 0, 0, 255}
+	headerColor    = color.RGBA{20, 40, 90, 255}
+	cursorColor    = color.RGBA{90, 70, 0, 255}
+	checkedText    = color.RGBA{110, 110, 110, 255}
+	checkedBox     = color.RGBA{40, 180, 60, 255}
+	expectColor    = color.RGBA{80, 200, 255, 255}
+	completeColor  = color.RGBA{20, 110, 40, 255}
+	hintColor      = color.RGBA{150, 150, 150, 255}
+)
+
+const (
+	headerHeight = 36
+	footerHeight = 18
+	rowHeight    = 22
+)
+
+// Page shows checklists on a FIP and steps through them with the FIP
+// controls:
+//
+//   - right dial: previous/next item
+//   - left dial: previous/next section
+//   - S1: check the item and move to the next unchecked one, or to the
+//     next section once the section is complete
+//   - S2: uncheck the item
+//   - S3: next checklist
+//   - S6: uncheck every item in the section
+//
+// Every change is saved to the progress store.
+type Page struct {
+	mu       sync.Mutex
+	lists    []*Checklist
+	store    *ProgressStore
+	list     int
+	progress Progress
+
+	titleFace  font.Face
+	headFace   font.Face
+	itemFace   font.Face
+	expectFace font.Face
+	hintFace   font.Face
+}
+
+// NewPage creates a page showing lists, restoring their progress from store
+func NewPage(lists []*Checklist, store *ProgressStore) (*Page, error) {
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("no checklists")
+	}
+	if store == nil {
+		store = NewMemoryProgress()
+	}
+	p := &Page{
+		lists:      lists,
+		store:      store,
+		titleFace:  text.Regular(11),
+		headFace:   text.Bold(14),
+		itemFace:   text.Regular(13),
+		expectFace: text.Bold(13),
+		hintFace:   text.Regular(10),
+	}
+	p.progress = store.Progress(lists[0])
+	return p, nil
+}
+
+// Checklist returns the checklist being shown
+func (p *Page) Checklist() *Checklist {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lists[p.list]
+}
+
+// Position returns the current section and item
+func (p *Page) Position() (section, item int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress.Section, p.progress.Item
+}
+
+// Checked reports whether an item of the current checklist is checked
+func (p *Page) Checked(section, item int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.lists[p.list]
+	if section < 0 || section >= len(c.Sections) {
+		return false
+	}
+	checked := p.progress.Checked[c.Sections[section].Name]
+	return item >= 0 && item < len(checked) && checked[item]
+}
+
+// SectionComplete reports whether every item of a section is checked
+func (p *Page) SectionComplete(section int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.complete(section)
+}
+
+// complete is SectionComplete with the lock held
+func (p *Page) complete(section int) bool {
+	c := p.lists[p.list]
+	if section < 0 || section >= len(c.Sections) {
+		return false
+	}
+	for _, checked := range p.progress.Checked[c.Sections[section].Name] {
+		if !checked {
+			return false
+		}
+	}
+	return true
+}
+
+// Check checks the current item and moves to the next unchecked item of
+// the section. On a complete section it moves to the next section instead.
+func (p *Page) Check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := p.lists[p.list]
+	if p.complete(p.progress.Section) {
+		if p.progress.Section+1 < len(c.Sections) {
+			p.progress.Section++
+			p.progress.Item = p.firstUnchecked(p.progress.Section, 0)
+		}
+		return p.save()
+	}
+
+	checked := p.progress.Checked[c.Sections[p.progress.Section].Name]
+	checked[p.progress.Item] = true
+	p.progress.Item = p.firstUnchecked(p.progress.Section, p.progress.Item)
+	return p.save()
+}
+
+// firstUnchecked returns the first unchecked item of a section from start
+// on, wrapping round, or the last item if all are checked
+func (p *Page) firstUnchecked(section, start int) int {
+	checked := p.progress.Checked[p.lists[p.list].Sections[section].Name]
+	for i := range checked {
+		if n := (start + i) % len(checked); !checked[n] {
+			return n
+		}
+	}
+	return len(checked) - 1
+}
+
+// Uncheck unchecks the current item
+func (p *Page) Uncheck() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.lists[p.list]
+	p.progress.Checked[c.Sections[p.progress.Section].Name][p.progress.Item] = false
+	return p.save()
+}
+
+// Move moves the cursor by delta items within the section
+func (p *Page) Move(delta int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	items := len(p.lists[p.list].Sections[p.progress.Section].Items)
+	p.progress.Item = clamp(p.progress.Item+delta, 0, items-1)
+	return p.save()
+}
+
+// MoveSection moves by delta sections, to the first unchecked item
+func (p *Page) MoveSection(delta int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Section = clamp(p.progress.Section+delta, 0, len(p.lists[p.list].Sections)-1)
+	p.progress.Item = p.firstUnchecked(p.progress.Section, 0)
+	return p.save()
+}
+
+// ResetSection unchecks every item of the current section
+func (p *Page) ResetSection() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.lists[p.list]
+	checked := p.progress.Checked[c.Sections[p.progress.Section].Name]
+	for i := range checked {
+		checked[i] = false
+	}
+	p.progress.Item = 0
+	return p.save()
+}
+
+// NextChecklist shows the next checklist, keeping each one's progress
+func (p *Page) NextChecklist() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.list = (p.list + 1) % len(p.lists)
+	p.progress = p.store.Progress(p.lists[p.list])
+}
+
+// save writes the progress of the current checklist
+func (p *Page) save() error {
+	return p.store.Save(p.lists[p.list].Name, p.progress)
+}
+
+// HandleInput steps through the checklist with the FIP controls and
+// reports whether the page changed
+func (p *Page) HandleInput(event fip.InputEvent) bool {
+	var err error
+	switch {
+	case event.Control == fip.InputRightDialCW || event.Control == fip.InputRightDialCCW:
+		err = p.Move(event.Delta())
+	case event.Control == fip.InputLeftDialCW || event.Control == fip.InputLeftDialCCW:
+		err = p.MoveSection(event.Delta())
+	case !event.Pressed:
+		return false
+	case event.Control == fip.InputButton1:
+		err = p.Check()
+	case event.Control == fip.InputButton2:
+		err = p.Uncheck()
+	case event.Control == fip.InputButton3:
+		p.NextChecklist()
+	case event.Control == fip.InputButton6:
+		err = p.ResetSection()
+	default:
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to save checklist progress: %v", err)
+	}
+	return true
+}
+
+// Render draws the current section with the cursor on the current item
+func (p *Page) Render(s *fip.Surface) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := p.lists[p.list]
+	section := c.Sections[p.progress.Section]
+	checked := p.progress.Checked[section.Name]
+	w, h := float64(s.Width()), float64(s.Height())
+	canvas := s.Canvas()
+	dst := s.Image()
+	canvas.Clear(pageBackground)
+
+	// Header: checklist, section and how many items are done
+	done := 0
+	for _, ok := range checked {
+		if ok {
+			done++
+		}
+	}
+	canvas.FillRect(0, 0, w, headerHeight, headerColor)
+	text.Draw(dst, c.Name, 6, 3, text.Style{Face: p.titleFace, Color: hintColor, VAlign: text.VAlignTop})
+	text.Draw(dst, fmt.Sprintf("%d/%d", p.progress.Section+1, len(c.Sections)), w-6, 3,
+		text.Style{Face: p.titleFace, Color: hintColor, Align: text.AlignRight, VAlign: text.VAlignTop})
+	count := fmt.Sprintf("%d/%d", done, len(checked))
+	countStyle := text.Style{Face: p.headFace, Color: colornames.White, Align: text.AlignRight, VAlign: text.VAlignTop}
+	text.Draw(dst, count, w-6, 17, countStyle)
+	headStyle := text.Style{Face: p.headFace, Color: colornames.White, VAlign: text.VAlignTop}
+	text.Draw(dst, fit(section.Name, w-18-text.Measure(count, countStyle).Width, headStyle), 6, 17, headStyle)
+
+	// Items, scrolled to keep the cursor in view
+	rows := int((h - headerHeight - footerHeight) / rowHeight)
+	first := clamp(p.progress.Item-rows/2, 0, len(section.Items)-rows)
+	for row := 0; row < rows && first+row < len(section.Items); row++ {
+		i := first + row
+		item := section.Items[i]
+		y := float64(headerHeight + row*rowHeight)
+		mid := y + rowHeight/2
+		if i == p.progress.Item {
+			canvas.FillRect(0, y, w, rowHeight, cursorColor)
+		}
+
+		// Checkbox
+		if checked[i] {
+			canvas.FillRect(6, mid-6, 12, 12, checkedBox)
+			tick := render.NewPath()
+			tick.MoveTo(8.5, mid)
+			tick.LineTo(11, mid+3)
+			tick.LineTo(15.5, mid-3.5)
+			canvas.Stroke(tick, colornames.White, render.Stroke(2))
+		} else {
+			canvas.Stroke(render.NewPath().Polygon(
+				render.Point{X: 6.5, Y: mid - 5.5}, render.Point{X: 17.5, Y: mid - 5.5},
+				render.Point{X: 17.5, Y: mid + 5.5}, render.Point{X: 6.5, Y: mid + 5.5},
+			), colornames.White, render.Stroke(1))
+		}
+
+		itemStyle := text.Style{Face: p.itemFace, Color: colornames.White, VAlign: text.VAlignMiddle}
+		expectStyle := text.Style{Face: p.expectFace, Color: expectColor, Align: text.AlignRight, VAlign: text.VAlignMiddle}
+		if checked[i] {
+			itemStyle.Color, expectStyle.Color = checkedText, checkedText
+		}
+		space := w - 30
+		if item.Expect != "" {
+			expect := fit(item.Expect, (w-30)/2, expectStyle)
+			text.Draw(dst, expect, w-6, mid, expectStyle)
+			space -= text.Measure(expect, expectStyle).Width + 10
+		}
+		text.Draw(dst, fit(item.Text, space, itemStyle), 24, mid, itemStyle)
+	}
+
+	// Footer: completion, or the control hints
+	y := h - footerHeight
+	if p.complete(p.progress.Section) {
+		canvas.FillRect(0, y, w, footerHeight, completeColor)
+		label := "SECTION COMPLETE"
+		if p.progress.Section+1 < len(c.Sections) {
+			label += "  -  S1: " + c.Sections[p.progress.Section+1].Name
+		}
+		text.Draw(dst, fit(label, w-8, text.Style{Face: p.hintFace}), w/2, y+footerHeight/2,
+			text.Style{Face: p.hintFace, Color: colornames.White, Align: text.AlignCenter, VAlign: text.VAlignMiddle})
+	} else {
+		text.Draw(dst, "S1 CHECK   S2 UNCHECK   S3 LIST   S6 RESET", w/2, y+footerHeight/2,
+			text.Style{Face: p.hintFace, Color: hintColor, Align: text.AlignCenter, VAlign: text.VAlignMiddle})
+	}
+	return nil
+}
+
+// fit shortens s with an ellipsis until it is at most width pixels wide
+func fit(s string, width float64, style text.Style) string {
+	if text.Measure(s, style).Width <= width {
+		return s
+	}
+	runes := []rune(s)
+	for n := len(runes) - 1; n > 0; n-- {
+		short := string(runes[:n]) + "..."
+		if text.Measure(short, style).Width <= width {
+			return short
+		}
+	}
+	return "..."
+}
+
+// clamp limits v to [min, max], preferring min when the range is empty
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
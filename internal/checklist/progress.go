@@ -0,0 +1,149 @@
+package checklist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is how far one checklist has got during a flight. Checked items
+// are kept per section name, so reordering sections keeps the progress.
+type Progress struct {
+	Checked map[string][]bool `json:"checked"`
+	Section int               `json:"section"`
+	Item    int               `json:"item"`
+}
+
+// flightFile is the JSON layout of a flight's progress file
+type flightFile struct {
+	Flight     string               `json:"flight"`
+	Updated    time.Time            `json:"updated"`
+	Checklists map[string]*Progress `json:"checklists"`
+}
+
+// ProgressStore keeps the progress of every checklist for one flight in a
+// JSON file, so a restart carries on where the crew left off
+type ProgressStore struct {
+	mu   sync.Mutex
+	path string
+	file flightFile
+}
+
+// OpenProgress opens the progress file for a flight in dir, starting fresh
+// if the flight has none yet
+func OpenProgress(dir, flight string) (*ProgressStore, error) {
+	if flight == "" {
+		return nil, fmt.Errorf("flight name is empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create progress directory: %w", err)
+	}
+	s := &ProgressStore{
+		path: filepath.Join(dir, flightFileName(flight)),
+		file: flightFile{Flight: flight, Checklists: make(map[string]*Progress)},
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.file); err != nil {
+		return nil, fmt.Errorf("failed to parse progress %s: %w", s.path, err)
+	}
+	if s.file.Checklists == nil {
+		s.file.Checklists = make(map[string]*Progress)
+	}
+	return s, nil
+}
+
+// NewMemoryProgress returns a store that is not saved to disk
+func NewMemoryProgress() *ProgressStore {
+	return &ProgressStore{file: flightFile{Checklists: make(map[string]*Progress)}}
+}
+
+// flightFileName makes a flight name safe to use as a file name
+func flightFileName(flight string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, flight)
+	return strings.TrimLeft(name, ".") + ".json"
+}
+
+// Flight returns the flight name
+func (s *ProgressStore) Flight() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Flight
+}
+
+// Path returns the progress file, or "" for a memory store
+func (s *ProgressStore) Path() string {
+	return s.path
+}
+
+// Progress returns a copy of a checklist's progress, sized to match it
+func (s *ProgressStore) Progress(c *Checklist) Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := Progress{Checked: make(map[string][]bool)}
+	saved := s.file.Checklists[c.Name]
+	if saved != nil {
+		p.Section, p.Item = saved.Section, saved.Item
+	}
+	for _, section := range c.Sections {
+		checked := make([]bool, len(section.Items))
+		if saved != nil {
+			copy(checked, saved.Checked[section.Name])
+		}
+		p.Checked[section.Name] = checked
+	}
+	if p.Section < 0 || p.Section >= len(c.Sections) {
+		p.Section, p.Item = 0, 0
+	}
+	if p.Item < 0 || p.Item >= len(c.Sections[p.Section].Items) {
+		p.Item = 0
+	}
+	return p
+}
+
+// Save records a checklist's progress and writes the progress file
+func (s *ProgressStore) Save(name string, p Progress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checked := make(map[string][]bool, len(p.Checked))
+	for section, items := range p.Checked {
+		checked[section] = append([]bool(nil), items...)
+	}
+	s.file.Checklists[name] = &Progress{Checked: checked, Section: p.Section, Item: p.Item}
+	s.file.Updated = time.Now().UTC()
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(&s.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode progress: %w", err)
+	}
+	// Write to a temporary file first so a crash never leaves half a file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,485 @@
+package fip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saitek-controller/internal/bmp"
+)
+
+const (
+	// defaultSequenceFPS is the frame rate of image sequences when none is given
+	defaultSequenceFPS = 10
+	// minGIFDelay is used for GIF frames with no or a near-zero delay, as
+	// browsers do
+	minGIFDelay = 100 * time.Millisecond
+)
+
+// AnimationFrame is one frame of an animation, pre-converted for the FIP
+type AnimationFrame struct {
+	Image  *image.RGBA
+	Buffer []byte // FIP frame buffer, see bmp.FIPBuffer
+	Delay  time.Duration
+}
+
+// Animation is a sequence of frames ready to play on a FIP
+type Animation struct {
+	Frames []AnimationFrame
+}
+
+// Len returns the number of frames
+func (a *Animation) Len() int {
+	return len(a.Frames)
+}
+
+// Duration returns the time one pass through the frames takes at their
+// native delays
+func (a *Animation) Duration() time.Duration {
+	var d time.Duration
+	for _, f := range a.Frames {
+		d += f.Delay
+	}
+	return d
+}
+
+// addFrame resizes and filters an image like LoadImageFromFile and appends
+// it to the animation
+func (loader *ImageLoader) addFrame(anim *Animation, img image.Image, delay time.Duration) error {
+	processed, err := loader.processImage(img)
+	if err != nil {
+		return err
+	}
+	frame := image.NewRGBA(image.Rect(0, 0, loader.FIPWidth, loader.FIPHeight))
+	draw.Draw(frame, frame.Bounds(), processed, processed.Bounds().Min, draw.Src)
+	anim.Frames = append(anim.Frames, AnimationFrame{
+		Image:  frame,
+		Buffer: bmp.FIPBuffer(frame),
+		Delay:  delay,
+	})
+	return nil
+}
+
+// LoadAnimation loads an animation from an animated GIF, an image sequence
+// given as a glob pattern such as "frames/*.png", or a single image
+func (loader *ImageLoader) LoadAnimation(filename string) (*Animation, error) {
+	if strings.ContainsAny(filename, "*?[") {
+		return loader.LoadImageSequence(filename, 0)
+	}
+	if strings.ToLower(filepath.Ext(filename)) == ".gif" {
+		return loader.LoadGIF(filename)
+	}
+
+	img, err := loader.LoadImageFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	anim := &Animation{}
+	if err := loader.addFrame(anim, img, time.Second); err != nil {
+		return nil, err
+	}
+	return anim, nil
+}
+
+// LoadGIF loads every frame of an animated GIF with its delay, applying the
+// GIF disposal methods so each frame is complete
+func (loader *ImageLoader) LoadGIF(filename string) (*Animation, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GIF: %v", err)
+	}
+	defer file.Close()
+
+	g, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %v", err)
+	}
+	if len(g.Image) == 0 {
+		return nil, fmt.Errorf("GIF has no frames: %s", filename)
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+
+	anim := &Animation{}
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		delay := minGIFDelay
+		if i < len(g.Delay) && g.Delay[i] > 1 {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		if err := loader.addFrame(anim, canvas, delay); err != nil {
+			return nil, fmt.Errorf("failed to process frame %d: %v", i, err)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	log.Printf("Loaded GIF: %s (%d frames, %v)", filename, anim.Len(), anim.Duration())
+	return anim, nil
+}
+
+// frameNumber matches the last number in a file name
+var frameNumber = regexp.MustCompile(`(\d+)\D*$`)
+
+// LoadImageSequence loads the images matching a glob pattern, such as
+// "frames/frame_*.png", in the order of the numbers in their names. Each
+// frame is shown for 1/fps seconds; fps <= 0 uses 10 frames per second.
+func (loader *ImageLoader) LoadImageSequence(pattern string, fps float64) (*Animation, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no images match %s", pattern)
+	}
+	sortFrameFiles(files)
+
+	if fps <= 0 {
+		fps = defaultSequenceFPS
+	}
+	delay := time.Duration(float64(time.Second) / fps)
+
+	anim := &Animation{}
+	for _, filename := range files {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image file: %v", err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", filename, err)
+		}
+		if err := loader.addFrame(anim, img, delay); err != nil {
+			return nil, fmt.Errorf("failed to process %s: %v", filename, err)
+		}
+	}
+
+	log.Printf("Loaded image sequence: %s (%d frames)", pattern, anim.Len())
+	return anim, nil
+}
+
+// sortFrameFiles sorts file names by their last number, so frame_2.png
+// comes before frame_10.png, then by name
+func sortFrameFiles(files []string) {
+	number := func(name string) int {
+		m := frameNumber.FindStringSubmatch(filepath.Base(name))
+		if m == nil {
+			return -1
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := number(files[i]), number(files[j])
+		if a != b {
+			return a < b
+		}
+		return files[i] < files[j]
+	})
+}
+
+// PlaybackMode selects what happens at the end of an animation
+type PlaybackMode int
+
+const (
+	PlaybackLoop     PlaybackMode = iota // Start again from the first frame
+	PlaybackPingPong                     // Play backwards, then forwards again
+	PlaybackOnce                         // Stop on the last frame
+)
+
+// ParsePlaybackMode returns the playback mode with the given name
+func ParsePlaybackMode(name string) (PlaybackMode, error) {
+	switch strings.ToLower(name) {
+	case "loop":
+		return PlaybackLoop, nil
+	case "pingpong", "ping-pong", "bounce":
+		return PlaybackPingPong, nil
+	case "once":
+		return PlaybackOnce, nil
+	default:
+		return 0, fmt.Errorf("unknown playback mode: %s", name)
+	}
+}
+
+// AnimationPlayer plays an animation into a sink. Sinks implementing
+// BufferSink, such as DeviceSink, get the pre-converted frame buffers.
+type AnimationPlayer struct {
+	mu        sync.Mutex
+	anim      *Animation
+	sink      Sink
+	mode      PlaybackMode
+	fps       float64
+	frame     int
+	direction int
+	paused    bool
+	ended     bool
+	err       error
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAnimationPlayer creates a player that loops an animation into a sink
+func NewAnimationPlayer(anim *Animation, sink Sink) (*AnimationPlayer, error) {
+	if anim == nil || anim.Len() == 0 {
+		return nil, errors.New("animation has no frames")
+	}
+	return &AnimationPlayer{
+		anim:      anim,
+		sink:      sink,
+		direction: 1,
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+// SetMode sets the playback mode
+func (p *AnimationPlayer) SetMode(mode PlaybackMode) {
+	p.mu.Lock()
+	p.mode = mode
+	p.mu.Unlock()
+}
+
+// SetFPS plays every frame for 1/fps seconds; 0 uses the native delays
+func (p *AnimationPlayer) SetFPS(fps float64) {
+	p.mu.Lock()
+	p.fps = fps
+	p.mu.Unlock()
+	p.notify()
+}
+
+// Play starts playback from the current frame, or from the first frame
+// once a PlaybackOnce animation has ended. It does nothing if the player is
+// already running.
+func (p *AnimationPlayer) Play() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	if p.ended {
+		p.frame, p.ended = 0, false
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run(p.stop, p.done)
+}
+
+// Pause holds the current frame
+func (p *AnimationPlayer) Pause() {
+	p.mu.Lock()
+	p.paused = true
+	p.mu.Unlock()
+	p.notify()
+}
+
+// Resume continues after Pause
+func (p *AnimationPlayer) Resume() {
+	p.mu.Lock()
+	p.paused = false
+	p.mu.Unlock()
+	p.notify()
+}
+
+// TogglePause pauses a playing animation or resumes a paused one
+func (p *AnimationPlayer) TogglePause() {
+	p.mu.Lock()
+	p.paused = !p.paused
+	p.mu.Unlock()
+	p.notify()
+}
+
+// Paused reports whether playback is paused
+func (p *AnimationPlayer) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Seek jumps to a frame, which is shown at once, even while paused
+func (p *AnimationPlayer) Seek(frame int) error {
+	if frame < 0 || frame >= p.anim.Len() {
+		return fmt.Errorf("frame %d out of range (0-%d)", frame, p.anim.Len()-1)
+	}
+	p.mu.Lock()
+	p.frame = frame
+	p.mu.Unlock()
+	p.notify()
+	return nil
+}
+
+// Frame returns the index of the current frame
+func (p *AnimationPlayer) Frame() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frame
+}
+
+// Err returns the last error from the sink, if any
+func (p *AnimationPlayer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Done returns a channel that is closed when playback ends, either by Stop
+// or at the end of a PlaybackOnce animation. It is nil before Play.
+func (p *AnimationPlayer) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done
+}
+
+// Stop ends playback and waits for the player to finish. The sink is left
+// open; Play starts again from the current frame.
+func (p *AnimationPlayer) Stop() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop = nil
+	p.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// notify wakes the playback goroutine to pick up a change
+func (p *AnimationPlayer) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run shows frames until stopped or a PlaybackOnce animation ends
+func (p *AnimationPlayer) run(stop, done chan struct{}) {
+	defer close(done)
+
+	shown := -1
+	for {
+		p.mu.Lock()
+		i, paused := p.frame, p.paused
+		frame := p.anim.Frames[i]
+		delay := frame.Delay
+		if p.fps > 0 {
+			delay = time.Duration(float64(time.Second) / p.fps)
+		}
+		p.mu.Unlock()
+
+		if i != shown {
+			p.write(i, frame)
+			shown = i
+		}
+
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if !paused {
+			timer = time.NewTimer(delay)
+			tick = timer.C
+		}
+		select {
+		case <-tick:
+			if !p.advance(i) {
+				p.mu.Lock()
+				p.ended = true
+				if p.stop == stop {
+					p.stop = nil
+				}
+				p.mu.Unlock()
+				return
+			}
+		case <-p.wake:
+		case <-stop:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// write sends a frame to the sink
+func (p *AnimationPlayer) write(index int, frame AnimationFrame) {
+	var err error
+	if bs, ok := p.sink.(BufferSink); ok {
+		err = bs.WriteBuffer(frame.Buffer)
+	} else {
+		err = p.sink.WriteFrame(frame.Image)
+	}
+	if err != nil {
+		log.Printf("Failed to show animation frame %d: %v", index, err)
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+	}
+}
+
+// advance moves on from frame i, returning false when playback has ended
+func (p *AnimationPlayer) advance(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.frame != i {
+		// Seek moved the player while the frame was shown
+		return true
+	}
+
+	n := p.anim.Len()
+	switch p.mode {
+	case PlaybackOnce:
+		if i == n-1 {
+			return false
+		}
+		p.frame = i + 1
+	case PlaybackPingPong:
+		if n == 1 {
+			return true
+		}
+		next := i + p.direction
+		if next < 0 || next >= n {
+			p.direction = -p.direction
+			next = i + p.direction
+		}
+		p.frame = next
+	default:
+		p.frame = (i + 1) % n
+	}
+	return true
+}
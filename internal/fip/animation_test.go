@@ -0,0 +1,241 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAnimation returns an animation whose frame i is filled with gray level i
+func testAnimation(n int) *Animation {
+	anim := &Animation{}
+	for i := 0; i < n; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+		for p := 0; p < len(img.Pix); p += 4 {
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = uint8(i), uint8(i), uint8(i), 255
+		}
+		anim.Frames = append(anim.Frames, AnimationFrame{Image: img, Delay: 10 * time.Millisecond})
+	}
+	return anim
+}
+
+// frameIndexes returns the gray level, and so the frame index, of each frame
+func frameIndexes(frames []image.Image) []int {
+	var indexes []int
+	for _, f := range frames {
+		r, _, _, _ := f.At(0, 0).RGBA()
+		indexes = append(indexes, int(r>>8))
+	}
+	return indexes
+}
+
+func TestLoadGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	full := image.NewPaletted(image.Rect(0, 0, 32, 24), palette)
+	for i := range full.Pix {
+		full.Pix[i] = 1
+	}
+	// A blue square drawn over the red frame, then cleared to the background
+	square := image.NewPaletted(image.Rect(8, 8, 16, 16), palette)
+	for i := range square.Pix {
+		square.Pix[i] = 2
+	}
+	g := &gif.GIF{
+		Image:    []*image.Paletted{full, square, square},
+		Delay:    []int{5, 0, 20},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 32, Height: 24},
+	}
+	filename := filepath.Join(t.TempDir(), "anim.gif")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.EncodeAll(file, g); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	loader := NewImageLoader()
+	loader.SetResizeMode(ResizeModeStretch)
+	anim, err := loader.LoadGIF(filename)
+	if err != nil {
+		t.Fatalf("Failed to load GIF: %v", err)
+	}
+	if anim.Len() != 3 {
+		t.Fatalf("Expected 3 frames, got %d", anim.Len())
+	}
+	if anim.Frames[0].Delay != 50*time.Millisecond || anim.Frames[1].Delay != minGIFDelay {
+		t.Errorf("Unexpected delays %v, %v", anim.Frames[0].Delay, anim.Frames[1].Delay)
+	}
+
+	// Frames are scaled 10x to 320x240; sample the middle of the square
+	at := func(frame, x, y int) color.RGBA { return anim.Frames[frame].Image.RGBAAt(x, y) }
+	if c := at(1, 120, 120); c.B != 255 || c.R != 0 {
+		t.Errorf("Expected blue square over red, got %v", c)
+	}
+	if c := at(1, 10, 10); c.R != 255 {
+		t.Errorf("Expected red background kept, got %v", c)
+	}
+	// Frame 1 is disposed to the background, which frame 2 draws over again
+	if c := at(2, 120, 120); c.B != 255 || at(2, 10, 10).R != 255 {
+		t.Errorf("Expected blue square over red after disposal, got %v", c)
+	}
+	if len(anim.Frames[2].Buffer) != 320*240*3 {
+		t.Errorf("Expected pre-converted frame buffer, got %d bytes", len(anim.Frames[2].Buffer))
+	}
+}
+
+func TestLoadImageSequence(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []int{10, 2, 1} {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+		img.Set(0, 0, color.RGBA{uint8(n), 0, 0, 255})
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("frame_%d.png", n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(file, img)
+		file.Close()
+	}
+
+	anim, err := NewImageLoader().LoadImageSequence(filepath.Join(dir, "frame_*.png"), 25)
+	if err != nil {
+		t.Fatalf("Failed to load sequence: %v", err)
+	}
+	var order []uint8
+	for _, f := range anim.Frames {
+		order = append(order, f.Image.RGBAAt(0, 0).R)
+	}
+	if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 10 {
+		t.Errorf("Expected frames in numeric order, got %v", order)
+	}
+	if anim.Frames[0].Delay != 40*time.Millisecond {
+		t.Errorf("Expected 40ms per frame at 25 FPS, got %v", anim.Frames[0].Delay)
+	}
+
+	if _, err := NewImageLoader().LoadImageSequence(filepath.Join(dir, "none_*.png"), 0); err == nil {
+		t.Error("Expected error when no files match")
+	}
+}
+
+func TestAnimationPlayerModes(t *testing.T) {
+	tests := []struct {
+		mode PlaybackMode
+		want []int
+	}{
+		{PlaybackOnce, []int{0, 1, 2, 3}},
+		{PlaybackLoop, []int{0, 1, 2, 3, 0, 1, 2}},
+		{PlaybackPingPong, []int{0, 1, 2, 3, 2, 1, 0, 1}},
+	}
+	for _, tt := range tests {
+		sink := NewMemorySink(0)
+		player, err := NewAnimationPlayer(testAnimation(4), sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		player.SetMode(tt.mode)
+		player.SetFPS(500)
+		player.Play()
+
+		deadline := time.Now().Add(2 * time.Second)
+		for len(sink.Frames()) < len(tt.want) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		player.Stop()
+
+		got := frameIndexes(sink.Frames())
+		if len(got) < len(tt.want) {
+			t.Fatalf("mode %d: expected %v, got %v", tt.mode, tt.want, got)
+		}
+		for i, want := range tt.want {
+			if got[i] != want {
+				t.Errorf("mode %d: expected %v, got %v", tt.mode, tt.want, got)
+				break
+			}
+		}
+		if tt.mode == PlaybackOnce && len(got) != 4 {
+			t.Errorf("Expected once mode to stop on the last frame, got %v", got)
+		}
+	}
+}
+
+func TestAnimationPlayerPauseSeek(t *testing.T) {
+	sink := NewMemorySink(0)
+	player, _ := NewAnimationPlayer(testAnimation(5), sink)
+	player.Pause()
+	player.Play()
+	defer player.Stop()
+
+	waitFrames := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for len(sink.Frames()) < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Paused: the first frame is shown and held
+	waitFrames(1)
+	time.Sleep(50 * time.Millisecond)
+	if got := frameIndexes(sink.Frames()); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("Expected only frame 0 while paused, got %v", got)
+	}
+
+	// Seeking while paused shows the frame at once
+	if err := player.Seek(3); err != nil {
+		t.Fatal(err)
+	}
+	waitFrames(2)
+	if got := frameIndexes(sink.Frames()); len(got) != 2 || got[1] != 3 || player.Frame() != 3 {
+		t.Fatalf("Expected frame 3 after seek, got %v", got)
+	}
+	if err := player.Seek(5); err == nil {
+		t.Error("Expected error seeking past the end")
+	}
+
+	player.Resume()
+	waitFrames(3)
+	if got := frameIndexes(sink.Frames()); len(got) < 3 || got[2] != 4 {
+		t.Errorf("Expected playback to continue from frame 3, got %v", got)
+	}
+}
+
+// bufferSink records the frame buffers it receives
+type bufferSink struct {
+	MemorySink
+	mu      sync.Mutex
+	buffers int
+}
+
+func (b *bufferSink) WriteBuffer(buf []byte) error {
+	b.mu.Lock()
+	b.buffers++
+	b.mu.Unlock()
+	return nil
+}
+
+func TestAnimationPlayerBufferSink(t *testing.T) {
+	sink := &bufferSink{}
+	anim, err := NewImageLoader().LoadAnimation(filepath.Join("..", "..", "assets", "gradient.png"))
+	if err != nil {
+		t.Skipf("Test image not available: %v", err)
+	}
+	player, _ := NewAnimationPlayer(anim, sink)
+	player.SetMode(PlaybackOnce)
+	player.SetFPS(1000)
+	player.Play()
+	<-player.Done()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.buffers != 1 || len(sink.Frames()) != 0 {
+		t.Errorf("Expected one frame buffer and no images, got %d and %d", sink.buffers, len(sink.Frames()))
+	}
+}
@@ -0,0 +1,160 @@
+package fip
+
+import (
+	"container/list"
+	"image"
+	"sync"
+
+	"saitek-controller/internal/bmp"
+)
+
+// defaultAssetCacheSize is the number of frames kept when no size is given,
+// about 7MB of frame buffers
+const defaultAssetCacheSize = 32
+
+// FrameSource provides FIP frame buffers by name, such as a Bundle
+type FrameSource interface {
+	ReadFrame(name string) ([]byte, error)
+}
+
+// CacheStats reports how well an AssetCache is doing
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Frames    int
+}
+
+// assetEntry is a cached frame; the image is made on first use
+type assetEntry struct {
+	name string
+	buf  []byte
+	img  *image.RGBA
+}
+
+// AssetCache keeps the most recently used frames of a FrameSource in
+// memory. The returned buffers and images are shared and must not be
+// modified.
+type AssetCache struct {
+	mu       sync.Mutex
+	source   FrameSource
+	capacity int
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+	stats    CacheStats
+}
+
+// NewAssetCache creates a cache holding up to capacity frames from source,
+// or 32 frames if capacity is zero or less
+func NewAssetCache(source FrameSource, capacity int) *AssetCache {
+	if capacity <= 0 {
+		capacity = defaultAssetCacheSize
+	}
+	return &AssetCache{
+		source:   source,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// entry returns the cached entry for name, reading it from the source on
+// a miss. Called with the lock held.
+func (c *AssetCache) entry(name string) (*assetEntry, error) {
+	if el, ok := c.entries[name]; ok {
+		c.stats.Hits++
+		c.order.MoveToFront(el)
+		return el.Value.(*assetEntry), nil
+	}
+
+	c.stats.Misses++
+	buf, err := c.source.ReadFrame(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &assetEntry{name: name, buf: buf}
+	c.entries[name] = c.order.PushFront(e)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*assetEntry).name)
+		c.stats.Evictions++
+	}
+	return e, nil
+}
+
+// Frame returns the frame buffer for name
+func (c *AssetCache) Frame(name string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, err := c.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Image returns the frame for name as an image
+func (c *AssetCache) Image(name string) (*image.RGBA, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, err := c.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.img == nil {
+		img, err := bmp.FIPImage(e.buf)
+		if err != nil {
+			return nil, err
+		}
+		e.img = img
+	}
+	return e.img, nil
+}
+
+// Show writes the frame for name to a sink, as a frame buffer if the sink
+// is a BufferSink
+func (c *AssetCache) Show(sink Sink, name string) error {
+	if bs, ok := sink.(BufferSink); ok {
+		buf, err := c.Frame(name)
+		if err != nil {
+			return err
+		}
+		return bs.WriteBuffer(buf)
+	}
+	img, err := c.Image(name)
+	if err != nil {
+		return err
+	}
+	return sink.WriteFrame(img)
+}
+
+// Renderer returns a renderer that draws the frame for name, for use as a
+// page or panel renderer
+func (c *AssetCache) Renderer(name string) Renderer {
+	return RendererFunc(func(s *Surface) error {
+		img, err := c.Image(name)
+		if err != nil {
+			return err
+		}
+		s.DrawImage(img)
+		return nil
+	})
+}
+
+// Stats returns the cache statistics
+func (c *AssetCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Frames = c.order.Len()
+	return stats
+}
+
+// Purge drops every cached frame
+func (c *AssetCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
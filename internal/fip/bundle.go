@@ -0,0 +1,359 @@
+package fip
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"saitek-controller/internal/bmp"
+)
+
+// Asset bundles hold named FIP frames that are already resized and
+// converted, so showing one is a single read. The file layout is:
+//
+//	"FIPB"              magic
+//	uint32              format version, little endian
+//	uint32              index length, little endian
+//	index               JSON BundleIndex
+//	frames              raw frame buffers, see bmp.FIPBuffer
+//
+// Frame offsets in the index are relative to the first frame.
+const (
+	bundleMagic   = "FIPB"
+	bundleVersion = 1
+)
+
+// maxBundleIndex limits the index size read from a bundle header
+const maxBundleIndex = 16 << 20
+
+// ErrAssetNotFound is returned for names that are not in a bundle
+var ErrAssetNotFound = errors.New("asset not found")
+
+// BundleSettings records the ImageLoader settings a bundle was built with
+type BundleSettings struct {
+	ResizeMode string `json:"resizeMode"`
+	Resampler  string `json:"resampler"`
+	Filters    string `json:"filters,omitempty"`
+}
+
+// BundleEntry describes one frame of a bundle
+type BundleEntry struct {
+	Name         string    `json:"name"`
+	Source       string    `json:"source,omitempty"`
+	SourceWidth  int       `json:"sourceWidth,omitempty"`
+	SourceHeight int       `json:"sourceHeight,omitempty"`
+	Modified     time.Time `json:"modified"`
+	Offset       int64     `json:"offset"`
+	Size         int       `json:"size"`
+	CRC32        uint32    `json:"crc32"`
+}
+
+// BundleIndex is the metadata stored at the start of a bundle
+type BundleIndex struct {
+	Version  int            `json:"version"`
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	Created  time.Time      `json:"created"`
+	Settings BundleSettings `json:"settings"`
+	Frames   []BundleEntry  `json:"frames"`
+}
+
+// Bundle reads frames from an asset bundle
+type Bundle struct {
+	BundleIndex
+	r         io.ReaderAt
+	closer    io.Closer
+	dataStart int64
+	byName    map[string]int
+}
+
+// OpenBundle opens an asset bundle file. Frames are read on demand.
+func OpenBundle(path string) (*Bundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	b, err := ReadBundle(io.NewSectionReader(file, 0, info.Size()))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	b.closer = file
+	return b, nil
+}
+
+// ReadBundle reads the index of a bundle from r. When r has a Size
+// method, such as a bytes.Reader or io.SectionReader, frames must also
+// lie within it.
+func ReadBundle(r io.ReaderAt) (*Bundle, error) {
+	size := int64(-1)
+	if s, ok := r.(interface{ Size() int64 }); ok {
+		size = s.Size()
+	}
+
+	var header [12]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read bundle header: %w", err)
+	}
+	if string(header[:4]) != bundleMagic {
+		return nil, errors.New("not a FIP asset bundle")
+	}
+	if v := binary.LittleEndian.Uint32(header[4:]); v != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", v)
+	}
+
+	indexLen := int64(binary.LittleEndian.Uint32(header[8:]))
+	if indexLen > maxBundleIndex || (size >= 0 && int64(len(header))+indexLen > size) {
+		return nil, fmt.Errorf("invalid bundle index length %d", indexLen)
+	}
+	index := make([]byte, indexLen)
+	if _, err := r.ReadAt(index, int64(len(header))); err != nil {
+		return nil, fmt.Errorf("failed to read bundle index: %w", err)
+	}
+
+	b := &Bundle{r: r, dataStart: int64(len(header)) + indexLen, byName: make(map[string]int)}
+	if err := json.Unmarshal(index, &b.BundleIndex); err != nil {
+		return nil, fmt.Errorf("invalid bundle index: %w", err)
+	}
+	if b.Width != bmp.FIPWidth || b.Height != bmp.FIPHeight {
+		return nil, fmt.Errorf("unsupported bundle frame size %dx%d", b.Width, b.Height)
+	}
+	for i, e := range b.Frames {
+		if e.Offset < 0 || e.Size != bmp.FIPBufferSize {
+			return nil, fmt.Errorf("invalid bundle frame %s: offset %d, size %d", e.Name, e.Offset, e.Size)
+		}
+		if size >= 0 && e.Offset > size-b.dataStart-int64(e.Size) {
+			return nil, fmt.Errorf("bundle frame %s lies past the end of the file", e.Name)
+		}
+		b.byName[e.Name] = i
+	}
+	return b, nil
+}
+
+// Names returns the frame names in the bundle, sorted
+func (b *Bundle) Names() []string {
+	names := make([]string, 0, len(b.Frames))
+	for _, e := range b.Frames {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Entry returns the index entry of a frame
+func (b *Bundle) Entry(name string) (BundleEntry, bool) {
+	i, ok := b.byName[name]
+	if !ok {
+		return BundleEntry{}, false
+	}
+	return b.Frames[i], true
+}
+
+// ReadFrame reads a frame buffer and verifies its checksum
+func (b *Bundle) ReadFrame(name string) ([]byte, error) {
+	e, ok := b.Entry(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, name)
+	}
+	buf := make([]byte, e.Size)
+	if _, err := b.r.ReadAt(buf, b.dataStart+e.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read frame %s: %w", name, err)
+	}
+	if sum := crc32.ChecksumIEEE(buf); sum != e.CRC32 {
+		return nil, fmt.Errorf("frame %s is corrupt: checksum %08x, expected %08x", name, sum, e.CRC32)
+	}
+	return buf, nil
+}
+
+// Verify reads every frame and checks its checksum
+func (b *Bundle) Verify() error {
+	for _, e := range b.Frames {
+		if _, err := b.ReadFrame(e.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the bundle file
+func (b *Bundle) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// BundleBuilder converts images with an ImageLoader and writes them as a
+// bundle
+type BundleBuilder struct {
+	loader  *ImageLoader
+	filters string
+	entries []BundleEntry
+	frames  [][]byte
+}
+
+// NewBundleBuilder creates a builder that processes images with loader
+func NewBundleBuilder(loader *ImageLoader) *BundleBuilder {
+	return &BundleBuilder{loader: loader}
+}
+
+// SetFilterSpec records the filter chain the loader uses, as given to
+// imaging.ParseFilters, in the bundle metadata
+func (b *BundleBuilder) SetFilterSpec(spec string) {
+	b.filters = spec
+}
+
+// Len returns the number of frames added
+func (b *BundleBuilder) Len() int {
+	return len(b.entries)
+}
+
+// AddImage resizes, filters and converts an image and adds it under name
+func (b *BundleBuilder) AddImage(name string, img image.Image) error {
+	return b.add(BundleEntry{Name: name}, img)
+}
+
+// AddFile adds an image file under name
+func (b *BundleBuilder) AddFile(name, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open image file: %v", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", filename, err)
+	}
+	entry := BundleEntry{Name: name, Source: filepath.ToSlash(filename)}
+	if info, err := file.Stat(); err == nil {
+		entry.Modified = info.ModTime().UTC()
+	}
+	return b.add(entry, img)
+}
+
+// AddDir adds every supported image below dir, named by its path relative
+// to dir without the extension, such as "instruments/airspeed". It returns
+// the number of images added.
+func (b *BundleBuilder) AddDir(dir string) (int, error) {
+	added := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !b.loader.IsSupportedFormat(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		if err := b.AddFile(name, path); err != nil {
+			return err
+		}
+		added++
+		return nil
+	})
+	return added, err
+}
+
+// add converts an image and appends it with its entry
+func (b *BundleBuilder) add(entry BundleEntry, img image.Image) error {
+	for _, e := range b.entries {
+		if e.Name == entry.Name {
+			return fmt.Errorf("duplicate asset name: %s", entry.Name)
+		}
+	}
+
+	processed, err := b.loader.processImage(img)
+	if err != nil {
+		return fmt.Errorf("failed to process %s: %v", entry.Name, err)
+	}
+	frame := image.NewRGBA(image.Rect(0, 0, bmp.FIPWidth, bmp.FIPHeight))
+	draw.Draw(frame, frame.Bounds(), processed, processed.Bounds().Min, draw.Src)
+	buf := bmp.FIPBuffer(frame)
+
+	entry.SourceWidth, entry.SourceHeight = img.Bounds().Dx(), img.Bounds().Dy()
+	entry.Size = len(buf)
+	entry.CRC32 = crc32.ChecksumIEEE(buf)
+	if n := len(b.entries); n > 0 {
+		last := b.entries[n-1]
+		entry.Offset = last.Offset + int64(last.Size)
+	}
+	b.entries = append(b.entries, entry)
+	b.frames = append(b.frames, buf)
+	return nil
+}
+
+// Write writes the bundle
+func (b *BundleBuilder) Write(w io.Writer) error {
+	index, err := json.Marshal(BundleIndex{
+		Version: bundleVersion,
+		Width:   bmp.FIPWidth,
+		Height:  bmp.FIPHeight,
+		Created: time.Now().UTC(),
+		Settings: BundleSettings{
+			ResizeMode: b.loader.ResizeMode.String(),
+			Resampler:  b.loader.Resampler.String(),
+			Filters:    b.filters,
+		},
+		Frames: b.entries,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle index: %w", err)
+	}
+
+	header := make([]byte, 12)
+	copy(header, bundleMagic)
+	binary.LittleEndian.PutUint32(header[4:], bundleVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(index)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(index); err != nil {
+		return err
+	}
+	for _, frame := range b.frames {
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes the bundle to a file, replacing it only once the new
+// bundle is complete
+func (b *BundleBuilder) WriteFile(path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := b.Write(file); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
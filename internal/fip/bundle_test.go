@@ -0,0 +1,251 @@
+package fip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"saitek-controller/internal/bmp"
+)
+
+// writePNG writes a solid image of the given size and color
+func writePNG(t *testing.T, path string, w, h int, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// buildTestBundle builds a bundle from a small assets directory
+func buildTestBundle(t *testing.T) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "red.png"), 320, 240, color.RGBA{255, 0, 0, 255})
+	writePNG(t, filepath.Join(dir, "gauges", "blue.png"), 64, 48, color.RGBA{0, 0, 255, 255})
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not an image"), 0644)
+
+	loader := NewImageLoader()
+	loader.SetResizeMode(ResizeModeStretch)
+	builder := NewBundleBuilder(loader)
+	builder.SetFilterSpec("gamma=1.0")
+	n, err := builder.AddDir(dir)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 images added, got %d, %v", n, err)
+	}
+	if err := builder.AddFile("red", filepath.Join(dir, "red.png")); err == nil {
+		t.Error("Expected error for a duplicate name")
+	}
+
+	var buf bytes.Buffer
+	if err := builder.Write(&buf); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	data := buildTestBundle(t)
+	b, err := ReadBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	defer b.Close()
+
+	if names := b.Names(); len(names) != 2 || names[0] != "gauges/blue" || names[1] != "red" {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if b.Width != 320 || b.Height != 240 || b.Settings.ResizeMode != "stretch" || b.Settings.Resampler != "bilinear" || b.Settings.Filters != "gamma=1.0" {
+		t.Errorf("Unexpected metadata %+v", b.BundleIndex)
+	}
+	entry, ok := b.Entry("gauges/blue")
+	if !ok || entry.SourceWidth != 64 || entry.SourceHeight != 48 || entry.Modified.IsZero() {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	buf, err := b.ReadFrame("gauges/blue")
+	if err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	img, _ := bmp.FIPImage(buf)
+	if c := img.RGBAAt(160, 120); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("Expected stretched blue frame, got %v", c)
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Expected bundle to verify: %v", err)
+	}
+
+	if _, err := b.ReadFrame("missing"); !errors.Is(err, ErrAssetNotFound) {
+		t.Errorf("Expected ErrAssetNotFound, got %v", err)
+	}
+}
+
+func TestBundleCorruption(t *testing.T) {
+	data := buildTestBundle(t)
+	data[len(data)-1] ^= 0xff // last byte of the last frame
+
+	b, err := ReadBundle(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	if err := b.Verify(); err == nil {
+		t.Error("Expected checksum error")
+	}
+
+	data[0] = 'X'
+	if _, err := ReadBundle(bytes.NewReader(data)); err == nil {
+		t.Error("Expected error for bad magic")
+	}
+}
+
+// rewriteBundleIndex returns a copy of a bundle with its index changed by
+// edit
+func rewriteBundleIndex(t *testing.T, data []byte, edit func(*BundleIndex)) []byte {
+	t.Helper()
+	indexLen := binary.LittleEndian.Uint32(data[8:])
+	var index BundleIndex
+	if err := json.Unmarshal(data[12:12+indexLen], &index); err != nil {
+		t.Fatal(err)
+	}
+	edit(&index)
+	encoded, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte{}, data[:12]...)
+	binary.LittleEndian.PutUint32(out[8:], uint32(len(encoded)))
+	out = append(out, encoded...)
+	return append(out, data[12+indexLen:]...)
+}
+
+func TestBundleCorruptIndex(t *testing.T) {
+	data := buildTestBundle(t)
+	hugeIndex := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(hugeIndex[8:], 0xFFFFFFF0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"index length", hugeIndex},
+		{"negative size", rewriteBundleIndex(t, data, func(i *BundleIndex) { i.Frames[0].Size = -1 })},
+		{"huge size", rewriteBundleIndex(t, data, func(i *BundleIndex) { i.Frames[0].Size = 1 << 40 })},
+		{"negative offset", rewriteBundleIndex(t, data, func(i *BundleIndex) { i.Frames[1].Offset = -10 })},
+		{"offset past end", rewriteBundleIndex(t, data, func(i *BundleIndex) { i.Frames[1].Offset = 1 << 30 })},
+		{"frame size", rewriteBundleIndex(t, data, func(i *BundleIndex) { i.Width, i.Height = 640, 480 })},
+		{"truncated", data[:len(data)-100]},
+	}
+	for _, tt := range tests {
+		if _, err := ReadBundle(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestBundleFile(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "assets", "a.png"), 10, 10, color.RGBA{1, 2, 3, 255})
+
+	builder := NewBundleBuilder(NewImageLoader())
+	if _, err := builder.AddDir(filepath.Join(dir, "assets")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "assets.fipb")
+	if err := builder.WriteFile(path); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected temporary file to be gone")
+	}
+
+	b, err := OpenBundle(path)
+	if err != nil {
+		t.Fatalf("Failed to open bundle: %v", err)
+	}
+	defer b.Close()
+	if _, err := b.ReadFrame("a"); err != nil {
+		t.Errorf("Failed to read frame: %v", err)
+	}
+}
+
+// countingSource counts reads from a bundle
+type countingSource struct {
+	*Bundle
+	reads int
+}
+
+func (c *countingSource) ReadFrame(name string) ([]byte, error) {
+	c.reads++
+	return c.Bundle.ReadFrame(name)
+}
+
+func TestAssetCache(t *testing.T) {
+	b, err := ReadBundle(bytes.NewReader(buildTestBundle(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &countingSource{Bundle: b}
+	cache := NewAssetCache(source, 1)
+
+	for _, name := range []string{"red", "red", "gauges/blue", "red"} {
+		if _, err := cache.Frame(name); err != nil {
+			t.Fatalf("Failed to get %s: %v", name, err)
+		}
+	}
+	stats := cache.Stats()
+	if source.reads != 3 || stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 2 || stats.Frames != 1 {
+		t.Errorf("Unexpected reads %d, stats %+v", source.reads, stats)
+	}
+
+	img, err := cache.Image("red")
+	if err != nil || img.RGBAAt(0, 0) != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("Expected red image, got %v, %v", err, img)
+	}
+	if again, _ := cache.Image("red"); again != img {
+		t.Error("Expected cached image to be reused")
+	}
+	if _, err := cache.Frame("missing"); !errors.Is(err, ErrAssetNotFound) {
+		t.Errorf("Expected ErrAssetNotFound, got %v", err)
+	}
+
+	// Show prefers frame buffers
+	sink := &bufferSink{}
+	if err := cache.Show(sink, "red"); err != nil || sink.buffers != 1 {
+		t.Errorf("Expected frame buffer to be written, got %d, %v", sink.buffers, err)
+	}
+	mem := NewMemorySink(0)
+	if err := cache.Show(mem, "red"); err != nil || len(mem.Frames()) != 1 {
+		t.Errorf("Expected image to be written, got %v", err)
+	}
+
+	surface := NewSurface(320, 240)
+	if err := cache.Renderer("gauges/blue").Render(surface); err != nil {
+		t.Fatal(err)
+	}
+	if c := surface.Image().RGBAAt(5, 5); c.B != 255 {
+		t.Errorf("Expected renderer to draw the frame, got %v", c)
+	}
+
+	cache.Purge()
+	if cache.Stats().Frames != 0 {
+		t.Error("Expected purge to empty the cache")
+	}
+}
@@ -0,0 +1,475 @@
+package fip
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Field identifies one value of InstrumentData
+type Field int
+
+const (
+	FieldPitch Field = iota
+	FieldRoll
+	FieldAirspeed
+	FieldAltitude
+	FieldPressure
+	FieldHeading
+	FieldHeadingBug
+	FieldVerticalSpeed
+	FieldTurnRate
+	FieldSlip
+	FieldLatitude
+	FieldLongitude
+
+	// fieldCount is the number of fields
+	fieldCount
+)
+
+// fieldNames match the gauge value names
+var fieldNames = [fieldCount]string{
+	"pitch", "roll", "airspeed", "altitude", "pressure", "heading",
+	"heading_bug", "vertical_speed", "turn_rate", "slip", "latitude", "longitude",
+}
+
+// String returns the field name
+func (f Field) String() string {
+	if f < 0 || f >= fieldCount {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return fieldNames[f]
+}
+
+// ParseField parses a field name such as "airspeed" or "heading_bug"
+func ParseField(name string) (Field, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for f, n := range fieldNames {
+		if n == name {
+			return Field(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown instrument data field: %s", name)
+}
+
+// FieldMask is a set of fields
+type FieldMask uint32
+
+// AllFields contains every field
+const AllFields FieldMask = 1<<fieldCount - 1
+
+// Fields returns the set of the given fields
+func Fields(fields ...Field) FieldMask {
+	var m FieldMask
+	for _, f := range fields {
+		m |= 1 << uint(f)
+	}
+	return m
+}
+
+// Has reports whether the set contains f
+func (m FieldMask) Has(f Field) bool {
+	return m&(1<<uint(f)) != 0
+}
+
+// String lists the fields in the set
+func (m FieldMask) String() string {
+	var names []string
+	for f := Field(0); f < fieldCount; f++ {
+		if m.Has(f) {
+			names = append(names, f.String())
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Value returns a field of the data
+func (d *InstrumentData) Value(f Field) float64 {
+	switch f {
+	case FieldPitch:
+		return d.Pitch
+	case FieldRoll:
+		return d.Roll
+	case FieldAirspeed:
+		return d.Airspeed
+	case FieldAltitude:
+		return d.Altitude
+	case FieldPressure:
+		return d.Pressure
+	case FieldHeading:
+		return d.Heading
+	case FieldHeadingBug:
+		return d.HeadingBug
+	case FieldVerticalSpeed:
+		return d.VerticalSpeed
+	case FieldTurnRate:
+		return d.TurnRate
+	case FieldSlip:
+		return d.Slip
+	case FieldLatitude:
+		return d.Latitude
+	case FieldLongitude:
+		return d.Longitude
+	}
+	return 0
+}
+
+// SetValue sets a field of the data
+func (d *InstrumentData) SetValue(f Field, v float64) {
+	switch f {
+	case FieldPitch:
+		d.Pitch = v
+	case FieldRoll:
+		d.Roll = v
+	case FieldAirspeed:
+		d.Airspeed = v
+	case FieldAltitude:
+		d.Altitude = v
+	case FieldPressure:
+		d.Pressure = v
+	case FieldHeading:
+		d.Heading = v
+	case FieldHeadingBug:
+		d.HeadingBug = v
+	case FieldVerticalSpeed:
+		d.VerticalSpeed = v
+	case FieldTurnRate:
+		d.TurnRate = v
+	case FieldSlip:
+		d.Slip = v
+	case FieldLatitude:
+		d.Latitude = v
+	case FieldLongitude:
+		d.Longitude = v
+	}
+}
+
+// Fields returns the data fields an instrument shows. Settings the pilot
+// makes, such as the heading bug, are left out.
+func (i Instrument) Fields() FieldMask {
+	switch i {
+	case InstrumentArtificialHorizon:
+		return Fields(FieldPitch, FieldRoll)
+	case InstrumentAirspeed:
+		return Fields(FieldAirspeed)
+	case InstrumentAltimeter:
+		return Fields(FieldAltitude)
+	case InstrumentCompass:
+		return Fields(FieldHeading)
+	case InstrumentVerticalSpeed:
+		return Fields(FieldVerticalSpeed)
+	case InstrumentTurnCoordinator:
+		return Fields(FieldTurnRate, FieldSlip)
+	}
+	return 0
+}
+
+// Interpolation selects how a field moves between samples
+type Interpolation int
+
+const (
+	// InterpolateNone shows each sample as it arrives
+	InterpolateNone Interpolation = iota
+	// InterpolateLinear moves to each sample at a steady rate over the
+	// time the next sample is expected to take
+	InterpolateLinear
+	// InterpolateExponential eases towards each sample with the filter's
+	// time constant, like a damped needle
+	InterpolateExponential
+)
+
+var interpolationNames = []string{"none", "linear", "exponential"}
+
+// String returns the interpolation name
+func (i Interpolation) String() string {
+	if i < 0 || int(i) >= len(interpolationNames) {
+		return fmt.Sprintf("Interpolation(%d)", int(i))
+	}
+	return interpolationNames[i]
+}
+
+// ParseInterpolation parses "none", "linear" or "exponential"
+func ParseInterpolation(s string) (Interpolation, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "none", "off":
+		return InterpolateNone, nil
+	case "linear":
+		return InterpolateLinear, nil
+	case "exponential", "exp", "smooth":
+		return InterpolateExponential, nil
+	}
+	return InterpolateNone, fmt.Errorf("unknown interpolation: %s", s)
+}
+
+// Limits on the sample interval used for linear interpolation, so a burst
+// or a pause in the data doesn't make needles snap or crawl
+const (
+	minSampleInterval = 10 * time.Millisecond
+	maxSampleInterval = time.Second
+
+	// defaultTimeConstant is used by exponential smoothing without one
+	defaultTimeConstant = 200 * time.Millisecond
+
+	// defaultStaleAfter is how long data may stop before failure flags show
+	defaultStaleAfter = 2 * time.Second
+)
+
+// FieldFilter conditions one field
+type FieldFilter struct {
+	Interpolation Interpolation
+	TimeConstant  time.Duration // for exponential smoothing, 200ms if zero
+	Wrap          float64       // period of an angle, such as 360 for heading; 0 if not an angle
+	MaxRate       float64       // largest change per second, 0 for no limit
+	DeadBand      float64       // samples closer than this to the last one are ignored
+}
+
+// Conditioning configures a Conditioner. Fields without a filter pass
+// straight through.
+type Conditioning struct {
+	Filters    map[Field]FieldFilter
+	StaleAfter time.Duration // how long data may stop before Watch fields fail, 0 never
+	Watch      FieldMask     // fields checked for stale data
+}
+
+// DefaultConditioning returns filters suited to the instrument: fast
+// damping for attitude, lag on the vertical speed like a real VSI, angles
+// wrapped at 360 degrees, and failure flags after two seconds without data
+// for the fields the instrument shows
+func DefaultConditioning(instrument Instrument) Conditioning {
+	c := Conditioning{
+		Filters: map[Field]FieldFilter{
+			FieldPitch:         {Interpolation: InterpolateExponential, TimeConstant: 100 * time.Millisecond, DeadBand: 0.05},
+			FieldRoll:          {Interpolation: InterpolateExponential, TimeConstant: 100 * time.Millisecond, Wrap: 360, DeadBand: 0.05},
+			FieldAirspeed:      {Interpolation: InterpolateLinear, DeadBand: 0.2},
+			FieldAltitude:      {Interpolation: InterpolateLinear, DeadBand: 1},
+			FieldHeading:       {Interpolation: InterpolateLinear, Wrap: 360, DeadBand: 0.1},
+			FieldHeadingBug:    {Wrap: 360},
+			FieldVerticalSpeed: {Interpolation: InterpolateExponential, TimeConstant: 500 * time.Millisecond, DeadBand: 10},
+			FieldTurnRate:      {Interpolation: InterpolateExponential, TimeConstant: 300 * time.Millisecond},
+			FieldSlip:          {Interpolation: InterpolateExponential, TimeConstant: 300 * time.Millisecond},
+			FieldLatitude:      {Interpolation: InterpolateLinear},
+			FieldLongitude:     {Interpolation: InterpolateLinear, Wrap: 360},
+		},
+		StaleAfter: defaultStaleAfter,
+		Watch:      instrument.Fields(),
+	}
+	// The turn coordinator's gyro is slow to respond
+	if instrument == InstrumentTurnCoordinator {
+		c.Filters[FieldTurnRate] = FieldFilter{Interpolation: InterpolateExponential, TimeConstant: 600 * time.Millisecond, MaxRate: 6}
+	}
+	return c
+}
+
+// fieldState is the conditioning state of one field
+type fieldState struct {
+	valid    bool
+	target   float64       // last accepted sample
+	start    float64       // shown value when the target last changed
+	value    float64       // shown value at sampled
+	updated  time.Time     // when the last sample arrived, even inside the dead band
+	changed  time.Time     // when the target last changed
+	sampled  time.Time     // when value was computed
+	interval time.Duration // estimated time between samples
+}
+
+// Conditioner sits between a data source and the instruments. Sources call
+// Update whenever data arrives, at whatever rate; displays call Sample at
+// their frame rate and get smoothly moving values, with Failed set for
+// watched fields whose data has stopped.
+type Conditioner struct {
+	mu       sync.Mutex
+	config   Conditioning
+	fields   [fieldCount]fieldState
+	now      func() time.Time
+	stopChan chan struct{}
+}
+
+// NewConditioner creates a conditioner with the given configuration
+func NewConditioner(config Conditioning) *Conditioner {
+	return &Conditioner{config: config, now: time.Now}
+}
+
+// SetConditioning replaces the configuration, keeping the current values
+func (c *Conditioner) SetConditioning(config Conditioning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+// Update records a sample of every field
+func (c *Conditioner) Update(data InstrumentData) {
+	c.UpdateFields(data, AllFields)
+}
+
+// UpdateFields records a sample of some fields, for sources that send
+// different values at different rates
+func (c *Conditioner) UpdateFields(data InstrumentData, fields FieldMask) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+
+	for f := Field(0); f < fieldCount; f++ {
+		if !fields.Has(f) {
+			continue
+		}
+		v := data.Value(f)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			// Treat garbage like a missing sample
+			continue
+		}
+		st := &c.fields[f]
+		filter := c.config.Filters[f]
+		if !st.valid {
+			*st = fieldState{valid: true, target: v, start: v, value: v, updated: now, changed: now, sampled: now}
+			continue
+		}
+
+		if gap := now.Sub(st.updated); gap > 0 {
+			if st.interval == 0 {
+				st.interval = gap
+			} else {
+				st.interval = (3*st.interval + gap) / 4
+			}
+		}
+		st.updated = now
+		if math.Abs(angleDiff(st.target, v, filter.Wrap)) < filter.DeadBand {
+			continue
+		}
+
+		// Carry on from where the needle is now
+		st.advance(now, filter)
+		st.start, st.target, st.changed = st.value, v, now
+	}
+}
+
+// advance moves the shown value up to now
+func (st *fieldState) advance(now time.Time, filter FieldFilter) {
+	dt := now.Sub(st.sampled).Seconds()
+	if dt <= 0 {
+		return
+	}
+	st.sampled = now
+
+	next := st.target
+	switch filter.Interpolation {
+	case InterpolateLinear:
+		span := st.interval
+		if span < minSampleInterval {
+			span = minSampleInterval
+		} else if span > maxSampleInterval {
+			span = maxSampleInterval
+		}
+		if t := now.Sub(st.changed).Seconds() / span.Seconds(); t < 1 {
+			next = st.start + angleDiff(st.start, st.target, filter.Wrap)*t
+		}
+	case InterpolateExponential:
+		tau := filter.TimeConstant
+		if tau <= 0 {
+			tau = defaultTimeConstant
+		}
+		next = st.value + angleDiff(st.value, st.target, filter.Wrap)*(1-math.Exp(-dt/tau.Seconds()))
+	}
+
+	if filter.MaxRate > 0 {
+		step := angleDiff(st.value, next, filter.Wrap)
+		limit := filter.MaxRate * dt
+		next = st.value + math.Max(-limit, math.Min(limit, step))
+	}
+
+	// Keep angles in the same range as the source, so 359 to 1 stays
+	// within 0-360 rather than passing through 360
+	if filter.Wrap > 0 {
+		next = st.target - angleDiff(next, st.target, filter.Wrap)
+	}
+	st.value = next
+}
+
+// angleDiff returns b-a, or the shortest way round for angles with the
+// given period
+func angleDiff(a, b, wrap float64) float64 {
+	d := b - a
+	if wrap <= 0 {
+		return d
+	}
+	d = math.Mod(d, wrap)
+	if d > wrap/2 {
+		d -= wrap
+	} else if d < -wrap/2 {
+		d += wrap
+	}
+	return d
+}
+
+// Sample returns the conditioned data at the current time. Watched fields
+// with no data for longer than StaleAfter are set in Failed.
+func (c *Conditioner) Sample() InstrumentData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+
+	var data InstrumentData
+	for f := Field(0); f < fieldCount; f++ {
+		st := &c.fields[f]
+		if st.valid {
+			st.advance(now, c.config.Filters[f])
+			data.SetValue(f, st.value)
+		}
+		if c.config.Watch.Has(f) && c.config.StaleAfter > 0 && (!st.valid || now.Sub(st.updated) > c.config.StaleAfter) {
+			data.Failed |= Fields(f)
+		}
+	}
+	return data
+}
+
+// Stale returns the watched fields whose data has stopped
+func (c *Conditioner) Stale() FieldMask {
+	return c.Sample().Failed
+}
+
+// Reset forgets all samples, as when the sim restarts
+func (c *Conditioner) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fields = [fieldCount]fieldState{}
+}
+
+// Start samples the data at the given interval and passes it to display,
+// such as FIPPanel.DisplayInstrument, until Stop is called
+func (c *Conditioner) Start(interval time.Duration, display func(InstrumentData) error) {
+	c.Stop()
+
+	stopChan := make(chan struct{})
+	c.mu.Lock()
+	c.stopChan = stopChan
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := display(c.Sample()); err != nil {
+				log.Printf("Failed to display conditioned data: %v", err)
+			}
+
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops a display loop started with Start
+func (c *Conditioner) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopChan != nil {
+		close(c.stopChan)
+		c.stopChan = nil
+	}
+}
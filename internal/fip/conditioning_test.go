@@ -0,0 +1,196 @@
+package fip
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock moved by hand
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestConditioner(config Conditioning) (*Conditioner, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	c := NewConditioner(config)
+	c.now = clock.now
+	return c, clock
+}
+
+func TestFieldValues(t *testing.T) {
+	var data InstrumentData
+	for f := Field(0); f < fieldCount; f++ {
+		data.SetValue(f, float64(f)+1)
+	}
+	for f := Field(0); f < fieldCount; f++ {
+		if data.Value(f) != float64(f)+1 {
+			t.Errorf("Field %v: got %v", f, data.Value(f))
+		}
+		if parsed, err := ParseField(f.String()); err != nil || parsed != f {
+			t.Errorf("ParseField(%q) = %v, %v", f.String(), parsed, err)
+		}
+	}
+	if data.Slip != 10 || data.Longitude != 12 {
+		t.Errorf("Fields set the wrong members: %+v", data)
+	}
+	if s := Fields(FieldAirspeed, FieldHeading).String(); s != "airspeed,heading" {
+		t.Errorf("Unexpected mask string %q", s)
+	}
+	if _, err := ParseInterpolation("cubic"); err == nil {
+		t.Error("Expected error for unknown interpolation")
+	}
+}
+
+func TestConditionerLinear(t *testing.T) {
+	c, clock := newTestConditioner(Conditioning{Filters: map[Field]FieldFilter{
+		FieldAirspeed: {Interpolation: InterpolateLinear},
+	}})
+
+	// Samples every 100ms teach the conditioner the data rate
+	for _, speed := range []float64{100, 100, 110} {
+		c.Update(InstrumentData{Airspeed: speed, Altitude: speed * 10})
+		clock.advance(50 * time.Millisecond)
+		if speed == 110 {
+			break
+		}
+		clock.advance(50 * time.Millisecond)
+	}
+	data := c.Sample()
+	if math.Abs(data.Airspeed-105) > 1e-9 {
+		t.Errorf("Expected airspeed halfway at 105, got %v", data.Airspeed)
+	}
+	if data.Altitude != 1100 {
+		t.Errorf("Expected unfiltered altitude to pass through, got %v", data.Altitude)
+	}
+	clock.advance(100 * time.Millisecond)
+	if data := c.Sample(); data.Airspeed != 110 {
+		t.Errorf("Expected airspeed to reach 110, got %v", data.Airspeed)
+	}
+}
+
+func TestConditionerExponentialWrap(t *testing.T) {
+	c, clock := newTestConditioner(Conditioning{Filters: map[Field]FieldFilter{
+		FieldHeading: {Interpolation: InterpolateExponential, TimeConstant: 100 * time.Millisecond, Wrap: 360},
+	}})
+	c.Update(InstrumentData{Heading: 350})
+	c.Update(InstrumentData{Heading: 10})
+
+	// One time constant covers 63% of the 20 degrees the short way round
+	clock.advance(100 * time.Millisecond)
+	want := 350 + 20*(1-math.Exp(-1)) - 360
+	if got := c.Sample().Heading; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected heading %v, got %v", want, got)
+	}
+	for i := 0; i < 50; i++ {
+		clock.advance(20 * time.Millisecond)
+		if h := c.Sample().Heading; h < -1e-9 || h > 10+1e-9 {
+			t.Fatalf("Heading left the source range: %v", h)
+		}
+	}
+}
+
+func TestConditionerRateAndDeadBand(t *testing.T) {
+	c, clock := newTestConditioner(Conditioning{Filters: map[Field]FieldFilter{
+		FieldVerticalSpeed: {MaxRate: 1000, DeadBand: 50},
+	}})
+	c.Update(InstrumentData{VerticalSpeed: 0})
+	c.Update(InstrumentData{VerticalSpeed: 30})
+	clock.advance(time.Second)
+	if vs := c.Sample().VerticalSpeed; vs != 0 {
+		t.Errorf("Expected a change inside the dead band to be ignored, got %v", vs)
+	}
+
+	c.Update(InstrumentData{VerticalSpeed: 2000})
+	clock.advance(500 * time.Millisecond)
+	if vs := c.Sample().VerticalSpeed; math.Abs(vs-500) > 1e-9 {
+		t.Errorf("Expected rate limit to 500 fpm after 0.5s, got %v", vs)
+	}
+	clock.advance(2 * time.Second)
+	if vs := c.Sample().VerticalSpeed; vs != 2000 {
+		t.Errorf("Expected to reach 2000 fpm, got %v", vs)
+	}
+}
+
+func TestConditionerStale(t *testing.T) {
+	c, clock := newTestConditioner(DefaultConditioning(InstrumentAirspeed))
+	if failed := c.Sample().Failed; failed != Fields(FieldAirspeed) {
+		t.Errorf("Expected airspeed to be failed before any data, got %v", failed)
+	}
+
+	c.Update(InstrumentData{Airspeed: 120})
+	clock.advance(time.Second)
+	if failed := c.Stale(); failed != 0 {
+		t.Errorf("Expected no failures with fresh data, got %v", failed)
+	}
+
+	// Heading keeps coming, airspeed stops
+	clock.advance(time.Second)
+	c.UpdateFields(InstrumentData{Heading: 90}, Fields(FieldHeading))
+	clock.advance(time.Second)
+	data := c.Sample()
+	if data.Failed != Fields(FieldAirspeed) || data.Airspeed != 120 {
+		t.Errorf("Expected airspeed failed and frozen at 120, got %v, %v", data.Failed, data.Airspeed)
+	}
+
+	c.UpdateFields(InstrumentData{Airspeed: math.NaN()}, Fields(FieldAirspeed))
+	if c.Stale() != Fields(FieldAirspeed) {
+		t.Error("Expected an invalid sample not to count as data")
+	}
+	c.Update(InstrumentData{Airspeed: 125})
+	if c.Stale() != 0 {
+		t.Error("Expected the failure to clear when data returns")
+	}
+}
+
+func TestConditionerStart(t *testing.T) {
+	c := NewConditioner(Conditioning{})
+	c.Update(InstrumentData{Airspeed: 90})
+	samples := make(chan InstrumentData, 10)
+	c.Start(time.Millisecond, func(data InstrumentData) error {
+		select {
+		case samples <- data:
+		default:
+		}
+		return nil
+	})
+	defer c.Stop()
+
+	select {
+	case data := <-samples:
+		if data.Airspeed != 90 {
+			t.Errorf("Expected airspeed 90, got %v", data.Airspeed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No sample displayed")
+	}
+}
+
+func TestFailureFlag(t *testing.T) {
+	s := NewSurface(240, 240)
+	r := InstrumentRenderer{Instrument: InstrumentAirspeed, Data: InstrumentData{Airspeed: 100}}
+	if err := r.Render(s); err != nil {
+		t.Fatal(err)
+	}
+	cx, cy, radius := dialGeometry(s.Canvas())
+	flag := s.Image().RGBAAt(int(cx)-20, int(cy-radius/2)-10)
+	if flag == instrumentFailure {
+		t.Fatal("Unexpected failure flag with good data")
+	}
+
+	// A failed field the instrument doesn't show has no flag
+	r.Data.Failed = Fields(FieldHeading)
+	r.Render(s)
+	if s.Image().RGBAAt(int(cx)-20, int(cy-radius/2)-10) == instrumentFailure {
+		t.Error("Unexpected failure flag for another instrument's field")
+	}
+
+	r.Data.Failed = Fields(FieldAirspeed)
+	r.Render(s)
+	if got := s.Image().RGBAAt(int(cx)-20, int(cy-radius/2)-10); got != instrumentFailure {
+		t.Errorf("Expected failure flag, got %v", got)
+	}
+}
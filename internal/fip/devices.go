@@ -0,0 +1,450 @@
+package fip
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+
+	"saitek-controller/internal/bmp"
+)
+
+// DeviceKind is the type of a DirectOutput device
+type DeviceKind int
+
+const (
+	DeviceUnknown DeviceKind = iota
+	DeviceFIP
+	DeviceX52Pro
+)
+
+// String returns the kind's name, which also prefixes its device IDs
+func (k DeviceKind) String() string {
+	switch k {
+	case DeviceFIP:
+		return "fip"
+	case DeviceX52Pro:
+		return "x52pro"
+	}
+	return "unknown"
+}
+
+// deviceKind returns the kind of a device type GUID
+func deviceKind(deviceType [16]byte) DeviceKind {
+	switch deviceType {
+	case DeviceTypeFip:
+		return DeviceFIP
+	case DeviceTypeX52Pro:
+		return DeviceX52Pro
+	}
+	return DeviceUnknown
+}
+
+// DeviceID identifies a device, such as "fip-1", for the life of its
+// DeviceManager. IDs are never reused, so a device plugged in again gets a
+// new one.
+type DeviceID string
+
+// DeviceEventType is what a DeviceEvent reports
+type DeviceEventType int
+
+const (
+	DeviceAdded DeviceEventType = iota
+	DeviceRemoved
+	PageChanged
+	SoftButtonsChanged
+)
+
+// String returns the event type's name
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "added"
+	case DeviceRemoved:
+		return "removed"
+	case PageChanged:
+		return "page"
+	case SoftButtonsChanged:
+		return "buttons"
+	}
+	return fmt.Sprintf("DeviceEventType(%d)", int(t))
+}
+
+// DeviceEvent is a device added or removed, a page change or a soft button
+// change
+type DeviceEvent struct {
+	Type   DeviceEventType
+	Device *Device
+
+	// Page changes: the page and whether it became active
+	Page   uint32
+	Active bool
+
+	// Soft button changes: the state as SoftButton* bits, and the buttons
+	// pressed and released since the last change
+	Buttons  uint32
+	Pressed  uint32
+	Released uint32
+}
+
+// Device is a DirectOutput device. Its methods fail with E_HANDLE once it
+// has been removed.
+type Device struct {
+	ID   DeviceID
+	Kind DeviceKind
+	// Instance identifies the physical device across sessions; it is zero
+	// if DirectOutput can't tell
+	Instance DeviceInstance
+
+	manager *DeviceManager
+	handle  unsafe.Pointer
+	buttons uint32
+	removed bool
+}
+
+// Attached reports whether the device is still present
+func (dev *Device) Attached() bool {
+	dev.manager.mu.Lock()
+	defer dev.manager.mu.Unlock()
+	return !dev.removed
+}
+
+// call runs a DirectOutput call on the device's handle
+func (dev *Device) call(fn func(do DirectOutput, h unsafe.Pointer) error) error {
+	m := dev.manager
+	m.mu.Lock()
+	removed := dev.removed
+	m.mu.Unlock()
+	if removed {
+		return E_HANDLE
+	}
+	return fn(m.do, dev.handle)
+}
+
+// AddPage adds a page, making it active if active is set
+func (dev *Device) AddPage(page uint32, name string, active bool) error {
+	var flags uint32
+	if active {
+		flags = FLAG_SET_AS_ACTIVE
+	}
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.AddPage(h, page, name, flags)
+	})
+}
+
+// RemovePage removes a page
+func (dev *Device) RemovePage(page uint32) error {
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.RemovePage(h, page)
+	})
+}
+
+// SetLed sets an LED of the active page
+func (dev *Device) SetLed(page uint32, index uint32, on bool) error {
+	var value uint32
+	if on {
+		value = 1
+	}
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.SetLed(h, page, index, value)
+	})
+}
+
+// SetString sets a text line of the active page
+func (dev *Device) SetString(page uint32, index uint32, value string) error {
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.SetString(h, page, index, value)
+	})
+}
+
+// SetImage shows an image on the active page, scaled to the FIP
+func (dev *Device) SetImage(page uint32, img image.Image) error {
+	data := bmp.FIPBuffer(img)
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.SetImage(h, page, 0, data)
+	})
+}
+
+// SetImageFromFile shows an image file on the active page
+func (dev *Device) SetImageFromFile(page uint32, filename string) error {
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.SetImageFromFile(h, page, 0, filename)
+	})
+}
+
+// SetProfile sets the Saitek profile file of the device, where the SDK
+// supports it
+func (dev *Device) SetProfile(profile string) error {
+	return dev.call(func(do DirectOutput, h unsafe.Pointer) error {
+		return do.SetProfile(h, profile)
+	})
+}
+
+// Events returns the events of this device, as Subscribe does. The channel
+// is also closed after the device's DeviceRemoved event.
+func (dev *Device) Events(ctx context.Context) <-chan DeviceEvent {
+	return dev.manager.subscribe(ctx, dev)
+}
+
+// DeviceManager is a channel based view of a DirectOutput session: devices
+// are Device values and their callbacks arrive as DeviceEvents. It
+// registers the device, page and soft button callbacks of the session, so
+// they must not be registered elsewhere.
+type DeviceManager struct {
+	do DirectOutput
+
+	mu            sync.Mutex
+	devices       map[unsafe.Pointer]*Device
+	order         []*Device
+	next          map[DeviceKind]int
+	subscriptions map[*subscription]struct{}
+	closed        bool
+}
+
+// NewDeviceManager takes over the callbacks of an initialized DirectOutput
+// and adds the devices present
+func NewDeviceManager(do DirectOutput) (*DeviceManager, error) {
+	m := &DeviceManager{
+		do:            do,
+		devices:       make(map[unsafe.Pointer]*Device),
+		next:          make(map[DeviceKind]int),
+		subscriptions: make(map[*subscription]struct{}),
+	}
+	if err := do.RegisterDeviceCallback(m.onDeviceChange, nil); err != nil {
+		return nil, fmt.Errorf("failed to register device callback: %w", err)
+	}
+
+	var handles []unsafe.Pointer
+	if err := do.Enumerate(func(hDevice unsafe.Pointer, _ unsafe.Pointer) {
+		handles = append(handles, hDevice)
+	}, nil); err != nil {
+		return nil, fmt.Errorf("failed to enumerate devices: %w", err)
+	}
+	for _, h := range handles {
+		m.add(h)
+	}
+	return m, nil
+}
+
+// Devices returns the devices present, in the order they were added
+func (m *DeviceManager) Devices() []*Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Device(nil), m.order...)
+}
+
+// Device returns a present device by ID
+func (m *DeviceManager) Device(id DeviceID) (*Device, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, dev := range m.order {
+		if dev.ID == id {
+			return dev, true
+		}
+	}
+	return nil, false
+}
+
+// Subscribe returns a channel of the events of every device, starting with
+// a DeviceAdded event for each device present. Events are queued rather
+// than dropped for a slow reader. The channel is closed when ctx is done or
+// the manager is closed.
+func (m *DeviceManager) Subscribe(ctx context.Context) <-chan DeviceEvent {
+	return m.subscribe(ctx, nil)
+}
+
+// Close stops the device callback and closes every event channel
+func (m *DeviceManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	subscriptions := m.subscriptions
+	m.subscriptions = nil
+	m.mu.Unlock()
+
+	for s := range subscriptions {
+		s.close()
+	}
+	return m.do.RegisterDeviceCallback(nil, nil)
+}
+
+// add creates the Device for a handle and registers its callbacks
+func (m *DeviceManager) add(hDevice unsafe.Pointer) *Device {
+	deviceType, err := m.do.GetDeviceType(hDevice)
+	kind := DeviceUnknown
+	if err == nil {
+		kind = deviceKind(deviceType)
+	}
+	instance, _ := m.do.GetDeviceInstance(hDevice)
+
+	m.mu.Lock()
+	if dev, ok := m.devices[hDevice]; ok {
+		m.mu.Unlock()
+		return dev
+	}
+	m.next[kind]++
+	dev := &Device{
+		ID:       DeviceID(fmt.Sprintf("%s-%d", kind, m.next[kind])),
+		Kind:     kind,
+		Instance: instance,
+		manager:  m,
+		handle:   hDevice,
+	}
+	m.devices[hDevice] = dev
+	m.order = append(m.order, dev)
+	m.publishLocked(DeviceEvent{Type: DeviceAdded, Device: dev})
+	m.mu.Unlock()
+
+	m.do.RegisterPageCallback(hDevice, func(_ unsafe.Pointer, page uint32, active bool, _ unsafe.Pointer) {
+		m.publish(DeviceEvent{Type: PageChanged, Device: dev, Page: page, Active: active})
+	}, nil)
+	m.do.RegisterSoftButtonCallback(hDevice, func(_ unsafe.Pointer, buttons uint32, _ unsafe.Pointer) {
+		m.mu.Lock()
+		previous := dev.buttons
+		dev.buttons = buttons
+		m.mu.Unlock()
+		m.publish(DeviceEvent{
+			Type:     SoftButtonsChanged,
+			Device:   dev,
+			Buttons:  buttons,
+			Pressed:  buttons &^ previous,
+			Released: previous &^ buttons,
+		})
+	}, nil)
+	return dev
+}
+
+// onDeviceChange adds and removes devices as DirectOutput reports them
+func (m *DeviceManager) onDeviceChange(hDevice unsafe.Pointer, added bool, _ unsafe.Pointer) {
+	if added {
+		m.add(hDevice)
+		return
+	}
+
+	m.mu.Lock()
+	dev, ok := m.devices[hDevice]
+	if ok {
+		dev.removed = true
+		delete(m.devices, hDevice)
+		for i, other := range m.order {
+			if other == dev {
+				m.order = append(m.order[:i], m.order[i+1:]...)
+				break
+			}
+		}
+		m.publishLocked(DeviceEvent{Type: DeviceRemoved, Device: dev})
+	}
+	m.mu.Unlock()
+}
+
+// publish queues an event for every subscription that wants it
+func (m *DeviceManager) publish(e DeviceEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishLocked(e)
+}
+
+// publishLocked is publish with the lock held
+func (m *DeviceManager) publishLocked(e DeviceEvent) {
+	for s := range m.subscriptions {
+		if s.device == nil || s.device == e.Device {
+			s.push(e)
+		}
+	}
+}
+
+// subscribe starts a subscription for the events of device, or of every
+// device if device is nil
+func (m *DeviceManager) subscribe(ctx context.Context, device *Device) <-chan DeviceEvent {
+	s := &subscription{
+		device: device,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	out := make(chan DeviceEvent)
+
+	m.mu.Lock()
+	if m.closed || (device != nil && device.removed) {
+		m.mu.Unlock()
+		close(out)
+		return out
+	}
+	for _, dev := range m.order {
+		if device == nil || device == dev {
+			s.push(DeviceEvent{Type: DeviceAdded, Device: dev})
+		}
+	}
+	m.subscriptions[s] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		defer close(out)
+		defer func() {
+			m.mu.Lock()
+			delete(m.subscriptions, s)
+			m.mu.Unlock()
+		}()
+		for {
+			for _, e := range s.pop() {
+				select {
+				case out <- e:
+					if device != nil && e.Type == DeviceRemoved {
+						return
+					}
+				case <-ctx.Done():
+					return
+				case <-s.done:
+					return
+				}
+			}
+			select {
+			case <-s.notify:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// subscription is the event queue of one subscriber, so that callbacks
+// never wait for a reader
+type subscription struct {
+	device    *Device
+	mu        sync.Mutex
+	queue     []DeviceEvent
+	notify    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// push queues an event and wakes the subscriber
+func (s *subscription) push(e DeviceEvent) {
+	s.mu.Lock()
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the queued events
+func (s *subscription) pop() []DeviceEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.queue
+	s.queue = nil
+	return queue
+}
+
+// close ends the subscription
+func (s *subscription) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
@@ -0,0 +1,165 @@
+package fip
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event from events
+func nextEvent(t *testing.T, events <-chan DeviceEvent) DeviceEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("Event channel closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("No event")
+	}
+	return DeviceEvent{}
+}
+
+// newTestDeviceManager manages a native DirectOutput with a virtual FIP
+func newTestDeviceManager(t *testing.T) (*DeviceManager, *NativeDirectOutput, *VirtualDevice) {
+	t.Helper()
+	device := NewVirtualFIP()
+	do := NewNativeDirectOutput(func() []DirectOutputTransport {
+		return []DirectOutputTransport{device}
+	})
+	if err := do.Initialize("test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { do.Deinitialize() })
+
+	m, err := NewDeviceManager(do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m, do, device
+}
+
+func TestDeviceManagerEvents(t *testing.T) {
+	m, do, device := newTestDeviceManager(t)
+	events := m.Subscribe(context.Background())
+
+	// Devices present are reported first
+	e := nextEvent(t, events)
+	if e.Type != DeviceAdded || e.Device.ID != "fip-1" || e.Device.Kind != DeviceFIP {
+		t.Fatalf("Expected fip-1 to be added, got %v %+v", e.Type, e.Device)
+	}
+	dev := e.Device
+	if got, ok := m.Device("fip-1"); !ok || got != dev {
+		t.Error("Expected to find fip-1 by ID")
+	}
+
+	if err := dev.AddPage(1, "One", true); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Type != PageChanged || e.Page != 1 || !e.Active {
+		t.Errorf("Expected page 1 to become active, got %+v", e)
+	}
+	if err := dev.SetImage(1, image.NewRGBA(image.Rect(0, 0, 320, 240))); err != nil || device.Frames() == 0 {
+		t.Errorf("Expected the image to reach the FIP, got %v", err)
+	}
+	if err := dev.SetLed(1, 2, true); err != nil || device.Led(2) != 1 {
+		t.Errorf("Expected LED 2 on, got %v", err)
+	}
+
+	device.Press(SoftButton1)
+	device.Press(SoftButton2)
+	device.Release(SoftButton1)
+	for _, want := range []DeviceEvent{
+		{Buttons: SoftButton1, Pressed: SoftButton1},
+		{Buttons: SoftButton1 | SoftButton2, Pressed: SoftButton2},
+		{Buttons: SoftButton2, Released: SoftButton1},
+	} {
+		e := nextEvent(t, events)
+		if e.Type != SoftButtonsChanged || e.Buttons != want.Buttons || e.Pressed != want.Pressed || e.Released != want.Released {
+			t.Errorf("Expected buttons %+v, got %+v", want, e)
+		}
+	}
+
+	// Hot plugged devices get new IDs
+	x52 := NewVirtualDevice(DeviceTypeX52Pro)
+	do.Attach(x52)
+	if e := nextEvent(t, events); e.Type != DeviceAdded || e.Device.ID != "x52pro-1" {
+		t.Errorf("Expected x52pro-1 to be added, got %v %+v", e.Type, e.Device)
+	}
+	device.Unplug()
+	if e := nextEvent(t, events); e.Type != DeviceRemoved || e.Device != dev {
+		t.Errorf("Expected fip-1 to be removed, got %v %+v", e.Type, e.Device)
+	}
+	if dev.Attached() || len(m.Devices()) != 1 {
+		t.Error("Expected fip-1 to be gone")
+	}
+	if err := dev.SetLed(1, 0, true); !errors.Is(err, E_HANDLE) {
+		t.Errorf("Expected E_HANDLE for a removed device, got %v", err)
+	}
+	do.Attach(NewVirtualFIP())
+	if e := nextEvent(t, events); e.Device.ID != "fip-2" {
+		t.Errorf("Expected a new FIP to be fip-2, got %s", e.Device.ID)
+	}
+}
+
+func TestDeviceEventsCancel(t *testing.T) {
+	m, _, device := newTestDeviceManager(t)
+	dev := m.Devices()[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := dev.Events(ctx)
+	nextEvent(t, events)
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			// An event may have been on its way; the channel closes next
+			if _, ok := <-events; ok {
+				t.Error("Expected the channel to close")
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Channel not closed after cancel")
+	}
+
+	// A device's channel closes after it is removed
+	events = dev.Events(context.Background())
+	nextEvent(t, events)
+	device.Unplug()
+	if e := nextEvent(t, events); e.Type != DeviceRemoved {
+		t.Errorf("Expected DeviceRemoved, got %v", e.Type)
+	}
+	if _, ok := <-events; ok {
+		t.Error("Expected the channel to close after DeviceRemoved")
+	}
+	if _, ok := <-dev.Events(context.Background()); ok {
+		t.Error("Expected no events for a removed device")
+	}
+
+	all := m.Subscribe(context.Background())
+	m.Close()
+	for range all {
+	}
+}
+
+func TestDeviceManagerSlowReader(t *testing.T) {
+	m, _, device := newTestDeviceManager(t)
+	events := m.Subscribe(context.Background())
+	dev := nextEvent(t, events).Device
+	dev.AddPage(1, "One", true)
+
+	// Callbacks don't wait for the reader and no event is lost
+	for i := 0; i < 100; i++ {
+		device.Click(SoftButtonUp)
+	}
+	nextEvent(t, events)
+	for i := 0; i < 200; i++ {
+		if e := nextEvent(t, events); e.Type != SoftButtonsChanged {
+			t.Fatalf("Expected soft button event %d, got %v", i, e.Type)
+		}
+	}
+}
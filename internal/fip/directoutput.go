@@ -3,33 +3,57 @@ package fip
 import (
 	"fmt"
 	"image"
-	"image/draw"
-	"image/png"
 	"os"
 	"unsafe"
-)
-
-// DirectOutput wrapper for Saitek FIP panels
-type DirectOutput struct {
-	// Cross-platform implementation
-	Devices map[unsafe.Pointer]*Device
-}
 
-// Device represents a DirectOutput device
-type Device struct {
-	Handle     unsafe.Pointer
-	DeviceType [16]byte
-	Pages      map[uint32]*Page
-}
+	"saitek-controller/internal/bmp"
+)
 
-// Page represents a DirectOutput page
-type Page struct {
-	ID        uint32
-	Name      string
-	Active    bool
-	Images    map[uint32][]byte
-	Leds      map[uint32]uint32
-	Callbacks *PageCallbacks
+// DirectOutput is the Saitek DirectOutput SDK as a Go interface. Devices are
+// opaque handles reported by Enumerate and the device callback; every call
+// returns nil or an HRESULT error that can be tested with errors.Is.
+//
+// NewDirectOutput picks the implementation for the platform: the SDK DLL
+// on Windows when it is installed, otherwise NativeDirectOutput on the
+// project's own USB/HID transports.
+type DirectOutput interface {
+	// Initialize starts a session for the named plugin
+	Initialize(pluginName string) error
+	// Deinitialize ends the session and forgets all pages and callbacks
+	Deinitialize() error
+	// RegisterDeviceCallback sets the callback for devices added or removed
+	RegisterDeviceCallback(callback DeviceChangeCallback, context unsafe.Pointer) error
+	// Enumerate calls callback for every device present
+	Enumerate(callback EnumerateCallback, context unsafe.Pointer) error
+	// RegisterPageCallback sets the callback for page changes on a device
+	RegisterPageCallback(hDevice unsafe.Pointer, callback PageChangeCallback, context unsafe.Pointer) error
+	// RegisterSoftButtonCallback sets the callback for soft button changes on
+	// a device; it is only called for the active page
+	RegisterSoftButtonCallback(hDevice unsafe.Pointer, callback SoftButtonChangeCallback, context unsafe.Pointer) error
+	// GetDeviceType returns DeviceTypeFip or DeviceTypeX52Pro
+	GetDeviceType(hDevice unsafe.Pointer) ([16]byte, error)
+	// GetDeviceInstance returns the instance GUID of the physical device
+	GetDeviceInstance(hDevice unsafe.Pointer) (DeviceInstance, error)
+	// SetProfile sets the Saitek profile file of a device; an empty name
+	// clears it
+	SetProfile(hDevice unsafe.Pointer, profile string) error
+	// AddPage adds a page, making it active with FLAG_SET_AS_ACTIVE
+	AddPage(hDevice unsafe.Pointer, page uint32, debugName string, flags uint32) error
+	// RemovePage removes a page
+	RemovePage(hDevice unsafe.Pointer, page uint32) error
+	// SetLed sets an LED of an active page
+	SetLed(hDevice unsafe.Pointer, page uint32, index uint32, value uint32) error
+	// SetString sets a text line of an active page
+	SetString(hDevice unsafe.Pointer, page uint32, index uint32, value string) error
+	// SetImage sets the image of an active page from a FIP frame buffer
+	SetImage(hDevice unsafe.Pointer, page uint32, index uint32, data []byte) error
+	// SetImageFromFile sets the image of an active page from an image file
+	SetImageFromFile(hDevice unsafe.Pointer, page uint32, index uint32, filename string) error
+}
+
+// NewDirectOutput creates the DirectOutput implementation for the platform
+func NewDirectOutput() (DirectOutput, error) {
+	return newDirectOutput()
 }
 
 // PageCallbacks holds callback functions for a page
@@ -64,213 +88,90 @@ const (
 	FLAG_SET_AS_ACTIVE = 0x00000001
 )
 
-// Error codes
-const (
-	E_PAGENOTACTIVE  = 0xFF040001
-	E_BUFFERTOOSMALL = 0xFF040000 | 0x6F // ERROR_BUFFER_OVERFLOW
-)
+// HRESULT is a DirectOutput result code. Failures are returned as errors
+// and compare equal with errors.Is.
+type HRESULT uint32
 
-// Callback types
-type (
-	EnumerateCallback        func(hDevice unsafe.Pointer, pCtxt unsafe.Pointer)
-	DeviceChangeCallback     func(hDevice unsafe.Pointer, bAdded bool, pCtxt unsafe.Pointer)
-	PageChangeCallback       func(hDevice unsafe.Pointer, dwPage uint32, bSetActive bool, pCtxt unsafe.Pointer)
-	SoftButtonChangeCallback func(hDevice unsafe.Pointer, dwButtons uint32, pCtxt unsafe.Pointer)
+// Result codes
+const (
+	S_OK             HRESULT = 0x00000000
+	E_NOTIMPL        HRESULT = 0x80004001
+	E_FAIL           HRESULT = 0x80004005
+	E_HANDLE         HRESULT = 0x80070006
+	E_OUTOFMEMORY    HRESULT = 0x8007000E
+	E_INVALIDARG     HRESULT = 0x80070057
+	E_BUFFERTOOSMALL HRESULT = 0xFF04006F
+	E_PAGENOTACTIVE  HRESULT = 0xFF040001
 )
 
-// NewDirectOutput creates a new DirectOutput instance
-func NewDirectOutput() (*DirectOutput, error) {
-	do := &DirectOutput{
-		Devices: make(map[unsafe.Pointer]*Device),
-	}
-
-	return do, nil
-}
-
-// Initialize initializes the DirectOutput library
-func (do *DirectOutput) Initialize(pluginName string) error {
-	// On macOS, we'll use a cross-platform approach
-	// For now, we'll simulate the DirectOutput behavior
-	fmt.Printf("DirectOutput initialized with plugin: %s\n", pluginName)
-	return nil
+var hresultNames = map[HRESULT]string{
+	S_OK:             "S_OK",
+	E_NOTIMPL:        "E_NOTIMPL",
+	E_FAIL:           "E_FAIL",
+	E_HANDLE:         "E_HANDLE",
+	E_OUTOFMEMORY:    "E_OUTOFMEMORY",
+	E_INVALIDARG:     "E_INVALIDARG",
+	E_BUFFERTOOSMALL: "E_BUFFERTOOSMALL",
+	E_PAGENOTACTIVE:  "E_PAGENOTACTIVE",
 }
 
-// Deinitialize cleans up the DirectOutput library
-func (do *DirectOutput) Deinitialize() error {
-	// Clean up devices
-	do.Devices = make(map[unsafe.Pointer]*Device)
-	return nil
-}
-
-// RegisterDeviceCallback registers a callback for device changes
-func (do *DirectOutput) RegisterDeviceCallback(callback DeviceChangeCallback, context unsafe.Pointer) error {
-	// Store callback for later use
-	return nil
-}
-
-// Enumerate enumerates all DirectOutput devices
-func (do *DirectOutput) Enumerate(callback EnumerateCallback, context unsafe.Pointer) error {
-	// For now, we'll simulate device enumeration
-	// In a real implementation, this would find actual FIP devices
-	return nil
-}
-
-// RegisterPageCallback registers a callback for page changes
-func (do *DirectOutput) RegisterPageCallback(hDevice unsafe.Pointer, callback PageChangeCallback, context unsafe.Pointer) error {
-	_, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
+// Error returns the code name and value
+func (r HRESULT) Error() string {
+	if name, ok := hresultNames[r]; ok {
+		return fmt.Sprintf("%s (0x%08X)", name, uint32(r))
 	}
-	
-	// Store callback for later use
-	return nil
-}
-
-// RegisterSoftButtonCallback registers a callback for soft button changes
-func (do *DirectOutput) RegisterSoftButtonCallback(hDevice unsafe.Pointer, callback SoftButtonChangeCallback, context unsafe.Pointer) error {
-	_, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
-	}
-
-	// Store callback for later use
-	return nil
+	return fmt.Sprintf("HRESULT 0x%08X", uint32(r))
 }
 
-// GetDeviceType gets the device type GUID
-func (do *DirectOutput) GetDeviceType(hDevice unsafe.Pointer) ([16]byte, error) {
-	device, exists := do.Devices[hDevice]
-	if !exists {
-		return [16]byte{}, fmt.Errorf("device not found")
-	}
-	return device.DeviceType, nil
+// Failed reports whether the code is a failure, like the FAILED macro
+func (r HRESULT) Failed() bool {
+	return r&0x80000000 != 0
 }
 
-// AddPage adds a page to the device
-func (do *DirectOutput) AddPage(hDevice unsafe.Pointer, page uint32, debugName string, flags uint32) error {
-	device, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
-	}
-
-	if device.Pages == nil {
-		device.Pages = make(map[uint32]*Page)
-	}
-
-	device.Pages[page] = &Page{
-		ID:        page,
-		Name:      debugName,
-		Active:    (flags & FLAG_SET_AS_ACTIVE) != 0,
-		Images:    make(map[uint32][]byte),
-		Leds:      make(map[uint32]uint32),
-		Callbacks: &PageCallbacks{},
+// Err returns nil for success codes and the code itself for failures
+func (r HRESULT) Err() error {
+	if !r.Failed() {
+		return nil
 	}
-
-	return nil
+	return r
 }
 
-// RemovePage removes a page from the device
-func (do *DirectOutput) RemovePage(hDevice unsafe.Pointer, page uint32) error {
-	device, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
-	}
-
-	delete(device.Pages, page)
-	return nil
-}
-
-// SetLed sets an LED on the device
-func (do *DirectOutput) SetLed(hDevice unsafe.Pointer, page uint32, index uint32, value uint32) error {
-	device, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
-	}
-
-	pageObj, exists := device.Pages[page]
-	if !exists {
-		return fmt.Errorf("page not found")
-	}
-
-	pageObj.Leds[index] = value
-	return nil
-}
-
-// SetImage sets an image on the device
-func (do *DirectOutput) SetImage(hDevice unsafe.Pointer, page uint32, index uint32, data []byte) error {
-	device, exists := do.Devices[hDevice]
-	if !exists {
-		return fmt.Errorf("device not found")
-	}
-
-	pageObj, exists := device.Pages[page]
-	if !exists {
-		return fmt.Errorf("page not found")
-	}
-
-	pageObj.Images[index] = data
-	return nil
-}
-
-// SetImageFromFile sets an image from a file
-func (do *DirectOutput) SetImageFromFile(hDevice unsafe.Pointer, page uint32, index uint32, filename string) error {
-	// Read the image file
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read image file: %v", err)
-	}
-
-	return do.SetImage(hDevice, page, index, data)
-}
-
-// CreateFIPImage creates a 320x240 image for the FIP display
-func (do *DirectOutput) CreateFIPImage(width, height int) *image.RGBA {
-	// FIP displays are 320x240
-	if width == 0 {
-		width = 320
-	}
-	if height == 0 {
-		height = 240
-	}
-
-	return image.NewRGBA(image.Rect(0, 0, width, height))
-}
-
-// ConvertImageToFIPFormat converts an image to FIP format (320x240, 24bpp RGB)
-func (do *DirectOutput) ConvertImageToFIPFormat(img image.Image) ([]byte, error) {
-	// Create a 320x240 RGBA image
-	fipImg := image.NewRGBA(image.Rect(0, 0, 320, 240))
-
-	// Draw the source image onto the FIP image
-	draw.Draw(fipImg, fipImg.Bounds(), img, image.Point{}, draw.Src)
+// Callback types
+type (
+	EnumerateCallback        func(hDevice unsafe.Pointer, pCtxt unsafe.Pointer)
+	DeviceChangeCallback     func(hDevice unsafe.Pointer, bAdded bool, pCtxt unsafe.Pointer)
+	PageChangeCallback       func(hDevice unsafe.Pointer, dwPage uint32, bSetActive bool, pCtxt unsafe.Pointer)
+	SoftButtonChangeCallback func(hDevice unsafe.Pointer, dwButtons uint32, pCtxt unsafe.Pointer)
+)
 
-	// Convert to 24bpp RGB format (FIP requirement)
-	data := make([]byte, 320*240*3)
-	for y := 0; y < 240; y++ {
-		for x := 0; x < 320; x++ {
-			idx := (y*320 + x) * 3
-			c := fipImg.RGBAAt(x, y)
-			data[idx] = c.R   // Red
-			data[idx+1] = c.G // Green
-			data[idx+2] = c.B // Blue
+// FirstDevice returns the first device of a type, such as DeviceTypeFip, or
+// E_HANDLE if there is none
+func FirstDevice(do DirectOutput, deviceType [16]byte) (unsafe.Pointer, error) {
+	var devices []unsafe.Pointer
+	if err := do.Enumerate(func(hDevice unsafe.Pointer, _ unsafe.Pointer) {
+		devices = append(devices, hDevice)
+	}, nil); err != nil {
+		return nil, err
+	}
+	for _, hDevice := range devices {
+		if t, err := do.GetDeviceType(hDevice); err == nil && t == deviceType {
+			return hDevice, nil
 		}
 	}
-
-	return data, nil
+	return nil, E_HANDLE
 }
 
-// SaveImageAsPNG saves an image as PNG for debugging
-func (do *DirectOutput) SaveImageAsPNG(img image.Image, filename string) error {
-	file, err := os.Create(filename)
+// loadImageFile reads a BMP, PNG, JPEG or GIF file as a FIP frame buffer
+func loadImageFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read image file: %w", err)
 	}
 	defer file.Close()
 
-	return png.Encode(file, img)
-}
-
-// Close closes the DirectOutput instance
-func (do *DirectOutput) Close() error {
-	return do.Deinitialize()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image file: %w", err)
+	}
+	return bmp.FIPBuffer(img), nil
 }
@@ -0,0 +1,655 @@
+package fip
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"unsafe"
+
+	"saitek-controller/internal/bmp"
+)
+
+// deviceModel is what one page of a device type can show
+type deviceModel struct {
+	leds      uint32
+	strings   uint32
+	images    uint32
+	imageSize int
+}
+
+var deviceModels = map[[16]byte]deviceModel{
+	DeviceTypeFip:    {leds: softButtonLEDs, images: 1, imageSize: bmp.FIPBufferSize},
+	DeviceTypeX52Pro: {leds: 20, strings: 3},
+}
+
+// DirectOutputTransport is the hardware behind a NativeDirectOutput device.
+// It only ever receives the output of the active page.
+type DirectOutputTransport interface {
+	DeviceType() [16]byte
+	SetImage(index uint32, data []byte) error
+	SetLed(index uint32, value uint32) error
+	SetString(index uint32, value string) error
+	// Instance identifies the physical device, see NewDeviceInstance
+	Instance() DeviceInstance
+	// SoftButtons delivers the button state as a bitmask of SoftButton*
+	// values, page buttons included. It is closed when the device goes away
+	// and is nil for devices without buttons.
+	SoftButtons() <-chan uint32
+	Close() error
+}
+
+// nativePage is what one page shows
+type nativePage struct {
+	images  map[uint32][]byte
+	leds    map[uint32]uint32
+	strings map[uint32]string
+}
+
+// newNativePage returns a blank page
+func newNativePage() *nativePage {
+	return &nativePage{
+		images:  make(map[uint32][]byte),
+		leds:    make(map[uint32]uint32),
+		strings: make(map[uint32]string),
+	}
+}
+
+// copy returns a snapshot of the page. Image buffers are replaced, never
+// changed, so they are shared.
+func (p *nativePage) copy() *nativePage {
+	c := newNativePage()
+	for i, buf := range p.images {
+		c.images[i] = buf
+	}
+	for i, v := range p.leds {
+		c.leds[i] = v
+	}
+	for i, v := range p.strings {
+		c.strings[i] = v
+	}
+	return c
+}
+
+// nativeDevice is an attached transport and its pages. The PageManager
+// keeps the page order, the active page and the page buttons; content
+// holds what each page shows.
+type nativeDevice struct {
+	transport      DirectOutputTransport
+	model          deviceModel
+	pages          *PageManager
+	content        map[uint32]*nativePage
+	callbacks      PageCallbacks
+	pageCallback   PageChangeCallback
+	pageContext    unsafe.Pointer
+	buttonCallback SoftButtonChangeCallback
+	buttonContext  unsafe.Pointer
+
+	// writeMu serializes writes to the transport, so drawing on a page and
+	// switching pages don't interleave. It is taken before the
+	// NativeDirectOutput lock.
+	writeMu sync.Mutex
+}
+
+// handle returns the device's DirectOutput handle
+func (dev *nativeDevice) handle() unsafe.Pointer {
+	return unsafe.Pointer(dev)
+}
+
+// activePage returns the content of a page for a call that draws on it.
+// It must be called with the NativeDirectOutput lock held.
+func (dev *nativeDevice) activePage(page uint32) (*nativePage, error) {
+	p, ok := dev.content[page]
+	if !ok {
+		return nil, E_INVALIDARG
+	}
+	if active := dev.pages.ActivePage(); active == nil || active.ID != page {
+		return nil, E_PAGENOTACTIVE
+	}
+	return p, nil
+}
+
+// NativeDirectOutput implements DirectOutput in Go on DirectOutputTransports:
+// the project's own USB/HID transports, or VirtualDevice in tests. Like the
+// SDK, the page buttons cycle through the pages, only the active page may
+// be drawn and soft button changes go to the device's callback.
+type NativeDirectOutput struct {
+	mu             sync.Mutex
+	discover       func() []DirectOutputTransport
+	initialized    bool
+	devices        []*nativeDevice
+	deviceCallback DeviceChangeCallback
+	deviceContext  unsafe.Pointer
+}
+
+// NewNativeDirectOutput creates a DirectOutput that opens the devices found
+// by discover on Initialize. discover may be nil when devices are only
+// attached with Attach.
+func NewNativeDirectOutput(discover func() []DirectOutputTransport) *NativeDirectOutput {
+	return &NativeDirectOutput{discover: discover}
+}
+
+// Initialize starts the session and opens the devices found
+func (d *NativeDirectOutput) Initialize(pluginName string) error {
+	d.mu.Lock()
+	if d.initialized {
+		d.mu.Unlock()
+		return E_FAIL
+	}
+	d.initialized = true
+	d.mu.Unlock()
+
+	if d.discover != nil {
+		for _, transport := range d.discover() {
+			d.Attach(transport)
+		}
+	}
+	log.Printf("DirectOutput initialized with plugin: %s", pluginName)
+	return nil
+}
+
+// Deinitialize closes every device and forgets pages and callbacks
+func (d *NativeDirectOutput) Deinitialize() error {
+	d.mu.Lock()
+	devices := d.devices
+	d.devices = nil
+	d.initialized = false
+	d.deviceCallback, d.deviceContext = nil, nil
+	d.mu.Unlock()
+
+	for _, dev := range devices {
+		if err := dev.transport.Close(); err != nil {
+			log.Printf("Failed to close DirectOutput device: %v", err)
+		}
+	}
+	return nil
+}
+
+// Attach adds a device, as when one is plugged in, and reports it to the
+// device callback. It returns the device handle.
+func (d *NativeDirectOutput) Attach(transport DirectOutputTransport) unsafe.Pointer {
+	dev := &nativeDevice{
+		transport: transport,
+		model:     deviceModels[transport.DeviceType()],
+		pages:     NewPageManager(nil, 0, 0),
+		content:   make(map[uint32]*nativePage),
+	}
+	dev.pages.output = func(page *FIPPage) error { return d.show(dev, page) }
+	dev.callbacks = PageCallbacks{
+		OnPageChanged:       func(page uint32, active bool) { d.pageChanged(dev, page, active) },
+		OnSoftButtonChanged: func(buttons uint32) { d.softButtonsChanged(dev, buttons) },
+	}
+
+	d.mu.Lock()
+	d.devices = append(d.devices, dev)
+	callback, context := d.deviceCallback, d.deviceContext
+	d.mu.Unlock()
+
+	if buttons := transport.SoftButtons(); buttons != nil {
+		go func() {
+			for state := range buttons {
+				dev.pages.HandleSoftButtons(state)
+			}
+			// The device went away
+			d.detach(dev, false)
+		}()
+	}
+	if callback != nil {
+		callback(dev.handle(), true, context)
+	}
+	return dev.handle()
+}
+
+// Detach removes a device, as when it is unplugged, closes it and reports
+// it to the device callback
+func (d *NativeDirectOutput) Detach(hDevice unsafe.Pointer) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	d.mu.Unlock()
+	if dev == nil {
+		return E_HANDLE
+	}
+	return d.detach(dev, true)
+}
+
+// detach removes dev if it is still attached
+func (d *NativeDirectOutput) detach(dev *nativeDevice, close bool) error {
+	d.mu.Lock()
+	found := false
+	for i, other := range d.devices {
+		if other == dev {
+			d.devices = append(d.devices[:i], d.devices[i+1:]...)
+			found = true
+			break
+		}
+	}
+	callback, context := d.deviceCallback, d.deviceContext
+	d.mu.Unlock()
+	if !found {
+		return nil
+	}
+
+	var err error
+	if close {
+		err = dev.transport.Close()
+	}
+	if callback != nil {
+		callback(dev.handle(), false, context)
+	}
+	return err
+}
+
+// device returns the device for a handle, or nil. It must be called with
+// the lock held.
+func (d *NativeDirectOutput) device(hDevice unsafe.Pointer) *nativeDevice {
+	for _, dev := range d.devices {
+		if dev.handle() == hDevice {
+			return dev
+		}
+	}
+	return nil
+}
+
+// RegisterDeviceCallback sets the callback for devices added or removed
+func (d *NativeDirectOutput) RegisterDeviceCallback(callback DeviceChangeCallback, context unsafe.Pointer) error {
+	d.mu.Lock()
+	d.deviceCallback, d.deviceContext = callback, context
+	d.mu.Unlock()
+	return nil
+}
+
+// Enumerate calls callback for every device attached
+func (d *NativeDirectOutput) Enumerate(callback EnumerateCallback, context unsafe.Pointer) error {
+	d.mu.Lock()
+	handles := make([]unsafe.Pointer, len(d.devices))
+	for i, dev := range d.devices {
+		handles[i] = dev.handle()
+	}
+	d.mu.Unlock()
+
+	for _, h := range handles {
+		callback(h, context)
+	}
+	return nil
+}
+
+// RegisterPageCallback sets the callback for page changes on a device
+func (d *NativeDirectOutput) RegisterPageCallback(hDevice unsafe.Pointer, callback PageChangeCallback, context unsafe.Pointer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dev := d.device(hDevice)
+	if dev == nil {
+		return E_HANDLE
+	}
+	dev.pageCallback, dev.pageContext = callback, context
+	return nil
+}
+
+// RegisterSoftButtonCallback sets the callback for soft button changes on a
+// device
+func (d *NativeDirectOutput) RegisterSoftButtonCallback(hDevice unsafe.Pointer, callback SoftButtonChangeCallback, context unsafe.Pointer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dev := d.device(hDevice)
+	if dev == nil {
+		return E_HANDLE
+	}
+	dev.buttonCallback, dev.buttonContext = callback, context
+	return nil
+}
+
+// GetDeviceType returns the device type GUID
+func (d *NativeDirectOutput) GetDeviceType(hDevice unsafe.Pointer) ([16]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dev := d.device(hDevice)
+	if dev == nil {
+		return [16]byte{}, E_HANDLE
+	}
+	return dev.transport.DeviceType(), nil
+}
+
+// GetDeviceInstance returns the instance of the device's transport
+func (d *NativeDirectOutput) GetDeviceInstance(hDevice unsafe.Pointer) (DeviceInstance, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dev := d.device(hDevice)
+	if dev == nil {
+		return DeviceInstance{}, E_HANDLE
+	}
+	return dev.transport.Instance(), nil
+}
+
+// SetProfile returns E_NOTIMPL: Saitek profiles are run by the Saitek
+// software, which the native implementation doesn't use
+func (d *NativeDirectOutput) SetProfile(hDevice unsafe.Pointer, profile string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.device(hDevice) == nil {
+		return E_HANDLE
+	}
+	return E_NOTIMPL
+}
+
+// AddPage adds a page. It becomes active with FLAG_SET_AS_ACTIVE or when
+// the device has no active page.
+func (d *NativeDirectOutput) AddPage(hDevice unsafe.Pointer, page uint32, debugName string, flags uint32) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	if dev == nil {
+		d.mu.Unlock()
+		return E_HANDLE
+	}
+	if _, ok := dev.content[page]; ok {
+		d.mu.Unlock()
+		return E_INVALIDARG
+	}
+	dev.content[page] = newNativePage()
+	d.mu.Unlock()
+
+	p, err := dev.pages.addPage(page, debugName, nil, dev.callbacks, flags)
+	if p == nil {
+		return E_INVALIDARG
+	}
+	return err
+}
+
+// RemovePage removes a page. If it was active, the next page becomes
+// active; removing the last page blanks the device.
+func (d *NativeDirectOutput) RemovePage(hDevice unsafe.Pointer, page uint32) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	d.mu.Unlock()
+	if dev == nil {
+		return E_HANDLE
+	}
+	if dev.pages.Page(page) == nil {
+		return E_INVALIDARG
+	}
+
+	err := dev.pages.RemovePage(page)
+	d.mu.Lock()
+	delete(dev.content, page)
+	d.mu.Unlock()
+	return err
+}
+
+// SetLed sets an LED of an active page; non-zero values are on
+func (d *NativeDirectOutput) SetLed(hDevice unsafe.Pointer, page uint32, index uint32, value uint32) error {
+	return d.draw(hDevice, page, func(dev *nativeDevice, p *nativePage) error {
+		if index >= dev.model.leds {
+			return E_INVALIDARG
+		}
+		p.leds[index] = value
+		return nil
+	}, func(t DirectOutputTransport) error {
+		return t.SetLed(index, value)
+	})
+}
+
+// SetString sets a text line of an active page
+func (d *NativeDirectOutput) SetString(hDevice unsafe.Pointer, page uint32, index uint32, value string) error {
+	return d.draw(hDevice, page, func(dev *nativeDevice, p *nativePage) error {
+		if index >= dev.model.strings {
+			return E_INVALIDARG
+		}
+		p.strings[index] = value
+		return nil
+	}, func(t DirectOutputTransport) error {
+		return t.SetString(index, value)
+	})
+}
+
+// SetImage sets the image of an active page from a FIP frame buffer, as
+// made by bmp.FIPBuffer
+func (d *NativeDirectOutput) SetImage(hDevice unsafe.Pointer, page uint32, index uint32, data []byte) error {
+	var buf []byte
+	return d.draw(hDevice, page, func(dev *nativeDevice, p *nativePage) error {
+		if index >= dev.model.images {
+			return E_INVALIDARG
+		}
+		if len(data) < dev.model.imageSize {
+			return E_BUFFERTOOSMALL
+		}
+		buf = append([]byte(nil), data[:dev.model.imageSize]...)
+		p.images[index] = buf
+		return nil
+	}, func(t DirectOutputTransport) error {
+		return t.SetImage(index, buf)
+	})
+}
+
+// SetImageFromFile sets the image of an active page from a BMP, PNG, JPEG
+// or GIF file
+func (d *NativeDirectOutput) SetImageFromFile(hDevice unsafe.Pointer, page uint32, index uint32, filename string) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	var err error = E_HANDLE
+	if dev != nil {
+		_, err = dev.activePage(page)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	data, err := loadImageFile(filename)
+	if err != nil {
+		return fmt.Errorf("%w: %w", E_INVALIDARG, err)
+	}
+	return d.SetImage(hDevice, page, index, data)
+}
+
+// draw changes an active page with update, under the lock, then sends the
+// change to the device with send. The transport is only called with the
+// device's write lock held, so a slow device doesn't hold up the others.
+func (d *NativeDirectOutput) draw(hDevice unsafe.Pointer, page uint32, update func(*nativeDevice, *nativePage) error, send func(DirectOutputTransport) error) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	d.mu.Unlock()
+	if dev == nil {
+		return E_HANDLE
+	}
+
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+	d.mu.Lock()
+	p, err := dev.activePage(page)
+	if err == nil {
+		err = update(dev, p)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return transportError(send(dev.transport))
+}
+
+// show sends everything a page shows to the transport, blanking what the
+// page hasn't set. It is the output of the device's PageManager, which
+// passes a nil page when the last one is removed.
+func (d *NativeDirectOutput) show(dev *nativeDevice, page *FIPPage) error {
+	dev.writeMu.Lock()
+	defer dev.writeMu.Unlock()
+
+	d.mu.Lock()
+	if dev.pages.ActivePage() != page {
+		// Another page was activated meanwhile and shows itself
+		d.mu.Unlock()
+		return nil
+	}
+	content := newNativePage()
+	if page != nil {
+		if p, ok := dev.content[page.ID]; ok {
+			content = p.copy()
+		}
+	}
+	d.mu.Unlock()
+
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = transportError(err)
+		}
+	}
+	for i := uint32(0); i < dev.model.images; i++ {
+		buf := content.images[i]
+		if buf == nil {
+			buf = make([]byte, dev.model.imageSize)
+		}
+		keep(dev.transport.SetImage(i, buf))
+	}
+	for i := uint32(0); i < dev.model.leds; i++ {
+		keep(dev.transport.SetLed(i, content.leds[i]))
+	}
+	for i := uint32(0); i < dev.model.strings; i++ {
+		keep(dev.transport.SetString(i, content.strings[i]))
+	}
+	return firstErr
+}
+
+// pageChanged passes a page change to the device's page callback
+func (d *NativeDirectOutput) pageChanged(dev *nativeDevice, page uint32, active bool) {
+	d.mu.Lock()
+	callback, context := dev.pageCallback, dev.pageContext
+	d.mu.Unlock()
+	if callback != nil {
+		callback(dev.handle(), page, active, context)
+	}
+}
+
+// softButtonsChanged passes the soft button state to the device's callback
+func (d *NativeDirectOutput) softButtonsChanged(dev *nativeDevice, buttons uint32) {
+	d.mu.Lock()
+	callback, context := dev.buttonCallback, dev.buttonContext
+	d.mu.Unlock()
+	if callback != nil {
+		callback(dev.handle(), buttons, context)
+	}
+}
+
+// HandleSoftButtons processes the button state of a device as a bitmask of
+// SoftButton* values, as its transport reports it. Presses of the page
+// buttons change page and changes to the other buttons go to the soft
+// button callback while a page is active.
+func (d *NativeDirectOutput) HandleSoftButtons(hDevice unsafe.Pointer, buttons uint32) error {
+	d.mu.Lock()
+	dev := d.device(hDevice)
+	d.mu.Unlock()
+	if dev == nil {
+		return E_HANDLE
+	}
+	return dev.pages.HandleSoftButtons(buttons)
+}
+
+// transportError turns a transport failure into an E_FAIL error
+func transportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", E_FAIL, err)
+}
+
+// FIPDevice is a FIP reached through the project's own transports, such as
+// FIPDirect (HID) or FIPUSB
+type FIPDevice interface {
+	BufferSender
+	LEDController
+	ReadButtonEvents() (chan InputEvent, error)
+	Instance() DeviceInstance
+	Disconnect() error
+}
+
+// fipTransport adapts a connected FIPDevice for NativeDirectOutput
+type fipTransport struct {
+	device  FIPDevice
+	buttons chan uint32
+}
+
+// NewFIPTransport adapts a connected FIPDirect or FIPUSB for
+// NativeDirectOutput, turning its input events into soft button states
+func NewFIPTransport(device FIPDevice) DirectOutputTransport {
+	t := &fipTransport{device: device}
+	events, err := device.ReadButtonEvents()
+	if err != nil {
+		log.Printf("FIP buttons unavailable: %v", err)
+		return t
+	}
+
+	t.buttons = make(chan uint32, 16)
+	go func() {
+		defer close(t.buttons)
+		var state uint32
+		for event := range events {
+			bit := event.Control.SoftButton()
+			switch {
+			case event.Control.IsDial():
+				// A detent is a press and release of the dial's bit
+				t.buttons <- state | bit
+			case event.Pressed:
+				state |= bit
+			default:
+				state &^= bit
+			}
+			t.buttons <- state
+		}
+	}()
+	return t
+}
+
+func (t *fipTransport) DeviceType() [16]byte {
+	return DeviceTypeFip
+}
+
+func (t *fipTransport) SetImage(index uint32, data []byte) error {
+	return t.device.SendFrameBuffer(data)
+}
+
+func (t *fipTransport) SetLed(index uint32, value uint32) error {
+	return t.device.SetLED(int(index), value != 0)
+}
+
+func (t *fipTransport) SetString(index uint32, value string) error {
+	return E_NOTIMPL
+}
+
+func (t *fipTransport) Instance() DeviceInstance {
+	return t.device.Instance()
+}
+
+func (t *fipTransport) SoftButtons() <-chan uint32 {
+	return t.buttons
+}
+
+func (t *fipTransport) Close() error {
+	return t.device.Disconnect()
+}
+
+// DiscoverFIPTransports opens the first FIP found over HID, or failing that
+// over libusb
+func DiscoverFIPTransports() []DirectOutputTransport {
+	direct := NewFIPDirect()
+	err := direct.Connect()
+	if err == nil {
+		return []DirectOutputTransport{NewFIPTransport(direct)}
+	}
+	log.Printf("No FIP over HID: %v", err)
+
+	device := NewFIPUSB()
+	if err := device.Connect(); err != nil {
+		log.Printf("No FIP over USB: %v", err)
+		return nil
+	}
+	return []DirectOutputTransport{NewFIPTransport(device)}
+}
+
+// DiscoverTransports opens the first FIP, as DiscoverFIPTransports does,
+// and the first X52 Pro
+func DiscoverTransports() []DirectOutputTransport {
+	transports := DiscoverFIPTransports()
+	x52, err := OpenX52ProUSB()
+	if err != nil {
+		log.Printf("No X52 Pro over USB: %v", err)
+		return transports
+	}
+	return append(transports, x52)
+}
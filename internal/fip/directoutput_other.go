@@ -0,0 +1,9 @@
+//go:build !windows
+
+package fip
+
+// newDirectOutput uses the native implementation: there is no SDK outside
+// Windows
+func newDirectOutput() (DirectOutput, error) {
+	return NewNativeDirectOutput(DiscoverTransports), nil
+}
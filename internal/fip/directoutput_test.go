@@ -0,0 +1,311 @@
+package fip
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"saitek-controller/internal/bmp"
+)
+
+// pageChange is one call of a page callback
+type pageChange struct {
+	page   uint32
+	active bool
+}
+
+// newTestDirectOutput attaches a virtual FIP to a native DirectOutput and
+// records its page and soft button callbacks
+func newTestDirectOutput(t *testing.T) (*NativeDirectOutput, unsafe.Pointer, *VirtualDevice, chan pageChange, chan uint32) {
+	t.Helper()
+	do := NewNativeDirectOutput(nil)
+	if err := do.Initialize("test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { do.Deinitialize() })
+
+	device := NewVirtualFIP()
+	h := do.Attach(device)
+	pages := make(chan pageChange, 10)
+	buttons := make(chan uint32, 10)
+	do.RegisterPageCallback(h, func(_ unsafe.Pointer, page uint32, active bool, _ unsafe.Pointer) {
+		pages <- pageChange{page, active}
+	}, nil)
+	do.RegisterSoftButtonCallback(h, func(_ unsafe.Pointer, state uint32, _ unsafe.Pointer) {
+		buttons <- state
+	}, nil)
+	return do, h, device, pages, buttons
+}
+
+// expectPages checks the next page callbacks
+func expectPages(t *testing.T, pages chan pageChange, want ...pageChange) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-pages:
+			if got != w {
+				t.Errorf("Expected page callback %+v, got %+v", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No page callback, expected %+v", w)
+		}
+	}
+}
+
+func testBuffer(c color.RGBA) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, bmp.FIPWidth, bmp.FIPHeight))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return bmp.FIPBuffer(img)
+}
+
+func TestHRESULT(t *testing.T) {
+	if S_OK.Err() != nil || S_OK.Failed() {
+		t.Error("Expected S_OK to succeed")
+	}
+	if !E_PAGENOTACTIVE.Failed() || E_PAGENOTACTIVE.Err() != E_PAGENOTACTIVE {
+		t.Error("Expected E_PAGENOTACTIVE to fail")
+	}
+	if s := E_HANDLE.Error(); s != "E_HANDLE (0x80070006)" {
+		t.Errorf("Unexpected error text %q", s)
+	}
+	if err := transportError(errors.New("write failed")); !errors.Is(err, E_FAIL) {
+		t.Errorf("Expected transport failures to be E_FAIL, got %v", err)
+	}
+}
+
+func TestNativeDirectOutputPages(t *testing.T) {
+	do, h, device, pages, _ := newTestDirectOutput(t)
+	red, blue := testBuffer(color.RGBA{255, 0, 0, 255}), testBuffer(color.RGBA{0, 0, 255, 255})
+
+	// The first page becomes active
+	if err := do.AddPage(h, 1, "One", 0); err != nil {
+		t.Fatal(err)
+	}
+	expectPages(t, pages, pageChange{1, true})
+	if err := do.AddPage(h, 2, "Two", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := do.AddPage(h, 2, "Again", 0); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for a duplicate page, got %v", err)
+	}
+
+	if err := do.SetImage(h, 1, 0, red); err != nil {
+		t.Fatal(err)
+	}
+	if err := do.SetLed(h, 1, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(device.Buffer(0), red) || device.Led(0) != 1 {
+		t.Error("Expected the active page to reach the device")
+	}
+
+	for name, tc := range map[string]struct {
+		err  error
+		want HRESULT
+	}{
+		"inactive page": {do.SetImage(h, 2, 0, blue), E_PAGENOTACTIVE},
+		"unknown page":  {do.SetLed(h, 9, 0, 1), E_INVALIDARG},
+		"bad handle":    {do.SetLed(unsafe.Pointer(&red[0]), 1, 0, 1), E_HANDLE},
+		"LED index":     {do.SetLed(h, 1, softButtonLEDs, 1), E_INVALIDARG},
+		"image index":   {do.SetImage(h, 1, 1, red), E_INVALIDARG},
+		"short buffer":  {do.SetImage(h, 1, 0, red[:100]), E_BUFFERTOOSMALL},
+		"FIP string":    {do.SetString(h, 1, 0, "text"), E_INVALIDARG},
+	} {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, tc.err)
+		}
+	}
+
+	// A new active page starts blank; removing it goes back to page 1
+	if err := do.AddPage(h, 3, "Three", FLAG_SET_AS_ACTIVE); err != nil {
+		t.Fatal(err)
+	}
+	expectPages(t, pages, pageChange{1, false}, pageChange{3, true})
+	if device.Led(0) != 0 || !bytes.Equal(device.Buffer(0), make([]byte, bmp.FIPBufferSize)) {
+		t.Error("Expected page 3 to start blank")
+	}
+	if err := do.RemovePage(h, 3); err != nil {
+		t.Fatal(err)
+	}
+	expectPages(t, pages, pageChange{3, false}, pageChange{1, true})
+	if !bytes.Equal(device.Buffer(0), red) || device.Led(0) != 1 {
+		t.Error("Expected page 1 to be shown again")
+	}
+}
+
+func TestNativeDirectOutputSoftButtons(t *testing.T) {
+	do, h, device, pages, buttons := newTestDirectOutput(t)
+	do.AddPage(h, 1, "One", 0)
+	do.AddPage(h, 2, "Two", 0)
+	expectPages(t, pages, pageChange{1, true})
+
+	device.Press(SoftButton1)
+	device.Release(SoftButton1)
+	for _, want := range []uint32{SoftButton1, 0} {
+		select {
+		case got := <-buttons:
+			if got != want {
+				t.Errorf("Expected buttons 0x%X, got 0x%X", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("No soft button callback")
+		}
+	}
+
+	// Page buttons change page without reaching the soft button callback
+	device.Click(SoftButtonPageDown)
+	expectPages(t, pages, pageChange{1, false}, pageChange{2, true})
+	device.Click(SoftButtonPageDown)
+	expectPages(t, pages, pageChange{2, false}, pageChange{1, true})
+	select {
+	case got := <-buttons:
+		t.Errorf("Unexpected soft button callback 0x%X", got)
+	default:
+	}
+}
+
+func TestNativeDirectOutputDevices(t *testing.T) {
+	device := NewVirtualFIP()
+	do := NewNativeDirectOutput(func() []DirectOutputTransport {
+		return []DirectOutputTransport{device}
+	})
+	if err := do.Initialize("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := do.Initialize("test"); !errors.Is(err, E_FAIL) {
+		t.Errorf("Expected a second Initialize to fail, got %v", err)
+	}
+
+	var handles []unsafe.Pointer
+	do.Enumerate(func(h unsafe.Pointer, _ unsafe.Pointer) { handles = append(handles, h) }, nil)
+	if len(handles) != 1 {
+		t.Fatalf("Expected 1 device, got %d", len(handles))
+	}
+	if guid, err := do.GetDeviceType(handles[0]); err != nil || guid != DeviceTypeFip {
+		t.Errorf("Expected a FIP, got %v, %v", guid, err)
+	}
+
+	changes := make(chan bool, 2)
+	do.RegisterDeviceCallback(func(_ unsafe.Pointer, added bool, _ unsafe.Pointer) { changes <- added }, nil)
+	x52 := NewVirtualDevice(DeviceTypeX52Pro)
+	h := do.Attach(x52)
+	if added := <-changes; !added {
+		t.Error("Expected a device added callback")
+	}
+	do.AddPage(h, 1, "MFD", 0)
+	if err := do.SetString(h, 1, 2, "HELLO"); err != nil || x52.Text(2) != "HELLO" {
+		t.Errorf("Expected the X52 Pro line to be set, got %q, %v", x52.Text(2), err)
+	}
+
+	// Unplugging is noticed when the button channel closes
+	x52.Unplug()
+	select {
+	case added := <-changes:
+		if added {
+			t.Error("Expected a device removed callback")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No device removed callback")
+	}
+	if _, err := do.GetDeviceType(h); !errors.Is(err, E_HANDLE) {
+		t.Errorf("Expected E_HANDLE for an unplugged device, got %v", err)
+	}
+
+	do.Deinitialize()
+	if err := device.SetLed(0, 1); !errors.Is(err, ErrDeviceClosed) {
+		t.Errorf("Expected Deinitialize to close the device, got %v", err)
+	}
+}
+
+// blockingTransport is a virtual device whose image writes wait for release
+type blockingTransport struct {
+	*VirtualDevice
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTransport) SetImage(index uint32, data []byte) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.VirtualDevice.SetImage(index, data)
+}
+
+func TestNativeDirectOutputSlowDevice(t *testing.T) {
+	do, h, device, pages, _ := newTestDirectOutput(t)
+	do.AddPage(h, 1, "One", 0)
+	expectPages(t, pages, pageChange{1, true})
+
+	slow := &blockingTransport{NewVirtualFIP(), make(chan struct{}, 1), make(chan struct{})}
+	defer close(slow.release)
+	hSlow := do.Attach(slow)
+	go do.AddPage(hSlow, 1, "Slow", 0)
+	<-slow.started
+
+	// The slow device's frame write must not hold up the other device
+	done := make(chan error, 1)
+	go func() {
+		do.AddPage(h, 2, "Two", FLAG_SET_AS_ACTIVE)
+		done <- do.SetLed(h, 2, 3, 1)
+	}()
+	select {
+	case err := <-done:
+		if err != nil || device.Led(3) != 1 {
+			t.Errorf("Expected the LED to be set, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drawing on one device waited for another device's write")
+	}
+	expectPages(t, pages, pageChange{1, false}, pageChange{2, true})
+}
+
+func TestNativeDirectOutputRemoveLastPage(t *testing.T) {
+	do, h, device, pages, _ := newTestDirectOutput(t)
+	do.AddPage(h, 1, "One", 0)
+	do.SetImage(h, 1, 0, testBuffer(color.RGBA{255, 0, 0, 255}))
+	do.SetLed(h, 1, 2, 1)
+
+	if err := do.RemovePage(h, 1); err != nil {
+		t.Fatal(err)
+	}
+	expectPages(t, pages, pageChange{1, true}, pageChange{1, false})
+	if device.Led(2) != 0 || !bytes.Equal(device.Buffer(0), make([]byte, bmp.FIPBufferSize)) {
+		t.Error("Expected the device to be blanked")
+	}
+	if err := do.RemovePage(h, 1); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for a removed page, got %v", err)
+	}
+}
+
+func TestNativeDirectOutputImageFile(t *testing.T) {
+	do, h, device, _, _ := newTestDirectOutput(t)
+	do.AddPage(h, 1, "One", 0)
+
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	img.Set(10, 20, color.RGBA{1, 2, 3, 255})
+	path := filepath.Join(t.TempDir(), "page.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, img)
+	file.Close()
+
+	if err := do.SetImageFromFile(h, 1, 0, path); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(device.Buffer(0), bmp.FIPBuffer(img)) {
+		t.Error("Expected the file's image on the device")
+	}
+	if err := do.SetImageFromFile(h, 1, 0, filepath.Join(t.TempDir(), "missing.png")); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package fip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"sync/atomic"
+
+	"saitek-controller/internal/bmp"
+)
+
+// ErrDeviceClosed is returned by a VirtualDevice after Close or Unplug
+var ErrDeviceClosed = errors.New("device closed")
+
+// virtualDevices numbers virtual devices for their instances
+var virtualDevices atomic.Int64
+
+// VirtualDevice is an in-memory DirectOutputTransport for tests and demos.
+// It keeps what the device shows and reports button presses made with
+// Press, Release and Click.
+type VirtualDevice struct {
+	mu         sync.Mutex
+	deviceType [16]byte
+	instance   DeviceInstance
+	images     map[uint32][]byte
+	leds       map[uint32]uint32
+	strings    map[uint32]string
+	frames     int
+	closed     bool
+
+	// sendMu orders button states and keeps Close from closing the
+	// channel during a send
+	sendMu  sync.Mutex
+	state   uint32
+	buttons chan uint32
+}
+
+// NewVirtualDevice creates a virtual device of the given type, such as
+// DeviceTypeFip, with an instance of its own
+func NewVirtualDevice(deviceType [16]byte) *VirtualDevice {
+	path := fmt.Sprintf("virtual:%d", virtualDevices.Add(1))
+	return &VirtualDevice{
+		deviceType: deviceType,
+		instance:   NewDeviceInstance(0, 0, "", path),
+		images:     make(map[uint32][]byte),
+		leds:       make(map[uint32]uint32),
+		strings:    make(map[uint32]string),
+		buttons:    make(chan uint32, 16),
+	}
+}
+
+// NewVirtualFIP creates a virtual FIP
+func NewVirtualFIP() *VirtualDevice {
+	return NewVirtualDevice(DeviceTypeFip)
+}
+
+// DeviceType returns the device type GUID
+func (v *VirtualDevice) DeviceType() [16]byte {
+	return v.deviceType
+}
+
+// Instance returns the device's instance
+func (v *VirtualDevice) Instance() DeviceInstance {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.instance
+}
+
+// SetInstance sets the instance, as if it were another physical device
+func (v *VirtualDevice) SetInstance(instance DeviceInstance) {
+	v.mu.Lock()
+	v.instance = instance
+	v.mu.Unlock()
+}
+
+// SetImage keeps a frame buffer
+func (v *VirtualDevice) SetImage(index uint32, data []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.closed {
+		return ErrDeviceClosed
+	}
+	v.images[index] = append([]byte(nil), data...)
+	v.frames++
+	return nil
+}
+
+// SetLed keeps an LED value
+func (v *VirtualDevice) SetLed(index uint32, value uint32) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.closed {
+		return ErrDeviceClosed
+	}
+	v.leds[index] = value
+	return nil
+}
+
+// SetString keeps a text line
+func (v *VirtualDevice) SetString(index uint32, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.closed {
+		return ErrDeviceClosed
+	}
+	v.strings[index] = value
+	return nil
+}
+
+// SoftButtons returns the button states made with Press and Release
+func (v *VirtualDevice) SoftButtons() <-chan uint32 {
+	return v.buttons
+}
+
+// Close closes the device
+func (v *VirtualDevice) Close() error {
+	v.mu.Lock()
+	closed := v.closed
+	v.closed = true
+	v.mu.Unlock()
+
+	if !closed {
+		v.sendMu.Lock()
+		close(v.buttons)
+		v.sendMu.Unlock()
+	}
+	return nil
+}
+
+// Unplug closes the device as if it were unplugged, which NativeDirectOutput
+// reports to its device callback
+func (v *VirtualDevice) Unplug() {
+	v.Close()
+}
+
+// Buffer returns the frame buffer last set at index, or nil
+func (v *VirtualDevice) Buffer(index uint32) []byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]byte(nil), v.images[index]...)
+}
+
+// Image returns the FIP image last set at index, or nil
+func (v *VirtualDevice) Image(index uint32) image.Image {
+	img, err := bmp.FIPImage(v.Buffer(index))
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// Frames returns how many images have been set
+func (v *VirtualDevice) Frames() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.frames
+}
+
+// Led returns an LED value
+func (v *VirtualDevice) Led(index uint32) uint32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.leds[index]
+}
+
+// Text returns a text line
+func (v *VirtualDevice) Text(index uint32) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.strings[index]
+}
+
+// Press presses buttons, a bitmask of SoftButton* values
+func (v *VirtualDevice) Press(buttons uint32) {
+	v.send(func(state uint32) uint32 { return state | buttons })
+}
+
+// Release releases buttons
+func (v *VirtualDevice) Release(buttons uint32) {
+	v.send(func(state uint32) uint32 { return state &^ buttons })
+}
+
+// Click presses and releases buttons, as a dial detent does
+func (v *VirtualDevice) Click(buttons uint32) {
+	v.Press(buttons)
+	v.Release(buttons)
+}
+
+// send reports the button state made by change. States sent after Close
+// are dropped.
+func (v *VirtualDevice) send(change func(uint32) uint32) {
+	v.sendMu.Lock()
+	defer v.sendMu.Unlock()
+	v.mu.Lock()
+	closed := v.closed
+	v.mu.Unlock()
+	if closed {
+		return
+	}
+	v.state = change(v.state)
+	v.buttons <- v.state
+}
@@ -0,0 +1,372 @@
+//go:build windows
+
+package fip
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// sdkPaths are where DirectOutput.dll is looked for
+var sdkPaths = []string{
+	"DirectOutput.dll",
+	"./DirectOutput.dll",
+	"./DirectOutput/DirectOutput.dll",
+	"../DirectOutput.dll",
+}
+
+// sdkProcs are the DLL functions used
+var sdkProcs = []string{
+	"DirectOutput_Initialize",
+	"DirectOutput_Deinitialize",
+	"DirectOutput_RegisterDeviceCallback",
+	"DirectOutput_Enumerate",
+	"DirectOutput_RegisterPageCallback",
+	"DirectOutput_RegisterSoftButtonCallback",
+	"DirectOutput_GetDeviceType",
+	"DirectOutput_AddPage",
+	"DirectOutput_RemovePage",
+	"DirectOutput_SetLed",
+	"DirectOutput_SetString",
+	"DirectOutput_SetImage",
+	"DirectOutput_SetImageFromFile",
+}
+
+// sdkOptionalProcs are DLL functions missing from older SDKs; calls to them
+// return E_NOTIMPL there
+var sdkOptionalProcs = []string{
+	"DirectOutput_GetDeviceInstance",
+	"DirectOutput_SetProfile",
+}
+
+// newDirectOutput uses the SDK when it is installed and the native
+// implementation otherwise
+func newDirectOutput() (DirectOutput, error) {
+	sdk, err := NewDirectOutputSDK()
+	if err == nil {
+		return sdk, nil
+	}
+	log.Printf("DirectOutput SDK unavailable, using native transports: %v", err)
+	return NewNativeDirectOutput(DiscoverTransports), nil
+}
+
+// sdkDeviceCallbacks are the callbacks registered for one device
+type sdkDeviceCallbacks struct {
+	page          PageChangeCallback
+	pageContext   unsafe.Pointer
+	button        SoftButtonChangeCallback
+	buttonContext unsafe.Pointer
+}
+
+// DirectOutputSDK implements DirectOutput with the Saitek DirectOutput DLL
+type DirectOutputSDK struct {
+	id    uintptr
+	procs map[string]*syscall.Proc
+
+	mu               sync.Mutex
+	enumerateMu      sync.Mutex
+	enumerate        EnumerateCallback
+	enumerateContext unsafe.Pointer
+	device           DeviceChangeCallback
+	deviceContext    unsafe.Pointer
+	devices          map[uintptr]*sdkDeviceCallbacks
+}
+
+// The DLL calls Go through one set of callbacks, made once as there is a
+// limit on how many can be made. The context passed to the DLL is the
+// instance ID, which finds the instance and its Go callbacks.
+var (
+	sdkCallbacksOnce      sync.Once
+	sdkEnumerateCallback  uintptr
+	sdkDeviceCallback     uintptr
+	sdkPageCallback       uintptr
+	sdkSoftButtonCallback uintptr
+
+	sdkInstancesMu sync.Mutex
+	sdkInstances   = make(map[uintptr]*DirectOutputSDK)
+	sdkNextID      uintptr
+)
+
+// NewDirectOutputSDK loads DirectOutput.dll
+func NewDirectOutputSDK() (*DirectOutputSDK, error) {
+	var (
+		dll *syscall.DLL
+		err error
+	)
+	for _, path := range sdkPaths {
+		if dll, err = syscall.LoadDLL(path); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DirectOutput.dll: %w", err)
+	}
+
+	sdk := &DirectOutputSDK{
+		procs:   make(map[string]*syscall.Proc),
+		devices: make(map[uintptr]*sdkDeviceCallbacks),
+	}
+	for _, name := range sdkProcs {
+		proc, err := dll.FindProc(name)
+		if err != nil {
+			dll.Release()
+			return nil, fmt.Errorf("failed to load DirectOutput.dll: %w", err)
+		}
+		sdk.procs[name] = proc
+	}
+	for _, name := range sdkOptionalProcs {
+		if proc, err := dll.FindProc(name); err == nil {
+			sdk.procs[name] = proc
+		}
+	}
+
+	sdkCallbacksOnce.Do(func() {
+		sdkEnumerateCallback = syscall.NewCallback(sdkOnEnumerate)
+		sdkDeviceCallback = syscall.NewCallback(sdkOnDeviceChange)
+		sdkPageCallback = syscall.NewCallback(sdkOnPageChange)
+		sdkSoftButtonCallback = syscall.NewCallback(sdkOnSoftButtonChange)
+	})
+	sdkInstancesMu.Lock()
+	sdkNextID++
+	sdk.id = sdkNextID
+	sdkInstances[sdk.id] = sdk
+	sdkInstancesMu.Unlock()
+
+	log.Printf("Loaded DirectOutput SDK")
+	return sdk, nil
+}
+
+// result converts a DLL return value
+func result(r uintptr) error {
+	return HRESULT(uint32(r)).Err()
+}
+
+// Initialize starts a session for the named plugin
+func (sdk *DirectOutputSDK) Initialize(pluginName string) error {
+	name, err := syscall.UTF16PtrFromString(pluginName)
+	if err != nil {
+		return E_INVALIDARG
+	}
+	r, _, _ := sdk.procs["DirectOutput_Initialize"].Call(uintptr(unsafe.Pointer(name)))
+	return result(r)
+}
+
+// Deinitialize ends the session and forgets the callbacks
+func (sdk *DirectOutputSDK) Deinitialize() error {
+	r, _, _ := sdk.procs["DirectOutput_Deinitialize"].Call()
+	sdk.mu.Lock()
+	sdk.device, sdk.deviceContext = nil, nil
+	sdk.devices = make(map[uintptr]*sdkDeviceCallbacks)
+	sdk.mu.Unlock()
+	return result(r)
+}
+
+// RegisterDeviceCallback sets the callback for devices added or removed
+func (sdk *DirectOutputSDK) RegisterDeviceCallback(callback DeviceChangeCallback, context unsafe.Pointer) error {
+	sdk.mu.Lock()
+	sdk.device, sdk.deviceContext = callback, context
+	sdk.mu.Unlock()
+	r, _, _ := sdk.procs["DirectOutput_RegisterDeviceCallback"].Call(sdkDeviceCallback, sdk.id)
+	return result(r)
+}
+
+// Enumerate calls callback for every device present
+func (sdk *DirectOutputSDK) Enumerate(callback EnumerateCallback, context unsafe.Pointer) error {
+	sdk.enumerateMu.Lock()
+	defer sdk.enumerateMu.Unlock()
+
+	sdk.mu.Lock()
+	sdk.enumerate, sdk.enumerateContext = callback, context
+	sdk.mu.Unlock()
+	r, _, _ := sdk.procs["DirectOutput_Enumerate"].Call(sdkEnumerateCallback, sdk.id)
+	sdk.mu.Lock()
+	sdk.enumerate, sdk.enumerateContext = nil, nil
+	sdk.mu.Unlock()
+	return result(r)
+}
+
+// RegisterPageCallback sets the callback for page changes on a device
+func (sdk *DirectOutputSDK) RegisterPageCallback(hDevice unsafe.Pointer, callback PageChangeCallback, context unsafe.Pointer) error {
+	sdk.mu.Lock()
+	callbacks := sdk.callbacks(uintptr(hDevice))
+	callbacks.page, callbacks.pageContext = callback, context
+	sdk.mu.Unlock()
+	r, _, _ := sdk.procs["DirectOutput_RegisterPageCallback"].Call(uintptr(hDevice), sdkPageCallback, sdk.id)
+	return result(r)
+}
+
+// RegisterSoftButtonCallback sets the callback for soft button changes on a
+// device
+func (sdk *DirectOutputSDK) RegisterSoftButtonCallback(hDevice unsafe.Pointer, callback SoftButtonChangeCallback, context unsafe.Pointer) error {
+	sdk.mu.Lock()
+	callbacks := sdk.callbacks(uintptr(hDevice))
+	callbacks.button, callbacks.buttonContext = callback, context
+	sdk.mu.Unlock()
+	r, _, _ := sdk.procs["DirectOutput_RegisterSoftButtonCallback"].Call(uintptr(hDevice), sdkSoftButtonCallback, sdk.id)
+	return result(r)
+}
+
+// callbacks returns the callbacks of a device. It must be called with the
+// lock held.
+func (sdk *DirectOutputSDK) callbacks(hDevice uintptr) *sdkDeviceCallbacks {
+	callbacks, ok := sdk.devices[hDevice]
+	if !ok {
+		callbacks = &sdkDeviceCallbacks{}
+		sdk.devices[hDevice] = callbacks
+	}
+	return callbacks
+}
+
+// GetDeviceType returns the device type GUID
+func (sdk *DirectOutputSDK) GetDeviceType(hDevice unsafe.Pointer) ([16]byte, error) {
+	var guid [16]byte
+	r, _, _ := sdk.procs["DirectOutput_GetDeviceType"].Call(uintptr(hDevice), uintptr(unsafe.Pointer(&guid)))
+	return guid, result(r)
+}
+
+// GetDeviceInstance returns the instance GUID of the physical device
+func (sdk *DirectOutputSDK) GetDeviceInstance(hDevice unsafe.Pointer) (DeviceInstance, error) {
+	var guid DeviceInstance
+	proc, ok := sdk.procs["DirectOutput_GetDeviceInstance"]
+	if !ok {
+		return guid, E_NOTIMPL
+	}
+	r, _, _ := proc.Call(uintptr(hDevice), uintptr(unsafe.Pointer(&guid)))
+	return guid, result(r)
+}
+
+// SetProfile sets the Saitek profile file of a device
+func (sdk *DirectOutputSDK) SetProfile(hDevice unsafe.Pointer, profile string) error {
+	proc, ok := sdk.procs["DirectOutput_SetProfile"]
+	if !ok {
+		return E_NOTIMPL
+	}
+	if profile == "" {
+		r, _, _ := proc.Call(uintptr(hDevice), 0, 0)
+		return result(r)
+	}
+	s, err := syscall.UTF16FromString(profile)
+	if err != nil {
+		return E_INVALIDARG
+	}
+	r, _, _ := proc.Call(uintptr(hDevice), uintptr(len(s)-1), uintptr(unsafe.Pointer(&s[0])))
+	return result(r)
+}
+
+// AddPage adds a page, making it active with FLAG_SET_AS_ACTIVE
+func (sdk *DirectOutputSDK) AddPage(hDevice unsafe.Pointer, page uint32, debugName string, flags uint32) error {
+	name, err := syscall.UTF16PtrFromString(debugName)
+	if err != nil {
+		return E_INVALIDARG
+	}
+	r, _, _ := sdk.procs["DirectOutput_AddPage"].Call(uintptr(hDevice), uintptr(page), uintptr(unsafe.Pointer(name)), uintptr(flags))
+	return result(r)
+}
+
+// RemovePage removes a page
+func (sdk *DirectOutputSDK) RemovePage(hDevice unsafe.Pointer, page uint32) error {
+	r, _, _ := sdk.procs["DirectOutput_RemovePage"].Call(uintptr(hDevice), uintptr(page))
+	return result(r)
+}
+
+// SetLed sets an LED of an active page
+func (sdk *DirectOutputSDK) SetLed(hDevice unsafe.Pointer, page uint32, index uint32, value uint32) error {
+	r, _, _ := sdk.procs["DirectOutput_SetLed"].Call(uintptr(hDevice), uintptr(page), uintptr(index), uintptr(value))
+	return result(r)
+}
+
+// SetString sets a text line of an active page
+func (sdk *DirectOutputSDK) SetString(hDevice unsafe.Pointer, page uint32, index uint32, value string) error {
+	s, err := syscall.UTF16FromString(value)
+	if err != nil {
+		return E_INVALIDARG
+	}
+	r, _, _ := sdk.procs["DirectOutput_SetString"].Call(uintptr(hDevice), uintptr(page), uintptr(index), uintptr(len(s)-1), uintptr(unsafe.Pointer(&s[0])))
+	return result(r)
+}
+
+// SetImage sets the image of an active page from a FIP frame buffer
+func (sdk *DirectOutputSDK) SetImage(hDevice unsafe.Pointer, page uint32, index uint32, data []byte) error {
+	if len(data) == 0 {
+		return E_BUFFERTOOSMALL
+	}
+	r, _, _ := sdk.procs["DirectOutput_SetImage"].Call(uintptr(hDevice), uintptr(page), uintptr(index), uintptr(len(data)), uintptr(unsafe.Pointer(&data[0])))
+	return result(r)
+}
+
+// SetImageFromFile sets the image of an active page from an image file
+func (sdk *DirectOutputSDK) SetImageFromFile(hDevice unsafe.Pointer, page uint32, index uint32, filename string) error {
+	s, err := syscall.UTF16FromString(filename)
+	if err != nil {
+		return E_INVALIDARG
+	}
+	r, _, _ := sdk.procs["DirectOutput_SetImageFromFile"].Call(uintptr(hDevice), uintptr(page), uintptr(index), uintptr(len(s)-1), uintptr(unsafe.Pointer(&s[0])))
+	return result(r)
+}
+
+// sdkInstance returns the instance for a callback context
+func sdkInstance(context uintptr) *DirectOutputSDK {
+	sdkInstancesMu.Lock()
+	defer sdkInstancesMu.Unlock()
+	return sdkInstances[context]
+}
+
+// sdkHandle turns a device handle from the DLL into the pointer the Go
+// callbacks take. Handles are opaque and never dereferenced.
+func sdkHandle(hDevice uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&hDevice))
+}
+
+func sdkOnEnumerate(hDevice, context uintptr) uintptr {
+	if sdk := sdkInstance(context); sdk != nil {
+		sdk.mu.Lock()
+		callback, ctxt := sdk.enumerate, sdk.enumerateContext
+		sdk.mu.Unlock()
+		if callback != nil {
+			callback(sdkHandle(hDevice), ctxt)
+		}
+	}
+	return 0
+}
+
+func sdkOnDeviceChange(hDevice, added, context uintptr) uintptr {
+	if sdk := sdkInstance(context); sdk != nil {
+		sdk.mu.Lock()
+		callback, ctxt := sdk.device, sdk.deviceContext
+		if added == 0 {
+			delete(sdk.devices, hDevice)
+		}
+		sdk.mu.Unlock()
+		if callback != nil {
+			callback(sdkHandle(hDevice), added != 0, ctxt)
+		}
+	}
+	return 0
+}
+
+func sdkOnPageChange(hDevice, page, active, context uintptr) uintptr {
+	if sdk := sdkInstance(context); sdk != nil {
+		sdk.mu.Lock()
+		callbacks := *sdk.callbacks(hDevice)
+		sdk.mu.Unlock()
+		if callbacks.page != nil {
+			callbacks.page(sdkHandle(hDevice), uint32(page), active != 0, callbacks.pageContext)
+		}
+	}
+	return 0
+}
+
+func sdkOnSoftButtonChange(hDevice, buttons, context uintptr) uintptr {
+	if sdk := sdkInstance(context); sdk != nil {
+		sdk.mu.Lock()
+		callbacks := *sdk.callbacks(hDevice)
+		sdk.mu.Unlock()
+		if callbacks.button != nil {
+			callbacks.button(sdkHandle(hDevice), uint32(buttons), callbacks.buttonContext)
+		}
+	}
+	return 0
+}
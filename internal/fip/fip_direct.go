@@ -4,18 +4,19 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"image/png"
 	"log"
 	"os"
-	"time"
 
 	"github.com/karalabe/hid"
+
+	"saitek-controller/internal/bmp"
 )
 
 // FIPDirect provides direct communication with Saitek FIP devices
 type FIPDirect struct {
 	device    *hid.Device
+	info      hid.DeviceInfo
 	connected bool
 }
 
@@ -51,6 +52,7 @@ func (f *FIPDirect) Connect() error {
 		}
 
 		f.device = hidDevice
+		f.info = device
 		f.connected = true
 		log.Printf("Successfully connected to FIP device: %s", device.Product)
 		return nil
@@ -90,6 +92,15 @@ func (f *FIPDirect) SendImage(img image.Image) error {
 	return f.sendImageData(fipData)
 }
 
+// SendFrameBuffer sends a pre-converted 230,400-byte frame buffer, as made
+// by bmp.FIPBuffer, to the FIP display
+func (f *FIPDirect) SendFrameBuffer(data []byte) error {
+	if !f.IsConnected() {
+		return fmt.Errorf("not connected to FIP device")
+	}
+	return f.sendImageData(data)
+}
+
 // SendImageFromFile sends an image from a file to the FIP display
 func (f *FIPDirect) SendImageFromFile(filename string) error {
 	// Read the image file
@@ -108,27 +119,10 @@ func (f *FIPDirect) SendImageFromFile(filename string) error {
 	return f.SendImage(img)
 }
 
-// convertImageToFIPFormat converts an image to FIP format (320x240, 24bpp RGB)
+// convertImageToFIPFormat converts an image to the FIP frame buffer: 320x240,
+// 24bpp BGR, bottom row first
 func (f *FIPDirect) convertImageToFIPFormat(img image.Image) ([]byte, error) {
-	// Create a 320x240 RGBA image
-	fipImg := image.NewRGBA(image.Rect(0, 0, 320, 240))
-
-	// Draw the source image onto the FIP image
-	draw.Draw(fipImg, fipImg.Bounds(), img, image.Point{}, draw.Src)
-
-	// Convert to 24bpp RGB format (FIP requirement)
-	data := make([]byte, 320*240*3)
-	for y := 0; y < 240; y++ {
-		for x := 0; x < 320; x++ {
-			idx := (y*320 + x) * 3
-			c := fipImg.RGBAAt(x, y)
-			data[idx] = c.R   // Red
-			data[idx+1] = c.G // Green
-			data[idx+2] = c.B // Blue
-		}
-	}
-
-	return data, nil
+	return bmp.FIPBuffer(img), nil
 }
 
 // sendImageData sends image data to the FIP device
@@ -209,13 +203,13 @@ func (f *FIPDirect) SetLED(index int, value bool) error {
 	return nil
 }
 
-// ReadButtonEvents reads button events from the FIP
-func (f *FIPDirect) ReadButtonEvents() (chan FIPButtonEvent, error) {
+// ReadButtonEvents reads input events from the FIP
+func (f *FIPDirect) ReadButtonEvents() (chan InputEvent, error) {
 	if !f.IsConnected() {
 		return nil, fmt.Errorf("not connected to FIP device")
 	}
 
-	eventChan := make(chan FIPButtonEvent, 10)
+	eventChan := make(chan InputEvent, 10)
 
 	go func() {
 		defer close(eventChan)
@@ -230,7 +224,7 @@ func (f *FIPDirect) ReadButtonEvents() (chan FIPButtonEvent, error) {
 			}
 
 			if read > 0 {
-				event := f.parseButtonEvent(buffer[:read])
+				event := parseButtonPacket(buffer[:read])
 				if event != nil {
 					eventChan <- *event
 				}
@@ -241,36 +235,6 @@ func (f *FIPDirect) ReadButtonEvents() (chan FIPButtonEvent, error) {
 	return eventChan, nil
 }
 
-// FIPButtonEvent represents a button press event from the FIP
-type FIPButtonEvent struct {
-	Button    int
-	Pressed   bool
-	Timestamp time.Time
-}
-
-// parseButtonEvent parses a button event from the FIP
-func (f *FIPDirect) parseButtonEvent(data []byte) *FIPButtonEvent {
-	if len(data) < 8 {
-		return nil
-	}
-
-	// Check if this is a button event packet
-	if data[0] == 0x06 && data[1] == 0xA3 && data[2] == 0xA2 && data[3] == 0xAE {
-		if data[4] == 0x03 { // Button event command
-			button := int(data[5])
-			pressed := data[6] != 0
-
-			return &FIPButtonEvent{
-				Button:    button,
-				Pressed:   pressed,
-				Timestamp: time.Now(),
-			}
-		}
-	}
-
-	return nil
-}
-
 // CreateTestImage creates a test image for the FIP
 func (f *FIPDirect) CreateTestImage() image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
@@ -302,26 +266,13 @@ func (f *FIPDirect) CreateTestImage() image.Image {
 	}
 
 	// Test text areas
-	f.drawText(img, "FIP TEST", 160, 60, color.RGBA{255, 255, 255, 255})
-	f.drawText(img, "320x240", 160, 80, color.RGBA{255, 255, 0, 255})
-	f.drawText(img, "READY", 160, 180, color.RGBA{0, 255, 0, 255})
+	drawText(img, "FIP TEST", 160, 60, color.RGBA{255, 255, 255, 255})
+	drawText(img, "320x240", 160, 80, color.RGBA{255, 255, 0, 255})
+	drawText(img, "READY", 160, 180, color.RGBA{0, 255, 0, 255})
 
 	return img
 }
 
-// drawText draws simple text on the image
-func (f *FIPDirect) drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i, _ := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
-	}
-}
-
 // SaveImageAsPNG saves an image as PNG for debugging
 func (f *FIPDirect) SaveImageAsPNG(img image.Image, filename string) error {
 	file, err := os.Create(filename)
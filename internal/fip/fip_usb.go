@@ -4,12 +4,11 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"image/png"
 	"log"
 	"os"
-	"time"
 
+	"saitek-controller/internal/bmp"
 	"saitek-controller/internal/usb"
 )
 
@@ -69,6 +68,15 @@ func (f *FIPUSB) SendImage(img image.Image) error {
 	return f.sendImageData(fipData)
 }
 
+// SendFrameBuffer sends a pre-converted 230,400-byte frame buffer, as made
+// by bmp.FIPBuffer, to the FIP display
+func (f *FIPUSB) SendFrameBuffer(data []byte) error {
+	if !f.IsConnected() {
+		return fmt.Errorf("not connected to FIP device")
+	}
+	return f.sendImageData(data)
+}
+
 // SendImageFromFile sends an image from a file to the FIP display
 func (f *FIPUSB) SendImageFromFile(filename string) error {
 	// Read the image file
@@ -87,27 +95,10 @@ func (f *FIPUSB) SendImageFromFile(filename string) error {
 	return f.SendImage(img)
 }
 
-// convertImageToFIPFormat converts an image to FIP format (320x240, 24bpp RGB)
+// convertImageToFIPFormat converts an image to the FIP frame buffer: 320x240,
+// 24bpp BGR, bottom row first
 func (f *FIPUSB) convertImageToFIPFormat(img image.Image) ([]byte, error) {
-	// Create a 320x240 RGBA image
-	fipImg := image.NewRGBA(image.Rect(0, 0, 320, 240))
-
-	// Draw the source image onto the FIP image
-	draw.Draw(fipImg, fipImg.Bounds(), img, image.Point{}, draw.Src)
-
-	// Convert to 24bpp RGB format (FIP requirement)
-	data := make([]byte, 320*240*3)
-	for y := 0; y < 240; y++ {
-		for x := 0; x < 320; x++ {
-			idx := (y*320 + x) * 3
-			c := fipImg.RGBAAt(x, y)
-			data[idx] = c.R   // Red
-			data[idx+1] = c.G // Green
-			data[idx+2] = c.B // Blue
-		}
-	}
-
-	return data, nil
+	return bmp.FIPBuffer(img), nil
 }
 
 // sendImageData sends image data to the FIP device via USB
@@ -184,13 +175,13 @@ func (f *FIPUSB) SetLED(index int, value bool) error {
 	return nil
 }
 
-// ReadButtonEvents reads button events from the FIP
-func (f *FIPUSB) ReadButtonEvents() (chan FIPUSBButtonEvent, error) {
+// ReadButtonEvents reads input events from the FIP
+func (f *FIPUSB) ReadButtonEvents() (chan InputEvent, error) {
 	if !f.IsConnected() {
 		return nil, fmt.Errorf("not connected to FIP device")
 	}
 
-	eventChan := make(chan FIPUSBButtonEvent, 10)
+	eventChan := make(chan InputEvent, 10)
 
 	go func() {
 		defer close(eventChan)
@@ -204,7 +195,7 @@ func (f *FIPUSB) ReadButtonEvents() (chan FIPUSBButtonEvent, error) {
 			}
 
 			if len(data) > 0 {
-				event := f.parseButtonEvent(data)
+				event := parseButtonPacket(data)
 				if event != nil {
 					eventChan <- *event
 				}
@@ -215,36 +206,6 @@ func (f *FIPUSB) ReadButtonEvents() (chan FIPUSBButtonEvent, error) {
 	return eventChan, nil
 }
 
-// FIPUSBButtonEvent represents a button press event from the FIP via USB
-type FIPUSBButtonEvent struct {
-	Button    int
-	Pressed   bool
-	Timestamp time.Time
-}
-
-// parseButtonEvent parses a button event from the FIP
-func (f *FIPUSB) parseButtonEvent(data []byte) *FIPUSBButtonEvent {
-	if len(data) < 8 {
-		return nil
-	}
-
-	// Check if this is a button event packet
-	if data[0] == 0x06 && data[1] == 0xA3 && data[2] == 0xA2 && data[3] == 0xAE {
-		if data[4] == 0x03 { // Button event command
-			button := int(data[5])
-			pressed := data[6] != 0
-
-			return &FIPUSBButtonEvent{
-				Button:    button,
-				Pressed:   pressed,
-				Timestamp: time.Now(),
-			}
-		}
-	}
-
-	return nil
-}
-
 // CreateTestImage creates a test image for the FIP
 func (f *FIPUSB) CreateTestImage() image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
@@ -276,26 +237,13 @@ func (f *FIPUSB) CreateTestImage() image.Image {
 	}
 
 	// Test text areas
-	f.drawText(img, "FIP USB", 160, 60, color.RGBA{255, 255, 255, 255})
-	f.drawText(img, "320x240", 160, 80, color.RGBA{255, 255, 0, 255})
-	f.drawText(img, "READY", 160, 180, color.RGBA{0, 255, 0, 255})
+	drawText(img, "FIP USB", 160, 60, color.RGBA{255, 255, 255, 255})
+	drawText(img, "320x240", 160, 80, color.RGBA{255, 255, 0, 255})
+	drawText(img, "READY", 160, 180, color.RGBA{0, 255, 0, 255})
 
 	return img
 }
 
-// drawText draws simple text on the image
-func (f *FIPUSB) drawText(img *image.RGBA, text string, x, y int, c color.Color) {
-	for i, _ := range text {
-		charX := x + i*8 - len(text)*4
-		if charX >= 0 && charX < 320 {
-			img.Set(charX, y, c)
-			img.Set(charX+1, y, c)
-			img.Set(charX, y+1, c)
-			img.Set(charX+1, y+1, c)
-		}
-	}
-}
-
 // SaveImageAsPNG saves an image as PNG for debugging
 func (f *FIPUSB) SaveImageAsPNG(img image.Image, filename string) error {
 	file, err := os.Create(filename)
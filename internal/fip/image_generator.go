@@ -11,6 +11,8 @@ import (
 	"path/filepath"
 
 	"golang.org/x/image/colornames"
+
+	"saitek-controller/internal/text"
 )
 
 // ImageGenerator creates test images for FIP panels
@@ -121,6 +123,15 @@ func (g *ImageGenerator) CreateInstrumentBackground() image.Image {
 	return img
 }
 
+// drawText draws a line of text in the bitmap font centred on (x, y)
+func drawText(img *image.RGBA, s string, x, y int, c color.Color) {
+	text.Draw(img, s, float64(x), float64(y), text.Style{
+		Color:  c,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
+}
+
 // SaveImage saves an image to a file
 func (g *ImageGenerator) SaveImage(img image.Image, filename string) error {
 	// Create directory if it doesn't exist
@@ -164,176 +175,8 @@ func (g *ImageGenerator) GenerateTestImages(outputDir string) error {
 
 // CreateInstrumentImage creates an instrument image with data
 func (g *ImageGenerator) CreateInstrumentImage(instrument Instrument, data InstrumentData) image.Image {
-	switch instrument {
-	case InstrumentArtificialHorizon:
-		return g.createArtificialHorizonImage(data.Pitch, data.Roll)
-	case InstrumentAirspeed:
-		return g.createAirspeedImage(data.Airspeed)
-	case InstrumentAltimeter:
-		return g.createAltimeterImage(data.Altitude, data.Pressure)
-	case InstrumentCompass:
-		return g.createCompassImage(data.Heading)
-	case InstrumentVerticalSpeed:
-		return g.createVSIImage(data.VerticalSpeed)
-	case InstrumentTurnCoordinator:
-		return g.createTurnCoordinatorImage(data.TurnRate, data.Slip)
-	default:
-		return g.CreateTestPattern()
-	}
-}
-
-// createArtificialHorizonImage creates an artificial horizon image
-func (g *ImageGenerator) createArtificialHorizonImage(pitch, roll float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, g.width, g.height))
-	
-	// Clear to blue (sky)
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Skyblue}, image.Point{}, draw.Src)
-	
-	// Calculate center
-	centerX := g.width / 2
-	centerY := g.height / 2
-	
-	// Draw brown earth (bottom half)
-	earthRect := image.Rect(0, centerY, g.width, g.height)
-	draw.Draw(img, earthRect, &image.Uniform{colornames.Saddlebrown}, image.Point{}, draw.Src)
-	
-	// Apply roll rotation
-	rollRad := roll * math.Pi / 180
-	cosRoll := math.Cos(rollRad)
-	sinRoll := math.Sin(rollRad)
-	
-	// Draw horizon line with roll
-	for x := 0; x < g.width; x++ {
-		// Calculate rotated position
-		relX := float64(x - centerX)
-		relY := float64(centerY) + pitch*2 // Simple pitch representation
-		
-		rotX := relX*cosRoll - relY*sinRoll + float64(centerX)
-		rotY := relX*sinRoll + relY*cosRoll + float64(centerY)
-		
-		if rotY >= 0 && rotY < float64(g.height) {
-			img.Set(int(rotX), int(rotY), colornames.White)
-		}
-	}
-	
-	return img
-}
-
-// createAirspeedImage creates an airspeed indicator image
-func (g *ImageGenerator) createAirspeedImage(airspeed float64) image.Image {
-	img := g.CreateInstrumentBackground()
-	rgba := img.(*image.RGBA)
-	
-	// Draw airspeed needle
-	centerX := g.width / 2
-	centerY := g.height / 2
-	radius := g.width / 3
-	
-	// Calculate needle angle (0-360 degrees)
-	angle := (airspeed / 200.0) * 360.0 // Scale to 0-200 knots
-	angleRad := angle * math.Pi / 180
-	
-	// Draw needle
-	needleLength := float64(radius) * 0.8
-	
-	// Draw needle line
-	for i := 0; i < int(needleLength); i++ {
-		x := centerX + int(float64(i)*math.Sin(angleRad))
-		y := centerY - int(float64(i)*math.Cos(angleRad))
-		if x >= 0 && x < g.width && y >= 0 && y < g.height {
-			rgba.Set(x, y, colornames.White)
-		}
-	}
-	
-	return img
-}
-
-// createAltimeterImage creates an altimeter image
-func (g *ImageGenerator) createAltimeterImage(altitude, pressure float64) image.Image {
-	img := g.CreateInstrumentBackground()
-	rgba := img.(*image.RGBA)
-	
-	// Draw altimeter face
-	centerX := g.width / 2
-	centerY := g.height / 2
-	radius := g.width / 3
-	
-	// Draw altitude needle
-	altitudeAngle := (altitude / 10000.0) * 360.0 // Scale to 0-10,000 feet
-	altitudeRad := altitudeAngle * math.Pi / 180
-	
-	needleLength := float64(radius) * 0.8
-	
-	// Draw needle
-	for i := 0; i < int(needleLength); i++ {
-		x := centerX + int(float64(i)*math.Sin(altitudeRad))
-		y := centerY - int(float64(i)*math.Cos(altitudeRad))
-		if x >= 0 && x < g.width && y >= 0 && y < g.height {
-			rgba.Set(x, y, colornames.White)
-		}
-	}
-	
-	return img
-}
-
-// createCompassImage creates a compass image
-func (g *ImageGenerator) createCompassImage(heading float64) image.Image {
-	img := g.CreateInstrumentBackground()
-	rgba := img.(*image.RGBA)
-	
-	// Draw compass face
-	centerX := g.width / 2
-	centerY := g.height / 2
-	radius := g.width / 3
-	
-	// Draw heading indicator
-	headingRad := heading * math.Pi / 180
-	indicatorLength := float64(radius) * 0.9
-	
-	// Draw heading line
-	for i := 0; i < int(indicatorLength); i++ {
-		x := centerX + int(float64(i)*math.Sin(headingRad))
-		y := centerY - int(float64(i)*math.Cos(headingRad))
-		if x >= 0 && x < g.width && y >= 0 && y < g.height {
-			rgba.Set(x, y, colornames.White)
-		}
-	}
-	
-	return img
-}
-
-// createVSIImage creates a vertical speed indicator image
-func (g *ImageGenerator) createVSIImage(vs float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, g.width, g.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	// Draw vertical speed gauge
-	centerX := g.width / 2
-	centerY := g.height / 2
-	
-	// Simple vertical speed representation
-	vsHeight := int(vs / 1000 * float64(g.height/2)) // Scale to reasonable range
-	vsY := centerY + vsHeight
-	
-	if vsY >= 0 && vsY < g.height {
-		for x := centerX - 20; x <= centerX + 20; x++ {
-			if x >= 0 && x < g.width {
-				img.Set(x, vsY, colornames.White)
-			}
-		}
+	if img := RenderInstrument(instrument, g.width, g.height, data); img != nil {
+		return img
 	}
-	
-	return img
+	return g.CreateTestPattern()
 }
-
-// createTurnCoordinatorImage creates a turn coordinator image
-func (g *ImageGenerator) createTurnCoordinatorImage(turnRate, slip float64) image.Image {
-	img := g.CreateInstrumentBackground()
-	
-	// This is a simplified turn coordinator
-	// In a real implementation, this would be more complex
-	
-	return img
-} 
\ No newline at end of file
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
-	"image/gif"
+	_ "image/gif"
 	"image/jpeg"
 	"image/png"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"saitek-controller/internal/bmp"
+	"saitek-controller/internal/imaging"
 )
 
 // ImageLoader provides functionality to load and process images for FIP display
@@ -22,7 +25,11 @@ type ImageLoader struct {
 	
 	// Resize options
 	ResizeMode ResizeMode
+	Resampler  imaging.Resampler
 	Quality    int // 1-100 for JPEG quality
+
+	// Filters applied after resizing, such as gamma or night mode
+	Filters imaging.Chain
 }
 
 // ResizeMode defines how images should be resized
@@ -35,6 +42,31 @@ const (
 	ResizeModeCenter                    // Center and pad with background
 )
 
+var resizeModeNames = map[ResizeMode]string{
+	ResizeModeStretch: "stretch",
+	ResizeModeFit:     "fit",
+	ResizeModeCrop:    "crop",
+	ResizeModeCenter:  "center",
+}
+
+// String returns the resize mode name as accepted by ParseResizeMode
+func (m ResizeMode) String() string {
+	if name, ok := resizeModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("ResizeMode(%d)", int(m))
+}
+
+// ParseResizeMode returns the resize mode with the given name
+func ParseResizeMode(name string) (ResizeMode, error) {
+	for mode, n := range resizeModeNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown resize mode: %s", name)
+}
+
 // NewImageLoader creates a new image loader with FIP specifications
 func NewImageLoader() *ImageLoader {
 	return &ImageLoader{
@@ -42,6 +74,7 @@ func NewImageLoader() *ImageLoader {
 		FIPHeight:  240,
 		FIPFormat:  "RGB",
 		ResizeMode: ResizeModeFit,
+		Resampler:  imaging.ResampleBilinear,
 		Quality:    90,
 	}
 }
@@ -82,22 +115,32 @@ func (loader *ImageLoader) ProcessImageForFIP(img image.Image) (image.Image, err
 	// Check if resizing is needed
 	if originalWidth == loader.FIPWidth && originalHeight == loader.FIPHeight {
 		log.Printf("Image is already the correct size")
-		return img, nil
 	}
+	return loader.processImage(img)
+}
 
-	// Resize the image according to the selected mode
-	resizedImg, err := loader.resizeImage(img)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resize image: %v", err)
+// processImage resizes and filters an image without logging, for callers
+// processing many images such as animation frames
+func (loader *ImageLoader) processImage(img image.Image) (image.Image, error) {
+	if img.Bounds().Dx() != loader.FIPWidth || img.Bounds().Dy() != loader.FIPHeight {
+		// Resize the image according to the selected mode
+		resizedImg, err := loader.resizeImage(img)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resize image: %v", err)
+		}
+		img = resizedImg
+	}
+
+	// Apply filters to a copy, leaving the caller's image untouched
+	if len(loader.Filters) > 0 {
+		img = imaging.Apply(img, loader.Filters...)
 	}
 
-	return resizedImg, nil
+	return img, nil
 }
 
 // resizeImage resizes an image according to the selected resize mode
 func (loader *ImageLoader) resizeImage(img image.Image) (image.Image, error) {
-	originalWidth := img.Bounds().Dx()
-	originalHeight := img.Bounds().Dy()
 	targetWidth := loader.FIPWidth
 	targetHeight := loader.FIPHeight
 
@@ -122,7 +165,7 @@ func (loader *ImageLoader) resizeImage(img image.Image) (image.Image, error) {
 // stretchImage stretches the image to fit the target dimensions (may distort)
 func (loader *ImageLoader) stretchImage(img image.Image, targetWidth, targetHeight int) image.Image {
 	resized := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
-	draw.BiLinear.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Over, nil)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 	return resized
 }
 
@@ -145,7 +188,7 @@ func (loader *ImageLoader) fitImage(img image.Image, targetWidth, targetHeight i
 
 	// Resize the image
 	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-	draw.BiLinear.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Over, nil)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 
 	// Create final image with padding
 	final := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
@@ -179,7 +222,7 @@ func (loader *ImageLoader) cropImage(img image.Image, targetWidth, targetHeight
 
 	// Resize the image
 	resized := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-	draw.BiLinear.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Over, nil)
+	imaging.Scale(resized, resized.Bounds(), img, img.Bounds(), loader.Resampler)
 
 	// Crop to target dimensions
 	final := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
@@ -264,6 +307,21 @@ func (loader *ImageLoader) SetResizeMode(mode ResizeMode) {
 	loader.ResizeMode = mode
 }
 
+// SetResampler sets the interpolation used when resizing
+func (loader *ImageLoader) SetResampler(resampler imaging.Resampler) {
+	loader.Resampler = resampler
+}
+
+// SetFilters sets the filters applied after resizing
+func (loader *ImageLoader) SetFilters(filters ...imaging.Filter) {
+	loader.Filters = filters
+}
+
+// AddFilter appends a filter to the filter chain
+func (loader *ImageLoader) AddFilter(filter imaging.Filter) {
+	loader.Filters = append(loader.Filters, filter)
+}
+
 // SetQuality sets the JPEG quality for saving images
 func (loader *ImageLoader) SetQuality(quality int) {
 	if quality < 1 {
@@ -297,9 +355,35 @@ func (loader *ImageLoader) SaveImageAsJPEG(img image.Image, filename string) err
 	return jpeg.Encode(file, img, &jpeg.Options{Quality: loader.Quality})
 }
 
+// SaveImageAsBMP saves an image as a 24bpp bottom-up BMP, the format
+// DirectOutput's SetImageFromFile expects
+func (loader *ImageLoader) SaveImageAsBMP(img image.Image, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return bmp.Encode(file, img, nil)
+}
+
+// SaveImage saves an image in the format given by the file extension
+func (loader *ImageLoader) SaveImage(img image.Image, filename string) error {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".png":
+		return loader.SaveImageAsPNG(img, filename)
+	case ".jpg", ".jpeg":
+		return loader.SaveImageAsJPEG(img, filename)
+	case ".bmp":
+		return loader.SaveImageAsBMP(img, filename)
+	default:
+		return fmt.Errorf("unsupported output format: %s", ext)
+	}
+}
+
 // GetSupportedFormats returns a list of supported image formats
 func (loader *ImageLoader) GetSupportedFormats() []string {
-	return []string{".png", ".jpg", ".jpeg", ".gif"}
+	return []string{".png", ".jpg", ".jpeg", ".gif", ".bmp"}
 }
 
 // IsSupportedFormat checks if a file format is supported
@@ -327,25 +411,8 @@ func (loader *ImageLoader) LoadAndConvertToFIP(filename string) ([]byte, error)
 	return loader.ConvertImageToFIPFormat(img)
 }
 
-// ConvertImageToFIPFormat converts an image to FIP format (320x240, 24bpp RGB)
+// ConvertImageToFIPFormat converts an image to the 230,400-byte FIP frame
+// buffer: 320x240, 24bpp BGR, bottom row first
 func (loader *ImageLoader) ConvertImageToFIPFormat(img image.Image) ([]byte, error) {
-	// Create a 320x240 RGBA image
-	fipImg := image.NewRGBA(image.Rect(0, 0, loader.FIPWidth, loader.FIPHeight))
-
-	// Draw the source image onto the FIP image
-	draw.Draw(fipImg, fipImg.Bounds(), img, image.Point{}, draw.Src)
-
-	// Convert to 24bpp RGB format (FIP requirement)
-	data := make([]byte, loader.FIPWidth*loader.FIPHeight*3)
-	for y := 0; y < loader.FIPHeight; y++ {
-		for x := 0; x < loader.FIPWidth; x++ {
-			idx := (y*loader.FIPWidth + x) * 3
-			c := fipImg.RGBAAt(x, y)
-			data[idx] = c.R   // Red
-			data[idx+1] = c.G // Green
-			data[idx+2] = c.B // Blue
-		}
-	}
-
-	return data, nil
-}
\ No newline at end of file
+	return bmp.FIPBuffer(img), nil
+}
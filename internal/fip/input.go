@@ -0,0 +1,195 @@
+package fip
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InputControl identifies a FIP control: a soft button, a page button or one
+// turn direction of a rotary dial
+type InputControl int
+
+const (
+	InputButton1 InputControl = iota
+	InputButton2
+	InputButton3
+	InputButton4
+	InputButton5
+	InputButton6
+	InputPageUp
+	InputPageDown
+	InputRightDialCW
+	InputRightDialCCW
+	InputLeftDialCW
+	InputLeftDialCCW
+
+	// inputControls is the number of controls
+	inputControls
+)
+
+var inputControlNames = [inputControls]string{
+	"S1", "S2", "S3", "S4", "S5", "S6",
+	"PageUp", "PageDown",
+	"RightDialCW", "RightDialCCW", "LeftDialCW", "LeftDialCCW",
+}
+
+// softButtonBits maps each control to its DirectOutput soft button bit.
+// DirectOutput reports the right dial as Up/Down and the left dial as
+// Right/Left; page buttons use the SoftButtonPage* bits.
+var softButtonBits = [inputControls]uint32{
+	SoftButton1, SoftButton2, SoftButton3, SoftButton4, SoftButton5, SoftButton6,
+	SoftButtonPageUp, SoftButtonPageDown,
+	SoftButtonUp, SoftButtonDown, SoftButtonRight, SoftButtonLeft,
+}
+
+// String returns the control name
+func (c InputControl) String() string {
+	if c < 0 || c >= inputControls {
+		return fmt.Sprintf("InputControl(%d)", int(c))
+	}
+	return inputControlNames[c]
+}
+
+// ParseInputControl parses a control name as returned by String, ignoring
+// case
+func ParseInputControl(name string) (InputControl, error) {
+	for c := InputControl(0); c < inputControls; c++ {
+		if strings.EqualFold(name, inputControlNames[c]) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown FIP control: %q", name)
+}
+
+// IsButton reports whether the control is a soft button (S1-S6)
+func (c InputControl) IsButton() bool {
+	return c >= InputButton1 && c <= InputButton6
+}
+
+// IsPage reports whether the control is a page button
+func (c InputControl) IsPage() bool {
+	return c == InputPageUp || c == InputPageDown
+}
+
+// IsDial reports whether the control is a rotary dial direction
+func (c InputControl) IsDial() bool {
+	return c >= InputRightDialCW && c < inputControls
+}
+
+// Button returns the soft button number (1-6), or 0 for other controls
+func (c InputControl) Button() int {
+	if !c.IsButton() {
+		return 0
+	}
+	return int(c-InputButton1) + 1
+}
+
+// SoftButton returns the control's DirectOutput soft button bit
+func (c InputControl) SoftButton() uint32 {
+	if c < 0 || c >= inputControls {
+		return 0
+	}
+	return softButtonBits[c]
+}
+
+// InputEvent is a FIP input event. Buttons send a press and a release; each
+// dial detent sends a single event with Pressed set.
+type InputEvent struct {
+	Control   InputControl
+	Pressed   bool
+	Timestamp time.Time
+}
+
+// Delta returns +1 for a clockwise dial detent, -1 for counter-clockwise
+// and 0 for buttons
+func (e InputEvent) Delta() int {
+	switch e.Control {
+	case InputRightDialCW, InputLeftDialCW:
+		return 1
+	case InputRightDialCCW, InputLeftDialCCW:
+		return -1
+	}
+	return 0
+}
+
+// String returns a short description of the event
+func (e InputEvent) String() string {
+	switch {
+	case e.Control.IsDial():
+		return e.Control.String()
+	case e.Pressed:
+		return e.Control.String() + " pressed"
+	default:
+		return e.Control.String() + " released"
+	}
+}
+
+// HIDReportToSoftButtons converts a raw FIP HID input report into a
+// DirectOutput soft button bitmask. The report holds one bit per control, in
+// InputControl order, starting at bit 0 of the first byte.
+func HIDReportToSoftButtons(report []byte) uint32 {
+	var buttons uint32
+	for c := InputControl(0); c < inputControls; c++ {
+		i := int(c) / 8
+		if i < len(report) && report[i]&(1<<(uint(c)%8)) != 0 {
+			buttons |= softButtonBits[c]
+		}
+	}
+	return buttons
+}
+
+// InputDecoder turns successive soft button states into input events. It
+// is not safe for concurrent use.
+type InputDecoder struct {
+	buttons uint32
+}
+
+// DecodeSoftButtons returns the events for a new DirectOutput soft button
+// state, in InputControl order
+func (d *InputDecoder) DecodeSoftButtons(buttons uint32) []InputEvent {
+	var events []InputEvent
+	now := time.Now()
+	changed := buttons ^ d.buttons
+	for c := InputControl(0); c < inputControls; c++ {
+		bit := softButtonBits[c]
+		if changed&bit == 0 {
+			continue
+		}
+		pressed := buttons&bit != 0
+		if c.IsDial() && !pressed {
+			continue
+		}
+		events = append(events, InputEvent{Control: c, Pressed: pressed, Timestamp: now})
+	}
+	d.buttons = buttons
+	return events
+}
+
+// DecodeHIDReport returns the events for a raw FIP HID input report
+func (d *InputDecoder) DecodeHIDReport(report []byte) []InputEvent {
+	return d.DecodeSoftButtons(HIDReportToSoftButtons(report))
+}
+
+// State returns the last soft button state
+func (d *InputDecoder) State() uint32 {
+	return d.buttons
+}
+
+// parseButtonPacket parses a FIP button packet: the vendor and product ID,
+// command 0x03, the control index and its state. Dial releases are dropped.
+func parseButtonPacket(data []byte) *InputEvent {
+	if len(data) < 8 {
+		return nil
+	}
+	if data[0] != 0x06 || data[1] != 0xA3 || data[2] != 0xA2 || data[3] != 0xAE || data[4] != 0x03 {
+		return nil
+	}
+
+	control := InputControl(data[5])
+	pressed := data[6] != 0
+	if control >= inputControls || (control.IsDial() && !pressed) {
+		return nil
+	}
+	return &InputEvent{Control: control, Pressed: pressed, Timestamp: time.Now()}
+}
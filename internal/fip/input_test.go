@@ -0,0 +1,102 @@
+package fip
+
+import (
+	"testing"
+)
+
+func TestDecodeSoftButtons(t *testing.T) {
+	var d InputDecoder
+
+	events := d.DecodeSoftButtons(SoftButton1 | SoftButtonUp)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", events)
+	}
+	if events[0].Control != InputButton1 || !events[0].Pressed {
+		t.Errorf("Expected S1 press, got %v", events[0])
+	}
+	if events[1].Control != InputRightDialCW || events[1].Delta() != 1 {
+		t.Errorf("Expected right dial clockwise detent, got %v", events[1])
+	}
+
+	// Dial bits clearing is not a detent, button release is an event
+	events = d.DecodeSoftButtons(0)
+	if len(events) != 1 || events[0].Control != InputButton1 || events[0].Pressed {
+		t.Errorf("Expected only S1 release, got %v", events)
+	}
+
+	events = d.DecodeSoftButtons(SoftButtonLeft | SoftButtonPageDown)
+	if len(events) != 2 || events[0].Control != InputPageDown || events[1].Control != InputLeftDialCCW {
+		t.Errorf("Expected page down and left dial detent, got %v", events)
+	}
+	if events[1].Delta() != -1 {
+		t.Errorf("Expected counter-clockwise delta, got %d", events[1].Delta())
+	}
+}
+
+func TestDecodeHIDReport(t *testing.T) {
+	if got := HIDReportToSoftButtons([]byte{0x41, 0x04}); got != SoftButton1|SoftButtonPageUp|SoftButtonRight {
+		t.Errorf("Unexpected soft buttons 0x%x", got)
+	}
+
+	var d InputDecoder
+	events := d.DecodeHIDReport([]byte{0x20, 0x00})
+	if len(events) != 1 || events[0].Control != InputButton6 || events[0].Control.Button() != 6 {
+		t.Errorf("Expected S6 press, got %v", events)
+	}
+	if events := d.DecodeHIDReport([]byte{0x20}); len(events) != 0 {
+		t.Errorf("Expected no events for unchanged report, got %v", events)
+	}
+}
+
+func TestParseButtonPacket(t *testing.T) {
+	packet := []byte{0x06, 0xA3, 0xA2, 0xAE, 0x03, byte(InputButton3), 1, 0}
+	event := parseButtonPacket(packet)
+	if event == nil || event.Control != InputButton3 || !event.Pressed {
+		t.Fatalf("Expected S3 press, got %v", event)
+	}
+
+	packet[5], packet[6] = byte(InputLeftDialCW), 0
+	if event := parseButtonPacket(packet); event != nil {
+		t.Errorf("Expected dial release to be dropped, got %v", event)
+	}
+	packet[5] = 42
+	if event := parseButtonPacket(packet); event != nil {
+		t.Errorf("Expected unknown control to be dropped, got %v", event)
+	}
+}
+
+func TestPageManagerHandleInput(t *testing.T) {
+	m, _, _ := newTestPageManager(t)
+	m.AddPage(1, "", nil, 0)
+	m.AddPage(2, "", nil, 0)
+
+	var buttons []uint32
+	m.SetPageCallbacks(1, PageCallbacks{
+		OnSoftButtonChanged: func(b uint32) { buttons = append(buttons, b) },
+	})
+
+	m.HandleInput(InputEvent{Control: InputRightDialCCW, Pressed: true})
+	if len(buttons) != 2 || buttons[0] != SoftButtonDown || buttons[1] != 0 {
+		t.Errorf("Expected dial detent as press and release, got %v", buttons)
+	}
+
+	m.HandleInput(InputEvent{Control: InputPageDown, Pressed: true})
+	m.HandleInput(InputEvent{Control: InputPageDown, Pressed: false})
+	if m.ActivePage().ID != 2 {
+		t.Errorf("Expected page 2 after page down, got %d", m.ActivePage().ID)
+	}
+}
+
+func TestParseInputControl(t *testing.T) {
+	for c := InputButton1; c < inputControls; c++ {
+		if got, err := ParseInputControl(c.String()); err != nil || got != c {
+			t.Errorf("ParseInputControl(%q) = %v, %v", c.String(), got, err)
+		}
+	}
+	if got, err := ParseInputControl("rightdialccw"); err != nil || got != InputRightDialCCW {
+		t.Errorf("Expected case-insensitive match, got %v, %v", got, err)
+	}
+	if _, err := ParseInputControl("S7"); err == nil {
+		t.Error("Expected error for unknown control")
+	}
+}
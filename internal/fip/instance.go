@@ -0,0 +1,97 @@
+package fip
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DeviceInstance identifies one physical device, like the instance GUID of
+// DirectOutput_GetDeviceInstance. It is kept in the SDK's GUID memory
+// layout and written as {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX}.
+type DeviceInstance [16]byte
+
+// instanceNamespace is the name space of instances derived from USB
+// identities
+var instanceNamespace = []byte("saitek-controller/device-instance")
+
+// NewDeviceInstance derives the instance of a device without an instance
+// GUID from its USB serial number or, failing that, its device path. The
+// same inputs always give the same name-based (version 5) GUID.
+func NewDeviceInstance(vendorID, productID uint16, serial, path string) DeviceInstance {
+	name := fmt.Sprintf("%04x:%04x:", vendorID, productID)
+	switch {
+	case serial != "":
+		name += "serial:" + serial
+	case path != "":
+		name += "path:" + path
+	}
+
+	h := sha1.New()
+	h.Write(instanceNamespace)
+	h.Write([]byte(name))
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = u[6]&0x0F | 0x50
+	u[8] = u[8]&0x3F | 0x80
+	return instanceFromUUID(u)
+}
+
+// instanceFromUUID converts a UUID in network byte order to the GUID layout
+func instanceFromUUID(u [16]byte) DeviceInstance {
+	g := DeviceInstance(u)
+	g[0], g[1], g[2], g[3] = u[3], u[2], u[1], u[0]
+	g[4], g[5] = u[5], u[4]
+	g[6], g[7] = u[7], u[6]
+	return g
+}
+
+// uuid returns the instance in network byte order
+func (g DeviceInstance) uuid() [16]byte {
+	u := [16]byte(g)
+	u[0], u[1], u[2], u[3] = g[3], g[2], g[1], g[0]
+	u[4], u[5] = g[5], g[4]
+	u[6], u[7] = g[7], g[6]
+	return u
+}
+
+// IsZero reports whether the instance is unknown
+func (g DeviceInstance) IsZero() bool {
+	return g == DeviceInstance{}
+}
+
+// String returns the GUID in registry format
+func (g DeviceInstance) String() string {
+	u := g.uuid()
+	s := strings.ToUpper(hex.EncodeToString(u[:]))
+	return fmt.Sprintf("{%s-%s-%s-%s-%s}", s[:8], s[8:12], s[12:16], s[16:20], s[20:])
+}
+
+// ParseDeviceInstance parses a GUID with or without braces
+func ParseDeviceInstance(s string) (DeviceInstance, error) {
+	digits := strings.ReplaceAll(strings.Trim(strings.TrimSpace(s), "{}"), "-", "")
+	var u [16]byte
+	if len(digits) != 32 {
+		return DeviceInstance{}, fmt.Errorf("invalid device instance: %q", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return DeviceInstance{}, fmt.Errorf("invalid device instance: %q", s)
+	}
+	return instanceFromUUID(u), nil
+}
+
+// MarshalText writes the instance as a GUID
+func (g DeviceInstance) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// UnmarshalText parses a GUID
+func (g *DeviceInstance) UnmarshalText(text []byte) error {
+	instance, err := ParseDeviceInstance(string(text))
+	if err != nil {
+		return err
+	}
+	*g = instance
+	return nil
+}
@@ -0,0 +1,164 @@
+package fip
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// inHgToHPa converts inches of mercury to hectopascals
+const inHgToHPa = 33.8639
+
+// PressureUnit selects how the altimeter's Kollsman window shows the setting
+type PressureUnit int
+
+const (
+	PressureInHg PressureUnit = iota
+	PressureHPa
+)
+
+// ParsePressureUnit parses "inhg" or "hpa"
+func ParsePressureUnit(s string) (PressureUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "inhg", "in":
+		return PressureInHg, nil
+	case "hpa", "mb", "mbar":
+		return PressureHPa, nil
+	default:
+		return PressureInHg, fmt.Errorf("unknown pressure unit: %s", s)
+	}
+}
+
+// VSpeeds are the limits marked on the airspeed indicator, in knots
+type VSpeeds struct {
+	VS0 float64 // stall speed with flaps down, bottom of the white arc
+	VS1 float64 // stall speed clean, bottom of the green arc
+	VFE float64 // maximum flap extended speed, top of the white arc
+	VNO float64 // maximum structural cruising speed, top of the green arc
+	VNE float64 // never exceed speed, red line
+}
+
+// DefaultVSpeeds returns the V-speeds of a typical light single
+func DefaultVSpeeds() VSpeeds {
+	return VSpeeds{VS0: 40, VS1: 48, VFE: 85, VNO: 129, VNE: 163}
+}
+
+// Validate checks that the V-speeds are in a sensible order
+func (v VSpeeds) Validate() error {
+	switch {
+	case v.VS0 <= 0:
+		return fmt.Errorf("VS0 must be positive")
+	case v.VS1 < v.VS0:
+		return fmt.Errorf("VS1 (%.0f) must not be below VS0 (%.0f)", v.VS1, v.VS0)
+	case v.VFE <= v.VS0:
+		return fmt.Errorf("VFE (%.0f) must be above VS0 (%.0f)", v.VFE, v.VS0)
+	case v.VNO <= v.VS1:
+		return fmt.Errorf("VNO (%.0f) must be above VS1 (%.0f)", v.VNO, v.VS1)
+	case v.VNE <= v.VNO || v.VNE < v.VFE:
+		return fmt.Errorf("VNE (%.0f) must be above VNO and VFE", v.VNE)
+	}
+	return nil
+}
+
+// ParseVSpeeds parses a comma separated "VS0,VS1,VFE,VNO,VNE" list
+func ParseVSpeeds(s string) (VSpeeds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 5 {
+		return VSpeeds{}, fmt.Errorf("expected VS0,VS1,VFE,VNO,VNE, got %q", s)
+	}
+
+	var values [5]float64
+	for i, p := range parts {
+		if _, err := fmt.Sscanf(strings.TrimSpace(p), "%g", &values[i]); err != nil {
+			return VSpeeds{}, fmt.Errorf("invalid speed %q: %v", p, err)
+		}
+	}
+
+	v := VSpeeds{VS0: values[0], VS1: values[1], VFE: values[2], VNO: values[3], VNE: values[4]}
+	if err := v.Validate(); err != nil {
+		return VSpeeds{}, err
+	}
+	return v, nil
+}
+
+// InstrumentConfig holds aircraft specific instrument settings that don't
+// change from frame to frame
+type InstrumentConfig struct {
+	VSpeeds      VSpeeds
+	PressureUnit PressureUnit
+}
+
+// DefaultInstrumentConfig returns the configuration used when none is set
+func DefaultInstrumentConfig() InstrumentConfig {
+	return InstrumentConfig{VSpeeds: DefaultVSpeeds(), PressureUnit: PressureInHg}
+}
+
+// withDefaults fills in unset fields
+func (c InstrumentConfig) withDefaults() InstrumentConfig {
+	if c.VSpeeds == (VSpeeds{}) {
+		c.VSpeeds = DefaultVSpeeds()
+	}
+	return c
+}
+
+// instrumentNames maps the canonical name of each instrument
+var instrumentNames = map[Instrument]string{
+	InstrumentArtificialHorizon: "artificial_horizon",
+	InstrumentAirspeed:          "airspeed",
+	InstrumentAltimeter:         "altimeter",
+	InstrumentCompass:           "compass",
+	InstrumentVerticalSpeed:     "vsi",
+	InstrumentTurnCoordinator:   "turn_coordinator",
+	InstrumentCustom:            "custom",
+}
+
+// instrumentAliases maps alternative names onto instruments
+var instrumentAliases = map[string]Instrument{
+	"attitude":           InstrumentArtificialHorizon,
+	"attitude_indicator": InstrumentArtificialHorizon,
+	"horizon":            InstrumentArtificialHorizon,
+	"ai":                 InstrumentArtificialHorizon,
+	"asi":                InstrumentAirspeed,
+	"airspeed_indicator": InstrumentAirspeed,
+	"alt":                InstrumentAltimeter,
+	"heading":            InstrumentCompass,
+	"heading_indicator":  InstrumentCompass,
+	"hi":                 InstrumentCompass,
+	"dg":                 InstrumentCompass,
+	"vertical_speed":     InstrumentVerticalSpeed,
+	"vs":                 InstrumentVerticalSpeed,
+	"turn":               InstrumentTurnCoordinator,
+	"tc":                 InstrumentTurnCoordinator,
+}
+
+// String returns the canonical name of the instrument
+func (i Instrument) String() string {
+	if name, ok := instrumentNames[i]; ok {
+		return name
+	}
+	return fmt.Sprintf("Instrument(%d)", int(i))
+}
+
+// ParseInstrument looks up an instrument by its name or a common alias
+func ParseInstrument(name string) (Instrument, error) {
+	key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	for i, n := range instrumentNames {
+		if n == key {
+			return i, nil
+		}
+	}
+	if i, ok := instrumentAliases[key]; ok {
+		return i, nil
+	}
+	return InstrumentCustom, fmt.Errorf("unknown instrument: %s", name)
+}
+
+// InstrumentNames returns the canonical instrument names in sorted order
+func InstrumentNames() []string {
+	names := make([]string, 0, len(instrumentNames))
+	for _, n := range instrumentNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
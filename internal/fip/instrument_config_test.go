@@ -0,0 +1,111 @@
+package fip
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseInstrument(t *testing.T) {
+	tests := map[string]Instrument{
+		"artificial_horizon": InstrumentArtificialHorizon,
+		"AI":                 InstrumentArtificialHorizon,
+		"asi":                InstrumentAirspeed,
+		"alt":                InstrumentAltimeter,
+		"heading":            InstrumentCompass,
+		"vsi":                InstrumentVerticalSpeed,
+		"turn-coordinator":   InstrumentTurnCoordinator,
+	}
+	for name, want := range tests {
+		got, err := ParseInstrument(name)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+
+	if _, err := ParseInstrument("fuel"); err == nil {
+		t.Error("Expected error for unknown instrument")
+	}
+
+	for _, name := range InstrumentNames() {
+		i, err := ParseInstrument(name)
+		if err != nil || i.String() != name {
+			t.Errorf("Expected %s to round trip, got %v (%v)", name, i, err)
+		}
+	}
+}
+
+func TestParseVSpeeds(t *testing.T) {
+	v, err := ParseVSpeeds("61, 70, 115, 160, 215")
+	if err != nil {
+		t.Fatalf("Failed to parse V-speeds: %v", err)
+	}
+	if v.VS0 != 61 || v.VNE != 215 {
+		t.Errorf("Unexpected V-speeds: %+v", v)
+	}
+
+	for _, s := range []string{"", "40,48,85,129", "40,48,85,200,163", "0,48,85,129,163", "a,b,c,d,e"} {
+		if _, err := ParseVSpeeds(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+
+	if err := DefaultVSpeeds().Validate(); err != nil {
+		t.Errorf("Default V-speeds are invalid: %v", err)
+	}
+}
+
+func TestInstrumentConfig(t *testing.T) {
+	if u, err := ParsePressureUnit("hPa"); err != nil || u != PressureHPa {
+		t.Errorf("Expected hPa, got %v (%v)", u, err)
+	}
+	if _, err := ParsePressureUnit("psi"); err == nil {
+		t.Error("Expected error for unknown pressure unit")
+	}
+
+	panel, err := NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+
+	if err := panel.SetInstrumentConfig(InstrumentConfig{}); err == nil {
+		t.Error("Expected error for missing V-speeds")
+	}
+
+	config := DefaultInstrumentConfig()
+	config.PressureUnit = PressureHPa
+	if err := panel.SetInstrumentConfig(config); err != nil {
+		t.Fatalf("Failed to set config: %v", err)
+	}
+
+	sink := NewMemorySink(0)
+	panel.AddSink(sink)
+	panel.SetInstrument(InstrumentAltimeter)
+	if err := panel.DisplayInstrument(InstrumentData{Altitude: 1500, Pressure: 29.92}); err != nil {
+		t.Fatalf("Failed to display altimeter: %v", err)
+	}
+
+	inHg := RenderInstrument(InstrumentAltimeter, 320, 240, InstrumentData{Altitude: 1500, Pressure: 29.92})
+	if sameImage(sink.Last(), inHg) {
+		t.Error("Expected hPa Kollsman window to differ from inHg")
+	}
+}
+
+// sameImage reports whether two images have identical pixels
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			r1, g1, b1, a1 := a.At(x, y).RGBA()
+			r2, g2, b2, a2 := b.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,474 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/colornames"
+
+	"saitek-controller/internal/render"
+	"saitek-controller/internal/text"
+)
+
+// Instrument colors
+var (
+	instrumentFace    = color.RGBA{20, 20, 20, 255}
+	instrumentBezel   = color.RGBA{70, 70, 70, 255}
+	instrumentMark    = colornames.White
+	instrumentNeedle  = colornames.White
+	instrumentSymbol  = colornames.Orange
+	instrumentBug     = color.RGBA{230, 60, 230, 255}
+	instrumentSky     = color.RGBA{40, 120, 200, 255}
+	instrumentGround  = color.RGBA{130, 80, 35, 255}
+	instrumentFailure = color.RGBA{220, 30, 30, 255}
+)
+
+// InstrumentRenderer renders one of the built-in instruments. A zero
+// Config uses the default V-speeds and shows pressure in inHg.
+type InstrumentRenderer struct {
+	Instrument Instrument
+	Data       InstrumentData
+	Config     InstrumentConfig
+}
+
+// Render draws the instrument onto the surface
+func (r InstrumentRenderer) Render(s *Surface) error {
+	c := s.Canvas()
+	c.Clear(colornames.Black)
+	cfg := r.Config.withDefaults()
+
+	switch r.Instrument {
+	case InstrumentArtificialHorizon:
+		drawArtificialHorizon(c, r.Data.Pitch, r.Data.Roll)
+	case InstrumentAirspeed:
+		drawAirspeedIndicator(c, r.Data.Airspeed, cfg.VSpeeds)
+	case InstrumentAltimeter:
+		drawAltimeter(c, r.Data.Altitude, r.Data.Pressure, cfg.PressureUnit)
+	case InstrumentCompass:
+		drawHeadingIndicator(c, r.Data.Heading, r.Data.HeadingBug)
+	case InstrumentVerticalSpeed:
+		drawVerticalSpeedIndicator(c, r.Data.VerticalSpeed)
+	case InstrumentTurnCoordinator:
+		drawTurnCoordinator(c, r.Data.TurnRate, r.Data.Slip)
+	default:
+		return fmt.Errorf("no renderer for instrument %d", r.Instrument)
+	}
+	if r.Data.Failed&r.Instrument.Fields() != 0 {
+		drawFailureFlag(c, failureLabels[r.Instrument])
+	}
+	return nil
+}
+
+// failureLabels are shown on the failure flag of each instrument
+var failureLabels = map[Instrument]string{
+	InstrumentArtificialHorizon: "ATT",
+	InstrumentAirspeed:          "IAS",
+	InstrumentAltimeter:         "ALT",
+	InstrumentCompass:           "HDG",
+	InstrumentVerticalSpeed:     "VS",
+	InstrumentTurnCoordinator:   "TURN",
+}
+
+// drawFailureFlag crosses out an instrument whose data has stopped and
+// shows a red flag with its label
+func drawFailureFlag(c *render.Canvas, label string) {
+	cx, cy, r := dialGeometry(c)
+	d := r * 0.7
+	c.Line(cx-d, cy-d, cx+d, cy+d, instrumentFailure, 4)
+	c.Line(cx-d, cy+d, cx+d, cy-d, instrumentFailure, 4)
+
+	w := math.Max(48, text.Measure(label, text.Style{Face: text.Bold(14)}).Width+16)
+	c.FillRect(cx-w/2, cy-r/2-12, w, 24, instrumentFailure)
+	c.StrokeCircle(cx, cy, r-1, instrumentFailure, 2)
+	drawLabel(c, cx, cy-r/2, label, colornames.White)
+}
+
+// RenderInstrument draws an instrument at the given size, or returns nil
+// if the instrument has no vector rendering
+func RenderInstrument(instrument Instrument, width, height int, data InstrumentData) image.Image {
+	s := NewSurface(width, height)
+	if err := (InstrumentRenderer{Instrument: instrument, Data: data}).Render(s); err != nil {
+		return nil
+	}
+	return s.Image()
+}
+
+// dialGeometry returns the centre and radius of a round instrument on the canvas
+func dialGeometry(c *render.Canvas) (cx, cy, r float64) {
+	w, h := float64(c.Width()), float64(c.Height())
+	return w / 2, h / 2, math.Min(w, h)/2 - 4
+}
+
+// dialAngle converts a dial position in degrees, measured clockwise from
+// 12 o'clock, into a canvas angle in radians
+func dialAngle(degrees float64) float64 {
+	return render.Deg(degrees - 90)
+}
+
+// polar returns the point at radius r and dial position degrees from (cx, cy)
+func polar(cx, cy, r, degrees float64) (float64, float64) {
+	a := dialAngle(degrees)
+	return cx + r*math.Cos(a), cy + r*math.Sin(a)
+}
+
+// clamp limits v to the range [min, max]
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+// drawDialFace draws the background and bezel of a round instrument
+func drawDialFace(c *render.Canvas, cx, cy, r float64) {
+	c.FillCircle(cx, cy, r, instrumentFace)
+	c.Fill(render.NewPath().Ring(cx, cy, r-3, r+2), instrumentBezel)
+}
+
+// drawTicks draws evenly spaced tick marks between two dial positions
+func drawTicks(c *render.Canvas, cx, cy, r, from, to, step, length, width float64, col color.Color) {
+	p := render.NewPath()
+	for d := from; d <= to+1e-9; d += step {
+		x1, y1 := polar(cx, cy, r, d)
+		x2, y2 := polar(cx, cy, r-length, d)
+		p.MoveTo(x1, y1).LineTo(x2, y2)
+	}
+	c.Stroke(p, col, render.Stroke(width))
+}
+
+// drawArcBand draws a colored band along the dial between two positions
+func drawArcBand(c *render.Canvas, cx, cy, r, from, to, width float64, col color.Color) {
+	p := render.NewPath().Arc(cx, cy, r, dialAngle(from), dialAngle(to))
+	c.Stroke(p, col, render.Stroke(width))
+}
+
+// drawNeedle draws a tapered needle pointing at a dial position
+func drawNeedle(c *render.Canvas, cx, cy, length, tail, width, degrees float64, col color.Color) {
+	c.Save()
+	c.Translate(cx, cy)
+	c.Rotate(render.Deg(degrees))
+	c.Fill(render.NewPath().Polygon(
+		render.Point{X: -width / 2, Y: tail},
+		render.Point{X: -width / 4, Y: -length + width},
+		render.Point{X: 0, Y: -length},
+		render.Point{X: width / 4, Y: -length + width},
+		render.Point{X: width / 2, Y: tail},
+	), col)
+	c.Restore()
+}
+
+// drawHub draws the cap covering the needle pivot
+func drawHub(c *render.Canvas, cx, cy, r float64) {
+	c.FillCircle(cx, cy, r, instrumentBezel)
+	c.StrokeCircle(cx, cy, r, colornames.Black, 1)
+}
+
+// drawLabel draws a dial label centred on (x, y)
+func drawLabel(c *render.Canvas, x, y float64, s string, col color.Color) {
+	text.Draw(c.Image(), s, x, y, text.Style{
+		Face:   text.Bold(14),
+		Color:  col,
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	})
+}
+
+// drawOutlinedLabel draws a dial label with a dark outline so it stays
+// legible over the attitude indicator's sky and ground
+func drawOutlinedLabel(c *render.Canvas, x, y float64, s string, col color.Color) {
+	text.Draw(c.Image(), s, x, y, text.Style{
+		Face:         text.Bold(14),
+		Color:        col,
+		Align:        text.AlignCenter,
+		VAlign:       text.VAlignMiddle,
+		Outline:      1,
+		OutlineColor: colornames.Black,
+	})
+}
+
+// drawArtificialHorizon draws an attitude indicator with pitch ladder and roll scale
+func drawArtificialHorizon(c *render.Canvas, pitch, roll float64) {
+	cx, cy, r := dialGeometry(c)
+	pitch = clamp(pitch, -90, 90)
+	pixelsPerDegree := r / 30
+
+	// Horizon card, moved by pitch and rotated against roll
+	c.Save()
+	c.Clip(render.NewPath().Circle(cx, cy, r))
+	c.Translate(cx, cy)
+	c.Rotate(render.Deg(-roll))
+	c.Translate(0, pitch*pixelsPerDegree)
+
+	extent := 4 * r
+	c.FillRect(-extent, -extent, 2*extent, extent, instrumentSky)
+	c.FillRect(-extent, 0, 2*extent, extent, instrumentGround)
+	c.Line(-extent, 0, extent, 0, instrumentMark, 2)
+
+	ladder := render.NewPath()
+	for p := -30; p <= 30; p += 5 {
+		if p == 0 {
+			continue
+		}
+		y := -float64(p) * pixelsPerDegree
+		half := 12.0
+		if p%10 == 0 {
+			half = 28
+		}
+		ladder.MoveTo(-half, y).LineTo(half, y)
+	}
+	c.Stroke(ladder, instrumentMark, render.Stroke(1.5))
+
+	m := c.Transform()
+	c.Restore()
+
+	// Ladder numbers are placed along the rotated card but drawn upright
+	for p := -20; p <= 20; p += 10 {
+		if p == 0 {
+			continue
+		}
+		y := -float64(p) * pixelsPerDegree
+		for _, side := range []float64{-1, 1} {
+			x, ly := m.Apply(side*42, y)
+			if math.Hypot(x-cx, ly-cy) < r-16 {
+				drawOutlinedLabel(c, x, ly, fmt.Sprintf("%d", absInt(p)), instrumentMark)
+			}
+		}
+	}
+
+	// Fixed roll scale on the bezel
+	for _, d := range []float64{-60, -45, -30, -20, -10, 0, 10, 20, 30, 45, 60} {
+		length := 8.0
+		if d == 0 || math.Abs(d) == 30 || math.Abs(d) == 60 {
+			length = 14
+		}
+		drawTicks(c, cx, cy, r-2, d, d, 1, length, 2, instrumentMark)
+	}
+
+	// Roll pointer turns with the horizon
+	c.Save()
+	c.RotateAbout(render.Deg(-roll), cx, cy)
+	c.Fill(render.NewPath().Polygon(
+		render.Point{X: cx, Y: cy - r + 16},
+		render.Point{X: cx - 7, Y: cy - r + 28},
+		render.Point{X: cx + 7, Y: cy - r + 28},
+	), instrumentSymbol)
+	c.Restore()
+
+	// Fixed aircraft symbol
+	wings := render.NewPath().
+		MoveTo(cx-70, cy).LineTo(cx-25, cy).LineTo(cx-15, cy+10).
+		MoveTo(cx+70, cy).LineTo(cx+25, cy).LineTo(cx+15, cy+10)
+	c.Stroke(wings, instrumentSymbol, render.StrokeStyle{Width: 4, Cap: render.CapRound})
+	c.FillCircle(cx, cy, 4, instrumentSymbol)
+
+	c.Fill(render.NewPath().Ring(cx, cy, r-1, r+2), instrumentBezel)
+}
+
+// airspeedScale returns the top of the airspeed scale, leaving room above VNE
+func airspeedScale(vne float64) float64 {
+	top := 200.0
+	for top < vne*1.1 {
+		top += 40
+	}
+	return top
+}
+
+// airspeedDial maps knots onto the airspeed dial
+func airspeedDial(knots, top float64) float64 {
+	return clamp(knots, 0, top) / top * 330
+}
+
+// drawAirspeedIndicator draws an airspeed indicator with arcs marking the V-speeds
+func drawAirspeedIndicator(c *render.Canvas, airspeed float64, v VSpeeds) {
+	cx, cy, r := dialGeometry(c)
+	drawDialFace(c, cx, cy, r)
+
+	top := airspeedScale(v.VNE)
+	dial := func(knots float64) float64 { return airspeedDial(knots, top) }
+
+	// Operating ranges
+	drawArcBand(c, cx, cy, r-14, dial(v.VS0), dial(v.VFE), 5, instrumentMark)
+	drawArcBand(c, cx, cy, r-7, dial(v.VS1), dial(v.VNO), 7, colornames.Limegreen)
+	drawArcBand(c, cx, cy, r-7, dial(v.VNO), dial(v.VNE), 7, colornames.Yellow)
+	drawTicks(c, cx, cy, r-3, dial(v.VNE), dial(v.VNE), 1, 16, 3, colornames.Red)
+
+	minor, major, label := 5.0, 10.0, 20.0
+	if top > 200 {
+		minor, major, label = 10, 20, 40
+	}
+	drawTicks(c, cx, cy, r-3, 0, 330, dial(minor), 8, 1.5, instrumentMark)
+	drawTicks(c, cx, cy, r-3, 0, 330, dial(major), 14, 2, instrumentMark)
+	for kt := label; kt <= top; kt += label {
+		x, y := polar(cx, cy, r-32, dial(kt))
+		drawLabel(c, x, y, fmt.Sprintf("%.0f", kt), instrumentMark)
+	}
+	drawLabel(c, cx, cy-30, "KNOTS", instrumentMark)
+
+	drawNeedle(c, cx, cy, r-14, 16, 8, dial(airspeed), instrumentNeedle)
+	drawHub(c, cx, cy, 8)
+}
+
+// drawAltimeter draws a three-pointer altimeter with a Kollsman window
+func drawAltimeter(c *render.Canvas, altitude, pressure float64, unit PressureUnit) {
+	cx, cy, r := dialGeometry(c)
+	drawDialFace(c, cx, cy, r)
+
+	if pressure == 0 {
+		pressure = 29.92
+	}
+
+	drawTicks(c, cx, cy, r-3, 0, 360, 7.2, 8, 1.5, instrumentMark)
+	drawTicks(c, cx, cy, r-3, 0, 360, 36, 16, 3, instrumentMark)
+	for i := 0; i < 10; i++ {
+		x, y := polar(cx, cy, r-30, float64(i)*36)
+		drawLabel(c, x, y, fmt.Sprintf("%d", i), instrumentMark)
+	}
+
+	// Kollsman window
+	c.FillRect(cx+30, cy-9, 52, 18, colornames.Black)
+	c.Stroke(render.NewPath().Rect(cx+30, cy-9, 52, 18), instrumentBezel, render.Stroke(1.5))
+	setting := fmt.Sprintf("%.2f", pressure)
+	if unit == PressureHPa {
+		setting = fmt.Sprintf("%.0f", pressure*inHgToHPa)
+	}
+	drawLabel(c, cx+56, cy, setting, instrumentMark)
+	drawLabel(c, cx, cy-40, "ALT", instrumentMark)
+
+	hundreds := math.Mod(altitude, 1000) / 1000 * 360
+	thousands := math.Mod(altitude, 10000) / 10000 * 360
+	tenThousands := math.Mod(altitude, 100000) / 100000 * 360
+
+	// Ten-thousand pointer: a thin line ending in a triangle at the bezel
+	c.Save()
+	c.RotateAbout(render.Deg(tenThousands), cx, cy)
+	c.Line(cx, cy, cx, cy-r+20, instrumentNeedle, 1.5)
+	c.Fill(render.NewPath().Polygon(
+		render.Point{X: cx, Y: cy - r + 20},
+		render.Point{X: cx - 6, Y: cy - r + 8},
+		render.Point{X: cx + 6, Y: cy - r + 8},
+	), instrumentNeedle)
+	c.Restore()
+
+	drawNeedle(c, cx, cy, r*0.5, 10, 12, thousands, instrumentNeedle)
+	drawNeedle(c, cx, cy, r-14, 16, 7, hundreds, instrumentNeedle)
+	drawHub(c, cx, cy, 7)
+}
+
+// drawHeadingIndicator draws a heading indicator with a rotating compass card
+// and a heading bug
+func drawHeadingIndicator(c *render.Canvas, heading, bug float64) {
+	cx, cy, r := dialGeometry(c)
+	drawDialFace(c, cx, cy, r)
+
+	c.Save()
+	c.RotateAbout(render.Deg(-heading), cx, cy)
+	drawTicks(c, cx, cy, r-6, 0, 355, 5, 8, 1.5, instrumentMark)
+	drawTicks(c, cx, cy, r-6, 0, 350, 10, 14, 2, instrumentMark)
+	m := c.Transform()
+
+	// Heading bug rides on the card at the selected heading
+	c.RotateAbout(render.Deg(bug), cx, cy)
+	c.Fill(render.NewPath().Polygon(
+		render.Point{X: cx - 9, Y: cy - r + 4},
+		render.Point{X: cx - 3, Y: cy - r + 4},
+		render.Point{X: cx, Y: cy - r + 10},
+		render.Point{X: cx + 3, Y: cy - r + 4},
+		render.Point{X: cx + 9, Y: cy - r + 4},
+		render.Point{X: cx + 9, Y: cy - r + 14},
+		render.Point{X: cx - 9, Y: cy - r + 14},
+	), instrumentBug)
+	c.Restore()
+
+	names := map[int]string{0: "N", 90: "E", 180: "S", 270: "W"}
+	for d := 0; d < 360; d += 30 {
+		label, ok := names[d]
+		if !ok {
+			label = fmt.Sprintf("%d", d/10)
+		}
+		x, y := m.Apply(polar(cx, cy, r-32, float64(d)))
+		drawLabel(c, x, y, label, instrumentMark)
+	}
+
+	// Fixed lubber line and 45 degree index marks
+	c.Fill(render.NewPath().Polygon(
+		render.Point{X: cx, Y: cy - r + 22},
+		render.Point{X: cx - 7, Y: cy - r + 4},
+		render.Point{X: cx + 7, Y: cy - r + 4},
+	), instrumentSymbol)
+	for _, d := range []float64{45, 90, 135, 180, 225, 270, 315} {
+		drawTicks(c, cx, cy, r+1, d, d, 1, 8, 3, instrumentSymbol)
+	}
+
+	// Fixed aircraft silhouette
+	plane := render.NewPath().
+		MoveTo(cx, cy-30).LineTo(cx, cy+26).
+		MoveTo(cx-30, cy-2).LineTo(cx+30, cy-2).
+		MoveTo(cx-12, cy+22).LineTo(cx+12, cy+22)
+	c.Stroke(plane, instrumentSymbol, render.StrokeStyle{Width: 4, Cap: render.CapRound})
+}
+
+// vsiDial maps feet per minute onto the VSI dial, with zero at 9 o'clock
+func vsiDial(fpm float64) float64 {
+	return 270 + clamp(fpm, -2000, 2000)/2000*170
+}
+
+// drawVerticalSpeedIndicator draws a VSI reading up to 2000 feet per minute
+func drawVerticalSpeedIndicator(c *render.Canvas, vs float64) {
+	cx, cy, r := dialGeometry(c)
+	drawDialFace(c, cx, cy, r)
+
+	drawTicks(c, cx, cy, r-3, vsiDial(-2000), vsiDial(2000), vsiDial(100)-270, 8, 1.5, instrumentMark)
+	drawTicks(c, cx, cy, r-3, vsiDial(-2000), vsiDial(2000), vsiDial(500)-270, 16, 3, instrumentMark)
+	for fpm := -2000.0; fpm <= 2000; fpm += 500 {
+		x, y := polar(cx, cy, r-32, vsiDial(fpm))
+		drawLabel(c, x, y, fmt.Sprintf("%.0f", math.Abs(fpm)/100), instrumentMark)
+	}
+	drawLabel(c, cx+45, cy-25, "UP", instrumentMark)
+	drawLabel(c, cx+45, cy+25, "DN", instrumentMark)
+	drawLabel(c, cx, cy+45, "100 FT/MIN", instrumentMark)
+
+	drawNeedle(c, cx, cy, r-14, 16, 8, vsiDial(vs), instrumentNeedle)
+	drawHub(c, cx, cy, 8)
+}
+
+// drawTurnCoordinator draws a turn coordinator with banking aircraft and inclinometer
+func drawTurnCoordinator(c *render.Canvas, turnRate, slip float64) {
+	cx, cy, r := dialGeometry(c)
+	drawDialFace(c, cx, cy, r)
+
+	// Wings-level and standard-rate (3 deg/s) index marks
+	for _, d := range []float64{90, 110, 250, 270} {
+		drawTicks(c, cx, cy, r-3, d, d, 1, 18, 4, instrumentMark)
+	}
+	drawLabel(c, cx-r+40, cy+48, "L", instrumentMark)
+	drawLabel(c, cx+r-40, cy+48, "R", instrumentMark)
+	drawLabel(c, cx, cy-50, "2 MIN", instrumentMark)
+
+	// Inclinometer tube with the ball displaced by slip
+	tubeCX, tubeCY, tubeR := cx, cy-r*0.6, r*1.25
+	tube := render.NewPath().Arc(tubeCX, tubeCY, tubeR, dialAngle(158), dialAngle(202))
+	c.Stroke(tube, instrumentMark, render.StrokeStyle{Width: 22, Cap: render.CapRound})
+	c.Stroke(tube, color.RGBA{200, 200, 190, 255}, render.StrokeStyle{Width: 18, Cap: render.CapRound})
+	for _, d := range []float64{174.5, 185.5} {
+		drawTicks(c, tubeCX, tubeCY, tubeR+11, d, d, 1, 22, 2, colornames.Black)
+	}
+	bx, by := polar(tubeCX, tubeCY, tubeR, 180-clamp(slip, -15, 15)*1.4)
+	c.FillCircle(bx, by, 8, colornames.Black)
+
+	// Aircraft symbol banks with the rate of turn
+	c.Save()
+	c.RotateAbout(render.Deg(clamp(turnRate/3*20, -45, 45)), cx, cy)
+	plane := render.NewPath().
+		MoveTo(cx-r+22, cy).LineTo(cx+r-22, cy).
+		MoveTo(cx, cy-14).LineTo(cx, cy)
+	c.Stroke(plane, instrumentNeedle, render.StrokeStyle{Width: 5, Cap: render.CapRound})
+	c.FillCircle(cx, cy, 9, instrumentNeedle)
+	c.Restore()
+}
+
+// absInt returns the absolute value of an integer
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
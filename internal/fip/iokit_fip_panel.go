@@ -1,17 +1,14 @@
+//go:build darwin
+
 package fip
 
 import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"log"
-	"math"
 	"sync"
 	"time"
-
-	"github.com/faiface/pixel/pixelgl"
-	"golang.org/x/image/colornames"
 )
 
 /*
@@ -87,12 +84,6 @@ void closeFIPDevice(IOHIDDeviceRef device) {
 */
 import "C"
 
-// ButtonEvent represents a button press/release event
-type ButtonEvent struct {
-	ButtonID int  // 0-11 for the 12 FIP buttons
-	Pressed  bool // true for press, false for release
-}
-
 // IOKitFIPPanel represents a FIP panel using IOKit for device access
 type IOKitFIPPanel struct {
 	device      C.IOHIDDeviceRef
@@ -106,15 +97,15 @@ type IOKitFIPPanel struct {
 	instrument Instrument
 
 	// Button state tracking
-	buttonStates   [12]bool // FIP has 12 buttons
+	decoder        InputDecoder
 	lastButtonData []byte
 
 	// Event channels
-	buttonEvents chan ButtonEvent
+	buttonEvents chan InputEvent
 	stopChan     chan struct{}
 
-	// Display window
-	window *pixelgl.Window
+	// Frame sinks
+	sinks []Sink
 }
 
 
@@ -126,7 +117,7 @@ func NewIOKitFIPPanel(title string, width, height int) (*IOKitFIPPanel, error) {
 		height:         height,
 		title:          title,
 		instrument:     InstrumentCustom,
-		buttonEvents:   make(chan ButtonEvent, 10),
+		buttonEvents:   make(chan InputEvent, 10),
 		stopChan:       make(chan struct{}),
 		lastButtonData: make([]byte, 2),
 	}
@@ -197,20 +188,16 @@ func (p *IOKitFIPPanel) IsConnected() bool {
 	return p.isConnected
 }
 
-// GetButtonEvents returns the channel for button events
-func (p *IOKitFIPPanel) GetButtonEvents() <-chan ButtonEvent {
+// GetButtonEvents returns the channel for input events
+func (p *IOKitFIPPanel) GetButtonEvents() <-chan InputEvent {
 	return p.buttonEvents
 }
 
-// GetButtonState returns the current state of a button
-func (p *IOKitFIPPanel) GetButtonState(buttonID int) bool {
-	if buttonID < 0 || buttonID >= 12 {
-		return false
-	}
-
+// GetButtonState returns whether a button is held down
+func (p *IOKitFIPPanel) GetButtonState(control InputControl) bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.buttonStates[buttonID]
+	return p.decoder.State()&control.SoftButton() != 0
 }
 
 // SetInstrument sets the type of instrument to display
@@ -223,16 +210,29 @@ func (p *IOKitFIPPanel) GetName() string {
 	return p.title
 }
 
-// GetWindow returns the display window
-func (p *IOKitFIPPanel) GetWindow() *pixelgl.Window {
-	return p.window
+// AddSink attaches a sink that receives every displayed frame
+func (p *IOKitFIPPanel) AddSink(sink Sink) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sinks = append(p.sinks, sink)
 }
 
 // DisplayImage displays an image on the FIP
 func (p *IOKitFIPPanel) DisplayImage(img image.Image) error {
-	// For now, we'll just log that we would display the image
-	// In a full implementation, this would send the image to the FIP display
-	log.Printf("Would display image of size %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	// Sending to the device itself is not implemented yet, so frames only
+	// reach the attached sinks
+	p.mu.RLock()
+	sinks := append([]Sink(nil), p.sinks...)
+	p.mu.RUnlock()
+
+	if len(sinks) == 0 {
+		log.Printf("Would display image of size %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	for _, sink := range sinks {
+		if err := sink.WriteFrame(img); err != nil {
+			return fmt.Errorf("failed to write frame: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -244,22 +244,8 @@ func (p *IOKitFIPPanel) DisplayImageFromFile(filename string) error {
 
 // DisplayInstrument displays an instrument with the given data
 func (p *IOKitFIPPanel) DisplayInstrument(data InstrumentData) error {
-	var img image.Image
-
-	switch p.instrument {
-	case InstrumentArtificialHorizon:
-		img = p.createArtificialHorizon(data.Pitch, data.Roll)
-	case InstrumentAirspeed:
-		img = p.createAirspeedIndicator(data.Airspeed)
-	case InstrumentAltimeter:
-		img = p.createAltimeter(data.Altitude, data.Pressure)
-	case InstrumentCompass:
-		img = p.createCompass(data.Heading)
-	case InstrumentVerticalSpeed:
-		img = p.createVerticalSpeedIndicator(data.VerticalSpeed)
-	case InstrumentTurnCoordinator:
-		img = p.createTurnCoordinator(data.TurnRate, data.Slip)
-	default:
+	img := RenderInstrument(p.instrument, p.width, p.height, data)
+	if img == nil {
 		img = p.createTestPattern()
 	}
 
@@ -311,55 +297,13 @@ func (p *IOKitFIPPanel) processButtonData(data []byte) {
 		return
 	}
 
-	// FIP button mapping (based on typical HID report structure)
-	// This may need adjustment based on actual FIP button layout
-	buttonMap := []struct {
-		byteIndex int
-		bitMask   byte
-	}{
-		{0, 0x01}, // Button 1
-		{0, 0x02}, // Button 2
-		{0, 0x04}, // Button 3
-		{0, 0x08}, // Button 4
-		{0, 0x10}, // Button 5
-		{0, 0x20}, // Button 6
-		{0, 0x40}, // Button 7
-		{0, 0x80}, // Button 8
-		{1, 0x01}, // Button 9
-		{1, 0x02}, // Button 10
-		{1, 0x04}, // Button 11
-		{1, 0x08}, // Button 12
-	}
-
-	// Check for button state changes
-	for buttonID, mapping := range buttonMap {
-		if buttonID >= len(p.buttonStates) {
-			continue
-		}
-
-		currentState := (data[mapping.byteIndex] & mapping.bitMask) != 0
-		previousState := p.buttonStates[buttonID]
-
-		if currentState != previousState {
-			p.buttonStates[buttonID] = currentState
-
-			// Send button event
-			select {
-			case p.buttonEvents <- ButtonEvent{
-				ButtonID: buttonID,
-				Pressed:  currentState,
-			}:
-			default:
-				// Channel full, skip this event
-			}
-
-			// Log button event
-			action := "pressed"
-			if !currentState {
-				action = "released"
-			}
-			log.Printf("FIP Button %d %s", buttonID+1, action)
+	for _, event := range p.decoder.DecodeHIDReport(data) {
+		select {
+		case p.buttonEvents <- event:
+		default:
+			// Channel full, skip this event
 		}
+		log.Printf("FIP %s", event)
 	}
 
 	// Store last data for debugging
@@ -376,154 +320,6 @@ func (p *IOKitFIPPanel) GetLastButtonData() []byte {
 	return result
 }
 
-// Instrument creation methods (same as original FIP panel)
-func (p *IOKitFIPPanel) createArtificialHorizon(pitch, roll float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to blue (sky)
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Skyblue}, image.Point{}, draw.Src)
-
-	// Calculate center
-	centerX := p.width / 2
-	centerY := p.height / 2
-
-	// Draw brown earth (bottom half)
-	earthRect := image.Rect(0, centerY, p.width, p.height)
-	draw.Draw(img, earthRect, &image.Uniform{colornames.Saddlebrown}, image.Point{}, draw.Src)
-
-	// Apply roll rotation
-	rollRad := roll * math.Pi / 180
-	cosRoll := math.Cos(rollRad)
-	sinRoll := math.Sin(rollRad)
-
-	// Draw horizon line with roll
-	for x := 0; x < p.width; x++ {
-		// Calculate rotated position
-		relX := float64(x - centerX)
-		relY := float64(centerY) + pitch*2 // Simple pitch representation
-
-		rotX := relX*cosRoll - relY*sinRoll + float64(centerX)
-		rotY := relX*sinRoll + relY*cosRoll + float64(centerY)
-
-		if rotY >= 0 && rotY < float64(p.height) {
-			img.Set(int(rotX), int(rotY), colornames.White)
-		}
-	}
-
-	return img
-}
-
-func (p *IOKitFIPPanel) createAirspeedIndicator(airspeed float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-
-	centerX := p.width / 2
-	centerY := p.height / 2
-	radius := p.width / 3
-
-	// Draw gauge background
-	for y := 0; y < p.height; y++ {
-		for x := 0; x < p.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
-	}
-
-	return img
-}
-
-func (p *IOKitFIPPanel) createAltimeter(altitude, pressure float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-
-	centerX := p.width / 2
-	centerY := p.height / 2
-	radius := p.width / 3
-
-	// Draw gauge background
-	for y := 0; y < p.height; y++ {
-		for x := 0; x < p.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
-	}
-
-	return img
-}
-
-func (p *IOKitFIPPanel) createCompass(heading float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-
-	centerX := p.width / 2
-	centerY := p.height / 2
-	radius := p.width / 3
-
-	// Draw compass face
-	for y := 0; y < p.height; y++ {
-		for x := 0; x < p.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
-	}
-
-	return img
-}
-
-func (p *IOKitFIPPanel) createVerticalSpeedIndicator(vs float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-
-	centerX := p.width / 2
-	centerY := p.height / 2
-
-	// Simple vertical speed representation
-	vsHeight := int(vs / 1000 * float64(p.height/2)) // Scale to reasonable range
-	vsY := centerY + vsHeight
-
-	if vsY >= 0 && vsY < p.height {
-		for x := centerX - 20; x <= centerX+20; x++ {
-			if x >= 0 && x < p.width {
-				img.Set(x, vsY, colornames.White)
-			}
-		}
-	}
-
-	return img
-}
-
-func (p *IOKitFIPPanel) createTurnCoordinator(turnRate, slip float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
-
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-
-	return img
-}
-
 func (p *IOKitFIPPanel) createTestPattern() image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, p.width, p.height))
 
@@ -0,0 +1,400 @@
+package fip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/colornames"
+	xdraw "golang.org/x/image/draw"
+
+	"saitek-controller/internal/render"
+	"saitek-controller/internal/text"
+	"saitek-controller/internal/tiles"
+)
+
+// MapOrientation selects which way is up on the moving map
+type MapOrientation int
+
+const (
+	MapNorthUp MapOrientation = iota
+	MapHeadingUp
+)
+
+// String returns the orientation name
+func (o MapOrientation) String() string {
+	if o == MapHeadingUp {
+		return "heading-up"
+	}
+	return "north-up"
+}
+
+// ParseMapOrientation parses "north" or "heading", with or without "-up"
+func ParseMapOrientation(s string) (MapOrientation, error) {
+	switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "-up") {
+	case "north", "n":
+		return MapNorthUp, nil
+	case "heading", "hdg", "h", "track":
+		return MapHeadingUp, nil
+	}
+	return MapNorthUp, fmt.Errorf("unknown map orientation: %s (use north or heading)", s)
+}
+
+const (
+	// defaultTrackPoints is the length of the track history
+	defaultTrackPoints = 500
+
+	// trackSpacing is the distance between track points in world
+	// coordinates, about 4 m at the equator
+	trackSpacing = 1e-7
+
+	// mapOverzoom is how many levels past the source's highest zoom the
+	// map may zoom, by enlarging lower zoom tiles
+	mapOverzoom = 3
+)
+
+var (
+	mapBackground = color.RGBA{30, 30, 40, 255}
+	mapTrack      = instrumentBug
+	mapOwnship    = instrumentSymbol
+)
+
+// MovingMap draws raster map tiles centred on the aircraft, with an
+// ownship symbol and the track flown. It implements DataRenderer, so it
+// can be set as a panel's gauge and fed through DisplayInstrument.
+type MovingMap struct {
+	mu          sync.Mutex
+	source      tiles.Source
+	zoom        int
+	minZoom     int
+	maxZoom     int
+	orientation MapOrientation
+	track       []render.Point // world coordinates
+	trackLimit  int
+	mosaic      *image.RGBA
+}
+
+// NewMovingMap creates a north-up map of source at the given zoom level
+func NewMovingMap(source tiles.Source, zoom int) *MovingMap {
+	min, max := source.Zooms()
+	m := &MovingMap{
+		source:     source,
+		minZoom:    min,
+		maxZoom:    max + mapOverzoom,
+		trackLimit: defaultTrackPoints,
+	}
+	m.SetZoom(zoom)
+	return m
+}
+
+// SetZoom sets the zoom level, limited to what the source can show
+func (m *MovingMap) SetZoom(zoom int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.zoom = zoom
+	if m.zoom < m.minZoom {
+		m.zoom = m.minZoom
+	}
+	if m.zoom > m.maxZoom {
+		m.zoom = m.maxZoom
+	}
+}
+
+// Zoom returns the zoom level
+func (m *MovingMap) Zoom() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.zoom
+}
+
+// ZoomBy changes the zoom level by delta levels
+func (m *MovingMap) ZoomBy(delta int) {
+	m.SetZoom(m.Zoom() + delta)
+}
+
+// SetOrientation sets which way is up
+func (m *MovingMap) SetOrientation(o MapOrientation) {
+	m.mu.Lock()
+	m.orientation = o
+	m.mu.Unlock()
+}
+
+// Orientation returns which way is up
+func (m *MovingMap) Orientation() MapOrientation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.orientation
+}
+
+// ToggleOrientation switches between north-up and heading-up
+func (m *MovingMap) ToggleOrientation() {
+	m.mu.Lock()
+	m.orientation = 1 - m.orientation
+	m.mu.Unlock()
+}
+
+// SetTrackLength sets how many track points are kept, 0 turns the track off
+func (m *MovingMap) SetTrackLength(points int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trackLimit = points
+	if len(m.track) > points {
+		m.track = append([]render.Point(nil), m.track[len(m.track)-points:]...)
+	}
+}
+
+// ClearTrack forgets the track flown so far
+func (m *MovingMap) ClearTrack() {
+	m.mu.Lock()
+	m.track = nil
+	m.mu.Unlock()
+}
+
+// TrackLength returns the number of track points
+func (m *MovingMap) TrackLength() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.track)
+}
+
+// Update adds the aircraft position to the track
+func (m *MovingMap) Update(data InstrumentData) {
+	x, y := tiles.Project(data.Latitude, data.Longitude)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.trackLimit <= 0 {
+		return
+	}
+	if n := len(m.track); n > 0 && math.Hypot(x-m.track[n-1].X, y-m.track[n-1].Y) < trackSpacing {
+		return
+	}
+	m.track = append(m.track, render.Point{X: x, Y: y})
+	if len(m.track) > m.trackLimit {
+		m.track = m.track[len(m.track)-m.trackLimit:]
+	}
+}
+
+// HandleInput zooms with either dial, toggles north-up and heading-up with
+// S1 and clears the track with S2. It reports whether the map changed.
+func (m *MovingMap) HandleInput(event InputEvent) bool {
+	switch {
+	case event.Control.IsDial():
+		before := m.Zoom()
+		m.ZoomBy(event.Delta())
+		return m.Zoom() != before
+	case event.Control == InputButton1 && event.Pressed:
+		m.ToggleOrientation()
+		return true
+	case event.Control == InputButton2 && event.Pressed:
+		m.ClearTrack()
+		return true
+	}
+	return false
+}
+
+// RenderData adds the position to the track and draws the map
+func (m *MovingMap) RenderData(s *Surface, data InstrumentData) error {
+	m.Update(data)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := s.Canvas()
+	c.Clear(mapBackground)
+	w, h := float64(s.Width()), float64(s.Height())
+
+	// The aircraft sits lower in heading-up mode to show more ahead
+	ox, oy := w/2, h/2
+	angle := 0.0
+	if m.orientation == MapHeadingUp {
+		oy = h * 2 / 3
+		angle = -render.Deg(data.Heading)
+	}
+
+	world := tiles.WorldSize(m.zoom)
+	wx, wy := tiles.Project(data.Latitude, data.Longitude)
+	cx, cy := wx*world, wy*world
+
+	// Any tile within this distance of the aircraft may be on screen
+	reach := math.Hypot(math.Max(ox, w-ox), math.Max(oy, h-oy))
+	size := float64(tiles.TileSize)
+	tx0, tx1 := int(math.Floor((cx-reach)/size)), int(math.Floor((cx+reach)/size))
+	ty0, ty1 := int(math.Floor((cy-reach)/size)), int(math.Floor((cy+reach)/size))
+
+	drawn, err := m.drawMosaic(tx0, ty0, tx1, ty1)
+	if err != nil {
+		return err
+	}
+
+	c.Save()
+	c.Translate(ox, oy)
+	c.Rotate(angle)
+	c.Translate(-cx, -cy)
+	if drawn {
+		c.DrawImage(m.mosaic, float64(tx0)*size, float64(ty0)*size)
+	}
+	if len(m.track) > 1 {
+		path := render.NewPath()
+		for i, p := range m.track {
+			if i == 0 {
+				path.MoveTo(p.X*world, p.Y*world)
+			} else {
+				path.LineTo(p.X*world, p.Y*world)
+			}
+		}
+		c.Stroke(path, mapTrack, render.Stroke(2))
+	}
+	c.Restore()
+
+	if !drawn {
+		drawLabel(c, w/2, h/4, "NO MAP DATA", colornames.White)
+	}
+	m.drawOwnship(c, ox, oy, data.Heading)
+	m.drawOverlay(s, data)
+	if data.Failed.Has(FieldLatitude) || data.Failed.Has(FieldLongitude) {
+		// The map is frozen at the last known position
+		c.FillRect(w/2-60, h/4-12, 120, 24, instrumentFailure)
+		drawLabel(c, w/2, h/4, "NO POSITION", colornames.White)
+	}
+	return nil
+}
+
+// drawMosaic draws the tiles tx0-tx1, ty0-ty1 side by side into the
+// mosaic, reusing it between frames. It reports whether any tile was found.
+func (m *MovingMap) drawMosaic(tx0, ty0, tx1, ty1 int) (bool, error) {
+	bounds := image.Rect(0, 0, (tx1-tx0+1)*tiles.TileSize, (ty1-ty0+1)*tiles.TileSize)
+	if m.mosaic == nil || m.mosaic.Bounds() != bounds {
+		m.mosaic = image.NewRGBA(bounds)
+	}
+	draw.Draw(m.mosaic, bounds, image.NewUniform(mapBackground), image.Point{}, draw.Src)
+
+	n := 1 << uint(m.zoom)
+	drawn := false
+	for ty := ty0; ty <= ty1; ty++ {
+		if ty < 0 || ty >= n {
+			continue
+		}
+		for tx := tx0; tx <= tx1; tx++ {
+			img, src, err := m.tile(m.zoom, ((tx%n)+n)%n, ty)
+			if errors.Is(err, tiles.ErrTileNotFound) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			dst := image.Rect(0, 0, tiles.TileSize, tiles.TileSize).Add(image.Pt((tx-tx0)*tiles.TileSize, (ty-ty0)*tiles.TileSize))
+			if src.Size() == dst.Size() {
+				draw.Draw(m.mosaic, dst, img, src.Min, draw.Src)
+			} else {
+				xdraw.ApproxBiLinear.Scale(m.mosaic, dst, img, src, draw.Src, nil)
+			}
+			drawn = true
+		}
+	}
+	return drawn, nil
+}
+
+// tile returns a tile image and the part of it covering tile z/x/y. Past
+// the source's zoom range, part of a lower zoom tile is enlarged.
+func (m *MovingMap) tile(z, x, y int) (image.Image, image.Rectangle, error) {
+	var lastErr error
+	for d := 0; d <= mapOverzoom && d <= z; d++ {
+		img, err := m.source.Tile(z-d, x>>uint(d), y>>uint(d))
+		if err != nil {
+			lastErr = err
+			if errors.Is(err, tiles.ErrTileNotFound) {
+				continue
+			}
+			return nil, image.Rectangle{}, err
+		}
+		b := img.Bounds()
+		part := b.Dx() >> uint(d)
+		if part == 0 {
+			continue
+		}
+		mask := 1<<uint(d) - 1
+		min := b.Min.Add(image.Pt((x&mask)*part, (y&mask)*part))
+		return img, image.Rectangle{Min: min, Max: min.Add(image.Pt(part, part))}, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%w: %d/%d/%d", tiles.ErrTileNotFound, z, x, y)
+	}
+	return nil, image.Rectangle{}, lastErr
+}
+
+// drawOwnship draws the aircraft symbol, pointing along the heading in
+// north-up mode and straight up in heading-up mode
+func (m *MovingMap) drawOwnship(c *render.Canvas, x, y, heading float64) {
+	c.Save()
+	c.Translate(x, y)
+	if m.orientation == MapNorthUp {
+		c.Rotate(render.Deg(heading))
+	}
+	aircraft := render.NewPath().Polygon(
+		render.Point{X: 0, Y: -12},
+		render.Point{X: 2, Y: -4},
+		render.Point{X: 11, Y: 2},
+		render.Point{X: 11, Y: 4},
+		render.Point{X: 2, Y: 2},
+		render.Point{X: 2, Y: 8},
+		render.Point{X: 5, Y: 11},
+		render.Point{X: -5, Y: 11},
+		render.Point{X: -2, Y: 8},
+		render.Point{X: -2, Y: 2},
+		render.Point{X: -11, Y: 4},
+		render.Point{X: -11, Y: 2},
+		render.Point{X: -2, Y: -4},
+	)
+	c.Stroke(aircraft, colornames.Black, render.Stroke(3))
+	c.Fill(aircraft, mapOwnship)
+	c.Restore()
+}
+
+// drawOverlay draws the orientation, zoom level and heading, and a north
+// arrow in heading-up mode
+func (m *MovingMap) drawOverlay(s *Surface, data InstrumentData) {
+	style := text.Style{
+		Face:         text.Bold(12),
+		Color:        colornames.White,
+		VAlign:       text.VAlignTop,
+		Outline:      1,
+		OutlineColor: colornames.Black,
+	}
+	mode := "N-UP"
+	if m.orientation == MapHeadingUp {
+		mode = "HDG-UP"
+	}
+	text.Draw(s.Image(), fmt.Sprintf("%s  Z%d", mode, m.zoom), 6, 4, style)
+
+	style.Align = text.AlignRight
+	text.Draw(s.Image(), fmt.Sprintf("HDG %03.0f", math.Mod(math.Mod(data.Heading, 360)+360, 360)), float64(s.Width())-6, 4, style)
+
+	if m.orientation == MapHeadingUp {
+		c := s.Canvas()
+		x, y := float64(s.Width())-18, 36.0
+		c.Save()
+		c.Translate(x, y)
+		c.Rotate(-render.Deg(data.Heading))
+		arrow := render.NewPath().Polygon(
+			render.Point{X: 0, Y: -11},
+			render.Point{X: 5, Y: 5},
+			render.Point{X: 0, Y: 2},
+			render.Point{X: -5, Y: 5},
+		)
+		c.Stroke(arrow, colornames.Black, render.Stroke(2))
+		c.Fill(arrow, colornames.White)
+		c.Restore()
+		drawOutlinedLabel(c, x, y+16, "N", colornames.White)
+	}
+}
+
+// Close closes the tile source
+func (m *MovingMap) Close() error {
+	return m.source.Close()
+}
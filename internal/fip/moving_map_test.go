@@ -0,0 +1,127 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"saitek-controller/internal/tiles"
+)
+
+// solidTiles is a tile source of solid tiles colored by their position
+type solidTiles struct {
+	maxZoom int
+}
+
+func solidTileColor(z, x, y int) color.RGBA {
+	return color.RGBA{uint8(z * 40), uint8(x * 30), uint8(y * 30), 255}
+}
+
+func (s *solidTiles) Tile(z, x, y int) (image.Image, error) {
+	if z > s.maxZoom || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		return nil, fmt.Errorf("%w: %d/%d/%d", tiles.ErrTileNotFound, z, x, y)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, tiles.TileSize, tiles.TileSize))
+	draw.Draw(img, img.Bounds(), image.NewUniform(solidTileColor(z, x, y)), image.Point{}, draw.Src)
+	return img, nil
+}
+
+func (s *solidTiles) Zooms() (min, max int) { return 0, s.maxZoom }
+func (s *solidTiles) Close() error          { return nil }
+
+func TestParseMapOrientation(t *testing.T) {
+	for input, want := range map[string]MapOrientation{"north": MapNorthUp, "North-Up": MapNorthUp, "heading": MapHeadingUp, "hdg-up": MapHeadingUp} {
+		if got, err := ParseMapOrientation(input); err != nil || got != want {
+			t.Errorf("ParseMapOrientation(%q) = %v, %v", input, got, err)
+		}
+	}
+	if _, err := ParseMapOrientation("south"); err == nil {
+		t.Error("Expected error for unknown orientation")
+	}
+}
+
+func TestMovingMapInput(t *testing.T) {
+	m := NewMovingMap(&solidTiles{maxZoom: 3}, 10)
+	if m.Zoom() != 3+mapOverzoom {
+		t.Errorf("Expected zoom limited to %d, got %d", 3+mapOverzoom, m.Zoom())
+	}
+
+	m.HandleInput(InputEvent{Control: InputRightDialCCW, Pressed: true})
+	if m.Zoom() != 2+mapOverzoom {
+		t.Errorf("Expected dial to zoom out, got zoom %d", m.Zoom())
+	}
+	m.SetZoom(0)
+	if m.HandleInput(InputEvent{Control: InputLeftDialCCW, Pressed: true}) {
+		t.Error("Zooming out past the lowest zoom should not change the map")
+	}
+
+	m.HandleInput(InputEvent{Control: InputButton1, Pressed: true})
+	if m.Orientation() != MapHeadingUp {
+		t.Errorf("Expected S1 to select heading-up, got %v", m.Orientation())
+	}
+
+	m.Update(InstrumentData{Latitude: 10, Longitude: 10})
+	m.Update(InstrumentData{Latitude: 10, Longitude: 10})
+	m.Update(InstrumentData{Latitude: 10.01, Longitude: 10})
+	if m.TrackLength() != 2 {
+		t.Errorf("Expected repeated positions to be skipped, got %d points", m.TrackLength())
+	}
+	m.HandleInput(InputEvent{Control: InputButton2, Pressed: true})
+	if m.TrackLength() != 0 {
+		t.Errorf("Expected S2 to clear the track, got %d points", m.TrackLength())
+	}
+
+	m.SetTrackLength(3)
+	for i := 0; i < 10; i++ {
+		m.Update(InstrumentData{Latitude: float64(i)})
+	}
+	if m.TrackLength() != 3 {
+		t.Errorf("Expected track limited to 3 points, got %d", m.TrackLength())
+	}
+}
+
+func TestMovingMapRender(t *testing.T) {
+	source := &solidTiles{maxZoom: 2}
+	m := NewMovingMap(source, 2)
+	s := NewSurface(320, 240)
+
+	// The middle of tile 2/1/1, with the aircraft in the screen centre
+	lat, lon := tiles.Unproject(0.375, 0.375)
+	data := InstrumentData{Latitude: lat, Longitude: lon}
+	if err := m.RenderData(s, data); err != nil {
+		t.Fatalf("RenderData failed: %v", err)
+	}
+	if got := s.Image().RGBAAt(100, 200); got != solidTileColor(2, 1, 1) {
+		t.Errorf("Expected tile 2/1/1 color, got %v", got)
+	}
+	if got := s.Image().RGBAAt(160, 125); got != mapOwnship {
+		t.Errorf("Expected ownship symbol at the centre, got %v", got)
+	}
+
+	// Past the source's zoom range the parent tile is enlarged
+	m.SetZoom(3)
+	if err := m.RenderData(s, data); err != nil {
+		t.Fatalf("RenderData failed: %v", err)
+	}
+	if got := s.Image().RGBAAt(100, 200); got != solidTileColor(2, 1, 1) {
+		t.Errorf("Expected enlarged tile 2/1/1 color, got %v", got)
+	}
+
+	// Heading-up turns the map; 90 degrees brings tile 2/2/1 to the top
+	m.SetZoom(2)
+	m.SetOrientation(MapHeadingUp)
+	data.Heading = 90
+	lat, lon = tiles.Unproject(0.49, 0.375)
+	data.Latitude, data.Longitude = lat, lon
+	if err := m.RenderData(s, data); err != nil {
+		t.Fatalf("RenderData failed: %v", err)
+	}
+	if got := s.Image().RGBAAt(160, 60); got != solidTileColor(2, 2, 1) {
+		t.Errorf("Expected tile 2/2/1 ahead, got %v", got)
+	}
+	if got := s.Image().RGBAAt(140, 230); got != solidTileColor(2, 1, 1) {
+		t.Errorf("Expected tile 2/1/1 behind, got %v", got)
+	}
+}
@@ -223,6 +223,27 @@ func (m *MultiPanel) ParseSwitchState(data []byte) map[string]bool {
 	return state
 }
 
+// GetSelector returns the position of the mode selector knob (ALT, VS, IAS, HDG or CRS)
+func (m *MultiPanel) GetSelector() (string, error) {
+	data, err := m.ReadSwitchState()
+	if err != nil {
+		return "", err
+	}
+
+	return SelectorPosition(m.ParseSwitchState(data)), nil
+}
+
+// SelectorPosition returns the selector position in a state parsed by
+// ParseSwitchState, or an empty string if no position is set
+func SelectorPosition(state map[string]bool) string {
+	for _, position := range multiSelectorPositions {
+		if state[position] {
+			return position
+		}
+	}
+	return ""
+}
+
 // FormatValue formats a value string for display
 // Handles common aviation value formats
 func FormatMultiValue(value string) string {
@@ -0,0 +1,461 @@
+package fip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"sync"
+)
+
+// Page button bits. DirectOutput handles paging itself, so these are only
+// reported by panels driven directly, alongside the soft button bits.
+const (
+	SoftButtonPageUp   = 0x00000800
+	SoftButtonPageDown = 0x00001000
+)
+
+// softButtonLEDs is the number of soft buttons with an LED (S1-S6)
+const softButtonLEDs = 6
+
+// ErrPageNotActive is returned when updating the display of an inactive
+// page, like DirectOutput's E_PAGENOTACTIVE
+var ErrPageNotActive = errors.New("page not active")
+
+// LEDController drives the soft button LEDs, such as FIPDirect or FIPUSB
+type LEDController interface {
+	SetLED(index int, value bool) error
+}
+
+// FIPPage is a page registered with a PageManager
+type FIPPage struct {
+	ID        uint32
+	Name      string
+	renderer  Renderer
+	leds      [softButtonLEDs]bool
+	callbacks PageCallbacks
+	mu        *sync.Mutex // the manager's lock, guarding renderer and leds
+}
+
+// Leds returns the page's soft button LED states
+func (p *FIPPage) Leds() [softButtonLEDs]bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.leds
+}
+
+// PageChangeEvent is published when the active page changes. Previous is
+// nil when there was no active page, Current is nil when the last page
+// was removed.
+type PageChangeEvent struct {
+	Previous *FIPPage
+	Current  *FIPPage
+}
+
+// PageManager keeps a set of pages for one FIP and shows the active one.
+// Only the active page is rendered and its LEDs sent to the device; the
+// others keep their state until they are paged to.
+type PageManager struct {
+	mu       sync.Mutex
+	display  ImageDisplay
+	leds     LEDController
+	width    int
+	height   int
+	pages    []*FIPPage
+	active   *FIPPage
+	buttons  uint32
+	handlers []func(PageChangeEvent)
+
+	// output replaces show for pages drawn by other means, as
+	// NativeDirectOutput's are
+	output func(page *FIPPage) error
+}
+
+// NewPageManager creates a page manager that draws pages at the given size
+// and shows them on display
+func NewPageManager(display ImageDisplay, width, height int) *PageManager {
+	return &PageManager{display: display, width: width, height: height}
+}
+
+// SetLEDController sets where soft button LED states are sent
+func (m *PageManager) SetLEDController(leds LEDController) {
+	m.mu.Lock()
+	m.leds = leds
+	m.mu.Unlock()
+}
+
+// OnPageChange registers a handler for page change events
+func (m *PageManager) OnPageChange(handler func(PageChangeEvent)) {
+	m.mu.Lock()
+	m.handlers = append(m.handlers, handler)
+	m.mu.Unlock()
+}
+
+// AddPage registers a page. With FLAG_SET_AS_ACTIVE, or if no page is active
+// yet, the new page becomes the active page.
+func (m *PageManager) AddPage(id uint32, name string, renderer Renderer, flags uint32) (*FIPPage, error) {
+	return m.addPage(id, name, renderer, PageCallbacks{}, flags)
+}
+
+// addPage is AddPage with the page's callbacks set before it is activated
+func (m *PageManager) addPage(id uint32, name string, renderer Renderer, callbacks PageCallbacks, flags uint32) (*FIPPage, error) {
+	m.mu.Lock()
+	if m.find(id) >= 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("page %d already exists", id)
+	}
+
+	page := &FIPPage{ID: id, Name: name, renderer: renderer, callbacks: callbacks, mu: &m.mu}
+	m.pages = append(m.pages, page)
+	sort.Slice(m.pages, func(i, j int) bool { return m.pages[i].ID < m.pages[j].ID })
+	activate := flags&FLAG_SET_AS_ACTIVE != 0 || m.active == nil
+	m.mu.Unlock()
+
+	if activate {
+		if err := m.SetActivePage(id); err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+// RemovePage removes a page. If it was active, the next page becomes active.
+func (m *PageManager) RemovePage(id uint32) error {
+	m.mu.Lock()
+	i := m.find(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("page %d not found", id)
+	}
+
+	removed := m.pages[i]
+	m.pages = append(m.pages[:i], m.pages[i+1:]...)
+	if m.active != removed {
+		m.mu.Unlock()
+		return nil
+	}
+
+	var next *FIPPage
+	if len(m.pages) > 0 {
+		next = m.pages[i%len(m.pages)]
+	}
+	return m.activate(next)
+}
+
+// Page returns a registered page, or nil
+func (m *PageManager) Page(id uint32) *FIPPage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.find(id); i >= 0 {
+		return m.pages[i]
+	}
+	return nil
+}
+
+// Pages returns the registered pages in ID order
+func (m *PageManager) Pages() []*FIPPage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*FIPPage(nil), m.pages...)
+}
+
+// ActivePage returns the active page, or nil if there are no pages
+func (m *PageManager) ActivePage() *FIPPage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active
+}
+
+// SetActivePage makes a page active and shows it
+func (m *PageManager) SetActivePage(id uint32) error {
+	m.mu.Lock()
+	i := m.find(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("page %d not found", id)
+	}
+	if m.pages[i] == m.active {
+		m.mu.Unlock()
+		return nil
+	}
+	return m.activate(m.pages[i])
+}
+
+// NextPage moves to the next page, wrapping around at the end
+func (m *PageManager) NextPage() error {
+	return m.step(1)
+}
+
+// PreviousPage moves to the previous page, wrapping around at the start
+func (m *PageManager) PreviousPage() error {
+	return m.step(-1)
+}
+
+// SetPageCallbacks sets the page and soft button callbacks of a page
+func (m *PageManager) SetPageCallbacks(id uint32, callbacks PageCallbacks) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(id)
+	if i < 0 {
+		return fmt.Errorf("page %d not found", id)
+	}
+	m.pages[i].callbacks = callbacks
+	return nil
+}
+
+// SetRenderer replaces a page's renderer, redrawing it if it is active
+func (m *PageManager) SetRenderer(id uint32, renderer Renderer) error {
+	m.mu.Lock()
+	i := m.find(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("page %d not found", id)
+	}
+	m.pages[i].renderer = renderer
+	active := m.pages[i] == m.active
+	m.mu.Unlock()
+
+	if !active {
+		return nil
+	}
+	return m.Refresh()
+}
+
+// SetLed sets a soft button LED (0-5) on a page. The state is kept with the
+// page and only sent to the device while the page is active.
+func (m *PageManager) SetLed(id uint32, index int, value bool) error {
+	if index < 0 || index >= softButtonLEDs {
+		return fmt.Errorf("invalid LED index: %d (must be 0-%d)", index, softButtonLEDs-1)
+	}
+
+	m.mu.Lock()
+	i := m.find(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("page %d not found", id)
+	}
+	m.pages[i].leds[index] = value
+	leds := m.leds
+	active := m.pages[i] == m.active
+	m.mu.Unlock()
+
+	if !active || leds == nil {
+		return nil
+	}
+	return leds.SetLED(index, value)
+}
+
+// UpdatePage redraws a page. It returns ErrPageNotActive if the page is
+// registered but not showing.
+func (m *PageManager) UpdatePage(id uint32) error {
+	m.mu.Lock()
+	i := m.find(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("page %d not found", id)
+	}
+	active := m.pages[i] == m.active
+	m.mu.Unlock()
+
+	if !active {
+		return ErrPageNotActive
+	}
+	return m.Refresh()
+}
+
+// Refresh renders the active page and sends it to the display
+func (m *PageManager) Refresh() error {
+	m.mu.Lock()
+	page := m.active
+	var renderer Renderer
+	if page != nil {
+		renderer = page.renderer
+	}
+	m.mu.Unlock()
+
+	if renderer == nil || m.display == nil {
+		return nil
+	}
+
+	s := NewSurface(m.width, m.height)
+	if err := renderer.Render(s); err != nil {
+		return fmt.Errorf("failed to render page %d: %w", page.ID, err)
+	}
+	return m.display.DisplayImage(s.Image())
+}
+
+// Frame renders a page without showing it, for previews of inactive pages
+func (m *PageManager) Frame(id uint32) (image.Image, error) {
+	m.mu.Lock()
+	i := m.find(id)
+	var renderer Renderer
+	if i >= 0 {
+		renderer = m.pages[i].renderer
+	}
+	m.mu.Unlock()
+	if i < 0 {
+		return nil, fmt.Errorf("page %d not found", id)
+	}
+
+	s := NewSurface(m.width, m.height)
+	if renderer != nil {
+		if err := renderer.Render(s); err != nil {
+			return nil, fmt.Errorf("failed to render page %d: %w", id, err)
+		}
+	}
+	return s.Image(), nil
+}
+
+// HandleSoftButtons processes the current soft button state as a bitmask of
+// SoftButton* values. Presses of the page buttons change page, and any
+// change to the other buttons is passed to the active page's callback.
+func (m *PageManager) HandleSoftButtons(buttons uint32) error {
+	const pageButtons = SoftButtonPageUp | SoftButtonPageDown
+
+	m.mu.Lock()
+	pressed := buttons &^ m.buttons
+	changed := (buttons ^ m.buttons) &^ pageButtons
+	m.buttons = buttons
+	var callback func(uint32)
+	if m.active != nil {
+		callback = m.active.callbacks.OnSoftButtonChanged
+	}
+	m.mu.Unlock()
+
+	if changed != 0 && callback != nil {
+		callback(buttons &^ pageButtons)
+	}
+
+	switch {
+	case pressed&SoftButtonPageDown != 0:
+		return m.NextPage()
+	case pressed&SoftButtonPageUp != 0:
+		return m.PreviousPage()
+	}
+	return nil
+}
+
+// HandleInput processes an input event from any FIP backend. Dial detents
+// reach the page callback as a press and release of their soft button bit.
+func (m *PageManager) HandleInput(event InputEvent) error {
+	bit := event.Control.SoftButton()
+	m.mu.Lock()
+	buttons := m.buttons
+	m.mu.Unlock()
+
+	switch {
+	case event.Control.IsDial():
+		if err := m.HandleSoftButtons(buttons | bit); err != nil {
+			return err
+		}
+		return m.HandleSoftButtons(buttons)
+	case event.Pressed:
+		return m.HandleSoftButtons(buttons | bit)
+	default:
+		return m.HandleSoftButtons(buttons &^ bit)
+	}
+}
+
+// step moves the active page by delta positions
+func (m *PageManager) step(delta int) error {
+	m.mu.Lock()
+	if len(m.pages) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+
+	i := 0
+	if m.active != nil {
+		i = m.find(m.active.ID) + delta
+	}
+	n := len(m.pages)
+	next := m.pages[((i%n)+n)%n]
+	if next == m.active {
+		m.mu.Unlock()
+		return nil
+	}
+	return m.activate(next)
+}
+
+// activate switches to page, which may be nil. It must be called with the
+// lock held and releases it before calling out.
+func (m *PageManager) activate(page *FIPPage) error {
+	previous := m.active
+	m.active = page
+	var left, shown func(uint32, bool)
+	if previous != nil {
+		left = previous.callbacks.OnPageChanged
+	}
+	if page != nil {
+		shown = page.callbacks.OnPageChanged
+	}
+	handlers := append([]func(PageChangeEvent){}, m.handlers...)
+	m.mu.Unlock()
+
+	if left != nil {
+		left(previous.ID, false)
+	}
+	err := m.show(page)
+	if shown != nil {
+		shown(page.ID, true)
+	}
+
+	event := PageChangeEvent{Previous: previous, Current: page}
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return err
+}
+
+// show sends a page's LEDs and image to the device. With no page the
+// display is blanked and the LEDs turned off.
+func (m *PageManager) show(page *FIPPage) error {
+	if m.output != nil {
+		return m.output(page)
+	}
+
+	m.mu.Lock()
+	if m.active != page {
+		// Another page was activated meanwhile and shows itself
+		m.mu.Unlock()
+		return nil
+	}
+	leds := m.leds
+	var states [softButtonLEDs]bool
+	if page != nil {
+		states = page.leds
+	}
+	m.mu.Unlock()
+
+	var firstErr error
+	if leds != nil {
+		for i, on := range states {
+			if err := leds.SetLED(i, on); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to set LED %d: %w", i, err)
+			}
+		}
+	}
+	if page != nil {
+		if err := m.Refresh(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	} else if m.display != nil {
+		blank := NewSurface(m.width, m.height)
+		blank.Clear(color.Black)
+		if err := m.display.DisplayImage(blank.Image()); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to blank display: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// find returns the index of a page, or -1
+func (m *PageManager) find(id uint32) int {
+	for i, p := range m.pages {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
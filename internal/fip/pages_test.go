@@ -0,0 +1,222 @@
+package fip
+
+import (
+	"image/color"
+	"sync"
+	"testing"
+)
+
+// fakeLEDs records soft button LED states
+type fakeLEDs struct {
+	state [softButtonLEDs]bool
+	calls int
+}
+
+func (f *fakeLEDs) SetLED(index int, value bool) error {
+	f.state[index] = value
+	f.calls++
+	return nil
+}
+
+// fillPage returns a renderer that fills the surface with a color
+func fillPage(c color.Color) Renderer {
+	return RendererFunc(func(s *Surface) error {
+		s.Clear(c)
+		return nil
+	})
+}
+
+func newTestPageManager(t *testing.T) (*PageManager, *MemorySink, *fakeLEDs) {
+	panel, err := NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	t.Cleanup(func() { panel.Close() })
+
+	sink := NewMemorySink(1)
+	panel.AddSink(sink)
+	leds := &fakeLEDs{}
+	m := NewPageManager(panel, 320, 240)
+	m.SetLEDController(leds)
+	return m, sink, leds
+}
+
+func TestPageManagerActivation(t *testing.T) {
+	m, sink, _ := newTestPageManager(t)
+
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	if _, err := m.AddPage(1, "Red", fillPage(red), 0); err != nil {
+		t.Fatalf("Failed to add page: %v", err)
+	}
+	if _, err := m.AddPage(2, "Green", fillPage(green), 0); err != nil {
+		t.Fatalf("Failed to add page: %v", err)
+	}
+	if _, err := m.AddPage(2, "Duplicate", nil, 0); err == nil {
+		t.Error("Expected error for duplicate page")
+	}
+
+	if m.ActivePage().ID != 1 {
+		t.Errorf("Expected first page to be active, got %d", m.ActivePage().ID)
+	}
+	if sink.Last().At(0, 0) != red {
+		t.Error("Expected active page to be displayed")
+	}
+	if err := m.UpdatePage(2); err != ErrPageNotActive {
+		t.Errorf("Expected ErrPageNotActive, got %v", err)
+	}
+
+	if _, err := m.AddPage(3, "Blue", fillPage(color.RGBA{0, 0, 255, 255}), FLAG_SET_AS_ACTIVE); err != nil {
+		t.Fatalf("Failed to add page: %v", err)
+	}
+	if m.ActivePage().ID != 3 {
+		t.Errorf("Expected FLAG_SET_AS_ACTIVE page to be active, got %d", m.ActivePage().ID)
+	}
+
+	if err := m.RemovePage(3); err != nil {
+		t.Fatalf("Failed to remove page: %v", err)
+	}
+	if m.ActivePage().ID != 1 {
+		t.Errorf("Expected removing the last page to wrap to page 1, got %d", m.ActivePage().ID)
+	}
+}
+
+func TestPageManagerRemoveLastPage(t *testing.T) {
+	m, sink, leds := newTestPageManager(t)
+	m.AddPage(1, "Red", fillPage(color.RGBA{255, 0, 0, 255}), 0)
+	m.SetLed(1, 2, true)
+
+	var events []PageChangeEvent
+	m.OnPageChange(func(e PageChangeEvent) { events = append(events, e) })
+	if err := m.RemovePage(1); err != nil {
+		t.Fatalf("Failed to remove page: %v", err)
+	}
+	if m.ActivePage() != nil {
+		t.Error("Expected no active page")
+	}
+	if sink.Last().At(0, 0) != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("Expected the display to be blanked, got %v", sink.Last().At(0, 0))
+	}
+	if leds.state != [softButtonLEDs]bool{} {
+		t.Errorf("Expected the LEDs to be turned off, got %v", leds.state)
+	}
+	if len(events) != 1 || events[0].Current != nil || events[0].Previous.ID != 1 {
+		t.Errorf("Expected a change event to no page, got %+v", events)
+	}
+}
+
+func TestPageManagerButtons(t *testing.T) {
+	m, _, _ := newTestPageManager(t)
+	for id := uint32(1); id <= 3; id++ {
+		m.AddPage(id, "", nil, 0)
+	}
+
+	var events []PageChangeEvent
+	m.OnPageChange(func(e PageChangeEvent) { events = append(events, e) })
+
+	var changes []string
+	m.SetPageCallbacks(1, PageCallbacks{
+		OnPageChanged: func(page uint32, active bool) {
+			if active {
+				changes = append(changes, "on")
+			} else {
+				changes = append(changes, "off")
+			}
+		},
+	})
+	var buttons []uint32
+	m.SetPageCallbacks(2, PageCallbacks{
+		OnSoftButtonChanged: func(b uint32) { buttons = append(buttons, b) },
+	})
+
+	// Holding page down only moves one page
+	m.HandleSoftButtons(SoftButtonPageDown)
+	m.HandleSoftButtons(SoftButtonPageDown)
+	m.HandleSoftButtons(0)
+	if m.ActivePage().ID != 2 {
+		t.Fatalf("Expected page 2 after page down, got %d", m.ActivePage().ID)
+	}
+
+	m.HandleSoftButtons(SoftButton1)
+	m.HandleSoftButtons(SoftButton1)
+	m.HandleSoftButtons(0)
+	if len(buttons) != 2 || buttons[0] != SoftButton1 || buttons[1] != 0 {
+		t.Errorf("Expected press and release of S1, got %v", buttons)
+	}
+
+	m.HandleSoftButtons(SoftButtonPageUp)
+	m.HandleSoftButtons(0)
+	m.HandleSoftButtons(SoftButtonPageUp)
+	if m.ActivePage().ID != 3 {
+		t.Errorf("Expected page up to wrap to page 3, got %d", m.ActivePage().ID)
+	}
+
+	if len(events) != 3 || events[0].Previous.ID != 1 || events[0].Current.ID != 2 {
+		t.Errorf("Unexpected page change events: %+v", events)
+	}
+	if len(changes) != 3 || changes[0] != "off" || changes[1] != "on" || changes[2] != "off" {
+		t.Errorf("Expected page 1 to be deactivated, activated and deactivated, got %v", changes)
+	}
+}
+
+func TestPageManagerLeds(t *testing.T) {
+	m, _, leds := newTestPageManager(t)
+	m.AddPage(1, "", nil, 0)
+	m.AddPage(2, "", nil, 0)
+
+	m.SetLed(1, 0, true)
+	if !leds.state[0] {
+		t.Error("Expected LED on the active page to be sent")
+	}
+
+	calls := leds.calls
+	m.SetLed(2, 5, true)
+	if leds.calls != calls {
+		t.Error("Expected LED on an inactive page not to be sent")
+	}
+	if err := m.SetLed(2, 6, true); err == nil {
+		t.Error("Expected error for invalid LED index")
+	}
+
+	m.NextPage()
+	if leds.state[0] || !leds.state[5] {
+		t.Errorf("Expected page 2 LEDs after switching, got %v", leds.state)
+	}
+	if m.Page(1).Leds()[0] != true {
+		t.Error("Expected page 1 to keep its LED state")
+	}
+}
+
+func TestPageManagerConcurrentUpdates(t *testing.T) {
+	panel, err := NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+	m := NewPageManager(panel, 320, 240)
+	m.AddPage(1, "", fillPage(color.Black), 0)
+	m.AddPage(2, "", fillPage(color.White), 0)
+
+	// Run with -race: renderers, LEDs and callbacks change while pages are
+	// drawn
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			m.SetPageCallbacks(uint32(i%2+1), PageCallbacks{OnPageChanged: func(uint32, bool) {}})
+			m.SetRenderer(uint32(i%2+1), fillPage(color.Gray{uint8(i)}))
+			m.SetLed(uint32(i%2+1), i%softButtonLEDs, i%3 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			m.NextPage()
+			m.Refresh()
+			m.Frame(2)
+			m.Page(1).Leds()
+		}
+	}()
+	wg.Wait()
+}
@@ -4,25 +4,35 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
-	"math"
+	_ "image/jpeg"
+	"os"
+	"sync"
+	"time"
 
-	"github.com/faiface/pixel/pixelgl"
-	"golang.org/x/image/colornames"
 	"saitek-controller/internal/usb"
 )
 
-// FIPPanel represents a Flight Instrument Panel
+// FIPPanel represents a Flight Instrument Panel. Frames are rendered
+// off-screen and handed to any number of sinks, so the panel runs headless
+// unless a preview window sink is attached.
 type FIPPanel struct {
 	device     *usb.Device
-	display    *usb.FIPDisplay
 	connected  bool
 	width      int
 	height     int
 	title      string
 	instrument Instrument
+	config     InstrumentConfig
+	gauge      DataRenderer
 	vendorID   uint16
 	productID  uint16
+
+	mu        sync.Mutex
+	sinks     []Sink
+	frame     image.Image
+	inputs    chan InputEvent
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // Instrument represents different types of flight instruments
@@ -43,56 +53,54 @@ type InstrumentData struct {
 	// Artificial Horizon
 	Pitch float64 // degrees
 	Roll  float64 // degrees
-	
+
 	// Airspeed
 	Airspeed float64 // knots
-	
+
 	// Altimeter
 	Altitude float64 // feet
 	Pressure float64 // inHg
-	
+
 	// Compass
-	Heading float64 // degrees
-	
+	Heading    float64 // degrees
+	HeadingBug float64 // selected heading, degrees
+
 	// Vertical Speed
 	VerticalSpeed float64 // feet per minute
-	
+
 	// Turn Coordinator
 	TurnRate float64 // degrees per second
 	Slip     float64 // degrees
+
+	// Position, for the moving map
+	Latitude  float64 // degrees, north positive
+	Longitude float64 // degrees, east positive
+
+	// Fields whose data has stopped, drawn with a failure flag
+	Failed FieldMask
 }
 
-// NewFIPPanel creates a new FIP panel
+// NewFIPPanel creates a new headless FIP panel
 func NewFIPPanel(title string, width, height int) (*FIPPanel, error) {
-	display, err := usb.NewFIPDisplay(title, width, height)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create FIP display: %w", err)
-	}
-
-	return &FIPPanel{
-		display:   display,
-		width:     width,
-		height:    height,
-		title:     title,
-		instrument: InstrumentCustom,
-	}, nil
+	return NewFIPPanelWithUSB(title, width, height, 0x06A3, 0xA2AE)
 }
 
-// NewFIPPanelWithUSB creates a new FIP panel with custom vendor/product IDs
+// NewFIPPanelWithUSB creates a new headless FIP panel with custom vendor/product IDs
 func NewFIPPanelWithUSB(title string, width, height int, vendorID, productID uint16) (*FIPPanel, error) {
-	display, err := usb.NewFIPDisplay(title, width, height)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create FIP display: %w", err)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid FIP size: %dx%d", width, height)
 	}
 
 	return &FIPPanel{
-		display:   display,
-		width:     width,
-		height:    height,
-		title:     title,
+		width:      width,
+		height:     height,
+		title:      title,
 		instrument: InstrumentCustom,
-		vendorID:  vendorID,
-		productID: productID,
+		config:     DefaultInstrumentConfig(),
+		vendorID:   vendorID,
+		productID:  productID,
+		inputs:     make(chan InputEvent, 10),
+		done:       make(chan struct{}),
 	}, nil
 }
 
@@ -133,12 +141,30 @@ func (f *FIPPanel) GetName() string {
 	return f.title
 }
 
-// GetWindow returns the display window
-func (f *FIPPanel) GetWindow() *pixelgl.Window {
-	if f.display != nil {
-		return f.display.Window
+// AddSink attaches a sink that receives every displayed frame
+func (f *FIPPanel) AddSink(sink Sink) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sinks = append(f.sinks, sink)
+}
+
+// RemoveSink detaches a sink without closing it
+func (f *FIPPanel) RemoveSink(sink Sink) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, s := range f.sinks {
+		if s == sink {
+			f.sinks = append(f.sinks[:i], f.sinks[i+1:]...)
+			return
+		}
 	}
-	return nil
+}
+
+// Frame returns the most recently displayed frame, or nil
+func (f *FIPPanel) Frame() image.Image {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frame
 }
 
 // SetInstrument sets the type of instrument to display
@@ -146,207 +172,90 @@ func (f *FIPPanel) SetInstrument(instrument Instrument) {
 	f.instrument = instrument
 }
 
-// DisplayImage displays an image on the FIP
-func (f *FIPPanel) DisplayImage(img image.Image) error {
-	return f.display.DisplayImage(img)
+// SetGauge makes DisplayInstrument draw with a custom gauge instead of the
+// built-in instrument. A nil gauge restores the built-in instrument.
+func (f *FIPPanel) SetGauge(gauge DataRenderer) {
+	f.gauge = gauge
 }
 
-// DisplayImageFromFile loads and displays an image from file
-func (f *FIPPanel) DisplayImageFromFile(filename string) error {
-	return f.display.DisplayImageFromFile(filename)
+// SetInstrumentConfig sets the V-speeds and pressure unit used by the instruments
+func (f *FIPPanel) SetInstrumentConfig(config InstrumentConfig) error {
+	if err := config.VSpeeds.Validate(); err != nil {
+		return fmt.Errorf("invalid V-speeds: %w", err)
+	}
+	f.config = config
+	return nil
 }
 
-// DisplayInstrument displays an instrument with the given data
-func (f *FIPPanel) DisplayInstrument(data InstrumentData) error {
-	var img image.Image
-
-	switch f.instrument {
-	case InstrumentArtificialHorizon:
-		img = f.createArtificialHorizon(data.Pitch, data.Roll)
-	case InstrumentAirspeed:
-		img = f.createAirspeedIndicator(data.Airspeed)
-	case InstrumentAltimeter:
-		img = f.createAltimeter(data.Altitude, data.Pressure)
-	case InstrumentCompass:
-		img = f.createCompass(data.Heading)
-	case InstrumentVerticalSpeed:
-		img = f.createVerticalSpeedIndicator(data.VerticalSpeed)
-	case InstrumentTurnCoordinator:
-		img = f.createTurnCoordinator(data.TurnRate, data.Slip)
-	default:
-		img = f.createTestPattern()
+// DisplayImage sends an image to every sink, scaling it to the panel size if needed
+func (f *FIPPanel) DisplayImage(img image.Image) error {
+	if img.Bounds().Dx() != f.width || img.Bounds().Dy() != f.height {
+		s := NewSurface(f.width, f.height)
+		s.DrawImage(img)
+		img = s.Image()
 	}
 
-	return f.DisplayImage(img)
-}
+	f.mu.Lock()
+	f.frame = img
+	sinks := append([]Sink(nil), f.sinks...)
+	f.mu.Unlock()
 
-// createArtificialHorizon creates an artificial horizon instrument
-func (f *FIPPanel) createArtificialHorizon(pitch, roll float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to blue (sky)
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Skyblue}, image.Point{}, draw.Src)
-	
-	// Calculate center
-	centerX := f.width / 2
-	centerY := f.height / 2
-	
-	// Draw brown earth (bottom half)
-	earthRect := image.Rect(0, centerY, f.width, f.height)
-	draw.Draw(img, earthRect, &image.Uniform{colornames.Saddlebrown}, image.Point{}, draw.Src)
-	
-	// Apply roll rotation
-	rollRad := roll * math.Pi / 180
-	cosRoll := math.Cos(rollRad)
-	sinRoll := math.Sin(rollRad)
-	
-	// Draw horizon line with roll
-	for x := 0; x < f.width; x++ {
-		// Calculate rotated position
-		relX := float64(x - centerX)
-		relY := float64(centerY) + pitch*2 // Simple pitch representation
-		
-		rotX := relX*cosRoll - relY*sinRoll + float64(centerX)
-		rotY := relX*sinRoll + relY*cosRoll + float64(centerY)
-		
-		if rotY >= 0 && rotY < float64(f.height) {
-			img.Set(int(rotX), int(rotY), colornames.White)
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.WriteFrame(img); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to write frame: %w", err)
 		}
 	}
-	
-	return img
+	return firstErr
 }
 
-// createAirspeedIndicator creates an airspeed indicator
-func (f *FIPPanel) createAirspeedIndicator(airspeed float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	centerX := f.width / 2
-	centerY := f.height / 2
-	radius := f.width / 3
-	
-	// Draw gauge background
-	for y := 0; y < f.height; y++ {
-		for x := 0; x < f.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-			
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
+// DisplayImageFromFile loads and displays an image from file
+func (f *FIPPanel) DisplayImageFromFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open image file: %w", err)
 	}
-	
-	// Draw airspeed text
-	// Note: In a real implementation, you'd use a proper font rendering library
-	// For now, we'll just draw a simple representation
-	_ = fmt.Sprintf("%.0f", airspeed)
-	
-	return img
-}
+	defer file.Close()
 
-// createAltimeter creates an altimeter
-func (f *FIPPanel) createAltimeter(altitude, pressure float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	// Draw altimeter face
-	centerX := f.width / 2
-	centerY := f.height / 2
-	radius := f.width / 3
-	
-	// Draw gauge background
-	for y := 0; y < f.height; y++ {
-		for x := 0; x < f.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-			
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
 	}
-	
-	return img
+
+	return f.DisplayImage(img)
 }
 
-// createCompass creates a compass
-func (f *FIPPanel) createCompass(heading float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	centerX := f.width / 2
-	centerY := f.height / 2
-	radius := f.width / 3
-	
-	// Draw compass face
-	for y := 0; y < f.height; y++ {
-		for x := 0; x < f.width; x++ {
-			dx := x - centerX
-			dy := y - centerY
-			distance := math.Sqrt(float64(dx*dx + dy*dy))
-			
-			if distance <= float64(radius) {
-				img.Set(x, y, colornames.Darkgray)
-			}
-		}
+// Render draws a frame with the renderer and displays it
+func (f *FIPPanel) Render(r Renderer) error {
+	s := NewSurface(f.width, f.height)
+	if err := r.Render(s); err != nil {
+		return err
 	}
-	
-	return img
+	return f.DisplayImage(s.Image())
 }
 
-// createVerticalSpeedIndicator creates a vertical speed indicator
-func (f *FIPPanel) createVerticalSpeedIndicator(vs float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	// Draw vertical speed gauge
-	centerX := f.width / 2
-	centerY := f.height / 2
-	
-	// Simple vertical speed representation
-	vsHeight := int(vs / 1000 * float64(f.height/2)) // Scale to reasonable range
-	vsY := centerY + vsHeight
-	
-	if vsY >= 0 && vsY < f.height {
-		for x := centerX - 20; x <= centerX + 20; x++ {
-			if x >= 0 && x < f.width {
-				img.Set(x, vsY, colornames.White)
-			}
+// DisplayInstrument displays an instrument with the given data
+func (f *FIPPanel) DisplayInstrument(data InstrumentData) error {
+	s := NewSurface(f.width, f.height)
+	if f.gauge != nil {
+		if err := f.gauge.RenderData(s, data); err != nil {
+			return fmt.Errorf("failed to render gauge: %w", err)
 		}
+		return f.DisplayImage(s.Image())
 	}
-	
-	return img
-}
 
-// createTurnCoordinator creates a turn coordinator
-func (f *FIPPanel) createTurnCoordinator(turnRate, slip float64) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
-	// Clear to black
-	draw.Draw(img, img.Bounds(), &image.Uniform{colornames.Black}, image.Point{}, draw.Src)
-	
-	// Draw turn coordinator representation
-	// This is a simplified version - a real turn coordinator is more complex
-	
-	return img
+	r := InstrumentRenderer{Instrument: f.instrument, Data: data, Config: f.config}
+	if err := r.Render(s); err != nil {
+		return f.DisplayImage(f.createTestPattern())
+	}
+
+	return f.DisplayImage(s.Image())
 }
 
 // createTestPattern creates a test pattern
 func (f *FIPPanel) createTestPattern() image.Image {
 	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
-	
+
 	// Create a test pattern
 	for y := 0; y < f.height; y++ {
 		for x := 0; x < f.width; x++ {
@@ -356,19 +265,55 @@ func (f *FIPPanel) createTestPattern() image.Image {
 			img.Set(x, y, color.RGBA{r, g, b, 255})
 		}
 	}
-	
+
 	return img
 }
 
-// Run starts the FIP display loop
+// Inputs returns the channel of input events for the panel
+func (f *FIPPanel) Inputs() <-chan InputEvent {
+	return f.inputs
+}
+
+// SendInput queues an input event, such as a click on a preview of the
+// panel. Events are dropped if nobody is reading them.
+func (f *FIPPanel) SendInput(event InputEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	select {
+	case f.inputs <- event:
+	default:
+	}
+}
+
+// Run blocks until the panel is closed. If a sink has its own event loop,
+// such as a preview window, that loop is run on the calling goroutine.
 func (f *FIPPanel) Run() {
-	f.display.Run()
+	f.mu.Lock()
+	sinks := append([]Sink(nil), f.sinks...)
+	f.mu.Unlock()
+
+	for _, sink := range sinks {
+		if runner, ok := sink.(interface{ Run() }); ok {
+			runner.Run()
+			return
+		}
+	}
+	<-f.done
 }
 
-// Close closes the FIP panel
+// Close closes the FIP panel and all of its sinks
 func (f *FIPPanel) Close() {
-	if f.display != nil {
-		f.display.Close()
-	}
+	f.closeOnce.Do(func() {
+		f.mu.Lock()
+		sinks := f.sinks
+		f.sinks = nil
+		f.mu.Unlock()
+
+		for _, sink := range sinks {
+			sink.Close()
+		}
+		close(f.done)
+	})
 	f.Disconnect()
-} 
\ No newline at end of file
+}
@@ -0,0 +1,58 @@
+package fip
+
+import "saitek-controller/internal/usb"
+
+// usbInstance returns the instance of an open USB device, or the zero
+// instance if there is none or it can't name itself
+func usbInstance(device interface{}) DeviceInstance {
+	identified, ok := device.(usb.Identified)
+	if !ok {
+		return DeviceInstance{}
+	}
+	id := identified.Identity()
+	return NewDeviceInstance(id.VendorID, id.ProductID, id.Serial, id.Path)
+}
+
+// Instance returns the instance of the connected FIP
+func (f *FIPDirect) Instance() DeviceInstance {
+	if !f.IsConnected() {
+		return DeviceInstance{}
+	}
+	return NewDeviceInstance(f.info.VendorID, f.info.ProductID, f.info.Serial, f.info.Path)
+}
+
+// Instance returns the instance of the connected FIP
+func (f *FIPUSB) Instance() DeviceInstance {
+	if !f.IsConnected() {
+		return DeviceInstance{}
+	}
+	return usbInstance(f.device)
+}
+
+// Instance returns the instance of the connected FIP
+func (f *FIPPanel) Instance() DeviceInstance {
+	if f.device == nil {
+		return DeviceInstance{}
+	}
+	return usbInstance(f.device)
+}
+
+// Instance returns the instance of the connected radio panel
+func (r *RadioPanel) Instance() DeviceInstance {
+	return usbInstance(r.device)
+}
+
+// Instance returns the instance of the connected multi panel
+func (m *MultiPanel) Instance() DeviceInstance {
+	return usbInstance(m.device)
+}
+
+// Instance returns the instance of the connected switch panel
+func (s *SwitchPanel) Instance() DeviceInstance {
+	return usbInstance(s.device)
+}
+
+// Instance returns the instance of the X52 Pro
+func (x *X52ProUSB) Instance() DeviceInstance {
+	return usbInstance(x.device)
+}
@@ -0,0 +1,469 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"sync"
+	"time"
+
+	"saitek-controller/internal/text"
+)
+
+// PanelSnapshot captures the physical positions of the panel controls that
+// have to agree with the sim when a flight is loaded
+type PanelSnapshot struct {
+	Switch        *SwitchState // nil when the switch panel is not part of the snapshot
+	MultiSelector string       // ALT, VS, IAS, HDG or CRS; empty when unknown
+	Taken         time.Time
+}
+
+// SimSource provides the sim's current control positions and accepts the
+// hardware positions when the hardware should win
+type SimSource interface {
+	TargetState() (PanelSnapshot, error)
+	ApplyHardwareState(snapshot PanelSnapshot) error
+}
+
+// SwitchStateReader reads the switch panel positions, such as SwitchPanel
+type SwitchStateReader interface {
+	IsConnected() bool
+	GetSwitchState() (*SwitchState, error)
+}
+
+// SelectorReader reads the multi panel selector position, such as MultiPanel
+type SelectorReader interface {
+	IsConnected() bool
+	GetSelector() (string, error)
+}
+
+// ImageDisplay is anything that can show a full frame, such as FIPPanel
+type ImageDisplay interface {
+	DisplayImage(img image.Image) error
+}
+
+// SyncMode selects how differences between hardware and sim are resolved
+type SyncMode int
+
+const (
+	SyncModePushToSim    SyncMode = iota // Send the hardware positions to the sim
+	SyncModeWaitForPilot                 // Show mismatches until the pilot corrects the switches
+)
+
+// String returns the name of the sync mode
+func (m SyncMode) String() string {
+	switch m {
+	case SyncModePushToSim:
+		return "push"
+	case SyncModeWaitForPilot:
+		return "wait"
+	default:
+		return fmt.Sprintf("SyncMode(%d)", int(m))
+	}
+}
+
+// ParseSyncMode parses the name returned by SyncMode.String
+func ParseSyncMode(name string) (SyncMode, error) {
+	switch name {
+	case "push":
+		return SyncModePushToSim, nil
+	case "wait":
+		return SyncModeWaitForPilot, nil
+	default:
+		return 0, fmt.Errorf("unknown sync mode: %s", name)
+	}
+}
+
+// Mismatch describes a single control whose hardware position differs from the sim
+type Mismatch struct {
+	Panel    string `json:"panel"`
+	Control  string `json:"control"`
+	Hardware string `json:"hardware"`
+	Sim      string `json:"sim"`
+}
+
+// SyncResult is the outcome of one synchronization pass
+type SyncResult struct {
+	Hardware   PanelSnapshot
+	Target     PanelSnapshot
+	Mismatches []Mismatch
+	Pushed     bool // Hardware positions were sent to the sim
+	Time       time.Time
+}
+
+// InSync returns whether hardware and sim agreed (or were made to agree)
+func (r SyncResult) InSync() bool {
+	return r.Pushed || len(r.Mismatches) == 0
+}
+
+// switchControl maps a switch panel control name to its position
+type switchControl struct {
+	name     string
+	position func(s *SwitchState) string
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// switchControls lists the switch panel controls compared during sync
+var switchControls = []switchControl{
+	{"BAT", func(s *SwitchState) string { return onOff(s.BAT) }},
+	{"ALT", func(s *SwitchState) string { return onOff(s.ALT) }},
+	{"AVIONICS", func(s *SwitchState) string { return onOff(s.AVIONICS) }},
+	{"FUEL", func(s *SwitchState) string { return onOff(s.FUEL) }},
+	{"DEICE", func(s *SwitchState) string { return onOff(s.DEICE) }},
+	{"PITOT", func(s *SwitchState) string { return onOff(s.PITOT) }},
+	{"COWL", func(s *SwitchState) string { return onOff(s.COWL) }},
+	{"PANEL", func(s *SwitchState) string { return onOff(s.PANEL) }},
+	{"BEACON", func(s *SwitchState) string { return onOff(s.BEACON) }},
+	{"NAV", func(s *SwitchState) string { return onOff(s.NAV) }},
+	{"STROBE", func(s *SwitchState) string { return onOff(s.STROBE) }},
+	{"TAXI", func(s *SwitchState) string { return onOff(s.TAXI) }},
+	{"LANDING", func(s *SwitchState) string { return onOff(s.LANDING) }},
+	{"MAGNETO", magnetoPosition},
+	{"GEAR", gearPosition},
+}
+
+// magnetoPosition returns the position of the magneto rotary switch
+func magnetoPosition(s *SwitchState) string {
+	switch {
+	case s.START:
+		return "START"
+	case s.BOTH:
+		return "BOTH"
+	case s.L:
+		return "L"
+	case s.R:
+		return "R"
+	default:
+		return "OFF"
+	}
+}
+
+// gearPosition returns the position of the landing gear lever
+func gearPosition(s *SwitchState) string {
+	switch {
+	case s.GEARDOWN:
+		return "DOWN"
+	case s.GEARUP:
+		return "UP"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// multiSelectorPositions lists the multi panel selector positions in knob order
+var multiSelectorPositions = []string{"ALT", "VS", "IAS", "HDG", "CRS"}
+
+// CompareSnapshots lists the controls whose hardware position differs from the target.
+// Controls the target does not know about are skipped.
+func CompareSnapshots(hardware, target PanelSnapshot) []Mismatch {
+	var mismatches []Mismatch
+
+	if hardware.Switch != nil && target.Switch != nil {
+		for _, control := range switchControls {
+			hw := control.position(hardware.Switch)
+			sim := control.position(target.Switch)
+			if hw != sim {
+				mismatches = append(mismatches, Mismatch{
+					Panel:    "switch",
+					Control:  control.name,
+					Hardware: hw,
+					Sim:      sim,
+				})
+			}
+		}
+	}
+
+	if hardware.MultiSelector != "" && target.MultiSelector != "" &&
+		hardware.MultiSelector != target.MultiSelector {
+		mismatches = append(mismatches, Mismatch{
+			Panel:    "multi",
+			Control:  "SELECTOR",
+			Hardware: hardware.MultiSelector,
+			Sim:      target.MultiSelector,
+		})
+	}
+
+	return mismatches
+}
+
+// SyncService keeps the physical panel positions and the sim in agreement
+type SyncService struct {
+	source      SimSource
+	switchPanel SwitchStateReader
+	multiPanel  SelectorReader
+	display     ImageDisplay
+	mode        SyncMode
+
+	mu        sync.RWMutex
+	last      SyncResult
+	showing   bool // the display shows a mismatch list
+	listeners []func(SyncResult)
+	stopChan  chan struct{}
+}
+
+// NewSyncService creates a sync service; either panel may be nil
+func NewSyncService(source SimSource, switchPanel SwitchStateReader, multiPanel SelectorReader) *SyncService {
+	return &SyncService{
+		source:      source,
+		switchPanel: switchPanel,
+		multiPanel:  multiPanel,
+		mode:        SyncModeWaitForPilot,
+	}
+}
+
+// SetMode sets how mismatches are resolved
+func (s *SyncService) SetMode(mode SyncMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+}
+
+// GetMode returns how mismatches are resolved
+func (s *SyncService) GetMode() SyncMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mode
+}
+
+// SetDisplay sets the FIP used to show the mismatch list; nil disables it.
+// Once the mismatches are resolved the list is replaced by an in-sync frame.
+func (s *SyncService) SetDisplay(display ImageDisplay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.display = display
+	s.showing = false
+}
+
+// OnResult registers a callback invoked after every sync pass
+func (s *SyncService) OnResult(callback func(SyncResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, callback)
+}
+
+// Snapshot reads the current hardware positions from the connected panels
+func (s *SyncService) Snapshot() (PanelSnapshot, error) {
+	snapshot := PanelSnapshot{Taken: time.Now()}
+
+	if s.switchPanel != nil && s.switchPanel.IsConnected() {
+		state, err := s.switchPanel.GetSwitchState()
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to read switch panel: %w", err)
+		}
+		snapshot.Switch = state
+	}
+
+	if s.multiPanel != nil && s.multiPanel.IsConnected() {
+		selector, err := s.multiPanel.GetSelector()
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to read multi panel: %w", err)
+		}
+		snapshot.MultiSelector = selector
+	}
+
+	return snapshot, nil
+}
+
+// Sync compares the hardware with the sim once and resolves the differences
+// according to the current mode
+func (s *SyncService) Sync() (SyncResult, error) {
+	hardware, err := s.Snapshot()
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	target, err := s.source.TargetState()
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to read sim state: %w", err)
+	}
+
+	result := SyncResult{
+		Hardware:   hardware,
+		Target:     target,
+		Mismatches: CompareSnapshots(hardware, target),
+		Time:       time.Now(),
+	}
+
+	if len(result.Mismatches) > 0 && s.GetMode() == SyncModePushToSim {
+		if err := s.source.ApplyHardwareState(hardware); err != nil {
+			return result, fmt.Errorf("failed to push hardware state to sim: %w", err)
+		}
+		result.Pushed = true
+		log.Printf("Sync: pushed %d hardware positions to sim", len(result.Mismatches))
+	}
+
+	s.mu.Lock()
+	s.last = result
+	display := s.display
+	// Draw while there are mismatches, and once more to replace the list
+	redraw := display != nil && (!result.InSync() || s.showing)
+	if display != nil {
+		s.showing = !result.InSync()
+	}
+	listeners := append([]func(SyncResult){}, s.listeners...)
+	s.mu.Unlock()
+
+	if redraw {
+		var remaining []Mismatch
+		if !result.InSync() {
+			remaining = result.Mismatches
+		}
+		if err := display.DisplayImage(RenderMismatchList(320, 240, remaining)); err != nil {
+			log.Printf("Sync: failed to display mismatch list: %v", err)
+		}
+	}
+
+	for _, listener := range listeners {
+		listener(result)
+	}
+
+	return result, nil
+}
+
+// LastResult returns the result of the most recent sync pass
+func (s *SyncService) LastResult() SyncResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+// Mismatches returns the mismatches found by the most recent sync pass
+func (s *SyncService) Mismatches() []Mismatch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.last.Pushed {
+		return nil
+	}
+	return append([]Mismatch{}, s.last.Mismatches...)
+}
+
+// Start syncs immediately (connect time) and, while mismatches remain, keeps
+// re-checking at the given interval until the pilot has corrected the switches
+func (s *SyncService) Start(interval time.Duration) {
+	s.Stop()
+
+	stopChan := make(chan struct{})
+	s.mu.Lock()
+	s.stopChan = stopChan
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			result, err := s.Sync()
+			if err != nil {
+				log.Printf("Sync failed: %v", err)
+			} else if result.InSync() {
+				log.Printf("Sync: panels match the sim")
+				return
+			}
+
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops a sync loop started with Start
+func (s *SyncService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+// RenderMismatchList draws the list of controls the pilot still has to move
+func RenderMismatchList(width, height int, mismatches []Mismatch) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{20, 20, 40, 255}}, image.Point{}, draw.Src)
+
+	face := text.Fixed()
+	lineHeight := face.Metrics().Height.Ceil() + 2
+
+	drawLine := func(s string, y int, c color.Color) {
+		text.Draw(img, s, 8, float64(y), text.Style{Face: face, Color: c})
+	}
+
+	y := lineHeight + 4
+	if len(mismatches) == 0 {
+		drawLine("PANELS MATCH SIM", y, color.RGBA{0, 255, 0, 255})
+		return img
+	}
+
+	drawLine(fmt.Sprintf("SET SWITCHES (%d)", len(mismatches)), y, color.RGBA{255, 200, 0, 255})
+	y += lineHeight + 4
+
+	for i, m := range mismatches {
+		if y > height-lineHeight {
+			drawLine(fmt.Sprintf("... %d more", len(mismatches)-i), y, color.RGBA{255, 200, 0, 255})
+			break
+		}
+		drawLine(fmt.Sprintf("%-9s %-5s -> %s", m.Control, m.Hardware, m.Sim), y, color.White)
+		y += lineHeight
+	}
+
+	return img
+}
+
+// MemorySimSource is a SimSource backed by in-memory state; a sim bridge
+// updates the target with SetTarget and reads pushed positions with Applied
+type MemorySimSource struct {
+	mu      sync.RWMutex
+	target  PanelSnapshot
+	applied *PanelSnapshot
+}
+
+// NewMemorySimSource creates an empty in-memory sim source
+func NewMemorySimSource() *MemorySimSource {
+	return &MemorySimSource{}
+}
+
+// SetTarget sets the sim state the hardware is compared against
+func (m *MemorySimSource) SetTarget(target PanelSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.target = target
+}
+
+// TargetState returns the sim state
+func (m *MemorySimSource) TargetState() (PanelSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.target, nil
+}
+
+// ApplyHardwareState records the hardware state and adopts it as the sim state
+func (m *MemorySimSource) ApplyHardwareState(snapshot PanelSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applied = &snapshot
+	if snapshot.Switch != nil {
+		m.target.Switch = snapshot.Switch
+	}
+	if snapshot.MultiSelector != "" {
+		m.target.MultiSelector = snapshot.MultiSelector
+	}
+	return nil
+}
+
+// Applied returns the last hardware state pushed to the sim, or nil
+func (m *MemorySimSource) Applied() *PanelSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.applied
+}
@@ -0,0 +1,215 @@
+package fip
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSwitchPanel is a switch panel whose positions the test sets
+type fakeSwitchPanel struct {
+	mu    sync.Mutex
+	state SwitchState
+}
+
+func (f *fakeSwitchPanel) IsConnected() bool { return true }
+
+func (f *fakeSwitchPanel) GetSwitchState() (*SwitchState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.state
+	return &state, nil
+}
+
+func (f *fakeSwitchPanel) set(state SwitchState) {
+	f.mu.Lock()
+	f.state = state
+	f.mu.Unlock()
+}
+
+// fakeMultiPanel is a multi panel with a fixed selector position
+type fakeMultiPanel struct {
+	selector string
+}
+
+func (f *fakeMultiPanel) IsConnected() bool { return true }
+
+func (f *fakeMultiPanel) GetSelector() (string, error) { return f.selector, nil }
+
+// fakeDisplay records the frames shown
+type fakeDisplay struct {
+	mu     sync.Mutex
+	frames []image.Image
+}
+
+func (f *fakeDisplay) DisplayImage(img image.Image) error {
+	f.mu.Lock()
+	f.frames = append(f.frames, img)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeDisplay) shown() []image.Image {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]image.Image(nil), f.frames...)
+}
+
+// samePixels reports whether two frames drawn by RenderMismatchList match
+func samePixels(a, b image.Image) bool {
+	return bytes.Equal(a.(*image.RGBA).Pix, b.(*image.RGBA).Pix)
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	hardware := PanelSnapshot{
+		Switch:        &SwitchState{BAT: true, BOTH: true, GEARDOWN: true},
+		MultiSelector: "ALT",
+	}
+	target := PanelSnapshot{
+		Switch:        &SwitchState{BAT: true, AVIONICS: true, OFF: true, GEARDOWN: true},
+		MultiSelector: "HDG",
+	}
+
+	mismatches := CompareSnapshots(hardware, target)
+	if len(mismatches) != 3 {
+		t.Fatalf("Expected 3 mismatches, got %d: %+v", len(mismatches), mismatches)
+	}
+
+	expected := []Mismatch{
+		{Panel: "switch", Control: "AVIONICS", Hardware: "OFF", Sim: "ON"},
+		{Panel: "switch", Control: "MAGNETO", Hardware: "BOTH", Sim: "OFF"},
+		{Panel: "multi", Control: "SELECTOR", Hardware: "ALT", Sim: "HDG"},
+	}
+	for i, m := range expected {
+		if mismatches[i] != m {
+			t.Errorf("Mismatch %d: expected %+v, got %+v", i, m, mismatches[i])
+		}
+	}
+}
+
+func TestCompareSnapshotsSkipsUnknownControls(t *testing.T) {
+	hardware := PanelSnapshot{Switch: &SwitchState{BAT: true}, MultiSelector: "VS"}
+
+	if mismatches := CompareSnapshots(hardware, PanelSnapshot{}); len(mismatches) != 0 {
+		t.Errorf("Expected no mismatches against an empty target, got %+v", mismatches)
+	}
+}
+
+func TestMemorySimSourceApplyHardwareState(t *testing.T) {
+	source := NewMemorySimSource()
+	source.SetTarget(PanelSnapshot{MultiSelector: "IAS"})
+
+	hardware := PanelSnapshot{Switch: &SwitchState{BAT: true}, MultiSelector: "ALT"}
+	if err := source.ApplyHardwareState(hardware); err != nil {
+		t.Fatalf("Failed to apply hardware state: %v", err)
+	}
+
+	target, _ := source.TargetState()
+	if mismatches := CompareSnapshots(hardware, target); len(mismatches) != 0 {
+		t.Errorf("Expected sim to match hardware after push, got %+v", mismatches)
+	}
+	if source.Applied() == nil {
+		t.Error("Expected applied state to be recorded")
+	}
+}
+
+func TestParseSyncMode(t *testing.T) {
+	for _, mode := range []SyncMode{SyncModePushToSim, SyncModeWaitForPilot} {
+		parsed, err := ParseSyncMode(mode.String())
+		if err != nil || parsed != mode {
+			t.Errorf("Round trip of %v failed: got %v, %v", mode, parsed, err)
+		}
+	}
+
+	if _, err := ParseSyncMode("bogus"); err == nil {
+		t.Error("Expected error for unknown sync mode")
+	}
+}
+
+func TestSyncPushToSim(t *testing.T) {
+	source := NewMemorySimSource()
+	source.SetTarget(PanelSnapshot{Switch: &SwitchState{OFF: true, GEARDOWN: true}, MultiSelector: "HDG"})
+	switchPanel := &fakeSwitchPanel{state: SwitchState{BAT: true, BOTH: true, GEARDOWN: true}}
+	display := &fakeDisplay{}
+
+	service := NewSyncService(source, switchPanel, &fakeMultiPanel{selector: "ALT"})
+	service.SetMode(SyncModePushToSim)
+	service.SetDisplay(display)
+
+	result, err := service.Sync()
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !result.Pushed || !result.InSync() || len(result.Mismatches) != 3 {
+		t.Errorf("Expected 3 mismatches pushed to the sim, got %+v", result)
+	}
+	applied := source.Applied()
+	if applied == nil || !applied.Switch.BAT || applied.MultiSelector != "ALT" {
+		t.Errorf("Expected the hardware state in the sim, got %+v", applied)
+	}
+	if len(service.Mismatches()) != 0 {
+		t.Errorf("Expected no mismatches after the push, got %+v", service.Mismatches())
+	}
+	if frames := display.shown(); len(frames) != 0 {
+		t.Errorf("Expected nothing drawn when the sim follows the hardware, got %d frames", len(frames))
+	}
+
+	result, err = service.Sync()
+	if err != nil || result.Pushed || len(result.Mismatches) != 0 {
+		t.Errorf("Expected the second pass to find nothing to push, got %+v, %v", result, err)
+	}
+}
+
+func TestSyncWaitForPilot(t *testing.T) {
+	source := NewMemorySimSource()
+	source.SetTarget(PanelSnapshot{Switch: &SwitchState{BAT: true, OFF: true, GEARDOWN: true}, MultiSelector: "ALT"})
+	switchPanel := &fakeSwitchPanel{state: SwitchState{OFF: true, GEARDOWN: true}}
+	display := &fakeDisplay{}
+
+	service := NewSyncService(source, switchPanel, &fakeMultiPanel{selector: "ALT"})
+	service.SetDisplay(display)
+	results := make(chan SyncResult, 100)
+	service.OnResult(func(result SyncResult) { results <- result })
+
+	service.Start(5 * time.Millisecond)
+	defer service.Stop()
+
+	first := <-results
+	if first.InSync() || len(first.Mismatches) != 1 || first.Mismatches[0].Control != "BAT" {
+		t.Fatalf("Expected the battery switch to mismatch, got %+v", first)
+	}
+
+	// The pilot flips the battery on; the loop ends on the next pass
+	switchPanel.set(SwitchState{BAT: true, OFF: true, GEARDOWN: true})
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case result := <-results:
+			if !result.InSync() {
+				continue
+			}
+		case <-deadline:
+			t.Fatal("Timed out waiting for the panels to match")
+		}
+		break
+	}
+
+	if source.Applied() != nil {
+		t.Error("Expected nothing pushed to the sim while waiting for the pilot")
+	}
+	frames := display.shown()
+	if len(frames) < 2 || !samePixels(frames[0], RenderMismatchList(320, 240, first.Mismatches)) {
+		t.Fatalf("Expected the mismatch list then an in-sync frame, got %d frames", len(frames))
+	}
+	if !samePixels(frames[len(frames)-1], RenderMismatchList(320, 240, nil)) {
+		t.Error("Expected the mismatch list to be replaced once the panels match")
+	}
+
+	// Nothing more is drawn while the panels stay in sync
+	service.Sync()
+	if n := len(display.shown()); n != len(frames) {
+		t.Errorf("Expected no redraw while in sync, got %d more frames", n-len(frames))
+	}
+}
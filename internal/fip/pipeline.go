@@ -0,0 +1,316 @@
+package fip
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultMaxFPS is a frame rate limit for FIP devices and the streams
+// mirroring them, above which frames are only dropped on the way
+const DefaultMaxFPS = 30
+
+// defaultTileSize is the size of the squares compared when looking for
+// changed parts of a frame
+const defaultTileSize = 16
+
+// RegionSink is a sink that can update only the changed parts of a frame
+type RegionSink interface {
+	Sink
+	// WriteRegions receives the whole frame and the rectangles that changed
+	// since the previous one. The first frame is dirty in full.
+	WriteRegions(frame image.Image, dirty []image.Rectangle) error
+}
+
+// PipelineOptions configures a FramePipeline
+type PipelineOptions struct {
+	MaxFPS   float64 // frames per second sent to the sink, 0 for no limit
+	TileSize int     // granularity of dirty rectangles in pixels, default 16
+}
+
+// FrameStats are frame counts and timings of a FramePipeline
+type FrameStats struct {
+	Submitted uint64        // frames written to the pipeline
+	Sent      uint64        // frames passed on to the sink
+	Skipped   uint64        // frames identical to the previous one
+	Dropped   uint64        // frames replaced by a newer one before sending
+	Errors    uint64        // failed sink writes
+	LastDiff  time.Duration // time to compare the last frame
+	LastWrite time.Duration // time the sink took for the last frame
+	AvgWrite  time.Duration
+	MaxWrite  time.Duration
+	FPS       float64 // smoothed rate of frames sent
+}
+
+// FramePipeline sits in front of a sink. It skips frames identical to the
+// previous one, passes dirty rectangles to sinks that can use them and
+// limits the frame rate. When frames arrive faster than the limit the latest
+// one wins and the others are dropped.
+type FramePipeline struct {
+	sink     Sink
+	interval time.Duration
+	tile     int
+
+	// sendMu serializes writes to the sink and guards previous
+	sendMu   sync.Mutex
+	previous *image.RGBA
+
+	mu         sync.Mutex
+	pending    *image.RGBA
+	stats      FrameStats
+	totalWrite time.Duration
+	lastSent   time.Time
+	lastTick   time.Time
+	err        error
+
+	wake      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewFramePipeline creates a pipeline that writes to sink
+func NewFramePipeline(sink Sink, options PipelineOptions) *FramePipeline {
+	p := &FramePipeline{
+		sink: sink,
+		tile: options.TileSize,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	if p.tile <= 0 {
+		p.tile = defaultTileSize
+	}
+	if options.MaxFPS > 0 {
+		p.interval = time.Duration(float64(time.Second) / options.MaxFPS)
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+// WriteFrame queues a frame. Without a frame rate limit it is sent before
+// WriteFrame returns; otherwise errors from earlier frames are returned.
+func (p *FramePipeline) WriteFrame(img image.Image) error {
+	frame := image.NewRGBA(img.Bounds())
+	draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	p.mu.Lock()
+	p.stats.Submitted++
+	if p.interval == 0 {
+		p.mu.Unlock()
+		return p.send(frame)
+	}
+
+	if p.pending != nil {
+		p.stats.Dropped++
+	}
+	p.pending = frame
+	err := p.err
+	p.err = nil
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// Stats returns the frame statistics so far
+func (p *FramePipeline) Stats() FrameStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Close sends any pending frame and closes the sink
+func (p *FramePipeline) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+
+		p.mu.Lock()
+		frame := p.pending
+		p.pending = nil
+		p.mu.Unlock()
+
+		if frame != nil {
+			err = p.send(frame)
+		}
+		if closeErr := p.sink.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// run sends pending frames no faster than the frame interval
+func (p *FramePipeline) run() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.wake:
+		}
+
+		p.mu.Lock()
+		wait := p.interval - time.Since(p.lastTick)
+		p.mu.Unlock()
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-p.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		// Take the frame only now, so frames written while waiting replace it
+		p.mu.Lock()
+		frame := p.pending
+		p.pending = nil
+		p.lastTick = time.Now()
+		p.mu.Unlock()
+
+		if frame == nil {
+			continue
+		}
+		if err := p.send(frame); err != nil {
+			log.Printf("Failed to write frame: %v", err)
+			p.mu.Lock()
+			p.err = err
+			p.mu.Unlock()
+		}
+	}
+}
+
+// send diffs a frame against the previous one and writes it if it changed
+func (p *FramePipeline) send(frame *image.RGBA) error {
+	p.sendMu.Lock()
+	defer p.sendMu.Unlock()
+
+	start := time.Now()
+	dirty := DiffFrames(p.previous, frame, p.tile)
+	diff := time.Since(start)
+
+	if len(dirty) == 0 {
+		p.mu.Lock()
+		p.stats.Skipped++
+		p.stats.LastDiff = diff
+		p.mu.Unlock()
+		return nil
+	}
+
+	start = time.Now()
+	var err error
+	if rs, ok := p.sink.(RegionSink); ok {
+		err = rs.WriteRegions(frame, dirty)
+	} else {
+		err = p.sink.WriteFrame(frame)
+	}
+	write := time.Since(start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.LastDiff = diff
+	if err != nil {
+		p.stats.Errors++
+		return err
+	}
+
+	// Only successful frames become the reference for the next diff
+	p.previous = frame
+	p.stats.Sent++
+	p.stats.LastWrite = write
+	p.totalWrite += write
+	p.stats.AvgWrite = p.totalWrite / time.Duration(p.stats.Sent)
+	if write > p.stats.MaxWrite {
+		p.stats.MaxWrite = write
+	}
+
+	now := time.Now()
+	if !p.lastSent.IsZero() {
+		if dt := now.Sub(p.lastSent).Seconds(); dt > 0 {
+			fps := 1 / dt
+			if p.stats.FPS == 0 {
+				p.stats.FPS = fps
+			} else {
+				p.stats.FPS += 0.1 * (fps - p.stats.FPS)
+			}
+		}
+	}
+	p.lastSent = now
+	return nil
+}
+
+// DiffFrames returns the rectangles that differ between two frames, made of
+// tile x tile squares merged into horizontal runs and then stacked
+// vertically. It returns nil for identical frames and the whole frame if
+// prev is nil or a different size.
+func DiffFrames(prev, next *image.RGBA, tile int) []image.Rectangle {
+	b := next.Bounds()
+	if b.Empty() {
+		return nil
+	}
+	if prev == nil || prev.Bounds() != b {
+		return []image.Rectangle{b}
+	}
+	if tile <= 0 {
+		tile = defaultTileSize
+	}
+
+	var done, open []image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y += tile {
+		y1 := min(y+tile, b.Max.Y)
+
+		// Changed tiles in this row, merged into runs
+		var row []image.Rectangle
+		for x := b.Min.X; x < b.Max.X; x += tile {
+			x1 := min(x+tile, b.Max.X)
+			if tileEqual(prev, next, x, y, x1, y1) {
+				continue
+			}
+			if n := len(row); n > 0 && row[n-1].Max.X == x {
+				row[n-1].Max.X = x1
+			} else {
+				row = append(row, image.Rect(x, y, x1, y1))
+			}
+		}
+
+		// Extend runs from the row above that cover the same columns
+		var still []image.Rectangle
+		for _, r := range row {
+			for i, o := range open {
+				if o.Min.X == r.Min.X && o.Max.X == r.Max.X {
+					r.Min.Y = o.Min.Y
+					open = append(open[:i], open[i+1:]...)
+					break
+				}
+			}
+			still = append(still, r)
+		}
+		done = append(done, open...)
+		open = still
+	}
+	return append(done, open...)
+}
+
+// tileEqual compares a rectangle of two frames with the same bounds
+func tileEqual(a, b *image.RGBA, x0, y0, x1, y1 int) bool {
+	n := (x1 - x0) * 4
+	for y := y0; y < y1; y++ {
+		i := a.PixOffset(x0, y)
+		j := b.PixOffset(x0, y)
+		if !bytes.Equal(a.Pix[i:i+n], b.Pix[j:j+n]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package fip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	"saitek-controller/internal/bmp"
+)
+
+// regionSink records the dirty rectangles it receives
+type regionSink struct {
+	MemorySink
+	mu    sync.Mutex
+	dirty [][]image.Rectangle
+}
+
+func (r *regionSink) WriteRegions(frame image.Image, dirty []image.Rectangle) error {
+	r.mu.Lock()
+	r.dirty = append(r.dirty, dirty)
+	r.mu.Unlock()
+	return r.WriteFrame(frame)
+}
+
+// testFrame returns a black 320x240 frame with a white square at x, y
+func testFrame(x, y int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	for dy := 0; dy < 8; dy++ {
+		for dx := 0; dx < 8; dx++ {
+			img.Set(x+dx, y+dy, color.White)
+		}
+	}
+	return img
+}
+
+func TestDiffFrames(t *testing.T) {
+	a := testFrame(0, 0)
+	if dirty := DiffFrames(nil, a, 16); len(dirty) != 1 || dirty[0] != a.Bounds() {
+		t.Errorf("Expected first frame dirty in full, got %v", dirty)
+	}
+	if dirty := DiffFrames(a, testFrame(0, 0), 16); dirty != nil {
+		t.Errorf("Expected identical frames to have no dirty rectangles, got %v", dirty)
+	}
+
+	// Moving the square dirties the tiles it left and entered
+	dirty := DiffFrames(a, testFrame(20, 0), 16)
+	want := []image.Rectangle{image.Rect(0, 0, 32, 16)}
+	if fmt.Sprint(dirty) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, dirty)
+	}
+
+	// A square inside one tile dirties only that tile
+	b := testFrame(0, 0)
+	for dy := 0; dy < 8; dy++ {
+		for dx := 0; dx < 8; dx++ {
+			b.Set(100+dx, 100+dy, color.White)
+		}
+	}
+	dirty = DiffFrames(a, b, 16)
+	want = []image.Rectangle{image.Rect(96, 96, 112, 112)}
+	if fmt.Sprint(dirty) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, dirty)
+	}
+	// Runs covering the same columns are stacked, others stay separate
+	b.Set(100, 120, color.White)
+	b.Set(130, 120, color.White)
+	if dirty := DiffFrames(a, b, 16); fmt.Sprint(dirty) != "[(96,96)-(112,128) (128,112)-(144,128)]" {
+		t.Errorf("Unexpected dirty rectangles %v", dirty)
+	}
+}
+
+func TestPipelineSkipsIdenticalFrames(t *testing.T) {
+	sink := &regionSink{}
+	p := NewFramePipeline(sink, PipelineOptions{})
+
+	for _, x := range []int{0, 0, 16, 16, 0} {
+		if err := p.WriteFrame(testFrame(x, 0)); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	p.Close()
+
+	stats := p.Stats()
+	if stats.Submitted != 5 || stats.Sent != 3 || stats.Skipped != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(sink.dirty) != 3 || len(sink.dirty[1]) != 1 {
+		t.Fatalf("Expected dirty rectangles for 3 frames, got %v", sink.dirty)
+	}
+	if sink.dirty[1][0] != image.Rect(0, 0, 32, 16) {
+		t.Errorf("Unexpected dirty rectangle %v", sink.dirty[1][0])
+	}
+}
+
+// bufferDevice records the frame buffers sent to it
+type bufferDevice struct {
+	buffers [][]byte
+	fail    bool
+}
+
+func (d *bufferDevice) SendImage(img image.Image) error {
+	return d.SendFrameBuffer(bmp.FIPBuffer(img))
+}
+
+func (d *bufferDevice) SendFrameBuffer(data []byte) error {
+	if d.fail {
+		return errors.New("device gone")
+	}
+	d.buffers = append(d.buffers, append([]byte(nil), data...))
+	return nil
+}
+
+func TestPipelineDeviceSink(t *testing.T) {
+	device := &bufferDevice{}
+	p := NewFramePipeline(NewDeviceSink(device), PipelineOptions{})
+	defer p.Close()
+
+	// Each buffer sent must match the whole frame, though only the dirty
+	// rectangles were converted
+	frames := []*image.RGBA{testFrame(0, 0), testFrame(40, 40), testFrame(40, 40), testFrame(300, 200)}
+	for _, frame := range frames {
+		if err := p.WriteFrame(frame); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	if len(device.buffers) != 3 {
+		t.Fatalf("Expected 3 buffers, identical frames skipped, got %d", len(device.buffers))
+	}
+	for i, frame := range []*image.RGBA{frames[0], frames[1], frames[3]} {
+		if !bytes.Equal(device.buffers[i], bmp.FIPBuffer(frame)) {
+			t.Errorf("Buffer %d doesn't match its frame", i)
+		}
+	}
+
+	// After a failed write the next frame is converted in full again
+	device.fail = true
+	p.WriteFrame(testFrame(100, 100))
+	device.fail = false
+	p.WriteFrame(testFrame(0, 0))
+	if last := device.buffers[len(device.buffers)-1]; !bytes.Equal(last, bmp.FIPBuffer(testFrame(0, 0))) {
+		t.Error("Expected the frame after a failed write to match")
+	}
+}
+
+func TestPipelineGovernor(t *testing.T) {
+	sink := NewMemorySink(0)
+	p := NewFramePipeline(sink, PipelineOptions{MaxFPS: 10})
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		p.WriteFrame(testFrame(i*8, 0))
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Failed to close pipeline: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	frames := sink.Frames()
+	if max := int(elapsed.Seconds()*10) + 2; len(frames) > max {
+		t.Errorf("Expected at most %d frames in %v, got %d", max, elapsed, len(frames))
+	}
+	if !sameImage(frames[len(frames)-1], testFrame(19*8, 0)) {
+		t.Error("Expected the latest frame to be sent last")
+	}
+
+	stats := p.Stats()
+	if stats.Sent != uint64(len(frames)) || stats.Sent+stats.Dropped != 20 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func BenchmarkDiffFramesIdentical(b *testing.B) {
+	prev, next := testFrame(0, 0), testFrame(0, 0)
+	b.SetBytes(int64(len(next.Pix)))
+	for i := 0; i < b.N; i++ {
+		DiffFrames(prev, next, defaultTileSize)
+	}
+}
+
+func BenchmarkDiffFramesSmallChange(b *testing.B) {
+	prev, next := testFrame(0, 0), testFrame(160, 120)
+	b.SetBytes(int64(len(next.Pix)))
+	for i := 0; i < b.N; i++ {
+		DiffFrames(prev, next, defaultTileSize)
+	}
+}
+
+func BenchmarkPipelineWriteFrame(b *testing.B) {
+	frames := []*image.RGBA{testFrame(0, 0), testFrame(160, 120)}
+	p := NewFramePipeline(NewMemorySink(1), PipelineOptions{})
+	defer p.Close()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.WriteFrame(frames[i%2])
+	}
+}
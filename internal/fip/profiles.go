@@ -0,0 +1,365 @@
+package fip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bindingButtons are the soft buttons a profile can bind, in the order
+// their actions are reported when pressed together
+var bindingButtons = []struct {
+	name string
+	bit  uint32
+}{
+	{"S1", SoftButton1}, {"S2", SoftButton2}, {"S3", SoftButton3},
+	{"S4", SoftButton4}, {"S5", SoftButton5}, {"S6", SoftButton6},
+	{"Select", SoftButtonSelect}, {"Up", SoftButtonUp}, {"Down", SoftButtonDown},
+	{"Left", SoftButtonLeft}, {"Right", SoftButtonRight},
+}
+
+// ProfileSet is a list of device profiles, as loaded from a YAML or JSON
+// file
+type ProfileSet struct {
+	Profiles []DeviceProfile `json:"profiles" yaml:"profiles"`
+}
+
+// DeviceProfile is the configuration of a physical device: its pages, the
+// actions bound to its soft buttons and the LEDs lit on each page. It
+// applies to the device with its Instance or, without one, to every device
+// of its Kind that has no profile of its own.
+type DeviceProfile struct {
+	Name          string            `json:"name" yaml:"name"`
+	Instance      string            `json:"instance,omitempty" yaml:"instance,omitempty"`
+	Kind          string            `json:"kind,omitempty" yaml:"kind,omitempty"`
+	SaitekProfile string            `json:"saitek_profile,omitempty" yaml:"saitek_profile,omitempty"`
+	Pages         []ProfilePage     `json:"pages,omitempty" yaml:"pages,omitempty"`
+	Bindings      map[string]string `json:"bindings,omitempty" yaml:"bindings,omitempty"`
+
+	instance DeviceInstance
+}
+
+// ProfilePage is a page of a profile. Images are for the FIP and lines for
+// the X52 Pro MFD. LEDs map LED names (S1-S6 on the FIP, light names such
+// as fire_a on the X52 Pro) to "on", "off" or an LEDColor name.
+type ProfilePage struct {
+	Page   uint32            `json:"page" yaml:"page"`
+	Name   string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Active bool              `json:"active,omitempty" yaml:"active,omitempty"`
+	Image  string            `json:"image,omitempty" yaml:"image,omitempty"`
+	Lines  []string          `json:"lines,omitempty" yaml:"lines,omitempty"`
+	LEDs   map[string]string `json:"leds,omitempty" yaml:"leds,omitempty"`
+}
+
+// BindingEvent is a soft button press bound to an action by a profile
+type BindingEvent struct {
+	Device  *Device
+	Profile string
+	Button  string
+	Action  string
+}
+
+// ParseProfiles reads profiles in the given format: "json", "yaml" or "yml"
+func ParseProfiles(data []byte, format string) (*ProfileSet, error) {
+	var set ProfileSet
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&set); err != nil {
+			return nil, fmt.Errorf("failed to parse profile JSON: %w", err)
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&set); err != nil {
+			return nil, fmt.Errorf("failed to parse profile YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported profile format: %s", format)
+	}
+	for i := range set.Profiles {
+		if err := set.Profiles[i].validate(); err != nil {
+			return nil, fmt.Errorf("profile %d: %w", i+1, err)
+		}
+	}
+	return &set, nil
+}
+
+// LoadProfiles reads a profile file, choosing the format by extension.
+// Image and Saitek profile paths are relative to the file.
+func LoadProfiles(filename string) (*ProfileSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	set, err := ParseProfiles(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	dir := filepath.Dir(filename)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for i := range set.Profiles {
+		p := &set.Profiles[i]
+		p.SaitekProfile = resolve(p.SaitekProfile)
+		for j := range p.Pages {
+			p.Pages[j].Image = resolve(p.Pages[j].Image)
+		}
+	}
+	return set, nil
+}
+
+// validate checks a profile and parses its instance
+func (p *DeviceProfile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	if p.Instance == "" && p.Kind == "" {
+		return fmt.Errorf("profile %q needs an instance or a kind", p.Name)
+	}
+	if p.Instance != "" {
+		instance, err := ParseDeviceInstance(p.Instance)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		p.instance = instance
+	}
+	if p.Kind != "" && p.Kind != DeviceFIP.String() && p.Kind != DeviceX52Pro.String() {
+		return fmt.Errorf("profile %q: unknown kind %q (must be fip or x52pro)", p.Name, p.Kind)
+	}
+
+	for name := range p.Bindings {
+		if _, err := bindingButton(name); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+
+	seen := make(map[uint32]bool)
+	for _, page := range p.Pages {
+		if seen[page.Page] {
+			return fmt.Errorf("profile %q: page %d is defined twice", p.Name, page.Page)
+		}
+		seen[page.Page] = true
+		if len(page.Lines) > X52ProMFDLines {
+			return fmt.Errorf("profile %q page %d: at most %d lines", p.Name, page.Page, X52ProMFDLines)
+		}
+		for name, value := range page.LEDs {
+			if _, err := profileLED(DeviceUnknown, name, value); err != nil {
+				return fmt.Errorf("profile %q page %d: %w", p.Name, page.Page, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bindingButton returns the soft button bit of a binding name
+func bindingButton(name string) (uint32, error) {
+	for _, b := range bindingButtons {
+		if strings.EqualFold(name, b.name) {
+			return b.bit, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown soft button %q", name)
+}
+
+// profileLED returns the LED indexes and values for an LED setting of a
+// page. With DeviceUnknown it accepts the LED names of every kind.
+func profileLED(kind DeviceKind, name, value string) ([]uint32, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "on" {
+		value = LEDGreen.String()
+	}
+	color, err := ParseLEDColor(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != DeviceX52Pro {
+		if control, err := ParseInputControl(name); err == nil && control.IsButton() {
+			on := uint32(0)
+			if color != LEDOff {
+				on = 1
+			}
+			return []uint32{uint32(control.Button() - 1), on}, nil
+		}
+	}
+	if kind != DeviceFIP {
+		if led, err := ParseX52ProLED(name); err == nil {
+			indexes, err := led.indexes()
+			if err != nil {
+				return nil, err
+			}
+			red, green := color.components()
+			if len(indexes) == 1 {
+				return []uint32{indexes[0], red | green}, nil
+			}
+			return []uint32{indexes[0], red, indexes[1], green}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown LED %q", name)
+}
+
+// Match returns the profile for a device: the one with its instance, or
+// else the first one for its kind without an instance
+func (s *ProfileSet) Match(dev *Device) (*DeviceProfile, bool) {
+	if !dev.Instance.IsZero() {
+		for i := range s.Profiles {
+			if s.Profiles[i].Instance != "" && s.Profiles[i].instance == dev.Instance {
+				return &s.Profiles[i], true
+			}
+		}
+	}
+	for i := range s.Profiles {
+		if s.Profiles[i].Instance == "" && s.Profiles[i].Kind == dev.Kind.String() {
+			return &s.Profiles[i], true
+		}
+	}
+	return nil, false
+}
+
+// Apply sets up a device from the profile. Pages can only be drawn while
+// active, so each is added as the active page and drawn, and the page
+// marked active (or else the first) is added last.
+func (p *DeviceProfile) Apply(dev *Device) error {
+	if p.SaitekProfile != "" {
+		err := dev.SetProfile(p.SaitekProfile)
+		switch {
+		case errors.Is(err, E_NOTIMPL):
+			log.Printf("Device %s: Saitek profiles need the DirectOutput SDK", dev.ID)
+		case err != nil:
+			return fmt.Errorf("failed to set Saitek profile: %w", err)
+		}
+	}
+
+	var first, last []ProfilePage
+	for i, page := range p.Pages {
+		if page.Active || (i == 0 && !p.hasActivePage()) {
+			last = append(last, page)
+		} else {
+			first = append(first, page)
+		}
+	}
+	for _, page := range append(first, last...) {
+		if err := p.applyPage(dev, page); err != nil {
+			return fmt.Errorf("profile %q page %d: %w", p.Name, page.Page, err)
+		}
+	}
+	return nil
+}
+
+// hasActivePage reports whether a page is marked active
+func (p *DeviceProfile) hasActivePage() bool {
+	for _, page := range p.Pages {
+		if page.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPage adds and draws one page
+func (p *DeviceProfile) applyPage(dev *Device, page ProfilePage) error {
+	name := page.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %d", p.Name, page.Page)
+	}
+	if err := dev.AddPage(page.Page, name, true); err != nil {
+		return err
+	}
+	if page.Image != "" {
+		if err := dev.SetImageFromFile(page.Page, page.Image); err != nil {
+			return err
+		}
+	}
+	for i, line := range page.Lines {
+		if err := dev.SetString(page.Page, uint32(i), line); err != nil {
+			return err
+		}
+	}
+	for name, value := range page.LEDs {
+		leds, err := profileLED(dev.Kind, name, value)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(leds); i += 2 {
+			if err := dev.SetLed(page.Page, leds[i], leds[i+1] != 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run applies the matching profile to every device as it connects and
+// reports soft button presses bound to actions. The channel is closed when
+// ctx is done or the manager is closed.
+func (s *ProfileSet) Run(ctx context.Context, devices *DeviceManager) <-chan BindingEvent {
+	out := make(chan BindingEvent)
+	events := devices.Subscribe(ctx)
+
+	go func() {
+		defer close(out)
+		profiles := make(map[*Device]*DeviceProfile)
+		for e := range events {
+			switch e.Type {
+			case DeviceAdded:
+				p, ok := s.Match(e.Device)
+				if !ok {
+					log.Printf("Device %s (%s) has no profile", e.Device.ID, e.Device.Instance)
+					continue
+				}
+				log.Printf("Device %s (%s): applying profile %q", e.Device.ID, e.Device.Instance, p.Name)
+				if err := p.Apply(e.Device); err != nil {
+					log.Printf("Device %s: %v", e.Device.ID, err)
+				}
+				profiles[e.Device] = p
+			case DeviceRemoved:
+				delete(profiles, e.Device)
+			case SoftButtonsChanged:
+				p, ok := profiles[e.Device]
+				if !ok {
+					continue
+				}
+				for _, b := range bindingButtons {
+					if e.Pressed&b.bit == 0 {
+						continue
+					}
+					action, ok := p.binding(b.name)
+					if !ok {
+						continue
+					}
+					select {
+					case out <- BindingEvent{Device: e.Device, Profile: p.Name, Button: b.name, Action: action}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// binding returns the action bound to a soft button
+func (p *DeviceProfile) binding(button string) (string, bool) {
+	for name, action := range p.Bindings {
+		if strings.EqualFold(name, button) {
+			return action, true
+		}
+	}
+	return "", false
+}
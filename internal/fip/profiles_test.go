@@ -0,0 +1,212 @@
+package fip
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceInstance(t *testing.T) {
+	// The FIP type GUID is stored in the same layout as instances
+	fip := DeviceInstance(DeviceTypeFip)
+	if s := fip.String(); s != "{3E083CD8-6A37-4A58-80A8-3D6A2C07513E}" {
+		t.Errorf("Unexpected GUID %s", s)
+	}
+	parsed, err := ParseDeviceInstance("3e083cd8-6a37-4a58-80a8-3d6a2c07513e")
+	if err != nil || parsed != fip {
+		t.Errorf("Expected the GUID to parse back, got %v, %v", parsed, err)
+	}
+	if _, err := ParseDeviceInstance("{3E083CD8}"); err == nil {
+		t.Error("Expected a short GUID to fail")
+	}
+
+	bySerial := NewDeviceInstance(0x06A3, 0xA2AE, "A1B2", "path-1")
+	if bySerial != NewDeviceInstance(0x06A3, 0xA2AE, "A1B2", "path-2") {
+		t.Error("Expected the serial number to decide the instance")
+	}
+	byPath := NewDeviceInstance(0x06A3, 0xA2AE, "", "path-1")
+	if byPath == bySerial || byPath == NewDeviceInstance(0x06A3, 0xA2AE, "", "path-2") {
+		t.Error("Expected devices without a serial number to differ by path")
+	}
+	if s := bySerial.String(); s[15] != '5' || !strings.ContainsAny(s[20:21], "89AB") {
+		t.Errorf("Expected a version 5 GUID, got %s", s)
+	}
+}
+
+func TestParseProfiles(t *testing.T) {
+	set, err := ParseProfiles([]byte(`
+profiles:
+  - name: Left FIP
+    instance: "{3E083CD8-6A37-4A58-80A8-3D6A2C07513E}"
+    pages:
+      - page: 1
+        leds: {S1: on, S2: off}
+    bindings: {S1: com1.swap, up: hdg.inc}
+  - name: Any X52
+    kind: x52pro
+    pages:
+      - page: 1
+        lines: [A, B, C]
+        leds: {fire_a: amber, throttle: on}
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Profiles) != 2 || set.Profiles[0].instance != DeviceInstance(DeviceTypeFip) {
+		t.Errorf("Unexpected profiles %+v", set.Profiles)
+	}
+
+	for name, data := range map[string]string{
+		"no name":      `profiles: [{kind: fip}]`,
+		"no target":    `profiles: [{name: x}]`,
+		"bad instance": `profiles: [{name: x, instance: "{1234}"}]`,
+		"bad kind":     `profiles: [{name: x, kind: radio}]`,
+		"bad button":   `profiles: [{name: x, kind: fip, bindings: {S9: a}}]`,
+		"bad LED":      `profiles: [{name: x, kind: fip, pages: [{page: 1, leds: {S7: on}}]}]`,
+		"bad colour":   `profiles: [{name: x, kind: fip, pages: [{page: 1, leds: {S1: blue}}]}]`,
+		"double page":  `profiles: [{name: x, kind: fip, pages: [{page: 1}, {page: 1}]}]`,
+		"extra lines":  `profiles: [{name: x, kind: x52pro, pages: [{page: 1, lines: [a, b, c, d]}]}]`,
+		"unknown key":  `profiles: [{name: x, kind: fip, colour: red}]`,
+	} {
+		if _, err := ParseProfiles([]byte(data), "yaml"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	file, err := os.Create(filepath.Join(dir, "page.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(file, img)
+	file.Close()
+
+	path := filepath.Join(dir, "profiles.json")
+	os.WriteFile(path, []byte(`{"profiles": [{"name": "FIP", "kind": "fip", "pages": [{"page": 1, "image": "page.png"}]}]}`), 0644)
+	set, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := set.Profiles[0].Pages[0].Image; got != filepath.Join(dir, "page.png") {
+		t.Errorf("Expected the image path to be relative to the file, got %s", got)
+	}
+
+	// The example profiles load and their images exist
+	set, err = LoadProfiles("../../assets/profiles/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range set.Profiles {
+		for _, page := range p.Pages {
+			if _, err := os.Stat(page.Image); page.Image != "" && err != nil {
+				t.Errorf("Profile %q: %v", p.Name, err)
+			}
+		}
+	}
+}
+
+func TestProfileSetRun(t *testing.T) {
+	left, right, x52 := NewVirtualFIP(), NewVirtualFIP(), NewVirtualDevice(DeviceTypeX52Pro)
+	instance := NewDeviceInstance(0x06A3, 0xA2AE, "LEFT", "")
+	left.SetInstance(instance)
+
+	set, err := ParseProfiles([]byte(`
+profiles:
+  - name: Left FIP
+    instance: "`+instance.String()+`"
+    pages:
+      - page: 1
+        leds: {S1: on}
+      - page: 2
+        active: true
+        leds: {S2: on}
+    bindings: {S1: com1.swap, S2: com2.swap}
+  - name: Other FIPs
+    kind: fip
+    pages:
+      - page: 7
+  - name: X52
+    kind: x52pro
+    pages:
+      - page: 1
+        lines: [HELLO]
+        leds: {clutch: amber}
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := NewNativeDirectOutput(func() []DirectOutputTransport {
+		return []DirectOutputTransport{left, right, x52}
+	})
+	do.Initialize("test")
+	defer do.Deinitialize()
+	devices, err := NewDeviceManager(do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devices.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bindings := set.Run(ctx, devices)
+	deadline := time.Now().Add(time.Second)
+	for left.Led(1) == 0 || x52.Text(0) == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("Profiles not applied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Press S1 then S2 and S1 together; the profile shows page 2
+	left.Press(SoftButton1)
+	left.Release(SoftButton1)
+	left.Press(SoftButton1 | SoftButton2)
+	right.Click(SoftButton1)
+	for _, want := range []string{"com1.swap", "com1.swap", "com2.swap"} {
+		select {
+		case e := <-bindings:
+			if e.Action != want || e.Profile != "Left FIP" || e.Device.Instance != instance {
+				t.Errorf("Expected %s from the left FIP, got %+v", want, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No binding event, expected %s", want)
+		}
+	}
+
+	if left.Led(1) != 1 || left.Led(0) != 0 {
+		t.Error("Expected page 2 to be shown on the left FIP")
+	}
+	if x52.Text(0) != "HELLO" || x52.Led(17) != 1 || x52.Led(18) != 1 {
+		t.Errorf("Expected the X52 page to be drawn, got %q", x52.Text(0))
+	}
+	for _, dev := range devices.Devices() {
+		if dev.Instance.IsZero() {
+			t.Errorf("Expected %s to have an instance", dev.ID)
+		}
+		want := "Other FIPs"
+		switch {
+		case dev.Instance == instance:
+			want = "Left FIP"
+		case dev.Kind == DeviceX52Pro:
+			want = "X52"
+		}
+		if p, ok := set.Match(dev); !ok || p.Name != want {
+			t.Errorf("Expected profile %q for %s, got %v", want, dev.ID, p)
+		}
+	}
+
+	cancel()
+	for range bindings {
+	}
+}
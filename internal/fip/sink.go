@@ -0,0 +1,229 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"saitek-controller/internal/bmp"
+)
+
+// Sink receives finished frames
+type Sink interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+// ImageSender is a device that accepts whole images, such as FIPDirect or FIPUSB
+type ImageSender interface {
+	SendImage(img image.Image) error
+}
+
+// BufferSender is a device that accepts pre-converted FIP frame buffers
+type BufferSender interface {
+	SendFrameBuffer(data []byte) error
+}
+
+// BufferSink is a sink that also accepts pre-converted FIP frame buffers,
+// saving the conversion when the same frames are sent repeatedly
+type BufferSink interface {
+	Sink
+	WriteBuffer(buf []byte) error
+}
+
+// DeviceSink forwards frames to a physical FIP. Behind a FramePipeline it
+// receives dirty rectangles, and devices that accept frame buffers get a
+// buffer in which only those rectangles were converted.
+type DeviceSink struct {
+	device ImageSender
+
+	mu  sync.Mutex
+	buf []byte // the last frame buffer sent by WriteRegions
+}
+
+// NewDeviceSink creates a sink that sends frames to a device
+func NewDeviceSink(device ImageSender) *DeviceSink {
+	return &DeviceSink{device: device}
+}
+
+// WriteFrame sends the frame to the device
+func (d *DeviceSink) WriteFrame(img image.Image) error {
+	d.reset()
+	return d.device.SendImage(img)
+}
+
+// WriteRegions implements RegionSink. Only the dirty rectangles of the frame
+// are converted into the buffer kept from the previous frame.
+func (d *DeviceSink) WriteRegions(frame image.Image, dirty []image.Rectangle) error {
+	sender, ok := d.device.(BufferSender)
+	if !ok {
+		return d.device.SendImage(frame)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.buf == nil || (len(dirty) == 1 && dirty[0] == frame.Bounds()) {
+		d.buf = bmp.FIPBuffer(frame)
+	} else {
+		for _, r := range dirty {
+			if err := bmp.UpdateFIPBuffer(d.buf, frame, r); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sender.SendFrameBuffer(d.buf); err != nil {
+		// The next dirty rectangles are relative to an earlier frame
+		d.buf = nil
+		return err
+	}
+	return nil
+}
+
+// reset drops the kept buffer after the device was sent something else
+func (d *DeviceSink) reset() {
+	d.mu.Lock()
+	d.buf = nil
+	d.mu.Unlock()
+}
+
+// WriteBuffer sends a FIP frame buffer to the device, converting it back to
+// an image for devices that only accept images
+func (d *DeviceSink) WriteBuffer(buf []byte) error {
+	d.reset()
+	if sender, ok := d.device.(BufferSender); ok {
+		return sender.SendFrameBuffer(buf)
+	}
+	img, err := bmp.FIPImage(buf)
+	if err != nil {
+		return err
+	}
+	return d.device.SendImage(img)
+}
+
+// Close implements Sink. The device is owned by the caller and left open.
+func (d *DeviceSink) Close() error {
+	return nil
+}
+
+// FileSink writes frames to PNG or BMP files
+type FileSink struct {
+	mu      sync.Mutex
+	pattern string
+	format  string
+	frames  int
+}
+
+// NewFileSink creates a sink that writes frames to path. If path contains a
+// %d verb every frame gets its own numbered file, otherwise the file is
+// overwritten with each new frame. The format follows the extension.
+func NewFileSink(path string) (*FileSink, error) {
+	format := strings.ToLower(filepath.Ext(path))
+	switch format {
+	case ".png", ".bmp":
+	default:
+		return nil, fmt.Errorf("unsupported frame format: %s", filepath.Ext(path))
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	return &FileSink{pattern: path, format: format}, nil
+}
+
+// WriteFrame encodes the frame to disk
+func (s *FileSink) WriteFrame(img image.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filename := s.pattern
+	if strings.Contains(filename, "%") {
+		filename = fmt.Sprintf(s.pattern, s.frames)
+	}
+	s.frames++
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create frame file: %w", err)
+	}
+	defer file.Close()
+
+	switch s.format {
+	case ".bmp":
+		err = bmp.Encode(file, img, nil)
+	default:
+		err = png.Encode(file, img)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	return nil
+}
+
+// Frames returns the number of frames written
+func (s *FileSink) Frames() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+// Close implements Sink
+func (s *FileSink) Close() error {
+	return nil
+}
+
+// MemorySink keeps frames in memory, mainly for tests
+type MemorySink struct {
+	mu     sync.Mutex
+	frames []image.Image
+	limit  int
+}
+
+// NewMemorySink creates a sink that keeps the most recent limit frames,
+// or every frame if limit is zero
+func NewMemorySink(limit int) *MemorySink {
+	return &MemorySink{limit: limit}
+}
+
+// WriteFrame stores a copy of the frame
+func (m *MemorySink) WriteFrame(img image.Image) error {
+	frame := image.NewRGBA(img.Bounds())
+	draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frames = append(m.frames, frame)
+	if m.limit > 0 && len(m.frames) > m.limit {
+		m.frames = m.frames[len(m.frames)-m.limit:]
+	}
+	return nil
+}
+
+// Frames returns the stored frames, oldest first
+func (m *MemorySink) Frames() []image.Image {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]image.Image(nil), m.frames...)
+}
+
+// Last returns the most recent frame, or nil if none has been written
+func (m *MemorySink) Last() image.Image {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.frames) == 0 {
+		return nil
+	}
+	return m.frames[len(m.frames)-1]
+}
+
+// Close implements Sink
+func (m *MemorySink) Close() error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package fip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"saitek-controller/internal/bmp"
+)
+
+func TestMemorySinkReceivesFrames(t *testing.T) {
+	panel, err := NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+
+	sink := NewMemorySink(2)
+	panel.AddSink(sink)
+	panel.SetInstrument(InstrumentArtificialHorizon)
+
+	for i := 0; i < 3; i++ {
+		if err := panel.DisplayInstrument(InstrumentData{Pitch: float64(i)}); err != nil {
+			t.Fatalf("Failed to display instrument: %v", err)
+		}
+	}
+
+	frames := sink.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	if frames[1].Bounds() != image.Rect(0, 0, 320, 240) {
+		t.Errorf("Expected 320x240 frame, got %v", frames[1].Bounds())
+	}
+	if panel.Frame() == nil {
+		t.Error("Expected panel to keep the last frame")
+	}
+}
+
+func TestDisplayImageScalesToPanel(t *testing.T) {
+	panel, err := NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+
+	sink := NewMemorySink(1)
+	panel.AddSink(sink)
+
+	small := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	if err := panel.DisplayImage(small); err != nil {
+		t.Fatalf("Failed to display image: %v", err)
+	}
+	if b := sink.Last().Bounds(); b.Dx() != 320 || b.Dy() != 240 {
+		t.Errorf("Expected frame scaled to 320x240, got %v", b)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	panel, err := NewFIPPanel("Test Panel", 64, 48)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+
+	sink := NewMemorySink(1)
+	panel.AddSink(sink)
+
+	red := color.RGBA{255, 0, 0, 255}
+	err = panel.Render(RendererFunc(func(s *Surface) error {
+		s.Clear(red)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if c := sink.Last().At(10, 10); c != red {
+		t.Errorf("Expected red pixel, got %v", c)
+	}
+
+	failing := RendererFunc(func(s *Surface) error { return fmt.Errorf("boom") })
+	if err := panel.Render(failing); err == nil {
+		t.Error("Expected renderer error to be returned")
+	}
+
+	if err := (InstrumentRenderer{Instrument: InstrumentCustom}).Render(NewSurface(64, 48)); err == nil {
+		t.Error("Expected error for instrument without a renderer")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	frame := image.NewRGBA(image.Rect(0, 0, 16, 8))
+
+	png, err := NewFileSink(filepath.Join(dir, "frames", "frame-%03d.png"))
+	if err != nil {
+		t.Fatalf("Failed to create PNG sink: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := png.WriteFrame(frame); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	for _, name := range []string{"frame-000.png", "frame-001.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "frames", name)); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+
+	path := filepath.Join(dir, "frame.bmp")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("Failed to create BMP sink: %v", err)
+	}
+	if err := sink.WriteFrame(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open BMP: %v", err)
+	}
+	defer file.Close()
+	img, err := bmp.Decode(file)
+	if err != nil {
+		t.Fatalf("Failed to decode BMP: %v", err)
+	}
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Errorf("Expected 16x8 BMP, got %v", img.Bounds())
+	}
+
+	if _, err := NewFileSink(filepath.Join(dir, "frame.gif")); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
@@ -0,0 +1,199 @@
+package fip
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// streamBoundary separates the parts of an MJPEG stream
+const streamBoundary = "fipframe"
+
+// streamKeepAlive is how often the last frame is resent to idle clients,
+// so browsers that only draw a part once the next one starts stay current
+const streamKeepAlive = 2 * time.Second
+
+// StreamSink encodes frames as JPEG and streams them to any number of HTTP
+// clients as MJPEG (multipart/x-mixed-replace), which browsers show in a
+// plain <img> tag. Slow clients skip frames rather than holding up the
+// panel.
+type StreamSink struct {
+	mu      sync.Mutex
+	quality int
+	last    []byte
+	seq     uint64
+	clients map[chan []byte]struct{}
+	closed  bool
+}
+
+// NewStreamSink creates a stream sink encoding at the given JPEG quality,
+// or jpeg.DefaultQuality if quality is zero or less
+func NewStreamSink(quality int) *StreamSink {
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	return &StreamSink{quality: quality, clients: make(map[chan []byte]struct{})}
+}
+
+// WriteFrame encodes the frame and sends it to every connected client
+func (s *StreamSink) WriteFrame(img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: s.quality}); err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	frame := buf.Bytes()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.last = frame
+	s.seq++
+	for client := range s.clients {
+		// Replace a frame the client has not picked up yet
+		select {
+		case <-client:
+		default:
+		}
+		client <- frame
+	}
+	return nil
+}
+
+// Last returns the most recent frame as JPEG, or nil
+func (s *StreamSink) Last() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Frames returns the number of frames written
+func (s *StreamSink) Frames() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// Clients returns the number of connected stream clients
+func (s *StreamSink) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// subscribe registers a client, primed with the last frame. It returns nil
+// once the sink is closed.
+func (s *StreamSink) subscribe() chan []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	client := make(chan []byte, 1)
+	if s.last != nil {
+		client <- s.last
+	}
+	s.clients[client] = struct{}{}
+	return client
+}
+
+// unsubscribe removes a client
+func (s *StreamSink) unsubscribe(client chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[client]; ok {
+		delete(s.clients, client)
+		close(client)
+	}
+}
+
+// ServeHTTP streams frames to the client until it disconnects or the sink
+// is closed
+func (s *StreamSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client := s.subscribe()
+	if client == nil {
+		http.Error(w, "stream closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamBoundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "close")
+	flusher, _ := w.(http.Flusher)
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	var frame []byte
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case next, ok := <-client:
+			if !ok {
+				return
+			}
+			frame = next
+		case <-keepAlive.C:
+			if frame == nil {
+				continue
+			}
+		}
+
+		if err := writeStreamPart(w, frame); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamPart writes one JPEG part of an MJPEG stream
+func writeStreamPart(w http.ResponseWriter, frame []byte) error {
+	header := "--" + streamBoundary + "\r\n" +
+		"Content-Type: image/jpeg\r\n" +
+		"Content-Length: " + strconv.Itoa(len(frame)) + "\r\n\r\n"
+	if _, err := w.Write([]byte(header)); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte("\r\n"))
+	return err
+}
+
+// ServeSnapshot serves the last frame as a single JPEG image
+func (s *StreamSink) ServeSnapshot(w http.ResponseWriter, r *http.Request) {
+	frame := s.Last()
+	if frame == nil {
+		http.Error(w, "no frame yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Write(frame)
+}
+
+// Close ends every stream. Frames written afterwards are dropped.
+func (s *StreamSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for client := range s.clients {
+		delete(s.clients, client)
+		close(client)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package fip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamSinkMJPEG(t *testing.T) {
+	sink := NewStreamSink(0)
+	frame := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	if err := sink.WriteFrame(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/x-mixed-replace" {
+		t.Fatalf("Unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(bufio.NewReader(resp.Body), params["boundary"])
+
+	// The last frame is sent on connect, then every new frame
+	for i := 0; i < 2; i++ {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("Failed to read part %d: %v", i, err)
+		}
+		img, err := jpeg.Decode(part)
+		if err != nil || img.Bounds() != frame.Bounds() {
+			t.Fatalf("Expected 320x240 JPEG, got %v", err)
+		}
+		if i == 0 {
+			if sink.Clients() != 1 {
+				t.Errorf("Expected 1 client, got %d", sink.Clients())
+			}
+			sink.WriteFrame(frame)
+		}
+	}
+	if sink.Frames() != 2 {
+		t.Errorf("Expected 2 frames, got %d", sink.Frames())
+	}
+
+	sink.Close()
+	if _, err := reader.NextPart(); err == nil {
+		t.Error("Expected stream to end when the sink is closed")
+	}
+	if err := sink.WriteFrame(frame); err != nil || sink.Frames() != 2 {
+		t.Errorf("Expected frames after close to be dropped, got %d, %v", sink.Frames(), err)
+	}
+}
+
+func TestStreamSinkSnapshot(t *testing.T) {
+	sink := NewStreamSink(50)
+	rec := httptest.NewRecorder()
+	sink.ServeSnapshot(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before the first frame, got %d", rec.Code)
+	}
+
+	sink.WriteFrame(image.NewRGBA(image.Rect(0, 0, 32, 24)))
+	rec = httptest.NewRecorder()
+	sink.ServeSnapshot(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("Content-Type") != "image/jpeg" || !bytes.Equal(rec.Body.Bytes(), sink.Last()) {
+		t.Error("Expected the last frame as JPEG")
+	}
+}
@@ -0,0 +1,83 @@
+package fip
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+
+	"saitek-controller/internal/render"
+)
+
+// Surface is an off-screen frame that renderers draw onto
+type Surface struct {
+	img    *image.RGBA
+	canvas *render.Canvas
+}
+
+// Renderer draws a frame onto a surface
+type Renderer interface {
+	Render(s *Surface) error
+}
+
+// RendererFunc adapts a function to the Renderer interface
+type RendererFunc func(s *Surface) error
+
+// Render calls f(s)
+func (f RendererFunc) Render(s *Surface) error {
+	return f(s)
+}
+
+// DataRenderer draws instrument data onto a surface. Gauges loaded from
+// definition files implement it.
+type DataRenderer interface {
+	RenderData(s *Surface, data InstrumentData) error
+}
+
+// NewSurface creates a new surface of the given size
+func NewSurface(width, height int) *Surface {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	return &Surface{img: img, canvas: render.NewCanvasFor(img)}
+}
+
+// Width returns the surface width in pixels
+func (s *Surface) Width() int {
+	return s.img.Bounds().Dx()
+}
+
+// Height returns the surface height in pixels
+func (s *Surface) Height() int {
+	return s.img.Bounds().Dy()
+}
+
+// Image returns the pixels of the surface
+func (s *Surface) Image() *image.RGBA {
+	return s.img
+}
+
+// Canvas returns a vector canvas that draws onto the surface
+func (s *Surface) Canvas() *render.Canvas {
+	return s.canvas
+}
+
+// Clear fills the surface with a color
+func (s *Surface) Clear(c color.Color) {
+	draw.Draw(s.img, s.img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// DrawImage draws img scaled to fill the surface
+func (s *Surface) DrawImage(img image.Image) {
+	if img.Bounds().Size() == s.img.Bounds().Size() {
+		draw.Draw(s.img, s.img.Bounds(), img, img.Bounds().Min, draw.Src)
+		return
+	}
+	xdraw.BiLinear.Scale(s.img, s.img.Bounds(), img, img.Bounds(), draw.Src, nil)
+}
+
+// Snapshot returns a copy of the current frame that later drawing won't change
+func (s *Surface) Snapshot() *image.RGBA {
+	frame := image.NewRGBA(s.img.Bounds())
+	copy(frame.Pix, s.img.Pix)
+	return frame
+}
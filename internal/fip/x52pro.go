@@ -0,0 +1,489 @@
+package fip
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// X52 Pro MFD size
+const (
+	X52ProMFDLines = 3
+	X52ProMFDWidth = 16
+)
+
+// x52ProLEDs is the number of DirectOutput LED indexes on the X52 Pro
+const x52ProLEDs = 20
+
+// x52ProScrollGap separates the end of a scrolling line from its start
+const x52ProScrollGap = "   "
+
+// X52ProSoftButtons are the soft buttons of the X52 Pro MFD
+const X52ProSoftButtons = SoftButtonSelect | SoftButtonUp | SoftButtonDown
+
+// X52ProLED is one of the lights of the X52 Pro. Most have a red and a green
+// LED; Fire and Throttle have one.
+type X52ProLED int
+
+const (
+	X52ProLEDFire X52ProLED = iota
+	X52ProLEDFireA
+	X52ProLEDFireB
+	X52ProLEDFireD
+	X52ProLEDFireE
+	X52ProLEDToggle12
+	X52ProLEDToggle34
+	X52ProLEDToggle56
+	X52ProLEDPOV2
+	X52ProLEDClutch
+	X52ProLEDThrottle
+)
+
+var x52ProLEDNames = []string{"fire", "fire_a", "fire_b", "fire_d", "fire_e", "t1", "t2", "t3", "pov2", "clutch", "throttle"}
+
+// String returns the light's name
+func (l X52ProLED) String() string {
+	if l < 0 || int(l) >= len(x52ProLEDNames) {
+		return fmt.Sprintf("X52ProLED(%d)", int(l))
+	}
+	return x52ProLEDNames[l]
+}
+
+// ParseX52ProLED parses a light name such as "fire_a" or "clutch"
+func ParseX52ProLED(name string) (X52ProLED, error) {
+	for i, n := range x52ProLEDNames {
+		if strings.EqualFold(name, n) {
+			return X52ProLED(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown X52 Pro LED: %s", name)
+}
+
+// indexes returns the DirectOutput LED indexes of the light: red then green
+// for two-colour lights, or a single index for Fire and Throttle
+func (l X52ProLED) indexes() ([]uint32, error) {
+	switch {
+	case l == X52ProLEDFire:
+		return []uint32{0}, nil
+	case l == X52ProLEDThrottle:
+		return []uint32{x52ProLEDs - 1}, nil
+	case l > X52ProLEDFire && l < X52ProLEDThrottle:
+		red := uint32(2*l - 1)
+		return []uint32{red, red + 1}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown X52 Pro LED %d", E_INVALIDARG, int(l))
+}
+
+// LEDColor is the colour of a two-colour LED
+type LEDColor int
+
+const (
+	LEDOff LEDColor = iota
+	LEDRed
+	LEDGreen
+	LEDAmber
+)
+
+var ledColorNames = []string{"off", "red", "green", "amber"}
+
+// String returns the colour's name
+func (c LEDColor) String() string {
+	if c < 0 || int(c) >= len(ledColorNames) {
+		return fmt.Sprintf("LEDColor(%d)", int(c))
+	}
+	return ledColorNames[c]
+}
+
+// ParseLEDColor parses "off", "red", "green" or "amber"
+func ParseLEDColor(name string) (LEDColor, error) {
+	for i, n := range ledColorNames {
+		if strings.EqualFold(name, n) {
+			return LEDColor(i), nil
+		}
+	}
+	return LEDOff, fmt.Errorf("unknown LED colour: %s", name)
+}
+
+// components returns the red and green LED values of the colour
+func (c LEDColor) components() (red, green uint32) {
+	switch c {
+	case LEDRed:
+		return 1, 0
+	case LEDGreen:
+		return 0, 1
+	case LEDAmber:
+		return 1, 1
+	}
+	return 0, 0
+}
+
+// x52ProLine is an MFD line. Lines longer than the MFD scroll.
+type x52ProLine struct {
+	text   []rune
+	offset int
+}
+
+// window returns the characters the line shows
+func (l *x52ProLine) window() string {
+	if len(l.text) <= X52ProMFDWidth {
+		return string(l.text)
+	}
+	n := len(l.text) + len(x52ProScrollGap)
+	out := make([]rune, X52ProMFDWidth)
+	for i := range out {
+		if j := (l.offset + i) % n; j < len(l.text) {
+			out[i] = l.text[j]
+		} else {
+			out[i] = ' '
+		}
+	}
+	return string(out)
+}
+
+// scroll moves a long line on by one character and reports whether it moved
+func (l *x52ProLine) scroll() bool {
+	if len(l.text) <= X52ProMFDWidth {
+		return false
+	}
+	l.offset = (l.offset + 1) % (len(l.text) + len(x52ProScrollGap))
+	return true
+}
+
+// x52ProPage is what a page shows
+type x52ProPage struct {
+	lines [X52ProMFDLines]x52ProLine
+	leds  [x52ProLEDs]uint32
+}
+
+// X52Pro drives the MFD and LEDs of an X52 Pro through DirectOutput. Unlike
+// the raw API it keeps what every page shows, so inactive pages can be
+// written and are drawn when they become active, and it scrolls MFD lines
+// longer than 16 characters.
+type X52Pro struct {
+	do     DirectOutput
+	device unsafe.Pointer
+
+	mu        sync.Mutex
+	pages     map[uint32]*x52ProPage
+	active    uint32
+	hasActive bool
+	callbacks PageCallbacks
+	stopChan  chan struct{}
+}
+
+// NewX52Pro wraps an X52 Pro device of do. It registers the device's page
+// and soft button callbacks; use SetCallbacks to receive them.
+func NewX52Pro(do DirectOutput, hDevice unsafe.Pointer) (*X52Pro, error) {
+	deviceType, err := do.GetDeviceType(hDevice)
+	if err != nil {
+		return nil, err
+	}
+	if deviceType != DeviceTypeX52Pro {
+		return nil, fmt.Errorf("%w: device is not an X52 Pro", E_INVALIDARG)
+	}
+
+	x := &X52Pro{do: do, device: hDevice, pages: make(map[uint32]*x52ProPage)}
+	if err := do.RegisterPageCallback(hDevice, x.onPageChanged, nil); err != nil {
+		return nil, err
+	}
+	if err := do.RegisterSoftButtonCallback(hDevice, x.onSoftButtons, nil); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// OpenX52Pro wraps the first X52 Pro of do
+func OpenX52Pro(do DirectOutput) (*X52Pro, error) {
+	hDevice, err := FirstDevice(do, DeviceTypeX52Pro)
+	if err != nil {
+		return nil, fmt.Errorf("no X52 Pro found: %w", err)
+	}
+	return NewX52Pro(do, hDevice)
+}
+
+// Handle returns the DirectOutput device handle
+func (x *X52Pro) Handle() unsafe.Pointer {
+	return x.device
+}
+
+// SetCallbacks sets the functions called on page changes and on changes of
+// the Select, Up and Down soft buttons of the active page
+func (x *X52Pro) SetCallbacks(callbacks PageCallbacks) {
+	x.mu.Lock()
+	x.callbacks = callbacks
+	x.mu.Unlock()
+}
+
+// AddPage adds a blank page. It becomes active if active is set or there is
+// no active page.
+func (x *X52Pro) AddPage(page uint32, name string, active bool) error {
+	x.mu.Lock()
+	if _, ok := x.pages[page]; ok {
+		x.mu.Unlock()
+		return E_INVALIDARG
+	}
+	x.pages[page] = &x52ProPage{}
+	x.mu.Unlock()
+
+	var flags uint32
+	if active {
+		flags = FLAG_SET_AS_ACTIVE
+	}
+	if err := x.do.AddPage(x.device, page, name, flags); err != nil {
+		x.mu.Lock()
+		delete(x.pages, page)
+		x.mu.Unlock()
+		return err
+	}
+
+	x.mu.Lock()
+	if active || !x.hasActive {
+		x.active, x.hasActive = page, true
+	}
+	x.mu.Unlock()
+	return nil
+}
+
+// RemovePage removes a page
+func (x *X52Pro) RemovePage(page uint32) error {
+	if err := x.do.RemovePage(x.device, page); err != nil {
+		return err
+	}
+	x.mu.Lock()
+	delete(x.pages, page)
+	if x.hasActive && x.active == page {
+		x.hasActive = false
+	}
+	x.mu.Unlock()
+	return nil
+}
+
+// ActivePage returns the page shown, if any
+func (x *X52Pro) ActivePage() (uint32, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.active, x.hasActive
+}
+
+// SetLine sets MFD line 0-2 of a page. Text longer than 16 characters
+// scrolls while scrolling is started with Start.
+func (x *X52Pro) SetLine(page uint32, line int, text string) error {
+	if line < 0 || line >= X52ProMFDLines {
+		return fmt.Errorf("%w: MFD line %d out of range", E_INVALIDARG, line)
+	}
+
+	x.mu.Lock()
+	p, ok := x.pages[page]
+	if !ok {
+		x.mu.Unlock()
+		return E_INVALIDARG
+	}
+	p.lines[line] = x52ProLine{text: []rune(text)}
+	shown := p.lines[line].window()
+	active := x.isActive(page)
+	x.mu.Unlock()
+
+	if !active {
+		return nil
+	}
+	return x.do.SetString(x.device, page, uint32(line), shown)
+}
+
+// Line returns the text of an MFD line of a page
+func (x *X52Pro) Line(page uint32, line int) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	p, ok := x.pages[page]
+	if !ok || line < 0 || line >= X52ProMFDLines {
+		return ""
+	}
+	return string(p.lines[line].text)
+}
+
+// SetLED sets the colour of a light on a page. Fire and Throttle only have
+// one LED, which is on for any colour but LEDOff.
+func (x *X52Pro) SetLED(page uint32, led X52ProLED, color LEDColor) error {
+	indexes, err := led.indexes()
+	if err != nil {
+		return err
+	}
+	red, green := color.components()
+	values := []uint32{red, green}
+	if len(indexes) == 1 && color != LEDOff {
+		values[0] = 1
+	}
+
+	x.mu.Lock()
+	p, ok := x.pages[page]
+	if !ok {
+		x.mu.Unlock()
+		return E_INVALIDARG
+	}
+	for i, index := range indexes {
+		p.leds[index] = values[i]
+	}
+	active := x.isActive(page)
+	x.mu.Unlock()
+
+	if !active {
+		return nil
+	}
+	for i, index := range indexes {
+		if err := x.do.SetLed(x.device, page, index, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LED returns the colour of a light on a page
+func (x *X52Pro) LED(page uint32, led X52ProLED) LEDColor {
+	indexes, err := led.indexes()
+	if err != nil {
+		return LEDOff
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	p, ok := x.pages[page]
+	if !ok {
+		return LEDOff
+	}
+	if len(indexes) == 1 {
+		if p.leds[indexes[0]] != 0 {
+			return LEDGreen
+		}
+		return LEDOff
+	}
+	switch red, green := p.leds[indexes[0]] != 0, p.leds[indexes[1]] != 0; {
+	case red && green:
+		return LEDAmber
+	case red:
+		return LEDRed
+	case green:
+		return LEDGreen
+	}
+	return LEDOff
+}
+
+// Scroll moves every long line of the active page on by one character
+func (x *X52Pro) Scroll() error {
+	x.mu.Lock()
+	p, ok := x.pages[x.active]
+	if !x.hasActive || !ok {
+		x.mu.Unlock()
+		return nil
+	}
+	page := x.active
+	shown := make(map[uint32]string)
+	for i := range p.lines {
+		if p.lines[i].scroll() {
+			shown[uint32(i)] = p.lines[i].window()
+		}
+	}
+	x.mu.Unlock()
+
+	for line, text := range shown {
+		if err := x.do.SetString(x.device, page, line, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start scrolls long lines at the given interval until Stop
+func (x *X52Pro) Start(interval time.Duration) {
+	x.Stop()
+
+	stopChan := make(chan struct{})
+	x.mu.Lock()
+	x.stopChan = stopChan
+	x.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+				if err := x.Scroll(); err != nil {
+					log.Printf("X52 Pro scroll failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops scrolling started with Start
+func (x *X52Pro) Stop() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.stopChan != nil {
+		close(x.stopChan)
+		x.stopChan = nil
+	}
+}
+
+// isActive reports whether page is shown. It must be called with the lock
+// held.
+func (x *X52Pro) isActive(page uint32) bool {
+	return x.hasActive && x.active == page
+}
+
+// onPageChanged tracks the active page and draws a page when it is shown
+func (x *X52Pro) onPageChanged(_ unsafe.Pointer, page uint32, active bool, _ unsafe.Pointer) {
+	x.mu.Lock()
+	if active {
+		x.active, x.hasActive = page, true
+	} else if x.isActive(page) {
+		x.hasActive = false
+	}
+	var shown *x52ProPage
+	if p, ok := x.pages[page]; ok && active {
+		shown = &x52ProPage{leds: p.leds}
+		for i := range p.lines {
+			p.lines[i].offset = 0
+			shown.lines[i] = x52ProLine{text: []rune(p.lines[i].window())}
+		}
+	}
+	callback := x.callbacks.OnPageChanged
+	x.mu.Unlock()
+
+	if shown != nil {
+		if err := x.draw(page, shown); err != nil {
+			log.Printf("Failed to draw X52 Pro page %d: %v", page, err)
+		}
+	}
+	if callback != nil {
+		callback(page, active)
+	}
+}
+
+// draw sends everything a page shows
+func (x *X52Pro) draw(page uint32, p *x52ProPage) error {
+	for i, line := range p.lines {
+		if err := x.do.SetString(x.device, page, uint32(i), string(line.text)); err != nil {
+			return err
+		}
+	}
+	for i, value := range p.leds {
+		if err := x.do.SetLed(x.device, page, uint32(i), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onSoftButtons passes on changes of the MFD soft buttons
+func (x *X52Pro) onSoftButtons(_ unsafe.Pointer, buttons uint32, _ unsafe.Pointer) {
+	x.mu.Lock()
+	callback := x.callbacks.OnSoftButtonChanged
+	x.mu.Unlock()
+	if callback != nil {
+		callback(buttons & X52ProSoftButtons)
+	}
+}
@@ -0,0 +1,218 @@
+package fip
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestX52Pro attaches a virtual X52 Pro with two pages
+func newTestX52Pro(t *testing.T) (*X52Pro, *VirtualDevice) {
+	t.Helper()
+	do := NewNativeDirectOutput(nil)
+	if err := do.Initialize("test"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { do.Deinitialize() })
+
+	device := NewVirtualDevice(DeviceTypeX52Pro)
+	x, err := NewX52Pro(do, do.Attach(device))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.AddPage(1, "One", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.AddPage(2, "Two", false); err != nil {
+		t.Fatal(err)
+	}
+	return x, device
+}
+
+func TestX52ProLines(t *testing.T) {
+	x, device := newTestX52Pro(t)
+
+	if err := x.SetLine(1, 0, "HDG 270"); err != nil {
+		t.Fatal(err)
+	}
+	if got := device.Text(0); got != "HDG 270" {
+		t.Errorf("Expected line 0 %q, got %q", "HDG 270", got)
+	}
+	if err := x.SetLine(1, 3, "X"); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for line 3, got %v", err)
+	}
+	if err := x.SetLine(9, 0, "X"); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for an unknown page, got %v", err)
+	}
+
+	// Long lines scroll through a gap back to their start
+	long := "ACTIVE WAYPOINT KJFK"
+	x.SetLine(1, 1, long)
+	if got := device.Text(1); got != "ACTIVE WAYPOINT " {
+		t.Errorf("Expected the first 16 characters, got %q", got)
+	}
+	x.Scroll()
+	if got := device.Text(1); got != "CTIVE WAYPOINT K" {
+		t.Errorf("Expected the line to scroll, got %q", got)
+	}
+	for i := 1; i < len(long)+len(x52ProScrollGap); i++ {
+		x.Scroll()
+	}
+	if got := device.Text(1); got != long[:X52ProMFDWidth] {
+		t.Errorf("Expected the line to wrap around, got %q", got)
+	}
+	if device.Text(0) != "HDG 270" || x.Line(1, 1) != long {
+		t.Error("Expected short lines and the full text to be kept")
+	}
+}
+
+func TestX52ProLEDs(t *testing.T) {
+	x, device := newTestX52Pro(t)
+
+	for _, tc := range []struct {
+		led        X52ProLED
+		color      LEDColor
+		red, green uint32
+	}{
+		{X52ProLEDFireA, LEDRed, 1, 2},
+		{X52ProLEDToggle12, LEDGreen, 9, 10},
+		{X52ProLEDClutch, LEDAmber, 17, 18},
+	} {
+		if err := x.SetLED(1, tc.led, tc.color); err != nil {
+			t.Fatal(err)
+		}
+		r, g := tc.color.components()
+		if device.Led(tc.red) != r || device.Led(tc.green) != g {
+			t.Errorf("%v %v: expected LEDs %d,%d = %d,%d, got %d,%d", tc.led, tc.color,
+				tc.red, tc.green, r, g, device.Led(tc.red), device.Led(tc.green))
+		}
+		if got := x.LED(1, tc.led); got != tc.color {
+			t.Errorf("Expected %v to be %v, got %v", tc.led, tc.color, got)
+		}
+	}
+
+	x.SetLED(1, X52ProLEDThrottle, LEDAmber)
+	if device.Led(19) != 1 {
+		t.Error("Expected the throttle LED to be on")
+	}
+	x.SetLED(1, X52ProLEDClutch, LEDOff)
+	if device.Led(17) != 0 || device.Led(18) != 0 {
+		t.Error("Expected the clutch LEDs to be off")
+	}
+	if err := x.SetLED(1, X52ProLED(42), LEDRed); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for an unknown LED, got %v", err)
+	}
+
+	if c, err := ParseLEDColor("Amber"); err != nil || c != LEDAmber {
+		t.Errorf("Expected amber, got %v, %v", c, err)
+	}
+	if led, err := ParseX52ProLED("pov2"); err != nil || led != X52ProLEDPOV2 {
+		t.Errorf("Expected pov2, got %v, %v", led, err)
+	}
+}
+
+func TestX52ProPages(t *testing.T) {
+	x, device := newTestX52Pro(t)
+	pages := make(chan pageChange, 10)
+	buttons := make(chan uint32, 10)
+	x.SetCallbacks(PageCallbacks{
+		OnPageChanged:       func(page uint32, active bool) { pages <- pageChange{page, active} },
+		OnSoftButtonChanged: func(state uint32) { buttons <- state },
+	})
+
+	if page, ok := x.ActivePage(); !ok || page != 1 {
+		t.Fatalf("Expected page 1 to be active, got %d, %v", page, ok)
+	}
+
+	// Inactive pages are kept and drawn when shown
+	x.SetLine(1, 0, "PAGE ONE")
+	if err := x.SetLine(2, 0, "PAGE TWO"); err != nil {
+		t.Fatal(err)
+	}
+	x.SetLED(2, X52ProLEDFireB, LEDRed)
+	if device.Text(0) != "PAGE ONE" || device.Led(3) != 0 {
+		t.Error("Expected page 2 not to be drawn while inactive")
+	}
+
+	device.Click(SoftButtonPageDown)
+	expectPages(t, pages, pageChange{1, false}, pageChange{2, true})
+	if device.Text(0) != "PAGE TWO" || device.Led(3) != 1 {
+		t.Errorf("Expected page 2 to be drawn, got %q", device.Text(0))
+	}
+	if page, _ := x.ActivePage(); page != 2 {
+		t.Errorf("Expected page 2 to be active, got %d", page)
+	}
+
+	// Only the MFD soft buttons are passed on
+	device.Press(SoftButtonSelect | SoftButton1)
+	select {
+	case got := <-buttons:
+		if got != SoftButtonSelect {
+			t.Errorf("Expected Select, got 0x%X", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No soft button callback")
+	}
+
+	if err := x.RemovePage(2); err != nil {
+		t.Fatal(err)
+	}
+	expectPages(t, pages, pageChange{2, false}, pageChange{1, true})
+	if device.Text(0) != "PAGE ONE" {
+		t.Errorf("Expected page 1 to be drawn again, got %q", device.Text(0))
+	}
+}
+
+func TestNewX52ProRejectsFIP(t *testing.T) {
+	do := NewNativeDirectOutput(nil)
+	do.Initialize("test")
+	defer do.Deinitialize()
+	if _, err := NewX52Pro(do, do.Attach(NewVirtualFIP())); !errors.Is(err, E_INVALIDARG) {
+		t.Errorf("Expected E_INVALIDARG for a FIP, got %v", err)
+	}
+	if _, err := OpenX52Pro(do); !errors.Is(err, E_HANDLE) {
+		t.Errorf("Expected E_HANDLE without an X52 Pro, got %v", err)
+	}
+}
+
+// recordingControl records the control transfers of an X52ProUSB
+type recordingControl struct {
+	index, value []uint16
+}
+
+func (r *recordingControl) SendControlMessage(requestType, request, value, index uint16, data []byte) error {
+	r.index = append(r.index, index)
+	r.value = append(r.value, value)
+	return nil
+}
+
+func (r *recordingControl) Close() error {
+	return nil
+}
+
+func TestX52ProUSBProtocol(t *testing.T) {
+	control := &recordingControl{}
+	x := &X52ProUSB{device: control}
+
+	x.SetString(1, "ABC")
+	wantIndex := []uint16{0xDA, 0xD2, 0xD2}
+	wantValue := []uint16{0, 'B'<<8 | 'A', ' '<<8 | 'C'}
+	for i := range wantIndex {
+		if i >= len(control.index) || control.index[i] != wantIndex[i] || control.value[i] != wantValue[i] {
+			t.Fatalf("Expected transfers %X/%X, got %X/%X", wantIndex, wantValue, control.index, control.value)
+		}
+	}
+
+	control.index, control.value = nil, nil
+	x.SetString(0, "0123456789ABCDEFGHIJ")
+	if len(control.index) != 1+X52ProMFDWidth/2 {
+		t.Errorf("Expected long lines to be cut to 16 characters, got %d transfers", len(control.index))
+	}
+
+	control.index, control.value = nil, nil
+	x.SetLed(0, 1)
+	x.SetLed(19, 0)
+	if control.value[0] != 0x0101 || control.value[1] != 0x1400 || control.index[0] != x52ProCommandLED {
+		t.Errorf("Unexpected LED transfers %X/%X", control.index, control.value)
+	}
+}
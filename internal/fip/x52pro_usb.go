@@ -0,0 +1,111 @@
+package fip
+
+import (
+	"fmt"
+	"log"
+
+	"saitek-controller/internal/usb"
+)
+
+// X52 Pro USB protocol: every update is a vendor control transfer to the
+// device with the command in wIndex and its argument in wValue
+const (
+	x52ProVendorID     = 0x06A3
+	x52ProProductID    = 0x0762
+	x52ProRequestType  = 0x40 // vendor, device, host to device
+	x52ProRequest      = 0x91
+	x52ProCommandLED   = 0x00B8
+	x52ProCommandClear = 0x0008 // or'ed with a line command
+)
+
+// x52ProLineCommands are the write commands of the three MFD lines
+var x52ProLineCommands = [X52ProMFDLines]uint16{0x00D1, 0x00D2, 0x00D4}
+
+// controlSender sends USB control transfers, like usb.GoUSBDevice
+type controlSender interface {
+	SendControlMessage(requestType, request, value, index uint16, data []byte) error
+	Close() error
+}
+
+// X52ProUSB is an X52 Pro reached over libusb, as a DirectOutputTransport.
+// It writes the MFD and LEDs; the MFD buttons are only reported by the
+// DirectOutput SDK.
+type X52ProUSB struct {
+	device controlSender
+}
+
+// OpenX52ProUSB opens the first X52 Pro over libusb
+func OpenX52ProUSB() (*X52ProUSB, error) {
+	device, err := usb.NewGoUSBDevice(x52ProVendorID, x52ProProductID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open X52 Pro: %w", err)
+	}
+	log.Printf("Successfully connected to X52 Pro via USB")
+	return &X52ProUSB{device: device}, nil
+}
+
+// command sends one command
+func (x *X52ProUSB) command(index, value uint16) error {
+	return x.device.SendControlMessage(x52ProRequestType, x52ProRequest, value, index, nil)
+}
+
+func (x *X52ProUSB) DeviceType() [16]byte {
+	return DeviceTypeX52Pro
+}
+
+func (x *X52ProUSB) SetImage(index uint32, data []byte) error {
+	return E_NOTIMPL
+}
+
+// SetLed switches an LED by its DirectOutput index
+func (x *X52ProUSB) SetLed(index uint32, value uint32) error {
+	if index >= x52ProLEDs {
+		return fmt.Errorf("invalid X52 Pro LED index: %d", index)
+	}
+	state := uint16(0)
+	if value != 0 {
+		state = 1
+	}
+	// The device numbers its LEDs from 1
+	return x.command(x52ProCommandLED, uint16(index+1)<<8|state)
+}
+
+// SetString writes an MFD line, two characters per transfer. Text past 16
+// characters is cut off and characters outside ASCII are shown as '?'.
+func (x *X52ProUSB) SetString(index uint32, value string) error {
+	if index >= X52ProMFDLines {
+		return fmt.Errorf("invalid X52 Pro MFD line: %d", index)
+	}
+	line := x52ProLineCommands[index]
+	if err := x.command(line|x52ProCommandClear, 0); err != nil {
+		return err
+	}
+
+	chars := make([]byte, 0, X52ProMFDWidth)
+	for _, r := range value {
+		if len(chars) == X52ProMFDWidth {
+			break
+		}
+		if r > 0x7F {
+			r = '?'
+		}
+		chars = append(chars, byte(r))
+	}
+	if len(chars)%2 == 1 {
+		chars = append(chars, ' ')
+	}
+	for i := 0; i < len(chars); i += 2 {
+		if err := x.command(line, uint16(chars[i+1])<<8|uint16(chars[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *X52ProUSB) SoftButtons() <-chan uint32 {
+	return nil
+}
+
+func (x *X52ProUSB) Close() error {
+	return x.device.Close()
+}
@@ -0,0 +1,219 @@
+// Package gauge builds FIP instruments from declarative JSON or YAML
+// definitions instead of Go code. A definition is a stack of layers drawn
+// in a fixed design coordinate space; layers can be bound to named data
+// values that move needles, scroll tapes, fill readouts and toggle
+// visibility.
+package gauge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layer types
+const (
+	LayerRect    = "rect"
+	LayerCircle  = "circle"
+	LayerPolygon = "polygon"
+	LayerLine    = "line"
+	LayerArc     = "arc"
+	LayerTicks   = "ticks"
+	LayerLabels  = "labels"
+	LayerImage   = "image"
+	LayerText    = "text"
+	LayerNeedle  = "needle"
+	LayerTape    = "tape"
+	LayerGroup   = "group"
+)
+
+// Definition describes a gauge. Coordinates are in design units that are
+// scaled to the size of the surface being drawn on.
+type Definition struct {
+	Name       string  `json:"name" yaml:"name"`
+	Width      float64 `json:"width" yaml:"width"`
+	Height     float64 `json:"height" yaml:"height"`
+	Background string  `json:"background,omitempty" yaml:"background,omitempty"`
+	Layers     []Layer `json:"layers" yaml:"layers"`
+}
+
+// Point is an x, y pair
+type Point [2]float64
+
+// Rect is an x, y, width, height quad
+type Rect [4]float64
+
+// Curve maps an input value to an output by linear interpolation between
+// points sorted by input. Inputs outside the curve are clamped to its ends.
+type Curve []Point
+
+// Binding reads a named data value, optionally wraps it with Modulo and maps
+// it through Curve
+type Binding struct {
+	Value  string  `json:"value,omitempty" yaml:"value,omitempty"`
+	Modulo float64 `json:"modulo,omitempty" yaml:"modulo,omitempty"`
+	Curve  Curve   `json:"curve,omitempty" yaml:"curve,omitempty"`
+}
+
+// Condition shows a layer only while a data value is within [Min, Max]
+type Condition struct {
+	Value string   `json:"value" yaml:"value"`
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// Clip restricts a group to a rectangle or a circle
+type Clip struct {
+	Rect   *Rect   `json:"rect,omitempty" yaml:"rect,omitempty"`
+	Center *Point  `json:"center,omitempty" yaml:"center,omitempty"`
+	Radius float64 `json:"radius,omitempty" yaml:"radius,omitempty"`
+}
+
+// Transform moves a group by a bound value. For rotation the bound value is
+// in degrees clockwise about the group's pivot; for translation it is an
+// offset along Axis ("x" or "y") applied after rotation.
+type Transform struct {
+	Binding `json:",inline" yaml:",inline"`
+	Axis    string `json:"axis,omitempty" yaml:"axis,omitempty"`
+}
+
+// Layer is one drawing step. Which fields apply depends on Type:
+//
+//   - rect, circle, polygon, line: Rect, Center/Radius or Points, painted
+//     with Fill and Stroke
+//   - arc, ticks, labels: dial marks around Center at Radius for values From
+//     to To, placed at the dial position (degrees clockwise from 12 o'clock)
+//     given by Curve
+//   - image: Src drawn into Rect
+//   - text: Text, or a bound value printed with Format, anchored at Pos
+//   - needle: rotated about Pivot by the bound value in dial degrees
+//   - tape: a scale scrolling through Rect, centred on the bound value
+//   - group: nested Layers with optional Clip, Rotate and Translate
+type Layer struct {
+	Type string     `json:"type" yaml:"type"`
+	When *Condition `json:"when,omitempty" yaml:"when,omitempty"`
+
+	Binding `json:",inline" yaml:",inline"`
+
+	// Geometry
+	Rect   *Rect   `json:"rect,omitempty" yaml:"rect,omitempty"`
+	Center Point   `json:"center,omitempty" yaml:"center,omitempty"`
+	Radius float64 `json:"radius,omitempty" yaml:"radius,omitempty"`
+	Points []Point `json:"points,omitempty" yaml:"points,omitempty"`
+	Pos    Point   `json:"pos,omitempty" yaml:"pos,omitempty"`
+
+	// Paint
+	Fill        string  `json:"fill,omitempty" yaml:"fill,omitempty"`
+	Stroke      string  `json:"stroke,omitempty" yaml:"stroke,omitempty"`
+	StrokeWidth float64 `json:"stroke_width,omitempty" yaml:"stroke_width,omitempty"`
+	Cap         string  `json:"cap,omitempty" yaml:"cap,omitempty"`
+
+	// Dial scales
+	From   float64  `json:"from,omitempty" yaml:"from,omitempty"`
+	To     float64  `json:"to,omitempty" yaml:"to,omitempty"`
+	Step   float64  `json:"step,omitempty" yaml:"step,omitempty"`
+	Length float64  `json:"length,omitempty" yaml:"length,omitempty"`
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Factor float64  `json:"factor,omitempty" yaml:"factor,omitempty"`
+	Abs    bool     `json:"abs,omitempty" yaml:"abs,omitempty"`
+
+	// Needles
+	Pivot Point   `json:"pivot,omitempty" yaml:"pivot,omitempty"`
+	Tail  float64 `json:"tail,omitempty" yaml:"tail,omitempty"`
+	Width float64 `json:"width,omitempty" yaml:"width,omitempty"`
+	Shape string  `json:"shape,omitempty" yaml:"shape,omitempty"`
+
+	// Images
+	Src    string `json:"src,omitempty" yaml:"src,omitempty"`
+	Origin Point  `json:"origin,omitempty" yaml:"origin,omitempty"`
+
+	// Text
+	Text         string  `json:"text,omitempty" yaml:"text,omitempty"`
+	Format       string  `json:"format,omitempty" yaml:"format,omitempty"`
+	Font         string  `json:"font,omitempty" yaml:"font,omitempty"`
+	Size         float64 `json:"size,omitempty" yaml:"size,omitempty"`
+	Align        string  `json:"align,omitempty" yaml:"align,omitempty"`
+	Outline      int     `json:"outline,omitempty" yaml:"outline,omitempty"`
+	OutlineColor string  `json:"outline_color,omitempty" yaml:"outline_color,omitempty"`
+
+	// Tapes
+	Horizontal bool    `json:"horizontal,omitempty" yaml:"horizontal,omitempty"`
+	Spacing    float64 `json:"spacing,omitempty" yaml:"spacing,omitempty"`
+	LabelStep  float64 `json:"label_step,omitempty" yaml:"label_step,omitempty"`
+
+	// Groups
+	Layers    []Layer    `json:"layers,omitempty" yaml:"layers,omitempty"`
+	Clip      *Clip      `json:"clip,omitempty" yaml:"clip,omitempty"`
+	Rotate    *Transform `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+	Translate *Transform `json:"translate,omitempty" yaml:"translate,omitempty"`
+}
+
+// Parse decodes a definition. Format is "json" or "yaml"; unknown fields
+// are rejected so typos don't silently drop layers.
+func Parse(data []byte, format string) (*Definition, error) {
+	var def Definition
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&def); err != nil {
+			return nil, fmt.Errorf("failed to parse gauge JSON: %w", err)
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&def); err != nil {
+			return nil, fmt.Errorf("failed to parse gauge YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported gauge format: %s", format)
+	}
+	return &def, nil
+}
+
+// LoadDefinition reads a definition file, choosing the format by extension
+func LoadDefinition(filename string) (*Definition, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gauge file: %w", err)
+	}
+	return Parse(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// Map returns the curve output for v
+func (c Curve) Map(v float64) float64 {
+	switch len(c) {
+	case 0:
+		return v
+	case 1:
+		return c[0][1]
+	}
+	if v <= c[0][0] {
+		return c[0][1]
+	}
+	for i := 1; i < len(c); i++ {
+		if v <= c[i][0] {
+			a, b := c[i-1], c[i]
+			if b[0] == a[0] {
+				return b[1]
+			}
+			return a[1] + (v-a[0])/(b[0]-a[0])*(b[1]-a[1])
+		}
+	}
+	return c[len(c)-1][1]
+}
+
+// validate checks that the curve inputs are in ascending order
+func (c Curve) validate() error {
+	for i := 1; i < len(c); i++ {
+		if c[i][0] < c[i-1][0] {
+			return fmt.Errorf("curve inputs must be ascending")
+		}
+	}
+	return nil
+}
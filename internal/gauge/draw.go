@@ -0,0 +1,431 @@
+package gauge
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/font"
+
+	"saitek-controller/internal/render"
+	"saitek-controller/internal/text"
+)
+
+// drawer carries the state of one render pass
+type drawer struct {
+	c         *render.Canvas
+	values    Values
+	textScale float64
+	faces     map[string]font.Face
+	clipDepth int
+}
+
+// close releases the faces created during the pass
+func (d *drawer) close() {
+	for _, f := range d.faces {
+		f.Close()
+	}
+}
+
+// eval reads a binding's value, wrapping and mapping it
+func (d *drawer) eval(b Binding) float64 {
+	v := d.values[b.Value]
+	if b.Modulo > 0 {
+		v = math.Mod(v, b.Modulo)
+		if v < 0 {
+			v += b.Modulo
+		}
+	}
+	return b.Curve.Map(v)
+}
+
+// visible evaluates a layer's condition
+func (d *drawer) visible(w *Condition) bool {
+	if w == nil {
+		return true
+	}
+	v := d.values[w.Value]
+	if w.Min != nil && v < *w.Min {
+		return false
+	}
+	if w.Max != nil && v > *w.Max {
+		return false
+	}
+	return true
+}
+
+// draw draws a layer and its children
+func (d *drawer) draw(l *layer) {
+	if !d.visible(l.When) {
+		return
+	}
+
+	switch l.Type {
+	case LayerRect:
+		r := *l.Rect
+		d.paint(render.NewPath().Rect(r[0], r[1], r[2], r[3]), l)
+	case LayerCircle:
+		d.paint(render.NewPath().Circle(l.Center[0], l.Center[1], l.Radius), l)
+	case LayerPolygon:
+		d.paint(render.NewPath().Polygon(points(l.Points)...), l)
+	case LayerLine:
+		p := render.NewPath().MoveTo(l.Points[0][0], l.Points[0][1])
+		for _, pt := range l.Points[1:] {
+			p.LineTo(pt[0], pt[1])
+		}
+		d.c.Stroke(p, orWhite(l.stroke), l.strokeStyle())
+	case LayerArc:
+		from, to := l.Curve.Map(l.From), l.Curve.Map(l.To)
+		if from > to {
+			from, to = to, from
+		}
+		p := render.NewPath().Arc(l.Center[0], l.Center[1], l.Radius, dialAngle(from), dialAngle(to))
+		d.c.Stroke(p, orWhite(l.stroke), l.strokeStyle())
+	case LayerTicks:
+		d.drawTicks(l)
+	case LayerLabels:
+		d.drawLabels(l)
+	case LayerImage:
+		d.drawImage(l)
+	case LayerText:
+		s := l.Text
+		if l.Value != "" {
+			s = l.format(d.eval(l.Binding))
+		}
+		d.drawText(l, s, l.Pos[0], l.Pos[1])
+	case LayerNeedle:
+		d.drawNeedle(l)
+	case LayerTape:
+		d.drawTape(l)
+	case LayerGroup:
+		d.drawGroup(l)
+	}
+}
+
+// paint fills and strokes a closed shape
+func (d *drawer) paint(p *render.Path, l *layer) {
+	if l.fill != nil {
+		d.c.Fill(p, l.fill)
+	}
+	if l.stroke != nil {
+		d.c.Stroke(p, l.stroke, l.strokeStyle())
+	}
+}
+
+// drawTicks draws marks from Radius inwards at each step between From and To
+func (d *drawer) drawTicks(l *layer) {
+	length := l.Length
+	if length == 0 {
+		length = 10
+	}
+	p := render.NewPath()
+	for v := l.From; v <= l.To+1e-9; v += l.Step {
+		deg := l.Curve.Map(v)
+		x1, y1 := polar(l.Center, l.Radius, deg)
+		x2, y2 := polar(l.Center, l.Radius-length, deg)
+		p.MoveTo(x1, y1).LineTo(x2, y2)
+	}
+	d.c.Stroke(p, orWhite(l.stroke), l.strokeStyle())
+}
+
+// drawLabels draws a label at Radius for each step between From and To
+func (d *drawer) drawLabels(l *layer) {
+	i := 0
+	for v := l.From; v <= l.To+1e-9; v += l.Step {
+		s := l.format(v)
+		if len(l.Labels) > 0 {
+			if i >= len(l.Labels) {
+				break
+			}
+			s = l.Labels[i]
+		}
+		x, y := polar(l.Center, l.Radius, l.Curve.Map(v))
+		d.drawText(l, s, x, y)
+		i++
+	}
+}
+
+// drawImage draws the image scaled into its rectangle
+func (d *drawer) drawImage(l *layer) {
+	b := l.img.Bounds()
+	r := Rect{0, 0, float64(b.Dx()), float64(b.Dy())}
+	if l.Rect != nil {
+		r = *l.Rect
+	}
+	d.c.Save()
+	d.c.Translate(r[0], r[1])
+	d.c.Scale(r[2]/float64(b.Dx()), r[3]/float64(b.Dy()))
+	d.c.DrawImage(l.img, 0, 0)
+	d.c.Restore()
+}
+
+// drawNeedle draws a needle pointing up at zero, rotated by the bound value
+func (d *drawer) drawNeedle(l *layer) {
+	col := orWhite(l.fill)
+	width := l.Width
+	if width == 0 {
+		width = 6
+	}
+
+	d.c.Save()
+	defer d.c.Restore()
+	d.c.Translate(l.Pivot[0], l.Pivot[1])
+	d.c.Rotate(render.Deg(d.eval(l.Binding)))
+
+	if l.img != nil {
+		d.c.DrawImage(l.img, -l.Origin[0], -l.Origin[1])
+		return
+	}
+
+	switch l.Shape {
+	case "line":
+		p := render.NewPath().MoveTo(0, l.Tail).LineTo(0, -l.Length)
+		d.c.Stroke(p, col, render.StrokeStyle{Width: width, Cap: render.CapRound})
+	case "triangle":
+		d.c.Fill(render.NewPath().Polygon(
+			render.Point{X: -width / 2, Y: l.Tail},
+			render.Point{X: 0, Y: -l.Length},
+			render.Point{X: width / 2, Y: l.Tail},
+		), col)
+	default:
+		d.c.Fill(render.NewPath().Polygon(
+			render.Point{X: -width / 2, Y: l.Tail},
+			render.Point{X: -width / 4, Y: -l.Length + width},
+			render.Point{X: 0, Y: -l.Length},
+			render.Point{X: width / 4, Y: -l.Length + width},
+			render.Point{X: width / 2, Y: l.Tail},
+		), col)
+	}
+}
+
+// drawTape draws a scale that scrolls so the bound value sits at the centre
+// of the rectangle
+func (d *drawer) drawTape(l *layer) {
+	r := *l.Rect
+	v := d.values[l.Value]
+
+	d.c.Save()
+	defer d.c.Restore()
+	d.c.ClipRect(r[0], r[1], r[2], r[3])
+	d.clipDepth++
+	defer func() { d.clipDepth-- }()
+
+	if l.fill != nil {
+		d.c.FillRect(r[0], r[1], r[2], r[3], l.fill)
+	}
+
+	extent := r[3]
+	if l.Horizontal {
+		extent = r[2]
+	}
+	length := l.Length
+	if length == 0 {
+		length = 8
+	}
+	labelStep := l.LabelStep
+	if labelStep == 0 {
+		labelStep = l.Step * 5
+	}
+
+	half := extent / 2 / l.Spacing
+	first := math.Floor((v-half)/l.Step) * l.Step
+	marks := render.NewPath()
+	for m := first; m <= v+half+l.Step; m += l.Step {
+		major := math.Abs(math.Remainder(m, labelStep)) < l.Step/2
+		tick := length
+		if !major {
+			tick = length / 2
+		}
+
+		label := m
+		if l.Modulo > 0 {
+			label = math.Mod(m, l.Modulo)
+			if label < 0 {
+				label += l.Modulo
+			}
+		}
+
+		if l.Horizontal {
+			x := r[0] + r[2]/2 + (m-v)*l.Spacing
+			y := r[1] + r[3]
+			marks.MoveTo(x, y).LineTo(x, y-tick)
+			if major {
+				d.drawText(l, l.format(label), x, y-length-2-l.fontSize()/2)
+			}
+		} else {
+			x := r[0] + r[2]
+			y := r[1] + r[3]/2 - (m-v)*l.Spacing
+			marks.MoveTo(x, y).LineTo(x-tick, y)
+			if major {
+				d.drawText(l, l.format(label), r[0]+(r[2]-length)/2, y)
+			}
+		}
+	}
+	d.c.Stroke(marks, orWhite(l.stroke), l.strokeStyle())
+}
+
+// drawGroup draws child layers with the group's clip and transforms
+func (d *drawer) drawGroup(l *layer) {
+	d.c.Save()
+	defer d.c.Restore()
+
+	if clip := l.Clip; clip != nil {
+		switch {
+		case clip.Rect != nil:
+			d.c.ClipRect(clip.Rect[0], clip.Rect[1], clip.Rect[2], clip.Rect[3])
+		case clip.Center != nil:
+			d.c.Clip(render.NewPath().Circle(clip.Center[0], clip.Center[1], clip.Radius))
+		}
+		d.clipDepth++
+		defer func() { d.clipDepth-- }()
+	}
+	if l.Rotate != nil {
+		d.c.RotateAbout(render.Deg(d.eval(l.Rotate.Binding)), l.Pivot[0], l.Pivot[1])
+	}
+	if l.Translate != nil {
+		offset := d.eval(l.Translate.Binding)
+		if l.Translate.Axis == "x" {
+			d.c.Translate(offset, 0)
+		} else {
+			d.c.Translate(0, offset)
+		}
+	}
+
+	for _, child := range l.children {
+		d.draw(child)
+	}
+}
+
+// face returns a cached face for the layer's font at device size
+func (d *drawer) face(l *layer) font.Face {
+	size := l.fontSize() * d.textScale
+
+	key := fmt.Sprintf("%s/%.2f", l.Font, size)
+	if f, ok := d.faces[key]; ok {
+		return f
+	}
+
+	var f font.Face
+	switch l.Font {
+	case "regular":
+		f = text.Regular(size)
+	case "mono":
+		f = text.Mono(size)
+	case "fixed":
+		f = text.Fixed()
+	default:
+		f = text.Bold(size)
+	}
+	d.faces[key] = f
+	return f
+}
+
+// drawText draws upright text anchored at a point in user space. Inside a
+// clip the text is composited through the canvas so the clip applies.
+func (d *drawer) drawText(l *layer, s string, x, y float64) {
+	if s == "" {
+		return
+	}
+
+	style := text.Style{
+		Face:   d.face(l),
+		Color:  orWhite(l.fill),
+		Align:  text.AlignCenter,
+		VAlign: text.VAlignMiddle,
+	}
+	switch l.Align {
+	case "left":
+		style.Align = text.AlignLeft
+	case "right":
+		style.Align = text.AlignRight
+	}
+	if l.Outline > 0 {
+		style.Outline = l.Outline
+		style.OutlineColor = l.outline
+		if style.OutlineColor == nil {
+			style.OutlineColor = color.Black
+		}
+	}
+
+	tx, ty := d.c.Transform().Apply(x, y)
+	if d.clipDepth == 0 {
+		text.Draw(d.c.Image(), s, tx, ty, style)
+		return
+	}
+
+	b := text.Bounds(s, tx, ty, style)
+	tmp := image.NewRGBA(b)
+	text.Draw(tmp, s, tx, ty, style)
+	d.c.Save()
+	d.c.SetTransform(render.Identity())
+	d.c.DrawImage(tmp, float64(b.Min.X), float64(b.Min.Y))
+	d.c.Restore()
+}
+
+// format formats a scale or readout value
+func (l *layer) format(v float64) string {
+	if l.Factor != 0 {
+		v *= l.Factor
+	}
+	if l.Abs {
+		v = math.Abs(v)
+	}
+	f := l.Format
+	if f == "" {
+		f = "%.0f"
+	}
+	return fmt.Sprintf(f, v)
+}
+
+// fontSize returns the layer's font size in design units
+func (l *layer) fontSize() float64 {
+	if l.Size == 0 {
+		return 14
+	}
+	return l.Size
+}
+
+// strokeStyle returns the layer's stroke width and cap
+func (l *layer) strokeStyle() render.StrokeStyle {
+	style := render.Stroke(l.StrokeWidth)
+	if l.StrokeWidth == 0 {
+		style.Width = 1
+	}
+	switch l.Cap {
+	case "round":
+		style.Cap = render.CapRound
+	case "square":
+		style.Cap = render.CapSquare
+	}
+	return style
+}
+
+// dialAngle converts a dial position in degrees, measured clockwise from
+// 12 o'clock, into a canvas angle in radians
+func dialAngle(degrees float64) float64 {
+	return render.Deg(degrees - 90)
+}
+
+// polar returns the point at radius r and dial position degrees from c
+func polar(c Point, r, degrees float64) (float64, float64) {
+	a := dialAngle(degrees)
+	return c[0] + r*math.Cos(a), c[1] + r*math.Sin(a)
+}
+
+// points converts definition points to render points
+func points(pts []Point) []render.Point {
+	out := make([]render.Point, len(pts))
+	for i, p := range pts {
+		out[i] = render.Point{X: p[0], Y: p[1]}
+	}
+	return out
+}
+
+// orWhite returns c, or white if c is nil
+func orWhite(c color.Color) color.Color {
+	if c == nil {
+		return color.White
+	}
+	return c
+}
@@ -0,0 +1,306 @@
+package gauge
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/font"
+
+	"saitek-controller/internal/fip"
+)
+
+// Gauge is a validated definition ready to render
+type Gauge struct {
+	def        *Definition
+	background color.Color
+	layers     []*layer
+}
+
+// layer is a definition layer with its colors parsed and images loaded
+type layer struct {
+	*Layer
+	fill, stroke, outline color.Color
+	img                   image.Image
+	children              []*layer
+}
+
+// Load reads, validates and compiles a gauge file. Images are resolved
+// relative to the file.
+func Load(filename string) (*Gauge, error) {
+	def, err := LoadDefinition(filename)
+	if err != nil {
+		return nil, err
+	}
+	g, err := New(def, filepath.Dir(filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return g, nil
+}
+
+// New compiles a definition. Image paths are resolved relative to dir.
+func New(def *Definition, dir string) (*Gauge, error) {
+	if def.Width <= 0 || def.Height <= 0 {
+		return nil, fmt.Errorf("gauge size must be positive, got %vx%v", def.Width, def.Height)
+	}
+	if len(def.Layers) == 0 {
+		return nil, fmt.Errorf("gauge has no layers")
+	}
+
+	bg, err := parseColor(def.Background)
+	if err != nil {
+		return nil, fmt.Errorf("background: %w", err)
+	}
+
+	layers, err := compileLayers(def.Layers, dir, "layers")
+	if err != nil {
+		return nil, err
+	}
+	return &Gauge{def: def, background: bg, layers: layers}, nil
+}
+
+// Name returns the gauge name
+func (g *Gauge) Name() string {
+	return g.def.Name
+}
+
+// Definition returns the definition the gauge was built from
+func (g *Gauge) Definition() *Definition {
+	return g.def
+}
+
+// Render draws the gauge with the given data values, scaled to fill the surface
+func (g *Gauge) Render(s *fip.Surface, values Values) error {
+	c := s.Canvas()
+	if g.background != nil {
+		c.Clear(g.background)
+	}
+
+	sx := float64(s.Width()) / g.def.Width
+	sy := float64(s.Height()) / g.def.Height
+	d := &drawer{c: c, values: values, textScale: math.Min(sx, sy), faces: make(map[string]font.Face)}
+	defer d.close()
+
+	c.Save()
+	defer c.Restore()
+	c.Scale(sx, sy)
+	for _, l := range g.layers {
+		d.draw(l)
+	}
+	return nil
+}
+
+// RenderData draws the gauge from instrument data, so a gauge can stand in
+// for the built-in instruments with FIPPanel.SetGauge
+func (g *Gauge) RenderData(s *fip.Surface, data fip.InstrumentData) error {
+	return g.Render(s, FromInstrumentData(data))
+}
+
+// Bind returns a renderer that draws the gauge with fixed values
+func (g *Gauge) Bind(values Values) fip.Renderer {
+	return fip.RendererFunc(func(s *fip.Surface) error {
+		return g.Render(s, values)
+	})
+}
+
+// compileLayers validates layers and resolves their resources
+func compileLayers(defs []Layer, dir, path string) ([]*layer, error) {
+	layers := make([]*layer, 0, len(defs))
+	for i := range defs {
+		where := fmt.Sprintf("%s[%d]", path, i)
+		l, err := compileLayer(&defs[i], dir, where)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	return layers, nil
+}
+
+// compileLayer validates a single layer
+func compileLayer(def *Layer, dir, where string) (*layer, error) {
+	l := &layer{Layer: def}
+	fail := func(format string, args ...interface{}) (*layer, error) {
+		return nil, fmt.Errorf("%s (%s): %s", where, def.Type, fmt.Sprintf(format, args...))
+	}
+
+	var err error
+	if l.fill, err = parseColor(def.Fill); err != nil {
+		return fail("fill: %v", err)
+	}
+	if l.stroke, err = parseColor(def.Stroke); err != nil {
+		return fail("stroke: %v", err)
+	}
+	if l.outline, err = parseColor(def.OutlineColor); err != nil {
+		return fail("outline_color: %v", err)
+	}
+	if err := def.Curve.validate(); err != nil {
+		return fail("%v", err)
+	}
+	if def.When != nil && def.When.Value == "" {
+		return fail("condition needs a value")
+	}
+
+	switch def.Type {
+	case LayerRect:
+		if def.Rect == nil {
+			return fail("rect is required")
+		}
+	case LayerCircle:
+		if def.Radius <= 0 {
+			return fail("radius must be positive")
+		}
+	case LayerPolygon:
+		if len(def.Points) < 3 {
+			return fail("at least three points are required")
+		}
+	case LayerLine:
+		if len(def.Points) < 2 {
+			return fail("at least two points are required")
+		}
+	case LayerArc:
+		if def.Radius <= 0 {
+			return fail("radius must be positive")
+		}
+	case LayerTicks, LayerLabels:
+		if def.Radius <= 0 {
+			return fail("radius must be positive")
+		}
+		if def.Step <= 0 {
+			return fail("step must be positive")
+		}
+	case LayerImage:
+		if def.Src == "" {
+			return fail("src is required")
+		}
+		if l.img, err = loadImage(resolve(dir, def.Src)); err != nil {
+			return fail("%v", err)
+		}
+	case LayerText:
+		if def.Text == "" && def.Value == "" {
+			return fail("text or value is required")
+		}
+	case LayerNeedle:
+		if def.Value == "" {
+			return fail("value is required")
+		}
+		if def.Src != "" {
+			if l.img, err = loadImage(resolve(dir, def.Src)); err != nil {
+				return fail("%v", err)
+			}
+		} else if def.Length <= 0 {
+			return fail("length must be positive")
+		}
+		switch def.Shape {
+		case "", "tapered", "line", "triangle":
+		default:
+			return fail("unknown needle shape %q", def.Shape)
+		}
+	case LayerTape:
+		if def.Value == "" {
+			return fail("value is required")
+		}
+		if def.Rect == nil {
+			return fail("rect is required")
+		}
+		if def.Spacing <= 0 || def.Step <= 0 {
+			return fail("spacing and step must be positive")
+		}
+	case LayerGroup:
+		if len(def.Layers) == 0 {
+			return fail("group has no layers")
+		}
+		if def.Translate != nil && def.Translate.Axis != "x" && def.Translate.Axis != "y" {
+			return fail("translate axis must be x or y")
+		}
+		if l.children, err = compileLayers(def.Layers, dir, where+".layers"); err != nil {
+			return nil, err
+		}
+	default:
+		return fail("unknown layer type")
+	}
+
+	switch def.Cap {
+	case "", "butt", "round", "square":
+	default:
+		return fail("unknown cap %q", def.Cap)
+	}
+	switch def.Align {
+	case "", "left", "center", "right":
+	default:
+		return fail("unknown align %q", def.Align)
+	}
+	switch def.Font {
+	case "", "regular", "bold", "mono", "fixed":
+	default:
+		return fail("unknown font %q", def.Font)
+	}
+	return l, nil
+}
+
+// resolve makes a relative path relative to dir
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// loadImage decodes an image file
+func loadImage(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", filename, err)
+	}
+	return img, nil
+}
+
+// parseColor parses "#rgb", "#rrggbb", "#rrggbbaa" or an SVG color name.
+// An empty string or "none" means no paint.
+func parseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "#") {
+		c, ok := colornames.Map[s]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", s)
+		}
+		return c, nil
+	}
+
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	// Definitions use straight alpha, image/color uses premultiplied
+	a := uint8(v)
+	premul := func(c uint8) uint8 { return uint8(uint32(c) * uint32(a) / 255) }
+	return color.RGBA{premul(uint8(v >> 24)), premul(uint8(v >> 16)), premul(uint8(v >> 8)), a}, nil
+}
@@ -0,0 +1,215 @@
+package gauge
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"saitek-controller/internal/fip"
+)
+
+const testYAML = `
+name: Test
+width: 100
+height: 100
+background: black
+layers:
+  - type: needle
+    value: speed
+    curve: [[0, 0], [100, 180]]
+    pivot: [50, 50]
+    length: 40
+    shape: line
+    width: 4
+    fill: red
+  - type: rect
+    rect: [0, 0, 10, 10]
+    fill: "#00ff00"
+    when: {value: warning, min: 1}
+`
+
+func TestParseFormats(t *testing.T) {
+	def, err := Parse([]byte(testYAML), "yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+	if len(def.Layers) != 2 || def.Layers[0].Value != "speed" || len(def.Layers[0].Curve) != 2 {
+		t.Errorf("Unexpected definition: %+v", def)
+	}
+
+	json := `{"name": "Test", "width": 10, "height": 10,
+		"layers": [{"type": "needle", "value": "speed", "pivot": [5, 5], "length": 4}]}`
+	def, err = Parse([]byte(json), "json")
+	if err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	if def.Layers[0].Value != "speed" || def.Layers[0].Pivot != (Point{5, 5}) {
+		t.Errorf("Unexpected definition: %+v", def.Layers[0])
+	}
+
+	if _, err := Parse([]byte("width: 10\nheigth: 10\n"), "yaml"); err == nil {
+		t.Error("Expected error for unknown YAML field")
+	}
+	if _, err := Parse([]byte(`{"widht": 10}`), "json"); err == nil {
+		t.Error("Expected error for unknown JSON field")
+	}
+	if _, err := Parse(nil, "toml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestValidation(t *testing.T) {
+	for name, src := range map[string]string{
+		"no size":       "layers: [{type: circle, radius: 1}]",
+		"no layers":     "width: 10\nheight: 10",
+		"unknown type":  "width: 10\nheight: 10\nlayers: [{type: hexagon}]",
+		"bad color":     "width: 10\nheight: 10\nlayers: [{type: circle, radius: 1, fill: '#12'}]",
+		"needle value":  "width: 10\nheight: 10\nlayers: [{type: needle, length: 4}]",
+		"curve order":   "width: 10\nheight: 10\nlayers: [{type: needle, value: v, length: 4, curve: [[1, 0], [0, 1]]}]",
+		"missing image": "width: 10\nheight: 10\nlayers: [{type: image, src: missing.png}]",
+		"nested":        "width: 10\nheight: 10\nlayers: [{type: group, layers: [{type: ticks, radius: 1}]}]",
+	} {
+		def, err := Parse([]byte(src), "yaml")
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+		if _, err := New(def, t.TempDir()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCurve(t *testing.T) {
+	c := Curve{{0, 0}, {100, 200}, {200, 250}}
+	tests := map[float64]float64{-10: 0, 0: 0, 50: 100, 150: 225, 300: 250}
+	for in, want := range tests {
+		if got := c.Map(in); got != want {
+			t.Errorf("Map(%v) = %v, expected %v", in, got, want)
+		}
+	}
+	if got := (Curve{}).Map(42); got != 42 {
+		t.Errorf("Expected empty curve to be the identity, got %v", got)
+	}
+}
+
+func TestRenderBindings(t *testing.T) {
+	def, err := Parse([]byte(testYAML), "yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	g, err := New(def, "")
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+
+	// Surface is twice the design size, so the needle tip at zero is (100, 20)
+	s := fip.NewSurface(200, 200)
+	if err := g.Render(s, Values{"speed": 0}); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !isRed(s.Image().At(100, 30)) {
+		t.Error("Expected needle pointing up at zero")
+	}
+	if s.Image().At(5, 5) != (color.RGBA{0, 0, 0, 255}) {
+		t.Error("Expected conditional layer to be hidden")
+	}
+
+	// At 100 the needle points straight down
+	if err := g.Render(s, Values{"speed": 100, "warning": 1}); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !isRed(s.Image().At(100, 170)) || isRed(s.Image().At(100, 30)) {
+		t.Error("Expected needle pointing down at 100")
+	}
+	if s.Image().At(5, 5) != (color.RGBA{0, 255, 0, 255}) {
+		t.Error("Expected conditional layer to be shown")
+	}
+}
+
+func TestExampleGauges(t *testing.T) {
+	files, err := filepath.Glob("../../assets/gauges/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No example gauges found: %v", err)
+	}
+
+	data := fip.InstrumentData{
+		Pitch: 5, Roll: 10, Airspeed: 120, Altitude: 5000, Heading: 180,
+		HeadingBug: 200, VerticalSpeed: 500, TurnRate: 3, Slip: 2,
+	}
+	for _, file := range files {
+		g, err := Load(file)
+		if err != nil {
+			t.Errorf("Failed to load %s: %v", file, err)
+			continue
+		}
+
+		a := fip.NewSurface(320, 240)
+		if err := g.RenderData(a, data); err != nil {
+			t.Errorf("%s: failed to render: %v", file, err)
+			continue
+		}
+		b := fip.NewSurface(320, 240)
+		g.Bind(Values{}).Render(b)
+		if sameImage(a.Image(), b.Image()) {
+			t.Errorf("%s: expected data to change the gauge", file)
+		}
+	}
+}
+
+func TestPanelGauge(t *testing.T) {
+	g, err := Load("../../assets/gauges/airspeed.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load gauge: %v", err)
+	}
+
+	panel, err := fip.NewFIPPanel("Test Panel", 320, 240)
+	if err != nil {
+		t.Fatalf("Failed to create FIP panel: %v", err)
+	}
+	defer panel.Close()
+
+	sink := fip.NewMemorySink(1)
+	panel.AddSink(sink)
+	panel.SetGauge(g)
+	if err := panel.DisplayInstrument(fip.InstrumentData{Airspeed: 100}); err != nil {
+		t.Fatalf("Failed to display gauge: %v", err)
+	}
+
+	want := fip.NewSurface(320, 240)
+	g.Render(want, FromInstrumentData(fip.InstrumentData{Airspeed: 100}))
+	if !sameImage(sink.Last(), want.Image()) {
+		t.Error("Expected panel to display the gauge")
+	}
+}
+
+// isRed reports whether a pixel is mostly red
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
+
+// sameImage reports whether two images have identical pixels
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestFailedValues(t *testing.T) {
+	values := FromInstrumentData(fip.InstrumentData{Airspeed: 90, Failed: fip.Fields(fip.FieldAirspeed, fip.FieldHeadingBug)})
+	if values["airspeed"+FailedSuffix] != 1 || values[ValueHeadingBug+FailedSuffix] != 1 {
+		t.Errorf("Expected failure values, got %v", values)
+	}
+	if _, ok := values[ValueAltitude+FailedSuffix]; ok || values[ValueAirspeed] != 90 {
+		t.Errorf("Unexpected values %v", values)
+	}
+}
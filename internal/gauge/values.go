@@ -0,0 +1,63 @@
+package gauge
+
+import (
+	"saitek-controller/internal/fip"
+)
+
+// Values are the named data values a gauge is bound to. Missing values
+// read as zero.
+type Values map[string]float64
+
+// Names of the values provided by FromInstrumentData
+const (
+	ValuePitch         = "pitch"
+	ValueRoll          = "roll"
+	ValueAirspeed      = "airspeed"
+	ValueAltitude      = "altitude"
+	ValuePressure      = "pressure"
+	ValueHeading       = "heading"
+	ValueHeadingBug    = "heading_bug"
+	ValueVerticalSpeed = "vertical_speed"
+	ValueTurnRate      = "turn_rate"
+	ValueSlip          = "slip"
+	ValueLatitude      = "latitude"
+	ValueLongitude     = "longitude"
+)
+
+// FailedSuffix is appended to a value name for its failure flag, such as
+// "airspeed_failed", which reads 1 while the value's data has stopped
+const FailedSuffix = "_failed"
+
+// FromInstrumentData converts instrument data into named values. An unset
+// pressure reads as standard pressure, as on the built-in altimeter.
+func FromInstrumentData(data fip.InstrumentData) Values {
+	if data.Pressure == 0 {
+		data.Pressure = 29.92
+	}
+	values := Values{
+		ValuePitch:         data.Pitch,
+		ValueRoll:          data.Roll,
+		ValueAirspeed:      data.Airspeed,
+		ValueAltitude:      data.Altitude,
+		ValuePressure:      data.Pressure,
+		ValueHeading:       data.Heading,
+		ValueHeadingBug:    data.HeadingBug,
+		ValueVerticalSpeed: data.VerticalSpeed,
+		ValueTurnRate:      data.TurnRate,
+		ValueSlip:          data.Slip,
+		ValueLatitude:      data.Latitude,
+		ValueLongitude:     data.Longitude,
+	}
+	if data.Failed != 0 {
+		var failed []string
+		for name := range values {
+			if field, err := fip.ParseField(name); err == nil && data.Failed.Has(field) {
+				failed = append(failed, name)
+			}
+		}
+		for _, name := range failed {
+			values[name+FailedSuffix] = 1
+		}
+	}
+	return values
+}
@@ -0,0 +1,237 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Filter adjusts an image in place. Filters expect opaque pixels; use Apply
+// to run them on arbitrary images.
+type Filter interface {
+	Apply(img *image.RGBA)
+}
+
+// FilterFunc adapts a function to the Filter interface
+type FilterFunc func(img *image.RGBA)
+
+// Apply calls f(img)
+func (f FilterFunc) Apply(img *image.RGBA) {
+	f(img)
+}
+
+// Chain is a list of filters applied in order
+type Chain []Filter
+
+// Apply runs every filter of the chain
+func (c Chain) Apply(img *image.RGBA) {
+	for _, f := range c {
+		f.Apply(img)
+	}
+}
+
+// Apply returns an opaque copy of an image, composited over black the way
+// the FIP shows it, with the filters applied. The source is not modified.
+func Apply(img image.Image, filters ...Filter) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	Chain(filters).Apply(dst)
+	return dst
+}
+
+// lut maps each color channel value through a lookup table
+type lut [256]uint8
+
+// Apply maps the color channels of every pixel
+func (l *lut) Apply(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+3 : i+3]
+		p[0], p[1], p[2] = l[p[0]], l[p[1]], l[p[2]]
+	}
+}
+
+// newLUT builds a lookup table from a function on values in [0, 1]
+func newLUT(f func(v float64) float64) *lut {
+	var l lut
+	for i := range l {
+		l[i] = clamp(f(float64(i)/255) * 255)
+	}
+	return &l
+}
+
+// Gamma applies gamma correction: values above 1 brighten mid tones, values
+// below 1 darken them. Black and white are unchanged.
+func Gamma(gamma float64) Filter {
+	if gamma <= 0 {
+		gamma = 1
+	}
+	return newLUT(func(v float64) float64 { return math.Pow(v, 1/gamma) })
+}
+
+// Brightness adds an offset in [-1, 1] to every channel
+func Brightness(offset float64) Filter {
+	return newLUT(func(v float64) float64 { return v + offset })
+}
+
+// Contrast scales channels around mid grey: 1 leaves the image unchanged, 0
+// makes it flat grey and values above 1 increase contrast
+func Contrast(factor float64) Filter {
+	return newLUT(func(v float64) float64 { return (v-0.5)*factor + 0.5 })
+}
+
+// NightMode converts the image to red on black at the given brightness in
+// [0, 1], to keep night vision in a dark cockpit
+func NightMode(dim float64) Filter {
+	return FilterFunc(func(img *image.RGBA) {
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := img.Pix[i : i+3 : i+3]
+			y := 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+			p[0], p[1], p[2] = clamp(y*dim), 0, 0
+		}
+	})
+}
+
+// Dither reduces every channel to the given number of bits (1-7) with
+// Floyd–Steinberg error diffusion, which hides banding on low color depth
+// displays. Other values leave the image unchanged.
+func Dither(bits int) Filter {
+	return FilterFunc(func(img *image.RGBA) {
+		if bits < 1 || bits > 7 {
+			return
+		}
+		floydSteinberg(img, float64(int(1)<<bits-1))
+	})
+}
+
+// floydSteinberg quantizes each channel to levels+1 evenly spaced values,
+// spreading the error 7/16 right, 3/16 down-left, 5/16 down, 1/16 down-right
+func floydSteinberg(img *image.RGBA, levels float64) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	// Errors for the current and next row, with a pixel of margin each side
+	cur := make([]float64, (w+2)*3)
+	next := make([]float64, (w+2)*3)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			for c := 0; c < 3; c++ {
+				e := (x+1)*3 + c
+				v := float64(row[x*4+c]) + cur[e]
+				q := math.Round(math.Max(0, math.Min(255, v))/255*levels) / levels * 255
+				row[x*4+c] = clamp(q)
+				d := v - q
+				cur[e+3] += d * 7 / 16
+				next[e-3] += d * 3 / 16
+				next[e] += d * 5 / 16
+				next[e+3] += d * 1 / 16
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+}
+
+// clamp rounds a value to the nearest byte
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
+
+// ParseFilters parses a comma separated filter chain such as
+// "gamma=2.2,contrast=1.2,night=0.6,dither=5". Filters are gamma,
+// brightness, contrast, night (default 0.6) and dither (default 5 bits).
+func ParseFilters(spec string) (Chain, error) {
+	var chain Chain
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, arg, hasArg := strings.Cut(item, "=")
+		value := 0.0
+		if hasArg {
+			v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for filter %s: %v", name, err)
+			}
+			value = v
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "gamma", "brightness", "contrast":
+			if !hasArg {
+				return nil, fmt.Errorf("filter %s needs a value", name)
+			}
+		}
+		switch name {
+		case "gamma":
+			chain = append(chain, Gamma(value))
+		case "brightness":
+			chain = append(chain, Brightness(value))
+		case "contrast":
+			chain = append(chain, Contrast(value))
+		case "night":
+			if !hasArg {
+				value = 0.6
+			}
+			chain = append(chain, NightMode(value))
+		case "dither":
+			if !hasArg {
+				value = 5
+			}
+			if value < 1 || value > 7 {
+				return nil, fmt.Errorf("dither bits must be between 1 and 7, got %v", value)
+			}
+			chain = append(chain, Dither(int(value)))
+		default:
+			return nil, fmt.Errorf("unknown filter: %s", name)
+		}
+	}
+	return chain, nil
+}
+
+// Adjustments are the common adjustments as separate settings, such as
+// command line flags. Zero values mean no adjustment.
+type Adjustments struct {
+	Gamma      float64 // 1 or 0 for none
+	Brightness float64 // -1 to 1
+	Contrast   float64 // 1 or 0 for none
+	Night      float64 // night mode brightness, 0 for off
+	DitherBits int     // 1-7, 0 for no dithering
+}
+
+// Filters returns the adjustments as a chain: color adjustments first, then
+// night mode, then dithering
+func (a Adjustments) Filters() Chain {
+	var chain Chain
+	if a.Gamma != 0 && a.Gamma != 1 {
+		chain = append(chain, Gamma(a.Gamma))
+	}
+	if a.Brightness != 0 {
+		chain = append(chain, Brightness(a.Brightness))
+	}
+	if a.Contrast != 0 && a.Contrast != 1 {
+		chain = append(chain, Contrast(a.Contrast))
+	}
+	if a.Night > 0 {
+		chain = append(chain, NightMode(a.Night))
+	}
+	if a.DitherBits > 0 {
+		chain = append(chain, Dither(a.DitherBits))
+	}
+	return chain
+}
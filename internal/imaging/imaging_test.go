@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grey returns an opaque image filled with one grey level
+func grey(w, h int, v uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+	}
+	return img
+}
+
+func TestParseResampler(t *testing.T) {
+	for _, r := range []Resampler{ResampleNearest, ResampleBilinear, ResampleCatmullRom, ResampleLanczos} {
+		got, err := ParseResampler(r.String())
+		if err != nil || got != r {
+			t.Errorf("Expected %v to parse back, got %v, %v", r, got, err)
+		}
+	}
+	if _, err := ParseResampler("sinc"); err == nil {
+		t.Error("Expected error for unknown resampler")
+	}
+}
+
+func TestResize(t *testing.T) {
+	// A 2x1 black and white image scaled to 8x1
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.Black)
+	src.Set(1, 0, color.White)
+
+	for _, r := range []Resampler{ResampleNearest, ResampleBilinear, ResampleCatmullRom, ResampleLanczos} {
+		dst := Resize(src, 8, 1, r)
+		if dst.Bounds() != image.Rect(0, 0, 8, 1) {
+			t.Fatalf("%v: unexpected bounds %v", r, dst.Bounds())
+		}
+		if dst.RGBAAt(0, 0).R > 10 || dst.RGBAAt(7, 0).R < 245 {
+			t.Errorf("%v: expected black to white, got %v ... %v", r, dst.RGBAAt(0, 0), dst.RGBAAt(7, 0))
+		}
+		mid := dst.RGBAAt(3, 0).R
+		if r == ResampleNearest && mid != 0 {
+			t.Errorf("%v: expected hard edge, got %d", r, mid)
+		}
+		if r != ResampleNearest && (mid == 0 || mid == 255) {
+			t.Errorf("%v: expected interpolated edge, got %d", r, mid)
+		}
+	}
+}
+
+func TestAdjustments(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		in     uint8
+		want   uint8
+	}{
+		{"gamma brightens", Gamma(2.2), 64, 136},
+		{"gamma keeps white", Gamma(2.2), 255, 255},
+		{"brightness", Brightness(0.2), 100, 151},
+		{"brightness clamps", Brightness(-1), 200, 0},
+		{"contrast", Contrast(2), 192, 255},
+		{"no contrast", Contrast(0), 30, 128},
+	}
+	for _, tt := range tests {
+		img := grey(1, 1, tt.in)
+		tt.filter.Apply(img)
+		if got := img.Pix[0]; got != tt.want || img.Pix[3] != 255 {
+			t.Errorf("%s: %d -> %d, expected %d", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNightMode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{0, 255, 0, 255})
+	NightMode(0.5).Apply(img)
+	if c := img.RGBAAt(0, 0); c.R != 75 || c.G != 0 || c.B != 0 {
+		t.Errorf("Expected dim red, got %v", c)
+	}
+}
+
+func TestDither(t *testing.T) {
+	// A 1-bit dither of 25% grey keeps the average level
+	img := grey(64, 64, 64)
+	Dither(1).Apply(img)
+	white := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		switch img.Pix[i] {
+		case 255:
+			white++
+		case 0:
+		default:
+			t.Fatalf("Expected only black and white, got %d", img.Pix[i])
+		}
+	}
+	if ratio := float64(white) / (64 * 64); ratio < 0.23 || ratio > 0.27 {
+		t.Errorf("Expected about 25%% white pixels, got %.3f", ratio)
+	}
+
+	// Levels already representable are unchanged
+	img = grey(4, 4, 255)
+	Dither(5).Apply(img)
+	if img.Pix[0] != 255 || img.Pix[len(img.Pix)-2] != 255 {
+		t.Error("Expected white to stay white")
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	chain, err := ParseFilters("gamma=2.2, contrast=1.2,night,dither")
+	if err != nil || len(chain) != 4 {
+		t.Fatalf("Expected 4 filters, got %d, %v", len(chain), err)
+	}
+	for _, spec := range []string{"gamma", "blur=2", "dither=9", "contrast=high"} {
+		if _, err := ParseFilters(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+	if chain, err := ParseFilters(""); err != nil || len(chain) != 0 {
+		t.Errorf("Expected empty chain, got %v, %v", chain, err)
+	}
+
+	if n := len((Adjustments{Gamma: 1, Contrast: 1}).Filters()); n != 0 {
+		t.Errorf("Expected neutral adjustments to add no filters, got %d", n)
+	}
+}
+
+func TestApplyCopies(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 12, 12))
+	src.SetNRGBA(10, 10, color.NRGBA{200, 0, 0, 128})
+	dst := Apply(src, Brightness(0.5))
+	if src.NRGBAAt(10, 10).R != 200 {
+		t.Error("Expected source to be unchanged")
+	}
+	if dst.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Errorf("Unexpected bounds %v", dst.Bounds())
+	}
+	// Composited over black, then brightened
+	if c := dst.RGBAAt(0, 0); c.R < 226 || c.R > 229 || c.G != 128 || c.A != 255 {
+		t.Errorf("Unexpected pixel %v", c)
+	}
+}
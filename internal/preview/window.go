@@ -0,0 +1,99 @@
+// Package preview shows FIP frames in a desktop window. It needs OpenGL and
+// a display, so only programs that want a preview should import it.
+package preview
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"golang.org/x/image/colornames"
+)
+
+// Window is a pixelgl window that displays frames written to it. It
+// implements fip.Sink and must be created inside pixelgl.Run.
+type Window struct {
+	Width  int
+	Height int
+	Window *pixelgl.Window
+	Canvas *pixelgl.Canvas
+
+	mu      sync.Mutex
+	pending image.Image
+}
+
+// NewWindow creates a new preview window
+func NewWindow(title string, width, height int) (*Window, error) {
+	cfg := pixelgl.WindowConfig{
+		Title:     title,
+		Bounds:    pixel.R(0, 0, float64(width), float64(height)),
+		Resizable: false,
+		VSync:     true,
+	}
+
+	win, err := pixelgl.NewWindow(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preview window: %w", err)
+	}
+
+	canvas := pixelgl.NewCanvas(pixel.R(0, 0, float64(width), float64(height)))
+
+	return &Window{
+		Width:  width,
+		Height: height,
+		Window: win,
+		Canvas: canvas,
+	}, nil
+}
+
+// WriteFrame queues a frame to be shown on the next update. It is safe to
+// call from any goroutine.
+func (w *Window) WriteFrame(img image.Image) error {
+	w.mu.Lock()
+	w.pending = img
+	w.mu.Unlock()
+	return nil
+}
+
+// Update draws the latest frame and processes window events. It must be
+// called from the pixelgl main thread.
+func (w *Window) Update() {
+	w.mu.Lock()
+	img := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if img != nil {
+		pic := pixel.PictureDataFromImage(img)
+		sprite := pixel.NewSprite(pic, pic.Bounds())
+
+		w.Canvas.Clear(colornames.Black)
+		sprite.Draw(w.Canvas, pixel.IM.Moved(w.Canvas.Bounds().Center()))
+		w.Canvas.Draw(w.Window, pixel.IM)
+	}
+	w.Window.Update()
+}
+
+// Closed reports whether the user has closed the window
+func (w *Window) Closed() bool {
+	return w.Window.Closed()
+}
+
+// Run updates the window until it is closed
+func (w *Window) Run() {
+	for !w.Window.Closed() {
+		w.Update()
+		time.Sleep(time.Millisecond * 16) // ~60 FPS
+	}
+}
+
+// Close destroys the window
+func (w *Window) Close() error {
+	if w.Window != nil {
+		w.Window.Destroy()
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+// Package render provides an anti-aliased 2D vector canvas for drawing
+// instruments and other graphics onto the 320x240 FIP display
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/vector"
+)
+
+// state is the part of the canvas saved and restored by Save and Restore
+type state struct {
+	transform Matrix
+	clip      *image.Alpha
+}
+
+// Canvas draws anti-aliased paths onto an RGBA image
+type Canvas struct {
+	img   *image.RGBA
+	rast  *vector.Rasterizer
+	mask  *image.Alpha
+	state state
+	stack []state
+}
+
+// NewCanvas creates a new canvas backed by a fresh image of the given size
+func NewCanvas(width, height int) *Canvas {
+	return NewCanvasFor(image.NewRGBA(image.Rect(0, 0, width, height)))
+}
+
+// NewCanvasFor creates a new canvas that draws onto an existing image
+func NewCanvasFor(img *image.RGBA) *Canvas {
+	b := img.Bounds()
+	return &Canvas{
+		img:   img,
+		rast:  vector.NewRasterizer(b.Dx(), b.Dy()),
+		mask:  image.NewAlpha(image.Rect(0, 0, b.Dx(), b.Dy())),
+		state: state{transform: Identity()},
+	}
+}
+
+// Image returns the image the canvas draws onto
+func (c *Canvas) Image() *image.RGBA {
+	return c.img
+}
+
+// Width returns the canvas width in pixels
+func (c *Canvas) Width() int {
+	return c.img.Bounds().Dx()
+}
+
+// Height returns the canvas height in pixels
+func (c *Canvas) Height() int {
+	return c.img.Bounds().Dy()
+}
+
+// Clear fills the whole canvas with a color, ignoring the transform and clip
+func (c *Canvas) Clear(col color.Color) {
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+// Save pushes the current transform and clip onto the state stack
+func (c *Canvas) Save() {
+	c.stack = append(c.stack, c.state)
+}
+
+// Restore pops the transform and clip saved by the last Save
+func (c *Canvas) Restore() {
+	if len(c.stack) == 0 {
+		return
+	}
+	c.state = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// Transform returns the current transform
+func (c *Canvas) Transform() Matrix {
+	return c.state.transform
+}
+
+// SetTransform replaces the current transform
+func (c *Canvas) SetTransform(m Matrix) {
+	c.state.transform = m
+}
+
+// Concat applies m before the current transform
+func (c *Canvas) Concat(m Matrix) {
+	c.state.transform = c.state.transform.Multiply(m)
+}
+
+// Translate moves the origin by (tx, ty)
+func (c *Canvas) Translate(tx, ty float64) {
+	c.Concat(Translation(tx, ty))
+}
+
+// Rotate rotates the coordinate system by angle radians (clockwise on screen)
+func (c *Canvas) Rotate(angle float64) {
+	c.Concat(Rotation(angle))
+}
+
+// RotateAbout rotates the coordinate system by angle radians around (x, y)
+func (c *Canvas) RotateAbout(angle, x, y float64) {
+	c.Translate(x, y)
+	c.Rotate(angle)
+	c.Translate(-x, -y)
+}
+
+// Scale scales the coordinate system by (sx, sy)
+func (c *Canvas) Scale(sx, sy float64) {
+	c.Concat(Scaling(sx, sy))
+}
+
+// Clip intersects the clip region with the area covered by the path
+func (c *Canvas) Clip(p *Path) {
+	clip := image.NewAlpha(c.mask.Rect)
+	c.rasterize(p, clip)
+	if prev := c.state.clip; prev != nil {
+		for i := range clip.Pix {
+			clip.Pix[i] = uint8(uint16(clip.Pix[i]) * uint16(prev.Pix[i]) / 255)
+		}
+	}
+	c.state.clip = clip
+}
+
+// ClipRect intersects the clip region with a rectangle
+func (c *Canvas) ClipRect(x, y, w, h float64) {
+	c.Clip(NewPath().Rect(x, y, w, h))
+}
+
+// Fill fills the path with a color using the non-zero winding rule
+func (c *Canvas) Fill(p *Path, col color.Color) {
+	if p.Empty() {
+		return
+	}
+	c.rasterize(p, c.mask)
+	c.composite(col)
+}
+
+// Stroke outlines the path with a color
+func (c *Canvas) Stroke(p *Path, col color.Color, style StrokeStyle) {
+	c.Fill(p.outline(style), col)
+}
+
+// FillCircle fills a circle
+func (c *Canvas) FillCircle(cx, cy, r float64, col color.Color) {
+	c.Fill(NewPath().Circle(cx, cy, r), col)
+}
+
+// StrokeCircle outlines a circle
+func (c *Canvas) StrokeCircle(cx, cy, r float64, col color.Color, width float64) {
+	c.Stroke(NewPath().Circle(cx, cy, r), col, Stroke(width))
+}
+
+// FillRect fills a rectangle
+func (c *Canvas) FillRect(x, y, w, h float64, col color.Color) {
+	c.Fill(NewPath().Rect(x, y, w, h), col)
+}
+
+// Line draws a straight line with butt caps
+func (c *Canvas) Line(x1, y1, x2, y2 float64, col color.Color, width float64) {
+	c.Stroke(NewPath().MoveTo(x1, y1).LineTo(x2, y2), col, Stroke(width))
+}
+
+// DrawImage draws img with its top-left corner at (x, y) in user space,
+// honouring the current transform and clip
+func (c *Canvas) DrawImage(img image.Image, x, y float64) {
+	origin := c.img.Bounds().Min
+	m := Translation(float64(origin.X), float64(origin.Y)).Multiply(c.state.transform)
+	m = m.Multiply(Translation(x, y))
+	b := img.Bounds()
+	// Map source pixel coordinates, not offsets from the bounds origin
+	m = m.Multiply(Translation(-float64(b.Min.X), -float64(b.Min.Y)))
+	aff := f64.Aff3{m[0], m[2], m[4], m[1], m[3], m[5]}
+
+	var opts *xdraw.Options
+	if c.state.clip != nil {
+		opts = &xdraw.Options{DstMask: c.state.clip, DstMaskP: image.Point{}.Sub(origin)}
+	}
+	xdraw.BiLinear.Transform(c.img, aff, img, b, xdraw.Over, opts)
+}
+
+// rasterize renders the coverage of the path, in device space, into dst
+func (c *Canvas) rasterize(p *Path, dst *image.Alpha) {
+	b := dst.Rect
+	c.rast.Reset(b.Dx(), b.Dy())
+	c.rast.DrawOp = draw.Src
+
+	m := c.state.transform
+	for _, sp := range p.subpaths {
+		for i, pt := range sp.points {
+			x, y := m.Apply(pt.X, pt.Y)
+			if i == 0 {
+				c.rast.MoveTo(float32(x), float32(y))
+			} else {
+				c.rast.LineTo(float32(x), float32(y))
+			}
+		}
+		c.rast.ClosePath()
+	}
+
+	c.rast.Draw(dst, b, image.Opaque, image.Point{})
+}
+
+// composite paints col through the coverage mask and the clip
+func (c *Canvas) composite(col color.Color) {
+	mask := c.mask
+	if clip := c.state.clip; clip != nil {
+		for i := range mask.Pix {
+			mask.Pix[i] = uint8(uint16(mask.Pix[i]) * uint16(clip.Pix[i]) / 255)
+		}
+	}
+
+	r := coverageBounds(mask)
+	if r.Empty() {
+		return
+	}
+	dr := r.Add(c.img.Bounds().Min)
+	draw.DrawMask(c.img, dr, image.NewUniform(col), image.Point{}, mask, r.Min, draw.Over)
+}
+
+// coverageBounds returns the smallest rectangle containing non-zero coverage
+func coverageBounds(mask *image.Alpha) image.Rectangle {
+	b := mask.Rect
+	minX, minY, maxX, maxY := math.MaxInt32, math.MaxInt32, -1, -1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := mask.Pix[(y-b.Min.Y)*mask.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			if row[x] == 0 {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			maxY = y
+		}
+	}
+	if maxX < 0 {
+		return image.Rectangle{}
+	}
+	return image.Rect(b.Min.X+minX, minY, b.Min.X+maxX+1, maxY+1)
+}
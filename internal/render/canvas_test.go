@@ -0,0 +1,119 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+var white = color.RGBA{255, 255, 255, 255}
+
+func alphaAt(c *Canvas, x, y int) uint8 {
+	return c.Image().RGBAAt(x, y).A
+}
+
+func TestFillRect(t *testing.T) {
+	c := NewCanvas(20, 20)
+	c.FillRect(5, 5, 10, 10, white)
+
+	if alphaAt(c, 10, 10) != 255 {
+		t.Errorf("Expected inside of rectangle to be filled")
+	}
+	if alphaAt(c, 2, 2) != 0 {
+		t.Errorf("Expected outside of rectangle to be empty")
+	}
+}
+
+func TestFillCircleIsAntiAliased(t *testing.T) {
+	c := NewCanvas(40, 40)
+	c.FillCircle(20, 20, 10.5, white)
+
+	if alphaAt(c, 20, 20) != 255 {
+		t.Errorf("Expected centre of circle to be filled")
+	}
+
+	partial := 0
+	pix := c.Image().Pix
+	for i := 3; i < len(pix); i += 4 {
+		if pix[i] > 0 && pix[i] < 255 {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Errorf("Expected partially covered pixels along the circle edge")
+	}
+}
+
+func TestRingLeavesHole(t *testing.T) {
+	c := NewCanvas(40, 40)
+	c.Fill(NewPath().Ring(20, 20, 8, 15), white)
+
+	if alphaAt(c, 20, 20) != 0 {
+		t.Errorf("Expected ring centre to be empty")
+	}
+	if alphaAt(c, 20+11, 20) != 255 {
+		t.Errorf("Expected ring band to be filled")
+	}
+}
+
+func TestStrokeJoinsDoNotCancel(t *testing.T) {
+	c := NewCanvas(40, 40)
+	p := NewPath().MoveTo(5, 20).LineTo(20, 20).LineTo(20, 35)
+	c.Stroke(p, white, Stroke(4))
+
+	for _, pt := range [][2]int{{10, 20}, {20, 20}, {20, 30}} {
+		if alphaAt(c, pt[0], pt[1]) != 255 {
+			t.Errorf("Expected stroke to cover (%d, %d)", pt[0], pt[1])
+		}
+	}
+	if alphaAt(c, 10, 30) != 0 {
+		t.Errorf("Expected stroke not to cover (10, 30)")
+	}
+}
+
+func TestTransformStack(t *testing.T) {
+	c := NewCanvas(40, 40)
+	c.Save()
+	c.RotateAbout(math.Pi/2, 20, 20)
+	// A horizontal bar to the right of centre becomes a vertical bar below it
+	c.FillRect(25, 18, 10, 4, white)
+	c.Restore()
+
+	if alphaAt(c, 20, 30) != 255 {
+		t.Errorf("Expected rotated bar below centre")
+	}
+	if alphaAt(c, 30, 20) != 0 {
+		t.Errorf("Expected nothing right of centre after rotation")
+	}
+	if c.Transform() != Identity() {
+		t.Errorf("Expected Restore to reset the transform, got %v", c.Transform())
+	}
+}
+
+func TestClip(t *testing.T) {
+	c := NewCanvas(40, 40)
+	c.Save()
+	c.ClipRect(0, 0, 20, 40)
+	c.FillRect(0, 0, 40, 40, white)
+	c.Restore()
+
+	if alphaAt(c, 10, 10) != 255 {
+		t.Errorf("Expected inside of clip to be filled")
+	}
+	if alphaAt(c, 30, 10) != 0 {
+		t.Errorf("Expected outside of clip to be empty")
+	}
+
+	c.FillRect(0, 0, 40, 40, white)
+	if alphaAt(c, 30, 10) != 255 {
+		t.Errorf("Expected Restore to remove the clip")
+	}
+}
+
+func TestMatrixMultiply(t *testing.T) {
+	m := Translation(10, 0).Multiply(Scaling(2, 2))
+	x, y := m.Apply(1, 1)
+	if x != 12 || y != 2 {
+		t.Errorf("Expected (12, 2), got (%v, %v)", x, y)
+	}
+}
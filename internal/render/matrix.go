@@ -0,0 +1,51 @@
+package render
+
+import "math"
+
+// Matrix is a 2D affine transform stored as [a b c d e f], mapping
+// (x, y) to (a*x + c*y + e, b*x + d*y + f)
+type Matrix [6]float64
+
+// Identity returns the identity transform
+func Identity() Matrix {
+	return Matrix{1, 0, 0, 1, 0, 0}
+}
+
+// Translation returns a transform that moves points by (tx, ty)
+func Translation(tx, ty float64) Matrix {
+	return Matrix{1, 0, 0, 1, tx, ty}
+}
+
+// Rotation returns a transform that rotates points by angle radians.
+// Because the y axis points down, positive angles rotate clockwise on screen.
+func Rotation(angle float64) Matrix {
+	s, c := math.Sincos(angle)
+	return Matrix{c, s, -s, c, 0, 0}
+}
+
+// Scaling returns a transform that scales points by (sx, sy)
+func Scaling(sx, sy float64) Matrix {
+	return Matrix{sx, 0, 0, sy, 0, 0}
+}
+
+// Multiply returns the transform that applies n first and then m
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// Apply transforms the point (x, y)
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// Deg converts degrees to radians
+func Deg(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
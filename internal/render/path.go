@@ -0,0 +1,193 @@
+package render
+
+import "math"
+
+// Point is a position in user space
+type Point struct {
+	X, Y float64
+}
+
+// subpath is a flattened run of connected points
+type subpath struct {
+	points []Point
+	closed bool
+}
+
+// Path is a sequence of sub-paths built from lines, curves and arcs.
+// Curves are flattened into line segments as they are added.
+type Path struct {
+	subpaths []subpath
+}
+
+// NewPath creates a new empty path
+func NewPath() *Path {
+	return &Path{}
+}
+
+// current returns the sub-path being built, or nil if there is none
+func (p *Path) current() *subpath {
+	if len(p.subpaths) == 0 {
+		return nil
+	}
+	sp := &p.subpaths[len(p.subpaths)-1]
+	if sp.closed {
+		return nil
+	}
+	return sp
+}
+
+// pen returns the last point of the path
+func (p *Path) pen() (Point, bool) {
+	if len(p.subpaths) == 0 {
+		return Point{}, false
+	}
+	sp := p.subpaths[len(p.subpaths)-1]
+	if sp.closed {
+		return sp.points[0], true
+	}
+	return sp.points[len(sp.points)-1], true
+}
+
+// MoveTo starts a new sub-path at (x, y)
+func (p *Path) MoveTo(x, y float64) *Path {
+	p.subpaths = append(p.subpaths, subpath{points: []Point{{x, y}}})
+	return p
+}
+
+// LineTo adds a straight line to (x, y)
+func (p *Path) LineTo(x, y float64) *Path {
+	sp := p.current()
+	if sp == nil {
+		return p.MoveTo(x, y)
+	}
+	if last := sp.points[len(sp.points)-1]; last.X == x && last.Y == y {
+		return p
+	}
+	sp.points = append(sp.points, Point{x, y})
+	return p
+}
+
+// QuadTo adds a quadratic Bézier curve through control point (cx, cy) to (x, y)
+func (p *Path) QuadTo(cx, cy, x, y float64) *Path {
+	start, ok := p.pen()
+	if !ok {
+		return p.MoveTo(x, y)
+	}
+	n := curveSegments(start, Point{cx, cy}, Point{x, y})
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		p.LineTo(
+			u*u*start.X+2*u*t*cx+t*t*x,
+			u*u*start.Y+2*u*t*cy+t*t*y,
+		)
+	}
+	return p
+}
+
+// CubicTo adds a cubic Bézier curve through control points (c1x, c1y) and (c2x, c2y) to (x, y)
+func (p *Path) CubicTo(c1x, c1y, c2x, c2y, x, y float64) *Path {
+	start, ok := p.pen()
+	if !ok {
+		return p.MoveTo(x, y)
+	}
+	n := curveSegments(start, Point{c1x, c1y}, Point{c2x, c2y}, Point{x, y})
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		p.LineTo(
+			u*u*u*start.X+3*u*u*t*c1x+3*u*t*t*c2x+t*t*t*x,
+			u*u*u*start.Y+3*u*u*t*c1y+3*u*t*t*c2y+t*t*t*y,
+		)
+	}
+	return p
+}
+
+// Arc adds a circular arc around (cx, cy) from angle start to end in radians.
+// Angles are measured clockwise on screen from the positive x axis. If a
+// sub-path is open, a line joins its last point to the start of the arc.
+func (p *Path) Arc(cx, cy, r, start, end float64) *Path {
+	n := arcSegments(r, end-start)
+	for i := 0; i <= n; i++ {
+		a := start + (end-start)*float64(i)/float64(n)
+		x, y := cx+r*math.Cos(a), cy+r*math.Sin(a)
+		if i == 0 && p.current() == nil {
+			p.MoveTo(x, y)
+		} else {
+			p.LineTo(x, y)
+		}
+	}
+	return p
+}
+
+// Close closes the current sub-path
+func (p *Path) Close() *Path {
+	if sp := p.current(); sp != nil {
+		sp.closed = true
+	}
+	return p
+}
+
+// Rect adds a closed rectangle
+func (p *Path) Rect(x, y, w, h float64) *Path {
+	return p.MoveTo(x, y).LineTo(x+w, y).LineTo(x+w, y+h).LineTo(x, y+h).Close()
+}
+
+// Circle adds a closed circle
+func (p *Path) Circle(cx, cy, r float64) *Path {
+	p.MoveTo(cx+r, cy)
+	return p.Arc(cx, cy, r, 0, 2*math.Pi).Close()
+}
+
+// Ring adds an annulus between radii inner and outer. The inner circle is
+// wound in the opposite direction so that it is left unfilled.
+func (p *Path) Ring(cx, cy, inner, outer float64) *Path {
+	p.Circle(cx, cy, outer)
+	p.MoveTo(cx+inner, cy)
+	return p.Arc(cx, cy, inner, 2*math.Pi, 0).Close()
+}
+
+// Polygon adds a closed polygon through the given points
+func (p *Path) Polygon(points ...Point) *Path {
+	for i, pt := range points {
+		if i == 0 {
+			p.MoveTo(pt.X, pt.Y)
+		} else {
+			p.LineTo(pt.X, pt.Y)
+		}
+	}
+	return p.Close()
+}
+
+// Empty reports whether the path has no sub-paths
+func (p *Path) Empty() bool {
+	return len(p.subpaths) == 0
+}
+
+// arcSegments returns the number of line segments used to flatten an arc
+func arcSegments(r, sweep float64) int {
+	n := int(math.Ceil(math.Abs(sweep) * math.Sqrt(math.Abs(r)) * 1.5))
+	if n < 4 {
+		n = 4
+	}
+	if n > 256 {
+		n = 256
+	}
+	return n
+}
+
+// curveSegments returns the number of line segments used to flatten a Bézier curve
+func curveSegments(points ...Point) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+	}
+	n := int(math.Ceil(length / 3))
+	if n < 4 {
+		n = 4
+	}
+	if n > 128 {
+		n = 128
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+package render
+
+import "math"
+
+// LineCap describes how the ends of open strokes are drawn
+type LineCap int
+
+const (
+	CapButt LineCap = iota
+	CapRound
+	CapSquare
+)
+
+// StrokeStyle configures how a path is outlined
+type StrokeStyle struct {
+	Width float64
+	Cap   LineCap
+}
+
+// Stroke returns a stroke style of the given width with butt caps
+func Stroke(width float64) StrokeStyle {
+	return StrokeStyle{Width: width}
+}
+
+// outline converts the path into a fillable path covering its stroke.
+// Every segment becomes a quad and every join a disc, all wound the same
+// way so that overlaps merge rather than cancel.
+func (p *Path) outline(style StrokeStyle) *Path {
+	out := NewPath()
+	hw := style.Width / 2
+	if hw <= 0 {
+		return out
+	}
+
+	for _, sp := range p.subpaths {
+		pts := sp.points
+		if sp.closed && len(pts) > 1 {
+			pts = append(append([]Point(nil), pts...), pts[0])
+		}
+
+		if len(pts) == 1 {
+			if style.Cap == CapRound {
+				outlineDisc(out, pts[0], hw)
+			} else if style.Cap == CapSquare {
+				out.Polygon(
+					Point{pts[0].X - hw, pts[0].Y - hw},
+					Point{pts[0].X + hw, pts[0].Y - hw},
+					Point{pts[0].X + hw, pts[0].Y + hw},
+					Point{pts[0].X - hw, pts[0].Y + hw},
+				)
+			}
+			continue
+		}
+
+		last := len(pts) - 2
+		for i := 0; i <= last; i++ {
+			a, b := pts[i], pts[i+1]
+			dx, dy := b.X-a.X, b.Y-a.Y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			ux, uy := dx/length, dy/length
+
+			// Extend open ends for square caps
+			if !sp.closed && style.Cap == CapSquare {
+				if i == 0 {
+					a = Point{a.X - ux*hw, a.Y - uy*hw}
+				}
+				if i == last {
+					b = Point{b.X + ux*hw, b.Y + uy*hw}
+				}
+			}
+
+			nx, ny := -uy*hw, ux*hw
+			out.Polygon(
+				Point{a.X + nx, a.Y + ny},
+				Point{b.X + nx, b.Y + ny},
+				Point{b.X - nx, b.Y - ny},
+				Point{a.X - nx, a.Y - ny},
+			)
+		}
+
+		// Round joins at interior vertices, and at the seam of closed paths
+		for i := 1; i < len(pts)-1; i++ {
+			outlineDisc(out, pts[i], hw)
+		}
+		if sp.closed {
+			outlineDisc(out, pts[0], hw)
+		} else if style.Cap == CapRound {
+			outlineDisc(out, pts[0], hw)
+			outlineDisc(out, pts[len(pts)-1], hw)
+		}
+	}
+
+	return out
+}
+
+// outlineDisc adds a disc wound the same way as the segment quads in outline
+func outlineDisc(out *Path, c Point, r float64) {
+	out.MoveTo(c.X+r, c.Y)
+	out.Arc(c.X, c.Y, r, 2*math.Pi, 0).Close()
+}
@@ -0,0 +1,288 @@
+package text
+
+//go:generate go run gen_bdf.go
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// bdfGlyph is a single bitmap glyph
+type bdfGlyph struct {
+	advance int
+	bounds  image.Rectangle // relative to the dot, y down
+	mask    *image.Alpha
+}
+
+// BDFFont is a bitmap font loaded from a Glyph Bitmap Distribution Format
+// file. It implements font.Face and is safe for concurrent use.
+type BDFFont struct {
+	Name     string
+	ascent   int
+	descent  int
+	glyphs   map[rune]*bdfGlyph
+	fallback *bdfGlyph
+}
+
+var _ font.Face = (*BDFFont)(nil)
+
+// LoadBDFFile loads a BDF font from a file
+func LoadBDFFile(filename string) (*BDFFont, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open font file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseBDF(file)
+}
+
+// ParseBDF parses a BDF font
+func ParseBDF(r io.Reader) (*BDFFont, error) {
+	f := &BDFFont{glyphs: make(map[rune]*bdfGlyph)}
+	scanner := bufio.NewScanner(r)
+
+	var (
+		line        int
+		boxH, boxY  int
+		defaultChar = -1
+		glyph       *bdfGlyph
+		encoding    = -1
+		bitmapRows  = -1
+		started     bool
+	)
+
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("bdf: line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Bitmap rows are bare hex numbers
+		if bitmapRows >= 0 && fields[0] != "ENDCHAR" {
+			b := glyph.mask.Rect
+			if bitmapRows >= b.Dy() {
+				return nil, fail("too many bitmap rows")
+			}
+			row := fields[0]
+			for i := 0; i < len(row); i++ {
+				nibble, err := strconv.ParseUint(row[i:i+1], 16, 8)
+				if err != nil {
+					return nil, fail("invalid bitmap row %q", row)
+				}
+				for bit := 0; bit < 4; bit++ {
+					x := i*4 + bit
+					if x < b.Dx() && nibble&(8>>uint(bit)) != 0 {
+						glyph.mask.Pix[bitmapRows*glyph.mask.Stride+x] = 0xff
+					}
+				}
+			}
+			bitmapRows++
+			continue
+		}
+
+		ints := func(n int) ([]int, error) {
+			if len(fields) < n+1 {
+				return nil, fail("%s expects %d values", fields[0], n)
+			}
+			values := make([]int, n)
+			for i := range values {
+				v, err := strconv.Atoi(fields[i+1])
+				if err != nil {
+					return nil, fail("invalid %s value %q", fields[0], fields[i+1])
+				}
+				values[i] = v
+			}
+			return values, nil
+		}
+
+		switch fields[0] {
+		case "STARTFONT":
+			started = true
+		case "FONT":
+			f.Name = strings.Join(fields[1:], " ")
+		case "FONTBOUNDINGBOX":
+			v, err := ints(4)
+			if err != nil {
+				return nil, err
+			}
+			boxH, boxY = v[1], v[3]
+		case "FONT_ASCENT":
+			v, err := ints(1)
+			if err != nil {
+				return nil, err
+			}
+			f.ascent = v[0]
+		case "FONT_DESCENT":
+			v, err := ints(1)
+			if err != nil {
+				return nil, err
+			}
+			f.descent = v[0]
+		case "DEFAULT_CHAR":
+			v, err := ints(1)
+			if err != nil {
+				return nil, err
+			}
+			defaultChar = v[0]
+		case "STARTCHAR":
+			glyph = &bdfGlyph{}
+			encoding = -1
+		case "ENCODING":
+			v, err := ints(1)
+			if err != nil {
+				return nil, err
+			}
+			encoding = v[0]
+		case "DWIDTH":
+			if glyph == nil {
+				return nil, fail("DWIDTH outside of a glyph")
+			}
+			v, err := ints(2)
+			if err != nil {
+				return nil, err
+			}
+			glyph.advance = v[0]
+		case "BBX":
+			if glyph == nil {
+				return nil, fail("BBX outside of a glyph")
+			}
+			v, err := ints(4)
+			if err != nil {
+				return nil, err
+			}
+			w, h, xoff, yoff := v[0], v[1], v[2], v[3]
+			if w < 0 || h < 0 {
+				return nil, fail("negative glyph size")
+			}
+			glyph.bounds = image.Rect(xoff, -(yoff + h), xoff+w, -yoff)
+			glyph.mask = image.NewAlpha(image.Rect(0, 0, w, h))
+		case "BITMAP":
+			if glyph == nil || glyph.mask == nil {
+				return nil, fail("BITMAP before BBX")
+			}
+			bitmapRows = 0
+		case "ENDCHAR":
+			if glyph == nil {
+				return nil, fail("ENDCHAR outside of a glyph")
+			}
+			// Glyphs without a Unicode encoding are not addressable
+			if encoding >= 0 {
+				f.glyphs[rune(encoding)] = glyph
+			}
+			glyph = nil
+			bitmapRows = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("bdf: %w", err)
+	}
+	if !started {
+		return nil, fmt.Errorf("bdf: missing STARTFONT")
+	}
+	if len(f.glyphs) == 0 {
+		return nil, fmt.Errorf("bdf: font has no glyphs")
+	}
+
+	if f.ascent == 0 && f.descent == 0 {
+		f.ascent = boxH + boxY
+		f.descent = -boxY
+	}
+	if g, ok := f.glyphs[rune(defaultChar)]; ok {
+		f.fallback = g
+	} else if g, ok := f.glyphs['?']; ok {
+		f.fallback = g
+	}
+
+	return f, nil
+}
+
+// lookup returns the glyph for r, or the default glyph
+func (f *BDFFont) lookup(r rune) (*bdfGlyph, bool) {
+	if g, ok := f.glyphs[r]; ok {
+		return g, true
+	}
+	return f.fallback, f.fallback != nil
+}
+
+// Glyph implements font.Face
+func (f *BDFFont) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	g, ok := f.lookup(r)
+	if !ok {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	// Bitmap glyphs are snapped to whole pixels to stay crisp
+	dr = g.bounds.Add(image.Pt(dot.X.Round(), dot.Y.Round()))
+	return dr, g.mask, image.Point{}, fixed.I(g.advance), true
+}
+
+// GlyphBounds implements font.Face
+func (f *BDFFont) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	g, ok := f.lookup(r)
+	if !ok {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	bounds = fixed.R(g.bounds.Min.X, g.bounds.Min.Y, g.bounds.Max.X, g.bounds.Max.Y)
+	return bounds, fixed.I(g.advance), true
+}
+
+// GlyphAdvance implements font.Face
+func (f *BDFFont) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	g, ok := f.lookup(r)
+	if !ok {
+		return 0, false
+	}
+	return fixed.I(g.advance), true
+}
+
+// Kern implements font.Face. BDF fonts carry no kerning information.
+func (f *BDFFont) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// Metrics implements font.Face
+func (f *BDFFont) Metrics() font.Metrics {
+	m := font.Metrics{
+		Height:  fixed.I(f.ascent + f.descent),
+		Ascent:  fixed.I(f.ascent),
+		Descent: fixed.I(f.descent),
+	}
+	if g, ok := f.glyphs['x']; ok {
+		m.XHeight = fixed.I(g.inkHeight())
+	}
+	if g, ok := f.glyphs['H']; ok {
+		m.CapHeight = fixed.I(g.inkHeight())
+	}
+	return m
+}
+
+// inkHeight returns the distance from the baseline to the topmost set pixel
+func (g *bdfGlyph) inkHeight() int {
+	b := g.mask.Rect
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if g.mask.Pix[y*g.mask.Stride+x] != 0 {
+				return -(g.bounds.Min.Y + y)
+			}
+		}
+	}
+	return 0
+}
+
+// Close implements font.Face
+func (f *BDFFont) Close() error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package text
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+//go:embed fonts/fixed7x13.bdf
+var fixed7x13BDF []byte
+
+var (
+	fixedOnce sync.Once
+	fixedFont *BDFFont
+
+	embeddedMu    sync.Mutex
+	embeddedFonts = make(map[string]*opentype.Font)
+)
+
+// Fixed returns the embedded 7x13 bitmap font, which stays crisp at small sizes
+func Fixed() *BDFFont {
+	fixedOnce.Do(func() {
+		f, err := ParseBDF(bytes.NewReader(fixed7x13BDF))
+		if err != nil {
+			panic(fmt.Sprintf("text: embedded bitmap font is invalid: %v", err))
+		}
+		fixedFont = f
+	})
+	return fixedFont
+}
+
+// Regular returns the embedded Go Regular font at the given pixel size
+func Regular(size float64) font.Face {
+	return embeddedFace("regular", goregular.TTF, size)
+}
+
+// Bold returns the embedded Go Bold font at the given pixel size
+func Bold(size float64) font.Face {
+	return embeddedFace("bold", gobold.TTF, size)
+}
+
+// Mono returns the embedded Go Mono font at the given pixel size
+func Mono(size float64) font.Face {
+	return embeddedFace("mono", gomono.TTF, size)
+}
+
+// embeddedFace creates a face from an embedded font, parsing it on first use.
+// Faces are not safe for concurrent use, so a new one is returned each call.
+func embeddedFace(name string, data []byte, size float64) font.Face {
+	embeddedMu.Lock()
+	f, ok := embeddedFonts[name]
+	if !ok {
+		var err error
+		f, err = opentype.Parse(data)
+		if err != nil {
+			embeddedMu.Unlock()
+			panic(fmt.Sprintf("text: embedded font %s is invalid: %v", name, err))
+		}
+		embeddedFonts[name] = f
+	}
+	embeddedMu.Unlock()
+
+	face, err := NewOpenTypeFace(f, size)
+	if err != nil {
+		panic(fmt.Sprintf("text: failed to create %s face: %v", name, err))
+	}
+	return face
+}
+
+// ParseOpenType parses a TrueType or OpenType font
+func ParseOpenType(data []byte) (*opentype.Font, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	return f, nil
+}
+
+// NewOpenTypeFace creates a hinted face from a parsed font at the given pixel size
+func NewOpenTypeFace(f *opentype.Font, size float64) (font.Face, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid font size: %v", size)
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// LoadFace loads a font file by extension: .ttf and .otf are scaled to size
+// pixels, while .bdf bitmap fonts are used at their native size
+func LoadFace(filename string, size float64) (font.Face, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".bdf":
+		return LoadBDFFile(filename)
+	case ".ttf", ".otf":
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font file: %w", err)
+		}
+		f, err := ParseOpenType(data)
+		if err != nil {
+			return nil, err
+		}
+		return NewOpenTypeFace(f, size)
+	default:
+		return nil, fmt.Errorf("unsupported font format: %s", filepath.Ext(filename))
+	}
+}
@@ -0,0 +1,75 @@
+//go:build ignore
+
+// This program generates fonts/fixed7x13.bdf from the public domain X11
+// misc-fixed glyphs bundled with golang.org/x/image/font/basicfont.
+// Run it with "go generate" in the text package.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+func main() {
+	face := basicfont.Face7x13
+
+	var runes []rune
+	for _, r := range face.Ranges {
+		for c := r.Low; c < r.High; c++ {
+			runes = append(runes, c)
+		}
+	}
+
+	out, err := os.Create("fonts/fixed7x13.bdf")
+	if err != nil {
+		log.Fatalf("failed to create font file: %v", err)
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "STARTFONT 2.1")
+	fmt.Fprintln(w, "COMMENT Generated from golang.org/x/image/font/basicfont.Face7x13")
+	fmt.Fprintln(w, "COMMENT Derived from the public domain X11 misc-fixed font")
+	fmt.Fprintln(w, "FONT -Misc-Fixed-Medium-R-Normal--13-120-75-75-C-70-ISO10646-1")
+	fmt.Fprintln(w, "SIZE 13 75 75")
+	fmt.Fprintf(w, "FONTBOUNDINGBOX %d %d 0 %d\n", face.Width, face.Height, -face.Descent)
+	fmt.Fprintln(w, "STARTPROPERTIES 3")
+	fmt.Fprintf(w, "FONT_ASCENT %d\n", face.Ascent)
+	fmt.Fprintf(w, "FONT_DESCENT %d\n", face.Descent)
+	fmt.Fprintf(w, "DEFAULT_CHAR %d\n", 0xfffd)
+	fmt.Fprintln(w, "ENDPROPERTIES")
+	fmt.Fprintf(w, "CHARS %d\n", len(runes))
+
+	for _, r := range runes {
+		_, mask, maskp, _, ok := face.Glyph(fixed.P(0, face.Ascent), r)
+		if !ok {
+			log.Fatalf("missing glyph %U", r)
+		}
+		fmt.Fprintf(w, "STARTCHAR U+%04X\n", r)
+		fmt.Fprintf(w, "ENCODING %d\n", r)
+		fmt.Fprintf(w, "SWIDTH %d 0\n", face.Advance*1000/face.Height)
+		fmt.Fprintf(w, "DWIDTH %d 0\n", face.Advance)
+		fmt.Fprintf(w, "BBX %d %d 0 %d\n", face.Width, face.Height, -face.Descent)
+		fmt.Fprintln(w, "BITMAP")
+		for y := 0; y < face.Height; y++ {
+			var row byte
+			for x := 0; x < face.Width; x++ {
+				_, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA()
+				if a >= 0x8000 {
+					row |= 0x80 >> uint(x)
+				}
+			}
+			fmt.Fprintf(w, "%02X\n", row)
+		}
+		fmt.Fprintln(w, "ENDCHAR")
+	}
+
+	fmt.Fprintln(w, "ENDFONT")
+}
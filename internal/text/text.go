@@ -0,0 +1,202 @@
+// Package text lays out and draws strings onto images using embedded
+// OpenType fonts or BDF bitmap fonts
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Align is the horizontal alignment of each line relative to the anchor
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// VAlign is the vertical alignment of the text block relative to the anchor
+type VAlign int
+
+const (
+	VAlignBaseline VAlign = iota // anchor is the first line's baseline
+	VAlignTop
+	VAlignMiddle
+	VAlignBottom
+)
+
+// Style controls how text is drawn. A nil Face uses the embedded bitmap
+// font and a nil Color draws white.
+type Style struct {
+	Face         font.Face
+	Color        color.Color
+	Align        Align
+	VAlign       VAlign
+	Tracking     float64 // extra pixels between glyphs
+	LineSpacing  float64 // multiple of the font height, 0 means 1
+	Outline      int     // outline radius in pixels
+	OutlineColor color.Color
+}
+
+// Size is the measured extent of a block of text
+type Size struct {
+	Width   float64
+	Height  float64
+	Ascent  float64 // first line's ascent
+	Descent float64 // last line's descent
+}
+
+// face returns the configured face or the default
+func (s Style) face() font.Face {
+	if s.Face == nil {
+		return Fixed()
+	}
+	return s.Face
+}
+
+// lineHeight returns the distance between consecutive baselines
+func (s Style) lineHeight(m font.Metrics) float64 {
+	spacing := s.LineSpacing
+	if spacing == 0 {
+		spacing = 1
+	}
+	return toFloat(m.Height) * spacing
+}
+
+// Measure returns the size of the text as Draw would lay it out
+func Measure(s string, style Style) Size {
+	face := style.face()
+	m := face.Metrics()
+	lines := strings.Split(s, "\n")
+
+	size := Size{Ascent: toFloat(m.Ascent), Descent: toFloat(m.Descent)}
+	for _, line := range lines {
+		size.Width = math.Max(size.Width, lineWidth(face, line, style.Tracking))
+	}
+	size.Height = size.Ascent + size.Descent + style.lineHeight(m)*float64(len(lines)-1)
+	return size
+}
+
+// Bounds returns the rectangle that Draw would cover, outline included
+func Bounds(s string, x, y float64, style Style) image.Rectangle {
+	size := Measure(s, style)
+	top := blockTop(size, y, style.VAlign)
+	left := x
+	switch style.Align {
+	case AlignCenter:
+		left -= size.Width / 2
+	case AlignRight:
+		left -= size.Width
+	}
+	r := image.Rect(
+		int(math.Floor(left)), int(math.Floor(top)),
+		int(math.Ceil(left+size.Width)), int(math.Ceil(top+size.Height)),
+	)
+	return r.Inset(-style.Outline)
+}
+
+// Draw draws the text anchored at (x, y)
+func Draw(dst draw.Image, s string, x, y float64, style Style) {
+	face := style.face()
+	m := face.Metrics()
+	size := Measure(s, style)
+	baseline := blockTop(size, y, style.VAlign) + size.Ascent
+
+	col := style.Color
+	if col == nil {
+		col = color.White
+	}
+
+	for i, line := range strings.Split(s, "\n") {
+		lx := x
+		switch style.Align {
+		case AlignCenter:
+			lx -= lineWidth(face, line, style.Tracking) / 2
+		case AlignRight:
+			lx -= lineWidth(face, line, style.Tracking)
+		}
+		ly := baseline + style.lineHeight(m)*float64(i)
+
+		if style.Outline > 0 && style.OutlineColor != nil {
+			src := image.NewUniform(style.OutlineColor)
+			o := style.Outline
+			for dy := -o; dy <= o; dy++ {
+				for dx := -o; dx <= o; dx++ {
+					if (dx != 0 || dy != 0) && dx*dx+dy*dy <= o*o+o {
+						drawLine(dst, face, line, lx+float64(dx), ly+float64(dy), style.Tracking, src)
+					}
+				}
+			}
+		}
+		drawLine(dst, face, line, lx, ly, style.Tracking, image.NewUniform(col))
+	}
+}
+
+// blockTop returns the top edge of a text block for a vertical alignment
+func blockTop(size Size, y float64, align VAlign) float64 {
+	switch align {
+	case VAlignTop:
+		return y
+	case VAlignMiddle:
+		return y - size.Height/2
+	case VAlignBottom:
+		return y - size.Height
+	default:
+		return y - size.Ascent
+	}
+}
+
+// lineWidth returns the advance width of a single line, including kerning
+func lineWidth(face font.Face, line string, tracking float64) float64 {
+	var width fixed.Int26_6
+	prev := rune(-1)
+	n := 0
+	for _, r := range line {
+		if prev >= 0 {
+			width += face.Kern(prev, r)
+		}
+		if advance, ok := face.GlyphAdvance(r); ok {
+			width += advance
+		}
+		prev = r
+		n++
+	}
+	if n > 1 {
+		width += toFixed(tracking * float64(n-1))
+	}
+	return toFloat(width)
+}
+
+// drawLine draws a single line with its left edge and baseline at (x, y)
+func drawLine(dst draw.Image, face font.Face, line string, x, y, tracking float64, src image.Image) {
+	dot := fixed.Point26_6{X: toFixed(x), Y: toFixed(y)}
+	prev := rune(-1)
+	for _, r := range line {
+		if prev >= 0 {
+			dot.X += face.Kern(prev, r) + toFixed(tracking)
+		}
+		dr, mask, maskp, advance, ok := face.Glyph(dot, r)
+		if ok {
+			draw.DrawMask(dst, dr, src, image.Point{}, mask, maskp, draw.Over)
+		}
+		dot.X += advance
+		prev = r
+	}
+}
+
+// toFixed converts pixels to 26.6 fixed point
+func toFixed(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * 64))
+}
+
+// toFloat converts 26.6 fixed point to pixels
+func toFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
@@ -0,0 +1,176 @@
+package text
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+const testBDF = `STARTFONT 2.1
+FONT -test-tiny
+FONTBOUNDINGBOX 4 4 0 -1
+STARTPROPERTIES 2
+FONT_ASCENT 3
+FONT_DESCENT 1
+ENDPROPERTIES
+CHARS 2
+STARTCHAR A
+ENCODING 65
+DWIDTH 5 0
+BBX 4 4 0 -1
+BITMAP
+F0
+90
+F0
+90
+ENDCHAR
+STARTCHAR question
+ENCODING 63
+DWIDTH 5 0
+BBX 4 4 0 -1
+BITMAP
+60
+10
+00
+20
+ENDCHAR
+ENDFONT
+`
+
+func TestParseBDF(t *testing.T) {
+	f, err := ParseBDF(strings.NewReader(testBDF))
+	if err != nil {
+		t.Fatalf("Failed to parse font: %v", err)
+	}
+
+	m := f.Metrics()
+	if m.Ascent.Round() != 3 || m.Descent.Round() != 1 {
+		t.Errorf("Expected ascent 3 and descent 1, got %v and %v", m.Ascent, m.Descent)
+	}
+
+	advance, ok := f.GlyphAdvance('A')
+	if !ok || advance.Round() != 5 {
+		t.Errorf("Expected advance 5 for 'A', got %v (%v)", advance, ok)
+	}
+
+	// Unknown runes fall back to '?'
+	if _, ok := f.GlyphAdvance('Z'); !ok {
+		t.Error("Expected fallback glyph for unknown rune")
+	}
+}
+
+func TestParseBDFErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"empty":      "",
+		"no glyphs":  "STARTFONT 2.1\nENDFONT\n",
+		"bad bitmap": "STARTFONT 2.1\nSTARTCHAR A\nENCODING 65\nBBX 4 1 0 0\nBITMAP\nZZ\nENDCHAR\n",
+	} {
+		if _, err := ParseBDF(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDrawBDFGlyph(t *testing.T) {
+	f, err := ParseBDF(strings.NewReader(testBDF))
+	if err != nil {
+		t.Fatalf("Failed to parse font: %v", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	Draw(img, "A", 2, 5, Style{Face: f, Color: color.White})
+
+	// Top row of 'A' sits ascent pixels above the baseline
+	for x := 2; x < 6; x++ {
+		if img.RGBAAt(x, 2).A != 255 {
+			t.Errorf("Expected pixel (%d, 2) to be set", x)
+		}
+	}
+	if img.RGBAAt(3, 3).A != 0 {
+		t.Error("Expected hollow pixel (3, 3) to be clear")
+	}
+}
+
+func TestMeasureAndAlign(t *testing.T) {
+	style := Style{Face: Fixed()}
+	size := Measure("ABC", style)
+	if size.Width != 21 || size.Height != 13 {
+		t.Errorf("Expected 21x13, got %vx%v", size.Width, size.Height)
+	}
+
+	style.Tracking = 2
+	if w := Measure("ABC", style).Width; w != 25 {
+		t.Errorf("Expected tracking to add 4 pixels, got width %v", w)
+	}
+
+	style = Style{Face: Fixed(), Align: AlignCenter, VAlign: VAlignMiddle}
+	r := Bounds("ABC\nDEF", 50, 50, style)
+	if r.Dy() != 26 || r.Min.X != 39 || r.Min.Y != 37 || r.Max.X != 61 {
+		t.Errorf("Unexpected bounds for centred block: %v", r)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	red := color.RGBA{255, 0, 0, 255}
+	Draw(img, "I", 20, 10, Style{
+		Align:        AlignCenter,
+		VAlign:       VAlignMiddle,
+		Outline:      1,
+		OutlineColor: red,
+	})
+
+	foundOutline := false
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] == 255 && img.Pix[i+1] == 0 {
+			foundOutline = true
+			break
+		}
+	}
+	if !foundOutline {
+		t.Error("Expected outline pixels around the glyph")
+	}
+}
+
+// kernedFace adds a fixed kerning adjustment to one pair of runes
+type kernedFace struct {
+	*BDFFont
+}
+
+func (f kernedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if r0 == 'A' && r1 == 'V' {
+		return -fixed.I(2)
+	}
+	return 0
+}
+
+func TestKerning(t *testing.T) {
+	style := Style{Face: kernedFace{Fixed()}}
+	if w := Measure("AV", style).Width; w != 12 {
+		t.Errorf("Expected kerning to tighten \"AV\" to 12 pixels, got %v", w)
+	}
+	if w := Measure("VA", style).Width; w != 14 {
+		t.Errorf("Expected \"VA\" to be unkerned, got %v", w)
+	}
+}
+
+func TestOpenTypeFace(t *testing.T) {
+	face := Regular(24)
+	defer face.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 40))
+	Draw(img, "FIP", 50, 20, Style{Face: face, Align: AlignCenter, VAlign: VAlignMiddle})
+
+	partial := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 && img.Pix[i] < 255 {
+			partial++
+		}
+	}
+	if partial == 0 {
+		t.Error("Expected anti-aliased glyph edges")
+	}
+}
@@ -0,0 +1,96 @@
+package tiles
+
+import (
+	"container/list"
+	"errors"
+	"image"
+	"sync"
+)
+
+// defaultCacheSize is the number of tiles kept when no size is given,
+// enough for a few screens of a 320x240 map
+const defaultCacheSize = 64
+
+// cacheKey identifies a tile
+type cacheKey struct {
+	z, x, y int
+}
+
+// cacheEntry is a decoded tile, or a tile known to be missing
+type cacheEntry struct {
+	key cacheKey
+	img image.Image
+	err error
+}
+
+// Cache keeps the most recently used decoded tiles of a source in memory.
+// Missing tiles are remembered too, so they are only looked up once.
+type Cache struct {
+	mu       sync.Mutex
+	source   Source
+	capacity int
+	order    *list.List // most recently used first
+	entries  map[cacheKey]*list.Element
+}
+
+// NewCache creates a cache holding up to capacity tiles from source, or
+// 64 tiles if capacity is zero or less
+func NewCache(source Source, capacity int) *Cache {
+	if capacity <= 0 {
+		capacity = defaultCacheSize
+	}
+	return &Cache{
+		source:   source,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+	}
+}
+
+// Tile returns a tile from the cache, reading it from the source on a miss
+func (c *Cache) Tile(z, x, y int) (image.Image, error) {
+	key := cacheKey{z, x, y}
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		e := el.Value.(*cacheEntry)
+		c.mu.Unlock()
+		return e.img, e.err
+	}
+	c.mu.Unlock()
+
+	img, err := c.source.Tile(z, x, y)
+	if err != nil && !errors.Is(err, ErrTileNotFound) {
+		// Don't remember read errors, they may be temporary
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, img: img, err: err})
+		for c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return img, err
+}
+
+// Zooms returns the zoom range of the source
+func (c *Cache) Zooms() (min, max int) {
+	return c.source.Zooms()
+}
+
+// Len returns the number of cached tiles
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Close closes the source
+func (c *Cache) Close() error {
+	return c.source.Close()
+}
@@ -0,0 +1,80 @@
+package tiles
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// tileExtensions are the image types looked for in tile directories
+var tileExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// DirSource reads tiles from a directory laid out as z/x/y.png, as written
+// by most tile downloaders
+type DirSource struct {
+	dir     string
+	minZoom int
+	maxZoom int
+}
+
+// OpenDir opens a tile directory. The zoom range is taken from the
+// numbered subdirectories.
+func OpenDir(dir string) (*DirSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tile directory: %w", err)
+	}
+
+	d := &DirSource{dir: dir, minZoom: -1, maxZoom: -1}
+	for _, e := range entries {
+		z, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() || z < 0 {
+			continue
+		}
+		if d.minZoom < 0 || z < d.minZoom {
+			d.minZoom = z
+		}
+		if z > d.maxZoom {
+			d.maxZoom = z
+		}
+	}
+	if d.maxZoom < 0 {
+		return nil, fmt.Errorf("%s has no zoom level directories", dir)
+	}
+	return d, nil
+}
+
+// Tile reads and decodes a tile
+func (d *DirSource) Tile(z, x, y int) (image.Image, error) {
+	base := filepath.Join(d.dir, strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y))
+	for _, ext := range tileExtensions {
+		file, err := os.Open(base + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tile: %w", err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tile %d/%d/%d: %w", z, x, y, err)
+		}
+		return img, nil
+	}
+	return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, z, x, y)
+}
+
+// Zooms returns the lowest and highest zoom levels in the directory
+func (d *DirSource) Zooms() (min, max int) {
+	return d.minZoom, d.maxZoom
+}
+
+// Close implements Source
+func (d *DirSource) Close() error {
+	return nil
+}
@@ -0,0 +1,200 @@
+package tiles
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// MBTiles reads raster tiles from an MBTiles file. Both the plain layout,
+// a tiles table, and the deduplicated layout, a tiles view over map and
+// images tables, are supported.
+type MBTiles struct {
+	mu       sync.Mutex
+	file     *os.File
+	db       *sqliteDB
+	metadata map[string]string
+
+	// Plain layout
+	tiles schemaEntry
+	data  int
+
+	// Deduplicated layout
+	tileMap   schemaEntry
+	images    schemaEntry
+	tileID    int
+	imageData int
+	dedup     bool
+}
+
+// tileKeyColumns identify a tile in the tiles table and the map table
+var tileKeyColumns = []string{"zoom_level", "tile_column", "tile_row"}
+
+// OpenMBTiles opens an MBTiles file
+func OpenMBTiles(path string) (*MBTiles, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MBTiles: %w", err)
+	}
+	m, err := readMBTiles(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.file = file
+	return m, nil
+}
+
+// readMBTiles reads the schema and metadata of an MBTiles database
+func readMBTiles(r io.ReaderAt) (*MBTiles, error) {
+	db, err := openSQLite(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &MBTiles{db: db, metadata: make(map[string]string)}
+
+	if meta, ok := db.entry("metadata"); ok && meta.kind == "table" {
+		err := db.scanTable(meta.root, func(rowid int64, p payload) error {
+			rec, err := db.record(p, -1)
+			if err != nil {
+				return err
+			}
+			name, value := meta.column("name"), meta.column("value")
+			if name >= 0 && value >= 0 && name < len(rec) && value < len(rec) {
+				k, _ := rec[name].(string)
+				v, _ := rec[value].(string)
+				m.metadata[k] = v
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata: %w", err)
+		}
+	}
+	if format := m.metadata["format"]; format == "pbf" || format == "mvt" {
+		return nil, fmt.Errorf("vector tiles (%s) are not supported, use raster tiles", format)
+	}
+
+	tiles, ok := db.entry("tiles")
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("no tiles table")
+	case tiles.kind == "table":
+		m.tiles = tiles
+		m.data = tiles.column("tile_data")
+		for _, c := range tileKeyColumns {
+			if tiles.column(c) < 0 {
+				return nil, fmt.Errorf("tiles table has no %s column", c)
+			}
+		}
+		if m.data < 0 {
+			return nil, fmt.Errorf("tiles table has no tile_data column")
+		}
+	default:
+		// The deduplicated layout created by mbutil and others
+		tileMap, ok1 := db.entry("map")
+		images, ok2 := db.entry("images")
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("unsupported tiles %s", tiles.kind)
+		}
+		m.dedup = true
+		m.tileMap, m.images = tileMap, images
+		m.tileID = tileMap.column("tile_id")
+		m.imageData = images.column("tile_data")
+		if m.tileID < 0 || m.imageData < 0 || images.column("tile_id") < 0 {
+			return nil, fmt.Errorf("map and images tables lack tile_id or tile_data columns")
+		}
+	}
+	return m, nil
+}
+
+// Metadata returns a value from the metadata table, such as "name",
+// "format" or "bounds"
+func (m *MBTiles) Metadata(name string) string {
+	return m.metadata[name]
+}
+
+// TileData returns the encoded image of a tile. MBTiles numbers rows from
+// the south, so y is flipped from the XYZ scheme.
+func (m *MBTiles) TileData(z, x, y int) ([]byte, error) {
+	if z < 0 || z > 30 || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, z, x, y)
+	}
+	key := []interface{}{int64(z), int64(x), int64(1<<uint(z) - 1 - y)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data interface{}
+	if !m.dedup {
+		row, ok, err := m.db.lookup(m.tiles, tileKeyColumns, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tile %d/%d/%d: %w", z, x, y, err)
+		}
+		if !ok || m.data >= len(row) {
+			return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, z, x, y)
+		}
+		data = row[m.data]
+	} else {
+		row, ok, err := m.db.lookup(m.tileMap, tileKeyColumns, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tile %d/%d/%d: %w", z, x, y, err)
+		}
+		if !ok || m.tileID >= len(row) {
+			return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, z, x, y)
+		}
+		img, ok, err := m.db.lookup(m.images, []string{"tile_id"}, []interface{}{row[m.tileID]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tile %d/%d/%d: %w", z, x, y, err)
+		}
+		if !ok || m.imageData >= len(img) {
+			return nil, fmt.Errorf("%w: %d/%d/%d", ErrTileNotFound, z, x, y)
+		}
+		data = img[m.imageData]
+	}
+
+	switch v := data.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("%w: %d/%d/%d has no image", ErrTileNotFound, z, x, y)
+}
+
+// Tile reads and decodes a tile
+func (m *MBTiles) Tile(z, x, y int) (image.Image, error) {
+	data, err := m.TileData(z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile %d/%d/%d: %w", z, x, y, err)
+	}
+	return img, nil
+}
+
+// Zooms returns the zoom range from the metadata, or 0-22 if it is missing
+func (m *MBTiles) Zooms() (min, max int) {
+	min, max = 0, 22
+	if v, err := strconv.Atoi(m.metadata["minzoom"]); err == nil {
+		min = v
+	}
+	if v, err := strconv.Atoi(m.metadata["maxzoom"]); err == nil {
+		max = v
+	}
+	return min, max
+}
+
+// Close closes the file
+func (m *MBTiles) Close() error {
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
@@ -0,0 +1,793 @@
+package tiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// sqliteDB is a minimal read-only reader for SQLite 3 files, enough to
+// look up the rows of an MBTiles file without cgo. It reads rowid tables
+// and uses indexes that compare with the default BINARY collation; views
+// are only understood by the MBTiles code, and WITHOUT ROWID tables and
+// UTF-16 databases are not supported.
+type sqliteDB struct {
+	r        io.ReaderAt
+	pageSize int
+	usable   int
+	schema   []schemaEntry
+	scans    map[string]map[string]int64
+}
+
+// schemaEntry is a table or index from the sqlite_master table
+type schemaEntry struct {
+	kind    string
+	name    string
+	table   string
+	root    uint32
+	sql     string
+	columns []string
+	rowid   int // column that aliases the rowid, or -1
+}
+
+// errShortRecord reports that a record continues on overflow pages
+var errShortRecord = errors.New("record continues on overflow pages")
+
+// errCorrupt reports b-tree cells or overflow chains that are malformed
+var errCorrupt = errors.New("database disk image is malformed")
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// B-tree page types
+const (
+	pageInteriorIndex = 0x02
+	pageInteriorTable = 0x05
+	pageLeafIndex     = 0x0a
+	pageLeafTable     = 0x0d
+)
+
+// openSQLite reads the header and schema of a database
+func openSQLite(r io.ReaderAt) (*sqliteDB, error) {
+	var header [100]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read database header: %w", err)
+	}
+	if string(header[:16]) != sqliteMagic {
+		return nil, errors.New("not an SQLite database")
+	}
+	if enc := binary.BigEndian.Uint32(header[56:]); enc > 1 {
+		return nil, errors.New("UTF-16 databases are not supported")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if pageSize-int(header[20]) < 480 {
+		return nil, fmt.Errorf("invalid reserved space %d", header[20])
+	}
+	db := &sqliteDB{
+		r:        r,
+		pageSize: pageSize,
+		usable:   pageSize - int(header[20]),
+		scans:    make(map[string]map[string]int64),
+	}
+
+	err := db.scanTable(1, func(rowid int64, p payload) error {
+		rec, err := db.record(p, -1)
+		if err != nil {
+			return err
+		}
+		if len(rec) < 5 {
+			return nil
+		}
+		e := schemaEntry{rowid: -1}
+		e.kind, _ = rec[0].(string)
+		e.name, _ = rec[1].(string)
+		e.table, _ = rec[2].(string)
+		root, _ := rec[3].(int64)
+		e.root = uint32(root)
+		e.sql, _ = rec[4].(string)
+		switch e.kind {
+		case "table":
+			e.columns, e.rowid = parseTableColumns(e.sql)
+		case "index":
+			e.columns = parseIndexColumns(e.sql)
+		}
+		db.schema = append(db.schema, e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return db, nil
+}
+
+// entry returns the schema entry for a table, index or view
+func (db *sqliteDB) entry(name string) (schemaEntry, bool) {
+	for _, e := range db.schema {
+		if strings.EqualFold(e.name, name) {
+			return e, true
+		}
+	}
+	return schemaEntry{}, false
+}
+
+// column returns the position of a column in a table, or -1
+func (e schemaEntry) column(name string) int {
+	for i, c := range e.columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// page reads a page, numbered from 1
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if n == 0 {
+		return nil, errors.New("invalid page number 0")
+	}
+	buf := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(buf, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %w", n, err)
+	}
+	return buf, nil
+}
+
+// btreePage is a parsed b-tree page
+type btreePage struct {
+	data  []byte
+	kind  byte
+	cells []int // cell offsets
+	right uint32
+}
+
+// btree reads and parses a b-tree page
+func (db *sqliteDB) btree(n uint32) (*btreePage, error) {
+	data, err := db.page(n)
+	if err != nil {
+		return nil, err
+	}
+	h := 0
+	if n == 1 {
+		h = 100
+	}
+	p := &btreePage{data: data, kind: data[h]}
+	headerSize := 8
+	switch p.kind {
+	case pageInteriorIndex, pageInteriorTable:
+		headerSize = 12
+		p.right = binary.BigEndian.Uint32(data[h+8:])
+	case pageLeafIndex, pageLeafTable:
+	default:
+		return nil, fmt.Errorf("page %d is not a b-tree page (type 0x%02x)", n, p.kind)
+	}
+	count := int(binary.BigEndian.Uint16(data[h+3:]))
+	ptrs := h + headerSize
+	if ptrs+2*count > len(data) {
+		return nil, fmt.Errorf("page %d: too many cells", n)
+	}
+	p.cells = make([]int, count)
+	for i := range p.cells {
+		p.cells[i] = int(binary.BigEndian.Uint16(data[ptrs+2*i:]))
+	}
+	return p, nil
+}
+
+// payload is a cell's record, of which only the first part may be on the
+// page
+type payload struct {
+	local    []byte
+	size     int
+	overflow uint32
+}
+
+// localSize returns how much of a payload is stored on the b-tree page
+func (db *sqliteDB) localSize(size int, table bool) int {
+	u := db.usable
+	x := u - 35
+	if !table {
+		x = (u-12)*64/255 - 23
+	}
+	if size <= x {
+		return size
+	}
+	m := (u-12)*32/255 - 23
+	k := m + (size-m)%(u-4)
+	if k <= x {
+		return k
+	}
+	return m
+}
+
+// cellPayload reads the payload of a cell starting at off
+func (db *sqliteDB) cellPayload(data []byte, off, size int, table bool) (payload, error) {
+	if size < 0 {
+		return payload{}, fmt.Errorf("%w: invalid payload size %d", errCorrupt, size)
+	}
+	local := db.localSize(size, table)
+	if off+local > len(data) {
+		return payload{}, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+	}
+	p := payload{local: data[off : off+local], size: size}
+	if local < size {
+		if off+local+4 > len(data) {
+			return payload{}, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+		}
+		p.overflow = binary.BigEndian.Uint32(data[off+local:])
+	}
+	return p, nil
+}
+
+// full returns the whole payload, following overflow pages
+func (db *sqliteDB) full(p payload) ([]byte, error) {
+	if p.overflow == 0 {
+		return p.local, nil
+	}
+	// The size is only trusted as far as there are pages to back it, and
+	// a page can't appear twice in a chain
+	capacity := p.size
+	if capacity > 1<<20 {
+		capacity = 1 << 20
+	}
+	buf := make([]byte, 0, capacity)
+	buf = append(buf, p.local...)
+	next := p.overflow
+	seen := make(map[uint32]bool)
+	for len(buf) < p.size {
+		if next == 0 {
+			return nil, errors.New("overflow chain ends early")
+		}
+		if seen[next] {
+			return nil, fmt.Errorf("%w: overflow page %d is in a loop", errCorrupt, next)
+		}
+		seen[next] = true
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+		chunk := page[4:db.usable]
+		if rest := p.size - len(buf); len(chunk) > rest {
+			chunk = chunk[:rest]
+		}
+		buf = append(buf, chunk...)
+	}
+	return buf, nil
+}
+
+// record decodes the first n columns of a payload, or all of them if n is
+// negative, reading overflow pages only when needed
+func (db *sqliteDB) record(p payload, n int) ([]interface{}, error) {
+	rec, err := decodeRecord(p.local, n)
+	if err == errShortRecord && p.overflow != 0 {
+		data, err := db.full(p)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRecord(data, n)
+	}
+	return rec, err
+}
+
+// tableCell parses a table b-tree cell. Interior cells only have a child
+// page and a key.
+func (db *sqliteDB) tableCell(pg *btreePage, i int) (child uint32, rowid int64, p payload, err error) {
+	off := pg.cells[i]
+	if off >= len(pg.data) {
+		return 0, 0, payload{}, fmt.Errorf("%w: cell offset past the end of its page", errCorrupt)
+	}
+	if pg.kind == pageInteriorTable {
+		if off+4 > len(pg.data) {
+			return 0, 0, payload{}, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+		}
+		child = binary.BigEndian.Uint32(pg.data[off:])
+		key, _, err := cellVarint(pg.data, off+4)
+		return child, int64(key), payload{}, err
+	}
+	size, off, err := cellVarint(pg.data, off)
+	if err != nil {
+		return 0, 0, payload{}, err
+	}
+	key, off, err := cellVarint(pg.data, off)
+	if err != nil {
+		return 0, 0, payload{}, err
+	}
+	p, err = db.cellPayload(pg.data, off, int(size), true)
+	return 0, int64(key), p, err
+}
+
+// indexCell parses an index b-tree cell
+func (db *sqliteDB) indexCell(pg *btreePage, i int) (child uint32, p payload, err error) {
+	off := pg.cells[i]
+	if off >= len(pg.data) {
+		return 0, payload{}, fmt.Errorf("%w: cell offset past the end of its page", errCorrupt)
+	}
+	if pg.kind == pageInteriorIndex {
+		if off+4 > len(pg.data) {
+			return 0, payload{}, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+		}
+		child = binary.BigEndian.Uint32(pg.data[off:])
+		off += 4
+	}
+	size, off, err := cellVarint(pg.data, off)
+	if err != nil {
+		return 0, payload{}, err
+	}
+	p, err = db.cellPayload(pg.data, off, int(size), false)
+	return child, p, err
+}
+
+// cellVarint reads a varint of a cell at off, returning the offset after it
+func cellVarint(data []byte, off int) (uint64, int, error) {
+	if off >= len(data) {
+		return 0, 0, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+	}
+	v, n := readVarint(data[off:])
+	if n < 9 && data[off+n-1]&0x80 != 0 {
+		return 0, 0, fmt.Errorf("%w: cell extends past the end of its page", errCorrupt)
+	}
+	return v, off + n, nil
+}
+
+// scanTable calls fn for every row of a table, in rowid order
+func (db *sqliteDB) scanTable(root uint32, fn func(rowid int64, p payload) error) error {
+	return db.scanPage(root, fn, 0)
+}
+
+// maxDepth guards against cycles in corrupt files
+const maxDepth = 32
+
+func (db *sqliteDB) scanPage(n uint32, fn func(int64, payload) error, depth int) error {
+	if depth > maxDepth {
+		return errors.New("b-tree too deep")
+	}
+	pg, err := db.btree(n)
+	if err != nil {
+		return err
+	}
+	for i := range pg.cells {
+		child, rowid, p, err := db.tableCell(pg, i)
+		if err != nil {
+			return err
+		}
+		if pg.kind == pageInteriorTable {
+			if err := db.scanPage(child, fn, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(rowid, p); err != nil {
+			return err
+		}
+	}
+	if pg.kind == pageInteriorTable {
+		return db.scanPage(pg.right, fn, depth+1)
+	}
+	return nil
+}
+
+// row returns the row of a table with the given rowid
+func (db *sqliteDB) row(table schemaEntry, rowid int64) ([]interface{}, bool, error) {
+	n := table.root
+	for depth := 0; depth <= maxDepth; depth++ {
+		pg, err := db.btree(n)
+		if err != nil {
+			return nil, false, err
+		}
+		if pg.kind == pageLeafTable {
+			for i := range pg.cells {
+				_, key, p, err := db.tableCell(pg, i)
+				if err != nil {
+					return nil, false, err
+				}
+				if key == rowid {
+					rec, err := db.record(p, -1)
+					if err != nil {
+						return nil, false, err
+					}
+					if table.rowid >= 0 && table.rowid < len(rec) && rec[table.rowid] == nil {
+						rec[table.rowid] = rowid
+					}
+					return rec, true, nil
+				}
+			}
+			return nil, false, nil
+		}
+
+		next := pg.right
+		for i := range pg.cells {
+			child, key, _, err := db.tableCell(pg, i)
+			if err != nil {
+				return nil, false, err
+			}
+			if rowid <= key {
+				next = child
+				break
+			}
+		}
+		n = next
+	}
+	return nil, false, errors.New("b-tree too deep")
+}
+
+// seekIndex returns the rowid of the first index entry whose leading
+// columns equal key
+func (db *sqliteDB) seekIndex(root uint32, key []interface{}) (int64, bool, error) {
+	return db.seekIndexPage(root, key, 0)
+}
+
+func (db *sqliteDB) seekIndexPage(n uint32, key []interface{}, depth int) (int64, bool, error) {
+	if depth > maxDepth {
+		return 0, false, errors.New("b-tree too deep")
+	}
+	pg, err := db.btree(n)
+	if err != nil {
+		return 0, false, err
+	}
+	interior := pg.kind == pageInteriorIndex
+	for i := range pg.cells {
+		child, p, err := db.indexCell(pg, i)
+		if err != nil {
+			return 0, false, err
+		}
+		rec, err := db.record(p, -1)
+		if err != nil {
+			return 0, false, err
+		}
+		if len(rec) <= len(key) {
+			return 0, false, errors.New("index entry has too few columns")
+		}
+		cmp := compareKeys(rec[:len(key)], key)
+		if cmp < 0 {
+			continue
+		}
+		// Equal entries may also sit in the subtree to the left
+		if interior {
+			if rowid, ok, err := db.seekIndexPage(child, key, depth+1); err != nil || ok {
+				return rowid, ok, err
+			}
+		}
+		if cmp == 0 {
+			rowid, ok := rec[len(rec)-1].(int64)
+			return rowid, ok, nil
+		}
+		return 0, false, nil
+	}
+	if interior {
+		return db.seekIndexPage(pg.right, key, depth+1)
+	}
+	return 0, false, nil
+}
+
+// lookup returns the first row of a table whose columns equal key, using
+// an index on those columns if there is one. Without an index, the table
+// is scanned once and the keys are kept in memory.
+func (db *sqliteDB) lookup(table schemaEntry, columns []string, key []interface{}) ([]interface{}, bool, error) {
+	for _, e := range db.schema {
+		if e.kind != "index" || !strings.EqualFold(e.table, table.name) || len(e.columns) < len(columns) {
+			continue
+		}
+		usable := true
+		for i, c := range columns {
+			usable = usable && strings.EqualFold(e.columns[i], c)
+		}
+		if !usable {
+			continue
+		}
+		rowid, ok, err := db.seekIndex(e.root, key)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		return db.row(table, rowid)
+	}
+
+	scanKey := table.name + "\x00" + strings.Join(columns, "\x00")
+	rows, ok := db.scans[scanKey]
+	if !ok {
+		var err error
+		if rows, err = db.scanKeys(table, columns); err != nil {
+			return nil, false, err
+		}
+		db.scans[scanKey] = rows
+	}
+	rowid, ok := rows[keyString(key)]
+	if !ok {
+		return nil, false, nil
+	}
+	return db.row(table, rowid)
+}
+
+// scanKeys maps the key columns of every row of a table to its rowid
+func (db *sqliteDB) scanKeys(table schemaEntry, columns []string) (map[string]int64, error) {
+	positions := make([]int, len(columns))
+	need := 0
+	for i, c := range columns {
+		positions[i] = table.column(c)
+		if positions[i] < 0 {
+			return nil, fmt.Errorf("table %s has no column %s", table.name, c)
+		}
+		if positions[i]+1 > need {
+			need = positions[i] + 1
+		}
+	}
+
+	rows := make(map[string]int64)
+	key := make([]interface{}, len(columns))
+	err := db.scanTable(table.root, func(rowid int64, p payload) error {
+		rec, err := db.record(p, need)
+		if err != nil {
+			return err
+		}
+		for i, pos := range positions {
+			key[i] = nil
+			if pos < len(rec) {
+				key[i] = rec[pos]
+			}
+			if pos == table.rowid && key[i] == nil {
+				key[i] = rowid
+			}
+		}
+		k := keyString(key)
+		if _, dup := rows[k]; !dup {
+			rows[k] = rowid
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// keyString encodes a key for use as a map key
+func keyString(key []interface{}) string {
+	var b strings.Builder
+	for _, v := range key {
+		fmt.Fprintf(&b, "%T:%v\x00", v, v)
+	}
+	return b.String()
+}
+
+// readVarint reads an SQLite variable-length integer
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// decodeRecord decodes the first n values of a record, or all of them if
+// n is negative. Values are nil, int64, float64, string or []byte.
+func decodeRecord(data []byte, n int) ([]interface{}, error) {
+	headerSize, off := readVarint(data)
+	if int(headerSize) > len(data) {
+		return nil, errShortRecord
+	}
+	var types []uint64
+	for off < int(headerSize) && (n < 0 || len(types) < n) {
+		t, m := readVarint(data[off:])
+		types = append(types, t)
+		off += m
+	}
+
+	body := int(headerSize)
+	rec := make([]interface{}, len(types))
+	for i, t := range types {
+		size := serialSize(t)
+		if body+size > len(data) {
+			return nil, errShortRecord
+		}
+		v := data[body : body+size]
+		switch {
+		case t == 0:
+			rec[i] = nil
+		case t <= 6:
+			rec[i] = readInt(v)
+		case t == 7:
+			rec[i] = math.Float64frombits(binary.BigEndian.Uint64(v))
+		case t == 8:
+			rec[i] = int64(0)
+		case t == 9:
+			rec[i] = int64(1)
+		case t >= 12 && t%2 == 0:
+			rec[i] = v
+		case t >= 13:
+			rec[i] = string(v)
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", t)
+		}
+		body += size
+	}
+	return rec, nil
+}
+
+// serialSize returns the size of a value of a serial type
+func serialSize(t uint64) int {
+	switch {
+	case t <= 4:
+		return [...]int{0, 1, 2, 3, 4}[t]
+	case t == 5:
+		return 6
+	case t == 6, t == 7:
+		return 8
+	case t < 12:
+		return 0
+	default:
+		return int(t-12) / 2
+	}
+}
+
+// readInt reads a big-endian two's complement integer
+func readInt(b []byte) int64 {
+	var v int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// compareKeys compares values in SQLite order: NULL, numbers, text, blobs
+func compareKeys(a, b []interface{}) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b interface{}) int {
+	ca, cb := valueClass(a), valueClass(b)
+	if ca != cb {
+		if ca < cb {
+			return -1
+		}
+		return 1
+	}
+	switch ca {
+	case 1:
+		ia, aInt := a.(int64)
+		ib, bInt := b.(int64)
+		if aInt && bInt {
+			switch {
+			case ia < ib:
+				return -1
+			case ia > ib:
+				return 1
+			}
+			return 0
+		}
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+	case 2:
+		return strings.Compare(a.(string), b.(string))
+	case 3:
+		return bytes.Compare(a.([]byte), b.([]byte))
+	}
+	return 0
+}
+
+func valueClass(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// parseTableColumns returns the column names of a CREATE TABLE statement
+// and the position of an INTEGER PRIMARY KEY column, or -1
+func parseTableColumns(sql string) ([]string, int) {
+	var columns []string
+	rowid := -1
+	for _, def := range splitDefinitions(sql) {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "constraint", "primary", "unique", "check", "foreign":
+			continue
+		}
+		lower := strings.ToLower(def)
+		if len(fields) > 1 && strings.ToLower(fields[1]) == "integer" && strings.Contains(lower, "primary key") {
+			rowid = len(columns)
+		}
+		columns = append(columns, unquoteName(fields[0]))
+	}
+	return columns, rowid
+}
+
+// parseIndexColumns returns the column names of a CREATE INDEX statement
+func parseIndexColumns(sql string) []string {
+	var columns []string
+	for _, def := range splitDefinitions(sql) {
+		if fields := strings.Fields(def); len(fields) > 0 {
+			columns = append(columns, unquoteName(fields[0]))
+		}
+	}
+	return columns
+}
+
+// splitDefinitions splits the outermost parenthesised list of a statement
+// at its top-level commas
+func splitDefinitions(sql string) []string {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var defs []string
+	depth, from := 0, start+1
+	var quote byte
+	for i := start + 1; i < end; i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(sql[from:i]))
+			from = i + 1
+		}
+	}
+	return append(defs, strings.TrimSpace(sql[from:end]))
+}
+
+// unquoteName strips SQL identifier quotes
+func unquoteName(name string) string {
+	if len(name) >= 2 {
+		switch {
+		case name[0] == '"' && name[len(name)-1] == '"',
+			name[0] == '`' && name[len(name)-1] == '`',
+			name[0] == '[' && name[len(name)-1] == ']':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
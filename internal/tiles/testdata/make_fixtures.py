@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""Builds the MBTiles fixtures for the tiles package tests.
+
+Every tile is a solid PNG whose color encodes its XYZ address:
+red = zoom * 40, green = x * 10, blue = y * 10. Tile 2/1/1 is noise,
+large enough to spill onto overflow pages. Small pages give the tables
+and indexes several levels.
+"""
+import os
+import random
+import sqlite3
+import struct
+import zlib
+
+
+def png(width, height, pixel):
+    rows = b"".join(b"\x00" + b"".join(bytes(pixel(x, y)) for x in range(width)) for y in range(height))
+
+    def chunk(kind, data):
+        return struct.pack(">I", len(data)) + kind + data + struct.pack(">I", zlib.crc32(kind + data) & 0xFFFFFFFF)
+
+    header = struct.pack(">IIBBBBB", width, height, 8, 2, 0, 0, 0)
+    return b"\x89PNG\r\n\x1a\n" + chunk(b"IHDR", header) + chunk(b"IDAT", zlib.compress(rows)) + chunk(b"IEND", b"")
+
+
+def tiles():
+    rng = random.Random(1)
+    for z in range(4):
+        for x in range(1 << z):
+            for y in range(1 << z):
+                if (z, x, y) == (2, 1, 1):
+                    data = png(64, 64, lambda px, py: (rng.randrange(256), rng.randrange(256), rng.randrange(256)))
+                else:
+                    data = png(16, 16, lambda px, py, c=(z * 40, x * 10, y * 10): c)
+                yield z, x, (1 << z) - 1 - y, data
+
+
+def create(path, dedup, index):
+    if os.path.exists(path):
+        os.remove(path)
+    db = sqlite3.connect(path)
+    db.execute("PRAGMA page_size = 1024")
+    db.execute("CREATE TABLE metadata (name text, value text)")
+    db.executemany("INSERT INTO metadata VALUES (?, ?)", [
+        ("name", "fixture"), ("format", "png"), ("minzoom", "0"), ("maxzoom", "3"),
+    ])
+    if dedup:
+        db.execute("CREATE TABLE map (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_id TEXT)")
+        db.execute("CREATE TABLE images (tile_data blob, tile_id text)")
+        db.execute("CREATE UNIQUE INDEX map_index ON map (zoom_level, tile_column, tile_row)")
+        db.execute("CREATE UNIQUE INDEX images_id ON images (tile_id)")
+        db.execute("""CREATE VIEW tiles AS SELECT map.zoom_level AS zoom_level, map.tile_column AS tile_column,
+            map.tile_row AS tile_row, images.tile_data AS tile_data FROM map JOIN images ON images.tile_id = map.tile_id""")
+        for z, x, row, data in tiles():
+            tile_id = "%d-%d-%d" % (z, x, row)
+            db.execute("INSERT INTO map VALUES (?, ?, ?, ?)", (z, x, row, tile_id))
+            db.execute("INSERT INTO images VALUES (?, ?)", (data, tile_id))
+    else:
+        db.execute("CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)")
+        if index:
+            db.execute("CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)")
+        # Insert in reverse so rowid order differs from tile order
+        db.executemany("INSERT INTO tiles VALUES (?, ?, ?, ?)", reversed(list(tiles())))
+    db.commit()
+    db.close()
+
+
+if __name__ == "__main__":
+    here = os.path.dirname(os.path.abspath(__file__))
+    create(os.path.join(here, "plain.mbtiles"), dedup=False, index=True)
+    create(os.path.join(here, "noindex.mbtiles"), dedup=False, index=False)
+    create(os.path.join(here, "dedup.mbtiles"), dedup=True, index=True)
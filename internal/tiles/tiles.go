@@ -0,0 +1,64 @@
+// Package tiles reads raster map tiles from local files, either an MBTiles
+// database or a z/x/y directory of images, so maps can be drawn without a
+// network connection.
+package tiles
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+)
+
+// TileSize is the width and height of a map tile in pixels
+const TileSize = 256
+
+// MaxLatitude is the latitude limit of the Web Mercator projection
+const MaxLatitude = 85.05112878
+
+// ErrTileNotFound is returned for tiles that are not in a source
+var ErrTileNotFound = errors.New("tile not found")
+
+// Source provides map tiles in the XYZ scheme used by OpenStreetMap:
+// tile (0, 0) is the north-west corner of the world at every zoom level
+type Source interface {
+	Tile(z, x, y int) (image.Image, error)
+	Zooms() (min, max int)
+	Close() error
+}
+
+// Open opens an MBTiles file, or a z/x/y tile directory if path is a
+// directory
+func Open(path string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tiles: %w", err)
+	}
+	if info.IsDir() {
+		return OpenDir(path)
+	}
+	return OpenMBTiles(path)
+}
+
+// Project converts a position in degrees to world coordinates, where the
+// world spans 0-1 from west to east and from north to south
+func Project(lat, lon float64) (x, y float64) {
+	lat = math.Max(-MaxLatitude, math.Min(MaxLatitude, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x = lon/360 + 0.5
+	y = 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+	return x, y
+}
+
+// Unproject converts world coordinates back to a position in degrees
+func Unproject(x, y float64) (lat, lon float64) {
+	lon = (x - 0.5) * 360
+	lat = 90 - 360*math.Atan(math.Exp((y-0.5)*2*math.Pi))/math.Pi
+	return lat, lon
+}
+
+// WorldSize returns the width of the world in pixels at a zoom level
+func WorldSize(z int) float64 {
+	return float64(TileSize) * math.Exp2(float64(z))
+}
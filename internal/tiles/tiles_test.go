@@ -0,0 +1,223 @@
+package tiles
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// tileColor is the color of a fixture tile, see testdata/make_fixtures.py
+func tileColor(z, x, y int) color.RGBA {
+	return color.RGBA{uint8(z * 40), uint8(x * 10), uint8(y * 10), 255}
+}
+
+// checkSource reads every fixture tile from a source
+func checkSource(t *testing.T, source Source) {
+	t.Helper()
+	for z := 0; z <= 3; z++ {
+		for x := 0; x < 1<<uint(z); x++ {
+			for y := 0; y < 1<<uint(z); y++ {
+				img, err := source.Tile(z, x, y)
+				if err != nil {
+					t.Fatalf("Failed to read tile %d/%d/%d: %v", z, x, y, err)
+				}
+				if z == 2 && x == 1 && y == 1 {
+					if img.Bounds().Dx() != 64 {
+						t.Errorf("Expected 64px noise tile, got %v", img.Bounds())
+					}
+					continue
+				}
+				if c := color.RGBAModel.Convert(img.At(0, 0)); c != tileColor(z, x, y) {
+					t.Errorf("Tile %d/%d/%d: got %v, want %v", z, x, y, c, tileColor(z, x, y))
+				}
+			}
+		}
+	}
+
+	for _, tile := range [][3]int{{4, 0, 0}, {1, 2, 0}, {0, -1, 0}} {
+		if _, err := source.Tile(tile[0], tile[1], tile[2]); !errors.Is(err, ErrTileNotFound) {
+			t.Errorf("Tile %v: expected ErrTileNotFound, got %v", tile, err)
+		}
+	}
+	if min, max := source.Zooms(); min != 0 || max != 3 {
+		t.Errorf("Expected zooms 0-3, got %d-%d", min, max)
+	}
+}
+
+func TestMBTiles(t *testing.T) {
+	for _, name := range []string{"plain", "noindex", "dedup"} {
+		t.Run(name, func(t *testing.T) {
+			m, err := OpenMBTiles(filepath.Join("testdata", name+".mbtiles"))
+			if err != nil {
+				t.Fatalf("Failed to open: %v", err)
+			}
+			defer m.Close()
+			if m.Metadata("name") != "fixture" || m.Metadata("format") != "png" {
+				t.Errorf("Unexpected metadata %v", m.metadata)
+			}
+			checkSource(t, m)
+		})
+	}
+}
+
+func TestMBTilesErrors(t *testing.T) {
+	if _, err := OpenMBTiles(filepath.Join("testdata", "make_fixtures.py")); err == nil {
+		t.Error("Expected error for a file that is not a database")
+	}
+	if _, err := OpenMBTiles(filepath.Join("testdata", "missing.mbtiles")); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	for z := 0; z <= 3; z++ {
+		for x := 0; x < 1<<uint(z); x++ {
+			for y := 0; y < 1<<uint(z); y++ {
+				w := 16
+				if z == 2 && x == 1 && y == 1 {
+					w = 64
+				}
+				writeTile(t, filepath.Join(dir, strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(y)+".png"), w, tileColor(z, x, y))
+			}
+		}
+	}
+
+	source, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open directory: %v", err)
+	}
+	defer source.Close()
+	if _, ok := source.(*DirSource); !ok {
+		t.Errorf("Expected a DirSource, got %T", source)
+	}
+	checkSource(t, source)
+
+	if _, err := OpenDir(t.TempDir()); err == nil {
+		t.Error("Expected error for a directory without zoom levels")
+	}
+}
+
+func TestCache(t *testing.T) {
+	m, err := OpenMBTiles(filepath.Join("testdata", "plain.mbtiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &countingSource{Source: m}
+	cache := NewCache(source, 2)
+	defer cache.Close()
+
+	for _, tile := range [][3]int{{1, 0, 0}, {1, 0, 0}, {1, 1, 0}, {5, 0, 0}, {5, 0, 0}, {1, 0, 0}} {
+		cache.Tile(tile[0], tile[1], tile[2])
+	}
+	// 1/0/0 is evicted by the missing tile before it is read again
+	if source.reads != 4 || cache.Len() != 2 {
+		t.Errorf("Expected 4 reads and 2 cached tiles, got %d, %d", source.reads, cache.Len())
+	}
+	if _, err := cache.Tile(5, 0, 0); !errors.Is(err, ErrTileNotFound) {
+		t.Errorf("Expected cached ErrTileNotFound, got %v", err)
+	}
+}
+
+// countingSource counts tile reads
+type countingSource struct {
+	Source
+	reads int
+}
+
+func (c *countingSource) Tile(z, x, y int) (image.Image, error) {
+	c.reads++
+	return c.Source.Tile(z, x, y)
+}
+
+func TestProject(t *testing.T) {
+	x, y := Project(0, 0)
+	if x != 0.5 || math.Abs(y-0.5) > 1e-12 {
+		t.Errorf("Expected (0.5, 0.5), got (%v, %v)", x, y)
+	}
+	// London is in tile 8/127/85
+	x, y = Project(51.5074, -0.1278)
+	if tx, ty := int(x*256), int(y*256); tx != 127 || ty != 85 {
+		t.Errorf("Expected tile 127/85, got %d/%d", tx, ty)
+	}
+	lat, lon := Unproject(x, y)
+	if math.Abs(lat-51.5074) > 1e-9 || math.Abs(lon+0.1278) > 1e-9 {
+		t.Errorf("Round trip gave %v, %v", lat, lon)
+	}
+	if _, y := Project(89, 0); y < -1e-9 {
+		t.Errorf("Expected latitude to be clamped, got y %v", y)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	columns, rowid := parseTableColumns(`CREATE TABLE "t" (id INTEGER PRIMARY KEY, [name] text NOT NULL, "value" blob DEFAULT (x'00'), UNIQUE (name, value))`)
+	if len(columns) != 3 || columns[0] != "id" || columns[1] != "name" || columns[2] != "value" || rowid != 0 {
+		t.Errorf("Unexpected columns %v, rowid %d", columns, rowid)
+	}
+	if columns := parseIndexColumns("CREATE UNIQUE INDEX i ON t (zoom_level ASC, `tile_column`)"); len(columns) != 2 || columns[1] != "tile_column" {
+		t.Errorf("Unexpected index columns %v", columns)
+	}
+}
+
+func writeTile(t *testing.T, path string, w int, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, w))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMBTilesCorruptPages(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "plain.mbtiles"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	pageSize := int(data[16])<<8 | int(data[17])
+
+	// Overwrite each byte of every page header and the first cell pointers
+	// with values that point outside the page. Reading must fail cleanly.
+	for start := 0; start < len(data); start += pageSize {
+		h := start
+		if start == 0 {
+			h = 100
+		}
+		for i := 0; i < 16; i++ {
+			for _, v := range []byte{0x00, 0x05, 0x0d, 0x7f, 0xff} {
+				corrupt := append([]byte(nil), data...)
+				corrupt[h+i] = v
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("Page at %d, header byte %d = 0x%02x: panic: %v", start, i, v, r)
+						}
+					}()
+					m, err := readMBTiles(bytes.NewReader(corrupt))
+					if err != nil {
+						return
+					}
+					for z := 0; z <= 3; z++ {
+						m.TileData(z, 0, 0)
+					}
+				}()
+			}
+		}
+	}
+}
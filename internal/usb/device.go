@@ -1,20 +1,17 @@
 package usb
 
 import (
+	"errors"
 	"fmt"
-	"image"
-	"image/color"
-	"image/png"
 	"log"
-	"os"
-	"time"
 
-	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/karalabe/hid"
-	"golang.org/x/image/colornames"
 )
 
+// ErrDeviceNotFound is returned when no device with the vendor and product
+// ID is plugged in
+var ErrDeviceNotFound = errors.New("device not found")
+
 // USBDevice represents a generic USB device interface
 type USBDevice interface {
 	SendControlMessage(requestType, request, value, index uint16, data []byte) error
@@ -28,6 +25,8 @@ type Device struct {
 	VendorID  uint16
 	ProductID uint16
 	Name      string
+	Serial    string
+	Path      string
 	handle    *hid.Device
 }
 
@@ -58,103 +57,6 @@ type Panel interface {
 	GetName() string
 }
 
-// FIPDisplay represents a Flight Instrument Panel display
-type FIPDisplay struct {
-	Width  int
-	Height int
-	Window *pixelgl.Window
-	Canvas *pixelgl.Canvas
-}
-
-// NewFIPDisplay creates a new FIP display window
-func NewFIPDisplay(title string, width, height int) (*FIPDisplay, error) {
-	cfg := pixelgl.WindowConfig{
-		Title:     title,
-		Bounds:    pixel.R(0, 0, float64(width), float64(height)),
-		Resizable: false,
-		VSync:     true,
-	}
-
-	win, err := pixelgl.NewWindow(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create FIP window: %w", err)
-	}
-
-	canvas := pixelgl.NewCanvas(pixel.R(0, 0, float64(width), float64(height)))
-
-	return &FIPDisplay{
-		Width:  width,
-		Height: height,
-		Window: win,
-		Canvas: canvas,
-	}, nil
-}
-
-// DisplayImage displays an image on the FIP
-func (f *FIPDisplay) DisplayImage(img image.Image) error {
-	// Convert image to pixel format
-	pic := pixel.PictureDataFromImage(img)
-	sprite := pixel.NewSprite(pic, pic.Bounds())
-
-	// Clear canvas and draw sprite
-	f.Canvas.Clear(colornames.Black)
-	sprite.Draw(f.Canvas, pixel.IM.Moved(f.Canvas.Bounds().Center()))
-
-	// Update window
-	f.Canvas.Draw(f.Window, pixel.IM)
-	f.Window.Update()
-
-	return nil
-}
-
-// DisplayImageFromFile loads and displays an image from file
-func (f *FIPDisplay) DisplayImageFromFile(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open image file: %w", err)
-	}
-	defer file.Close()
-
-	img, err := png.Decode(file)
-	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	return f.DisplayImage(img)
-}
-
-// CreateTestImage creates a test image for the FIP
-func (f *FIPDisplay) CreateTestImage() image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
-
-	// Create a test pattern
-	for y := 0; y < f.Height; y++ {
-		for x := 0; x < f.Width; x++ {
-			r := uint8((x * 255) / f.Width)
-			g := uint8((y * 255) / f.Height)
-			b := uint8(128)
-			img.Set(x, y, color.RGBA{r, g, b, 255})
-		}
-	}
-
-	return img
-}
-
-// Close closes the FIP display
-func (f *FIPDisplay) Close() {
-	if f.Window != nil {
-		f.Window.Destroy()
-	}
-}
-
-// Run starts the FIP display loop
-func (f *FIPDisplay) Run() {
-	for !f.Window.Closed() {
-		f.Window.Update()
-		time.Sleep(time.Millisecond * 16) // ~60 FPS
-	}
-}
-
 // FindDevices finds all connected Saitek devices
 func FindDevices() ([]DeviceInfo, error) {
 	var devices []DeviceInfo
@@ -173,7 +75,7 @@ func FindDevices() ([]DeviceInfo, error) {
 func OpenDevice(vendorID, productID uint16) (*Device, error) {
 	devs := hid.Enumerate(vendorID, productID)
 	if len(devs) == 0 {
-		return nil, fmt.Errorf("device not found: vendor=0x%04x product=0x%04x", vendorID, productID)
+		return nil, fmt.Errorf("%w: vendor=0x%04x product=0x%04x", ErrDeviceNotFound, vendorID, productID)
 	}
 
 	// Debug: print device info
@@ -213,6 +115,8 @@ func OpenDevice(vendorID, productID uint16) (*Device, error) {
 				VendorID:  vendorID,
 				ProductID: productID,
 				Name:      dev.Product,
+				Serial:    dev.Serial,
+				Path:      dev.Path,
 				handle:    handle,
 			}, nil
 		}
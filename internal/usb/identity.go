@@ -0,0 +1,60 @@
+package usb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+// Identity names one physical device. The serial number follows a device
+// from port to port; devices without one are told apart by their path, so
+// they keep their identity as long as they stay on the same port.
+type Identity struct {
+	VendorID  uint16
+	ProductID uint16
+	Serial    string
+	Path      string
+}
+
+// Identified is implemented by devices that know their Identity
+type Identified interface {
+	Identity() Identity
+}
+
+// String returns the serial number or path the identity is based on
+func (id Identity) String() string {
+	switch {
+	case id.Serial != "":
+		return fmt.Sprintf("%04x:%04x serial %s", id.VendorID, id.ProductID, id.Serial)
+	case id.Path != "":
+		return fmt.Sprintf("%04x:%04x at %s", id.VendorID, id.ProductID, id.Path)
+	}
+	return fmt.Sprintf("%04x:%04x", id.VendorID, id.ProductID)
+}
+
+// Identity returns the device's identity
+func (d *Device) Identity() Identity {
+	return Identity{VendorID: d.VendorID, ProductID: d.ProductID, Serial: d.Serial, Path: d.Path}
+}
+
+// Identity returns the device's identity
+func (d *GoUSBDevice) Identity() Identity {
+	return Identity{VendorID: d.VendorID, ProductID: d.ProductID, Serial: d.Serial, Path: d.Path}
+}
+
+// Identity returns the device's identity
+func (d *USBCoreDevice) Identity() Identity {
+	return Identity{VendorID: d.VendorID, ProductID: d.ProductID, Serial: d.Serial, Path: d.Path}
+}
+
+// gousbIdentity reads the serial number and port path of an open device
+func gousbIdentity(dev *gousb.Device) (serial, path string) {
+	serial, _ = dev.SerialNumber()
+	ports := make([]string, len(dev.Desc.Path))
+	for i, port := range dev.Desc.Path {
+		ports[i] = fmt.Sprint(port)
+	}
+	path = fmt.Sprintf("usb:%d-%s", dev.Desc.Bus, strings.Join(ports, "."))
+	return strings.TrimSpace(serial), path
+}
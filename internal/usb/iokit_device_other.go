@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package usb
+
+import "fmt"
+
+// OpenIOKitDevice is only available on macOS
+func OpenIOKitDevice(vendorID, productID uint16) (*Device, error) {
+	return nil, fmt.Errorf("IOKit is not available on this platform")
+}
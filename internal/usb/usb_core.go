@@ -12,6 +12,8 @@ type USBCoreDevice struct {
 	VendorID  uint16
 	ProductID uint16
 	Name      string
+	Serial    string
+	Path      string
 	device    *gousb.Device
 	ctx       *gousb.Context
 }
@@ -29,7 +31,7 @@ func NewUSBCoreDevice(vendorID, productID uint16) (*USBCoreDevice, error) {
 
 	if dev == nil {
 		ctx.Close()
-		return nil, fmt.Errorf("device not found: vendor=0x%04x product=0x%04x", vendorID, productID)
+		return nil, fmt.Errorf("%w: vendor=0x%04x product=0x%04x", ErrDeviceNotFound, vendorID, productID)
 	}
 
 	// Set auto detach to prevent kernel driver issues
@@ -37,10 +39,13 @@ func NewUSBCoreDevice(vendorID, productID uint16) (*USBCoreDevice, error) {
 		log.Printf("Warning: failed to set auto detach: %v", err)
 	}
 
+	serial, path := gousbIdentity(dev)
 	return &USBCoreDevice{
 		VendorID:  vendorID,
 		ProductID: productID,
 		Name:      "Saitek Radio Panel (USB Core)",
+		Serial:    serial,
+		Path:      path,
 		device:    dev,
 		ctx:       ctx,
 	}, nil